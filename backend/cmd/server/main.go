@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // user timezones; the runtime image ships no zoneinfo
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/api"
@@ -17,8 +20,10 @@ import (
 	"github.com/nohe-sohbi/mailsorter/backend/internal/config"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/crypto"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/database"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/embed"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -55,6 +60,9 @@ func main() {
 
 	// Initialize Gmail service (may be empty if not configured)
 	gmailService := gmail.NewService("", "", "")
+	if err := gmailService.SetUnsubscribeProxy(cfg.UnsubscribeProxyURL); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Try to load existing config from database
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -99,6 +107,29 @@ func main() {
 		log.Println("Warning: MISTRAL_API_KEY not set - AI features disabled")
 	}
 
+	// Initialize the embeddings provider behind inbox clustering: Mistral's
+	// hosted API, or any OpenAI-compatible endpoint (e.g. a local model).
+	var embedder embed.Provider
+	switch cfg.EmbeddingsProvider {
+	case "mistral":
+		key := cfg.EmbeddingsAPIKey
+		if key == "" {
+			key = cfg.MistralAPIKey
+		}
+		if key != "" {
+			embedder = embed.NewMistral(key, cfg.EmbeddingsModel)
+		}
+	case "http":
+		if cfg.EmbeddingsURL != "" {
+			embedder = embed.NewHTTP(cfg.EmbeddingsURL, cfg.EmbeddingsAPIKey, cfg.EmbeddingsModel)
+		}
+	}
+	if embedder != nil {
+		log.Printf("Embeddings provider initialized (%s, %s)", cfg.EmbeddingsProvider, embedder.Model())
+	} else {
+		log.Println("Warning: no embeddings provider - inbox clustering disabled")
+	}
+
 	// Initialize Stripe billing (optional)
 	billingCfg := api.BillingConfig{
 		PriceID:       cfg.StripePriceID,
@@ -112,17 +143,42 @@ func main() {
 		log.Println("Warning: STRIPE_SECRET_KEY not set - billing disabled")
 	}
 
+	// SMTP relay behind the "smtp" notification channel (optional).
+	if cfg.SMTPHost != "" {
+		from := cfg.SMTPFrom
+		if from == "" {
+			from = cfg.SMTPUsername
+		}
+		api.SMTPRelay = &notify.SMTP{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     from,
+		}
+		log.Printf("SMTP relay configured (%s)", api.SMTPRelay.Addr)
+	} else {
+		log.Println("Warning: SMTP_HOST not set - SMTP notifications disabled")
+	}
+
 	// Session/CSRF token manager, keyed off the server secret.
 	authManager := auth.NewManager(cfg.EncryptionKey)
 
-	// Surface the running build, default digest hour and CORS allow-list to the
-	// API layer.
+	// Surface the running build, default digest hour, CORS allow-list, analysis
+	// cache TTLs, suggestion lifetime, admin list and AI token budget to the API
+	// layer.
 	api.Version = cfg.BuildVersion
 	api.DefaultDigestHourUTC = cfg.DigestHourUTC
 	api.AllowedOrigins = cfg.AllowedOrigins
+	api.UserCacheTTL = time.Duration(cfg.UserCacheTTLHours) * time.Hour
+	api.GlobalCacheTTL = time.Duration(cfg.GlobalCacheTTLHours) * time.Hour
+	api.SuggestionMaxAge = time.Duration(cfg.SuggestionMaxAgeDays) * 24 * time.Hour
+	api.UnsubscribeGrace = time.Duration(cfg.UnsubscribeGraceDays) * 24 * time.Hour
+	api.AdminEmails = cfg.AdminEmails
+	api.MonthlyTokenBudget = int64(cfg.MonthlyTokenBudget)
 
 	// Initialize API handler
 	handler := api.NewHandler(db, gmailService, encryptor, aiClient, billingCfg, authManager)
+	handler.SetEmbedder(embedder)
 
 	// Setup routes
 	router := handler.SetupRoutes()
@@ -138,6 +194,9 @@ func main() {
 		WriteTimeout:      150 * time.Second, // long enough for synchronous AI analysis
 		IdleTimeout:       120 * time.Second,
 	}
+	// Event streams never finish on their own: end them first so Shutdown
+	// only waits for ordinary requests.
+	srv.RegisterOnShutdown(handler.CloseEvents)
 
 	// Run the server in the background so we can listen for shutdown signals.
 	go func() {
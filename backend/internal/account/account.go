@@ -19,16 +19,26 @@ import (
 type Dataset string
 
 const (
-	DatasetRules            Dataset = "rules"
-	DatasetProtectedSenders Dataset = "protectedSenders"
-	DatasetSnoozes          Dataset = "snoozes"
-	DatasetSuggestions      Dataset = "suggestions"
-	DatasetSenderPrefs      Dataset = "senderPreferences"
-	DatasetSmartLabels      Dataset = "smartLabels"
-	DatasetUnsubscribes     Dataset = "unsubscribes"
-	DatasetUsage            Dataset = "usage"
-	DatasetActionLog        Dataset = "actionLog"
-	DatasetJobs             Dataset = "analysisJobs"
+	DatasetRules             Dataset = "rules"
+	DatasetProtectedSenders  Dataset = "protectedSenders"
+	DatasetSnoozes           Dataset = "snoozes"
+	DatasetSuggestions       Dataset = "suggestions"
+	DatasetSenderPrefs       Dataset = "senderPreferences"
+	DatasetSenders           Dataset = "senders"
+	DatasetSmartLabels       Dataset = "smartLabels"
+	DatasetUnsubscribes      Dataset = "unsubscribes"
+	DatasetUnsubViolations   Dataset = "unsubscribeViolations"
+	DatasetFollowUps         Dataset = "followUps"
+	DatasetDeliveries        Dataset = "deliveries"
+	DatasetWebhookDeliveries Dataset = "webhookDeliveries"
+	DatasetAPITokens         Dataset = "apiTokens"
+	DatasetUsage             Dataset = "usage"
+	DatasetActionLog         Dataset = "actionLog"
+	DatasetJobs              Dataset = "analysisJobs"
+	DatasetAnalysisCache     Dataset = "analysisCache"
+	DatasetSummaries         Dataset = "summaries"
+	DatasetEmbeddings        Dataset = "embeddings"
+	DatasetLabelProposals    Dataset = "labelProposals"
 )
 
 // Datasets returns the canonical, stable list of user-owned data categories. The
@@ -41,11 +51,21 @@ func Datasets() []Dataset {
 		DatasetSnoozes,
 		DatasetSuggestions,
 		DatasetSenderPrefs,
+		DatasetSenders,
 		DatasetSmartLabels,
 		DatasetUnsubscribes,
+		DatasetUnsubViolations,
+		DatasetFollowUps,
+		DatasetDeliveries,
+		DatasetWebhookDeliveries,
+		DatasetAPITokens,
 		DatasetUsage,
 		DatasetActionLog,
 		DatasetJobs,
+		DatasetAnalysisCache,
+		DatasetSummaries,
+		DatasetEmbeddings,
+		DatasetLabelProposals,
 	}
 }
 
@@ -53,14 +73,26 @@ func Datasets() []Dataset {
 // export. It deliberately omits OAuth tokens and Stripe identifiers — secrets a
 // user's own data export must never leak, even to the user.
 type Profile struct {
-	Email           string    `json:"email"`
-	Plan            string    `json:"plan"`
-	AutoApplyRules  bool      `json:"autoApplyRules"`
-	AutoSyncEnabled bool      `json:"autoSyncEnabled"`
-	DigestEnabled   bool      `json:"digestEnabled"`
-	DigestHourUTC   int       `json:"digestHourUTC"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	Email                  string                     `json:"email"`
+	Plan                   string                     `json:"plan"`
+	AutoApplyRules         bool                       `json:"autoApplyRules"`
+	AutoSyncEnabled        bool                       `json:"autoSyncEnabled"`
+	DigestEnabled          bool                       `json:"digestEnabled"`
+	DigestHourUTC          int                        `json:"digestHourUTC"`
+	DigestHour             *int                       `json:"digestHour,omitempty"`
+	Digest                 models.DigestPrefs         `json:"digest"`
+	Timezone               string                     `json:"timezone,omitempty"`
+	WorkHours              models.WorkHours           `json:"workHours"`
+	Locale                 string                     `json:"locale,omitempty"`
+	ReplyTone              string                     `json:"replyTone,omitempty"`
+	Signature              string                     `json:"signature,omitempty"`
+	Autopilot              models.AutopilotThresholds `json:"autopilot"`
+	UnsubscribeEnforcement string                     `json:"unsubscribeEnforcement,omitempty"`
+	FollowUps              models.FollowUpSettings    `json:"followUps"`
+	NotifyChannels         []string                   `json:"notifyChannels,omitempty"`
+	Webhooks               []WebhookProfile           `json:"webhooks,omitempty"`
+	CreatedAt              time.Time                  `json:"createdAt"`
+	UpdatedAt              time.Time                  `json:"updatedAt"`
 }
 
 // RedactUser projects a stored User onto the safe Profile, dropping the OAuth
@@ -71,13 +103,53 @@ func RedactUser(u models.User) Profile {
 		plan = "free"
 	}
 	return Profile{
-		Email:           u.Email,
-		Plan:            plan,
-		AutoApplyRules:  u.AutoApplyRules,
-		AutoSyncEnabled: u.AutoSyncEnabled,
-		DigestEnabled:   u.DigestEnabled,
-		DigestHourUTC:   u.DigestHourUTC,
-		CreatedAt:       u.CreatedAt,
-		UpdatedAt:       u.UpdatedAt,
+		Email:                  u.Email,
+		Plan:                   plan,
+		AutoApplyRules:         u.AutoApplyRules,
+		AutoSyncEnabled:        u.AutoSyncEnabled,
+		DigestEnabled:          u.DigestEnabled,
+		DigestHourUTC:          u.DigestHourUTC,
+		DigestHour:             u.DigestHour,
+		Digest:                 u.Digest,
+		Timezone:               u.Timezone,
+		WorkHours:              u.WorkHours,
+		Locale:                 u.Locale,
+		ReplyTone:              u.ReplyTone,
+		Signature:              u.Signature,
+		Autopilot:              u.Autopilot,
+		UnsubscribeEnforcement: u.UnsubscribeEnforcement,
+		FollowUps:              u.FollowUps,
+		NotifyChannels:         channelTypes(u.NotifyChannels),
+		Webhooks:               webhookProfiles(u.Webhooks),
+		CreatedAt:              u.CreatedAt,
+		UpdatedAt:              u.UpdatedAt,
 	}
 }
+
+// channelTypes lists the user's notification channels by type only: webhook
+// URLs and signing secrets are credentials.
+func channelTypes(channels []models.NotifyChannel) []string {
+	var out []string
+	for _, c := range channels {
+		out = append(out, c.Type)
+	}
+	return out
+}
+
+// WebhookProfile is a webhook in the export: what it listens to, not where it
+// posts nor how it signs.
+type WebhookProfile struct {
+	ID          string    `json:"id"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func webhookProfiles(hooks []models.Webhook) []WebhookProfile {
+	var out []WebhookProfile
+	for _, w := range hooks {
+		out = append(out, WebhookProfile{ID: w.ID, Events: w.Events, Description: w.Description, Enabled: w.Enabled, CreatedAt: w.CreatedAt})
+	}
+	return out
+}
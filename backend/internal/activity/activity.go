@@ -64,12 +64,26 @@ func canonicalAction(a string) string {
 	}
 }
 
-// Summarize buckets ledger rows over the 7 calendar days ending on now (UTC).
-// The Days slice is always exactly 7 entries, oldest first, with zero-filled
-// gaps. ByAction is seeded with the headline triage actions so the UI can rely
-// on their presence. Rows outside the window are ignored.
+// Summarize buckets ledger rows over the 7 calendar days ending on now, in
+// now's location — pass now in the user's timezone so an action at 22:00 in
+// Montréal counts on that day, not the next. The Days slice is always exactly
+// 7 entries, oldest first, with zero-filled gaps. ByAction is seeded with the
+// headline triage actions so the UI can rely on their presence. Rows outside
+// the window are ignored.
 func Summarize(rows []Row, now time.Time) Summary {
-	startDay := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -6)
+	return SummarizeDays(rows, now, 7)
+}
+
+// SummarizeDays is Summarize over the days calendar days ending on now (at
+// least one), for digests that cover a month rather than a week.
+func SummarizeDays(rows []Row, now time.Time, days int) Summary {
+	if days < 1 {
+		days = 1
+	}
+	loc := now.Location()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	startDay := WindowStartDays(now, days)
 
 	dayCounts := map[string]int{}
 	byAction := map[string]int{"archive": 0, "delete": 0, "label": 0, "keep": 0}
@@ -77,7 +91,7 @@ func Summarize(rows []Row, now time.Time) Summary {
 	total := 0
 
 	for _, r := range rows {
-		at := r.At.UTC()
+		at := r.At.In(loc)
 		if at.Before(startDay) {
 			continue
 		}
@@ -89,11 +103,27 @@ func Summarize(rows []Row, now time.Time) Summary {
 		total++
 	}
 
-	days := make([]DayCount, 0, 7)
-	for i := 6; i >= 0; i-- {
-		key := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -i).Format("2006-01-02")
-		days = append(days, DayCount{Date: key, Count: dayCounts[key]})
+	series := make([]DayCount, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		key := today.AddDate(0, 0, -i).Format("2006-01-02")
+		series = append(series, DayCount{Date: key, Count: dayCounts[key]})
 	}
 
-	return Summary{Total: total, Days: days, ByAction: byAction, BySource: bySource}
+	return Summary{Total: total, Days: series, ByAction: byAction, BySource: bySource}
+}
+
+// WindowStart returns the first instant Summarize counts for now: midnight,
+// in now's location, six calendar days before now's day. Calendar arithmetic
+// keeps it on midnight across DST changes.
+func WindowStart(now time.Time) time.Time {
+	return WindowStartDays(now, 7)
+}
+
+// WindowStartDays is WindowStart for a window of days calendar days.
+func WindowStartDays(now time.Time, days int) time.Time {
+	if days < 1 {
+		days = 1
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d-(days-1), 0, 0, 0, 0, now.Location())
 }
@@ -93,3 +93,38 @@ func TestInverse(t *testing.T) {
 		})
 	}
 }
+
+func TestSummarizeInUserTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday 8 March 2026 (spring forward) at noon, Montréal.
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, loc)
+	rows := []Row{
+		// Saturday 22:30 local is already Sunday in UTC.
+		{At: time.Date(2026, 3, 8, 3, 30, 0, 0, time.UTC), Action: "archive"},
+		// Monday 2 March 00:30 local: the first day of the window.
+		{At: time.Date(2026, 3, 2, 0, 30, 0, 0, loc), Action: "archive"},
+		// Sunday 1 March 23:30 local: just outside.
+		{At: time.Date(2026, 3, 1, 23, 30, 0, 0, loc), Action: "archive"},
+	}
+
+	s := Summarize(rows, now)
+
+	if s.Total != 2 {
+		t.Errorf("Total = %d, want 2", s.Total)
+	}
+	if s.Days[0].Date != "2026-03-02" || s.Days[6].Date != "2026-03-08" {
+		t.Fatalf("Days window = %s..%s, want 2026-03-02..2026-03-08", s.Days[0].Date, s.Days[6].Date)
+	}
+	if s.Days[5].Count != 1 || s.Days[6].Count != 0 {
+		t.Errorf("late Saturday action should count on Saturday: %+v", s.Days)
+	}
+	if s.Days[0].Count != 1 {
+		t.Errorf("first day should hold one action: %+v", s.Days)
+	}
+	if got := WindowStart(now); !got.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, loc)) {
+		t.Errorf("WindowStart = %v, want local midnight on 2 March", got)
+	}
+}
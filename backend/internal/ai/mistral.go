@@ -11,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/risk"
 )
 
 const (
@@ -58,6 +60,18 @@ func (c *MistralClient) SetMaxRetries(n int) {
 	c.maxRetries = n
 }
 
+// Model returns the configured model name, the key usage is aggregated under.
+func (c *MistralClient) Model() string {
+	return c.model
+}
+
+// CacheVersion returns the model + prompt fingerprint that analysis caches are
+// keyed on for a user locale: a verdict from another model, prompt revision or
+// language is never reused.
+func (c *MistralClient) CacheVersion(loc string) string {
+	return c.model + "/" + PromptVersion(loc)
+}
+
 // Mistral API request/response types
 type chatRequest struct {
 	Model       string        `json:"model"`
@@ -77,6 +91,7 @@ type chatResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage Usage `json:"usage"`
 }
 
 // EmailAnalysis represents the AI's analysis of an email
@@ -87,54 +102,26 @@ type EmailAnalysis struct {
 	Reasoning  string  `json:"reasoning"`  // Brief explanation
 }
 
-// AnalyzeEmail analyzes a single email and returns a suggested action
-func (c *MistralClient) AnalyzeEmail(email models.Email, existingLabels []string) (*EmailAnalysis, error) {
-	labelsContext := ""
-	if len(existingLabels) > 0 {
-		labelsContext = fmt.Sprintf("\nLabels existants de l'utilisateur: %s", strings.Join(existingLabels, ", "))
-	}
-
-	prompt := fmt.Sprintf(`Tu es un assistant de tri d'emails. Analyse cet email et suggère une action.
-
-Email:
-- De: %s
-- Sujet: %s
-- Extrait: %s
-%s
-
-Actions possibles:
-- "archive": Pour les emails informatifs déjà lus ou non importants (newsletters lues, confirmations, notifications)
-- "delete": Pour les emails indésirables, spam, ou promotions non souhaitées
-- "label": Pour les emails à catégoriser
-- "keep": Pour les emails importants qui nécessitent une action ou attention
-
-Réponds UNIQUEMENT en JSON valide avec ce format exact:
-{
-  "action": "archive|delete|label|keep",
-  "label_name": "Nom du label si action=label, sinon chaîne vide",
-  "confidence": 0.0 à 1.0,
-  "reasoning": "Explication courte en français (max 100 caractères)"
-}
+// AnalyzeEmail analyzes a single email and returns a suggested action, along
+// with the tokens the call consumed (reported even when parsing fails). The
+// prompt, reasoning and label catalogue follow the user's locale loc.
+func (c *MistralClient) AnalyzeEmail(email models.Email, existingLabels []string, loc string) (*EmailAnalysis, Usage, error) {
+	prompt, err := render(promptsFor(loc).Email, emailPromptData{
+		From:     email.From,
+		Subject:  email.Subject,
+		Snippet:  truncate(email.Snippet, 200),
+		Labels:   strings.Join(existingLabels, ", "),
+		Catalog:  strings.Join(locale.DefaultLabels(loc), ", "),
+		Language: locale.LanguageName(locale.Detect(email.Subject+" "+email.Snippet), loc),
+		Risk:     riskSignals(email),
+	})
+	if err != nil {
+		return nil, Usage{}, err
+	}
 
-IMPORTANT pour les labels - sois PRECIS et SPECIFIQUE:
-- Utilise un label existant si pertinent
-- Propose des labels PRECIS selon le TYPE d'email:
-  * Livraisons/Colis: "Livraison" ou "Suivi Colis"
-  * Factures/Paiements: "Factures"
-  * Confirmations d'achat: "Achats"
-  * Newsletters: "Newsletters"
-  * Réseaux sociaux: "Social" (Facebook, Twitter, LinkedIn...)
-  * Voyages: "Voyages" (billets, réservations)
-  * Banque: "Banque"
-  * Travail: "Travail"
-  * Administration: "Administratif"
-- NE PAS utiliser de labels trop génériques comme "E-commerce"
-- Préfère des labels orientés ACTION/TYPE plutôt que SOURCE`,
-		email.From, email.Subject, truncate(email.Snippet, 200), labelsContext)
-
-	response, err := c.chat(prompt)
+	response, usage, err := c.chat(prompt)
 	if err != nil {
-		return nil, fmt.Errorf("mistral API error: %w", err)
+		return nil, usage, fmt.Errorf("mistral API error: %w", err)
 	}
 
 	var analysis EmailAnalysis
@@ -145,10 +132,10 @@ IMPORTANT pour les labels - sois PRECIS et SPECIFIQUE:
 		if jsonStart >= 0 && jsonEnd > jsonStart {
 			cleanJSON := response[jsonStart : jsonEnd+1]
 			if err := json.Unmarshal([]byte(cleanJSON), &analysis); err != nil {
-				return nil, fmt.Errorf("failed to parse AI response: %w", err)
+				return nil, usage, fmt.Errorf("failed to parse AI response: %w", err)
 			}
 		} else {
-			return nil, fmt.Errorf("failed to parse AI response: %w", err)
+			return nil, usage, fmt.Errorf("failed to parse AI response: %w", err)
 		}
 	}
 
@@ -164,7 +151,7 @@ IMPORTANT pour les labels - sois PRECIS et SPECIFIQUE:
 		analysis.Confidence = 1
 	}
 
-	return &analysis, nil
+	return &analysis, usage, nil
 }
 
 // SenderAnalysis represents the AI's analysis of a sender's emails
@@ -176,50 +163,31 @@ type SenderAnalysis struct {
 	SenderType      string  `json:"sender_type"` // "commercial", "personal", "work", "newsletter", "transactional"
 }
 
-// AnalyzeSender analyzes multiple emails from the same sender
-func (c *MistralClient) AnalyzeSender(senderEmail string, emails []models.Email, existingLabels []string) (*SenderAnalysis, error) {
+// AnalyzeSender analyzes multiple emails from the same sender and reports the
+// tokens consumed.
+func (c *MistralClient) AnalyzeSender(senderEmail string, emails []models.Email, existingLabels []string, loc string) (*SenderAnalysis, Usage, error) {
 	// Build email summaries
 	var emailSummaries []string
 	for i, email := range emails {
 		if i >= 5 { // Limit to 5 emails for context
 			break
 		}
-		emailSummaries = append(emailSummaries, fmt.Sprintf("- Sujet: %s", email.Subject))
+		emailSummaries = append(emailSummaries, "- "+email.Subject)
 	}
 
-	labelsContext := ""
-	if len(existingLabels) > 0 {
-		labelsContext = fmt.Sprintf("\nLabels existants: %s", strings.Join(existingLabels, ", "))
+	prompt, err := render(promptsFor(loc).Sender, senderPromptData{
+		Sender:   senderEmail,
+		Count:    len(emails),
+		Subjects: strings.Join(emailSummaries, "\n"),
+		Labels:   strings.Join(existingLabels, ", "),
+	})
+	if err != nil {
+		return nil, Usage{}, err
 	}
 
-	prompt := fmt.Sprintf(`Tu es un assistant de tri d'emails. Analyse cet expéditeur et ses emails pour suggérer une action par défaut.
-
-Expéditeur: %s
-Nombre d'emails: %d
-
-Exemples de sujets:
-%s
-%s
-
-Actions possibles:
-- "archive": Archiver automatiquement (notifications, confirmations)
-- "delete": Supprimer (spam, promotions non voulues)
-- "label": Catégoriser avec un label
-- "keep": Garder en inbox (emails importants)
-
-Réponds UNIQUEMENT en JSON valide:
-{
-  "suggested_action": "archive|delete|label|keep",
-  "suggested_label": "Nom du label si action=label",
-  "confidence": 0.0 à 1.0,
-  "reasoning": "Explication courte en français",
-  "sender_type": "commercial|personal|work|newsletter|transactional"
-}`,
-		senderEmail, len(emails), strings.Join(emailSummaries, "\n"), labelsContext)
-
-	response, err := c.chat(prompt)
+	response, usage, err := c.chat(prompt)
 	if err != nil {
-		return nil, fmt.Errorf("mistral API error: %w", err)
+		return nil, usage, fmt.Errorf("mistral API error: %w", err)
 	}
 
 	var analysis SenderAnalysis
@@ -230,73 +198,73 @@ Réponds UNIQUEMENT en JSON valide:
 		if jsonStart >= 0 && jsonEnd > jsonStart {
 			cleanJSON := response[jsonStart : jsonEnd+1]
 			if err := json.Unmarshal([]byte(cleanJSON), &analysis); err != nil {
-				return nil, fmt.Errorf("failed to parse AI response: %w", err)
+				return nil, usage, fmt.Errorf("failed to parse AI response: %w", err)
 			}
 		} else {
-			return nil, fmt.Errorf("failed to parse AI response: %w", err)
+			return nil, usage, fmt.Errorf("failed to parse AI response: %w", err)
 		}
 	}
 
-	return &analysis, nil
+	return &analysis, usage, nil
 }
 
 // AnalyzeBatch analyzes several emails in a single API call and returns one
 // analysis per email, in order. This collapses N requests into ⌈N/batch⌉,
 // slashing both cost and latency. Returns an error if the model's response
-// can't be aligned with the input, so the caller can fall back per-email.
-func (c *MistralClient) AnalyzeBatch(emails []models.Email, existingLabels []string) ([]EmailAnalysis, error) {
+// can't be aligned with the input, so the caller can fall back per-email. The
+// tokens consumed are reported even then, since they were billed regardless.
+func (c *MistralClient) AnalyzeBatch(emails []models.Email, existingLabels []string, loc string) ([]EmailAnalysis, Usage, error) {
 	if len(emails) == 0 {
-		return nil, nil
+		return nil, Usage{}, nil
 	}
 
 	var list strings.Builder
+	risky := false
 	for i, e := range emails {
-		fmt.Fprintf(&list, "%d. De: %s | Sujet: %s | Extrait: %s\n",
-			i+1, e.From, e.Subject, truncate(e.Snippet, 160))
+		fmt.Fprintf(&list, "%d. %s | %s | %s", i+1, e.From, e.Subject, truncate(e.Snippet, 160))
+		if lang := locale.LanguageName(locale.Detect(e.Subject+" "+e.Snippet), loc); lang != "" {
+			fmt.Fprintf(&list, " | %s", lang)
+		}
+		if signals := riskSignals(e); signals != "" {
+			fmt.Fprintf(&list, " | ⚠ %s", signals)
+			risky = true
+		}
+		list.WriteString("\n")
 	}
 
-	labelsContext := ""
-	if len(existingLabels) > 0 {
-		labelsContext = "\nLabels existants de l'utilisateur: " + strings.Join(existingLabels, ", ")
+	prompt, err := render(promptsFor(loc).Batch, batchPromptData{
+		Count:   len(emails),
+		List:    strings.TrimRight(list.String(), "\n"),
+		Labels:  strings.Join(existingLabels, ", "),
+		Catalog: strings.Join(locale.DefaultLabels(loc), ", "),
+		Risky:   risky,
+	})
+	if err != nil {
+		return nil, Usage{}, err
 	}
 
-	prompt := fmt.Sprintf(`Tu es un assistant de tri d'emails. Analyse les %d emails ci-dessous et propose une action pour CHACUN.
-
-Emails:
-%s%s
-
-Actions possibles:
-- "archive": informatif déjà lu / non important (newsletters lues, confirmations, notifications)
-- "delete": indésirable, spam, promotions non souhaitées
-- "label": à catégoriser (labels PRÉCIS par TYPE: Livraison, Factures, Achats, Newsletters, Social, Voyages, Banque, Travail, Administratif)
-- "keep": important, nécessite une action ou attention
-
-Réponds UNIQUEMENT avec un TABLEAU JSON de %d objets, dans le MÊME ORDRE que les emails, format exact:
-[{"action":"archive|delete|label|keep","label_name":"label si action=label sinon vide","confidence":0.0,"reasoning":"explication courte en français"}]`,
-		len(emails), list.String(), labelsContext, len(emails))
-
 	maxTokens := 120*len(emails) + 200
 	if maxTokens > 4000 {
 		maxTokens = 4000
 	}
 
-	response, err := c.chatTokens(prompt, maxTokens)
+	response, usage, err := c.chatTokens(prompt, maxTokens)
 	if err != nil {
-		return nil, fmt.Errorf("mistral API error: %w", err)
+		return nil, usage, fmt.Errorf("mistral API error: %w", err)
 	}
 
 	start := strings.Index(response, "[")
 	end := strings.LastIndex(response, "]")
 	if start < 0 || end <= start {
-		return nil, fmt.Errorf("no JSON array in batch response")
+		return nil, usage, fmt.Errorf("no JSON array in batch response")
 	}
 
 	var results []EmailAnalysis
 	if err := json.Unmarshal([]byte(response[start:end+1]), &results); err != nil {
-		return nil, fmt.Errorf("failed to parse batch response: %w", err)
+		return nil, usage, fmt.Errorf("failed to parse batch response: %w", err)
 	}
 	if len(results) < len(emails) {
-		return nil, fmt.Errorf("batch returned %d analyses for %d emails", len(results), len(emails))
+		return nil, usage, fmt.Errorf("batch returned %d analyses for %d emails", len(results), len(emails))
 	}
 
 	for i := range results {
@@ -314,36 +282,26 @@ Réponds UNIQUEMENT avec un TABLEAU JSON de %d objets, dans le MÊME ORDRE que l
 		}
 	}
 
-	return results[:len(emails)], nil
+	return results[:len(emails)], usage, nil
 }
 
 // FindMatchingLabel checks if a suggested label matches an existing one
-func (c *MistralClient) FindMatchingLabel(suggestedLabel string, existingLabels []string) (string, bool, error) {
+func (c *MistralClient) FindMatchingLabel(suggestedLabel string, existingLabels []string, loc string) (string, bool, Usage, error) {
 	if len(existingLabels) == 0 {
-		return suggestedLabel, false, nil
+		return suggestedLabel, false, Usage{}, nil
 	}
 
-	prompt := fmt.Sprintf(`Tu dois déterminer si un label suggéré correspond à un label existant.
-
-Label suggéré: "%s"
-Labels existants: %s
-
-Réponds UNIQUEMENT en JSON valide:
-{
-  "matches_existing": true ou false,
-  "matched_label": "nom du label existant qui correspond, ou le label suggéré si pas de correspondance"
-}
-
-Règles:
-- "E-commerce" et "Shopping" sont équivalents
-- "Newsletters" et "Newsletter" sont équivalents
-- Ignore les différences de casse
-- Si aucun label existant ne correspond, renvoie le label suggéré`,
-		suggestedLabel, strings.Join(existingLabels, ", "))
+	prompt, err := render(promptsFor(loc).Match, matchPromptData{
+		Suggested: suggestedLabel,
+		Labels:    strings.Join(existingLabels, ", "),
+	})
+	if err != nil {
+		return suggestedLabel, false, Usage{}, nil
+	}
 
-	response, err := c.chat(prompt)
+	response, usage, err := c.chat(prompt)
 	if err != nil {
-		return suggestedLabel, false, nil // Fallback to suggested label
+		return suggestedLabel, false, usage, nil // Fallback to suggested label
 	}
 
 	var result struct {
@@ -357,18 +315,18 @@ Règles:
 		if jsonStart >= 0 && jsonEnd > jsonStart {
 			cleanJSON := response[jsonStart : jsonEnd+1]
 			if err := json.Unmarshal([]byte(cleanJSON), &result); err != nil {
-				return suggestedLabel, false, nil
+				return suggestedLabel, false, usage, nil
 			}
 		} else {
-			return suggestedLabel, false, nil
+			return suggestedLabel, false, usage, nil
 		}
 	}
 
-	return result.MatchedLabel, result.MatchesExisting, nil
+	return result.MatchedLabel, result.MatchesExisting, usage, nil
 }
 
 // chat sends a message to Mistral and returns the response (default token budget).
-func (c *MistralClient) chat(prompt string) (string, error) {
+func (c *MistralClient) chat(prompt string) (string, Usage, error) {
 	return c.chatTokens(prompt, 500)
 }
 
@@ -377,7 +335,9 @@ func (c *MistralClient) chat(prompt string) (string, error) {
 // exponential backoff + jitter. Permanent failures (4xx other than 429, JSON
 // errors) fail fast. The LLM is the flakiest dependency in the request path, so
 // a single 429 no longer collapses a whole analysis batch down to "keep".
-func (c *MistralClient) chatTokens(prompt string, maxTokens int) (string, error) {
+// Failed attempts carry no usage block, so the returned Usage is that of the
+// successful attempt.
+func (c *MistralClient) chatTokens(prompt string, maxTokens int) (string, Usage, error) {
 	reqBody := chatRequest{
 		Model: c.model,
 		Messages: []chatMessage{
@@ -389,29 +349,29 @@ func (c *MistralClient) chatTokens(prompt string, maxTokens int) (string, error)
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return "", Usage{}, err
 	}
 
 	var lastErr error
 	for attempt := 0; ; attempt++ {
-		content, retryable, retryAfter, err := c.doChat(jsonBody)
+		content, usage, retryable, retryAfter, err := c.doChat(jsonBody)
 		if err == nil {
-			return content, nil
+			return content, usage, nil
 		}
 		lastErr = err
 		if !retryable || attempt >= c.maxRetries {
-			return "", lastErr
+			return "", usage, lastErr
 		}
 		c.sleep(c.backoff(attempt, retryAfter))
 	}
 }
 
-// doChat performs a single Mistral call. It reports whether the failure is worth
-// retrying and any server-advised Retry-After delay.
-func (c *MistralClient) doChat(jsonBody []byte) (content string, retryable bool, retryAfter time.Duration, err error) {
+// doChat performs a single Mistral call. It reports the token usage, whether
+// the failure is worth retrying and any server-advised Retry-After delay.
+func (c *MistralClient) doChat(jsonBody []byte) (content string, usage Usage, retryable bool, retryAfter time.Duration, err error) {
 	req, err := http.NewRequest("POST", c.baseURL, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", false, 0, err
+		return "", Usage{}, false, 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
@@ -419,30 +379,30 @@ func (c *MistralClient) doChat(jsonBody []byte) (content string, retryable bool,
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		// Transport-level errors (timeouts, resets) are transient.
-		return "", true, 0, err
+		return "", Usage{}, true, 0, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", true, 0, err
+		return "", Usage{}, true, 0, err
 	}
 
 	if resp.StatusCode == http.StatusOK {
 		var chatResp chatResponse
 		if err := json.Unmarshal(body, &chatResp); err != nil {
-			return "", false, 0, err
+			return "", Usage{}, false, 0, err
 		}
 		if len(chatResp.Choices) == 0 {
-			return "", false, 0, fmt.Errorf("no response from Mistral")
+			return "", chatResp.Usage, false, 0, fmt.Errorf("no response from Mistral")
 		}
-		return chatResp.Choices[0].Message.Content, false, 0, nil
+		return chatResp.Choices[0].Message.Content, chatResp.Usage, false, 0, nil
 	}
 
 	// Rate limits and server errors are transient; everything else is permanent.
 	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
 	retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
-	return "", retryable, retryAfter, fmt.Errorf("mistral API returned status %d: %s", resp.StatusCode, string(body))
+	return "", Usage{}, retryable, retryAfter, fmt.Errorf("mistral API returned status %d: %s", resp.StatusCode, string(body))
 }
 
 // backoff computes the wait before the next attempt: exponential in the attempt
@@ -476,6 +436,15 @@ func parseRetryAfter(v string) time.Duration {
 	return 0
 }
 
+// riskSignals lists an email's risk reasons for the prompt when its score
+// reaches risk.High, and is empty otherwise so ordinary mail reads as before.
+func riskSignals(e models.Email) string {
+	if e.Risk < risk.High {
+		return ""
+	}
+	return strings.Join(e.RiskReasons, ", ")
+}
+
 // Helper function to truncate strings
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -37,7 +37,7 @@ func TestChatRetriesOn429ThenSucceeds(t *testing.T) {
 	c := newTestClient(srv.URL)
 	c.SetMaxRetries(3)
 
-	got, err := c.chat("ping")
+	got, _, err := c.chat("ping")
 	if err != nil {
 		t.Fatalf("expected success after retries, got error: %v", err)
 	}
@@ -49,6 +49,22 @@ func TestChatRetriesOn429ThenSucceeds(t *testing.T) {
 	}
 }
 
+func TestChatParsesUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"hello"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer srv.Close()
+
+	_, usage, err := newTestClient(srv.URL).chat("ping")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
 func TestChatExhaustsRetriesOn500(t *testing.T) {
 	var calls int32
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +76,7 @@ func TestChatExhaustsRetriesOn500(t *testing.T) {
 	c := newTestClient(srv.URL)
 	c.SetMaxRetries(2)
 
-	if _, err := c.chat("ping"); err == nil {
+	if _, _, err := c.chat("ping"); err == nil {
 		t.Fatal("expected an error after exhausting retries")
 	}
 	// 1 initial attempt + 2 retries = 3 calls.
@@ -80,7 +96,7 @@ func TestChatDoesNotRetryClientError(t *testing.T) {
 	c := newTestClient(srv.URL)
 	c.SetMaxRetries(5)
 
-	if _, err := c.chat("ping"); err == nil {
+	if _, _, err := c.chat("ping"); err == nil {
 		t.Fatal("expected an error on a 400 response")
 	}
 	if calls != 1 {
@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/risk"
+)
+
+func TestPromptsRenderForEveryLocale(t *testing.T) {
+	for _, loc := range []string{locale.French, locale.English, locale.German} {
+		set := promptsFor(loc)
+		if !strings.HasPrefix(set.Version, loc+"-") {
+			t.Errorf("%s: version %q should be locale-prefixed", loc, set.Version)
+		}
+		renders := []struct {
+			tpl  *template.Template
+			data interface{}
+		}{
+			{set.Email, emailPromptData{From: "a@x.com", Subject: "Hi", Snippet: "s", Labels: "Work", Catalog: "A, B", Language: "x"}},
+			{set.Email, emailPromptData{From: "a@x.com", Subject: "Hi", Snippet: "s", Risk: "auth-fail, lookalike-domain"}},
+			{set.Batch, batchPromptData{Count: 2, List: "1. a\n2. b", Labels: "Work", Catalog: "A, B"}},
+			{set.Batch, batchPromptData{Count: 1, List: "1. a | ⚠ auth-fail", Risky: true}},
+			{set.Sender, senderPromptData{Sender: "a@x.com", Count: 3, Subjects: "- Hi"}},
+			{set.Match, matchPromptData{Suggested: "Bills", Labels: "Invoices"}},
+			{set.Summary, summaryPromptData{Count: 2, Messages: "From: a\n\nhi", Today: "2026-10-18"}},
+			{set.Reply, replyPromptData{Count: 1, Messages: "From: a\n\nhi", Me: "me@x.com", Tone: "", Language: "x"}},
+		}
+		for _, r := range renders {
+			out, err := render(r.tpl, r.data)
+			if err != nil || out == "" || strings.Contains(out, "<no value>") {
+				t.Errorf("%s: render failed (err=%v): %q", r.tpl.Name(), err, out)
+			}
+		}
+	}
+}
+
+func TestPromptsForFallsBackToDefault(t *testing.T) {
+	if got, want := PromptVersion("es"), PromptVersion(locale.Default); got != want {
+		t.Errorf("unsupported locale version = %q, want default %q", got, want)
+	}
+}
+
+func TestEmailPromptNotesDetectedLanguageOnlyWhenKnown(t *testing.T) {
+	with, _ := render(promptsFor(locale.English).Email, emailPromptData{Language: "German"})
+	without, _ := render(promptsFor(locale.English).Email, emailPromptData{})
+	if !strings.Contains(with, "Detected language: German") {
+		t.Error("detected language should be noted")
+	}
+	if strings.Contains(without, "Detected language") {
+		t.Error("unknown language should not be mentioned")
+	}
+}
+
+func TestEmailPromptWarnsOnlyWhenRisky(t *testing.T) {
+	risky := models.Email{From: "x@paypa1.com", Risk: risk.High, RiskReasons: []string{"lookalike-domain", "reply-to-mismatch"}}
+	low := models.Email{From: "a@acme.com", Risk: risk.High - 1, RiskReasons: []string{"reply-to-mismatch"}}
+	if got := riskSignals(risky); got != "lookalike-domain, reply-to-mismatch" {
+		t.Errorf("riskSignals(risky) = %q", got)
+	}
+	if got := riskSignals(low); got != "" {
+		t.Errorf("riskSignals below High = %q, want empty", got)
+	}
+
+	with, _ := render(promptsFor(locale.English).Email, emailPromptData{Risk: riskSignals(risky)})
+	without, _ := render(promptsFor(locale.English).Email, emailPromptData{})
+	if !strings.Contains(with, "Risk signals: lookalike-domain") || !strings.Contains(with, "WARNING") {
+		t.Error("risky email should carry its signals and the warning")
+	}
+	if strings.Contains(without, "Risk signals") || strings.Contains(without, "WARNING") {
+		t.Error("ordinary email should not mention risk")
+	}
+}
+
+func TestCacheVersionVariesByLocale(t *testing.T) {
+	c := NewMistralClient("k", "m")
+	if c.CacheVersion(locale.French) == c.CacheVersion(locale.English) {
+		t.Error("locales must not share cached verdicts")
+	}
+}
@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
+)
+
+// ReplyOptions personalizes a drafted reply.
+type ReplyOptions struct {
+	Me           string // the user's address, who the reply is written as
+	Tone         string // free-form tone preference ("" = locale default)
+	Instructions string // one-off guidance for this reply ("accept", "decline politely"…)
+}
+
+// DraftReply writes the body of a reply to the last message of thread (oldest
+// first). It answers in the language of that message when it can be detected,
+// else in loc's language. The result has no subject and no signature — the
+// caller owns headers and appends the user's signature.
+func (c *MistralClient) DraftReply(thread []SummaryMessage, opts ReplyOptions, loc string) (string, Usage, error) {
+	if len(thread) == 0 {
+		return "", Usage{}, fmt.Errorf("nothing to reply to")
+	}
+	last := thread[len(thread)-1]
+
+	prompt, err := render(promptsFor(loc).Reply, replyPromptData{
+		Count:        len(thread),
+		Messages:     renderSummaryMessages(thread),
+		Me:           opts.Me,
+		Tone:         opts.Tone,
+		Instructions: opts.Instructions,
+		Language:     locale.LanguageName(locale.Detect(last.Subject+" "+last.Body), loc),
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	response, usage, err := c.chatTokens(prompt, 900)
+	if err != nil {
+		return "", usage, fmt.Errorf("mistral API error: %w", err)
+	}
+	body := strings.TrimSpace(response)
+	if body == "" {
+		return "", usage, fmt.Errorf("empty reply from model")
+	}
+	return body, usage, nil
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+// SummaryMessage is one message handed to Summarize: headers plus the decoded
+// plain-text body.
+type SummaryMessage struct {
+	From    string
+	Date    time.Time
+	Subject string
+	Body    string
+}
+
+// Summary is the structured digest of a message or thread.
+type Summary struct {
+	Summary     string              `json:"summary"`
+	ActionItems []models.ActionItem `json:"action_items"`
+	Deadlines   []models.Deadline   `json:"deadlines"`
+	Amounts     []models.Amount     `json:"amounts"`
+}
+
+// Per-message and whole-prompt body budgets. Long threads keep their most
+// recent messages intact: older ones are truncated first.
+const (
+	summaryMessageChars = 4000
+	summaryTotalChars   = 12000
+)
+
+// Summarize condenses one message or a whole thread (oldest first) into a short
+// summary plus the action items, deadlines and amounts it contains. now anchors
+// relative dates in the mail; loc picks the prompt and output language.
+func (c *MistralClient) Summarize(messages []SummaryMessage, loc string, now time.Time) (*Summary, Usage, error) {
+	if len(messages) == 0 {
+		return nil, Usage{}, fmt.Errorf("nothing to summarize")
+	}
+
+	prompt, err := render(promptsFor(loc).Summary, summaryPromptData{
+		Count:    len(messages),
+		Messages: renderSummaryMessages(messages),
+		Today:    now.Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, Usage{}, err
+	}
+
+	response, usage, err := c.chatTokens(prompt, 800)
+	if err != nil {
+		return nil, usage, fmt.Errorf("mistral API error: %w", err)
+	}
+
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end <= start {
+		return nil, usage, fmt.Errorf("no JSON object in summary response")
+	}
+	var out Summary
+	if err := json.Unmarshal([]byte(response[start:end+1]), &out); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse summary response: %w", err)
+	}
+	out.Deadlines = validDeadlines(out.Deadlines)
+	return &out, usage, nil
+}
+
+// renderSummaryMessages lays the messages out for the prompt, newest given the
+// most room: the total budget is spent from the end of the thread backwards.
+func renderSummaryMessages(messages []SummaryMessage) string {
+	blocks := make([]string, len(messages))
+	budget := summaryTotalChars
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		limit := summaryMessageChars
+		if budget < limit {
+			limit = budget
+		}
+		body := strings.TrimSpace(m.Body)
+		if limit <= 0 {
+			body = "[…]"
+		} else {
+			body = truncate(body, limit)
+		}
+		budget -= len(body)
+		date := ""
+		if !m.Date.IsZero() {
+			date = m.Date.Format("2006-01-02 15:04")
+		}
+		blocks[i] = fmt.Sprintf("--- %d/%d | %s | %s | %s\n%s", i+1, len(messages), m.From, date, m.Subject, body)
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// validDeadlines drops deadlines whose date the model did not give as a real
+// YYYY-MM-DD calendar date, so callers can parse every survivor.
+func validDeadlines(in []models.Deadline) []models.Deadline {
+	out := make([]models.Deadline, 0, len(in))
+	for _, d := range in {
+		d.Date = strings.TrimSpace(d.Date)
+		if _, err := time.Parse("2006-01-02", d.Date); err == nil {
+			out = append(out, d)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeParsesStructuredResult(t *testing.T) {
+	content := "Voici:\n{\"summary\":\"Facture à régler.\",\"action_items\":[{\"task\":\"Payer\",\"owner\":\"moi\"}]," +
+		"\"deadlines\":[{\"description\":\"échéance\",\"date\":\"2026-10-30\"},{\"description\":\"flou\",\"date\":\"fin du mois\"}]," +
+		"\"amounts\":[{\"description\":\"total\",\"value\":129.9,\"currency\":\"EUR\"}]}"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":` + quoteJSON(content) + `}}],"usage":{"prompt_tokens":50,"completion_tokens":20,"total_tokens":70}}`))
+	}))
+	defer srv.Close()
+
+	s, usage, err := newTestClient(srv.URL).Summarize([]SummaryMessage{{From: "a@x.com", Subject: "Facture", Body: "Merci de régler 129,90 €"}}, "fr", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if s.Summary != "Facture à régler." || len(s.ActionItems) != 1 || len(s.Amounts) != 1 || s.Amounts[0].Value != 129.9 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if len(s.Deadlines) != 1 || s.Deadlines[0].Date != "2026-10-30" {
+		t.Fatalf("invalid deadline dates must be dropped, got %+v", s.Deadlines)
+	}
+	if usage.TotalTokens != 70 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestRenderSummaryMessagesFavorsRecent(t *testing.T) {
+	long := strings.Repeat("a", summaryTotalChars)
+	msgs := []SummaryMessage{{From: "old@x.com", Body: long}}
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, SummaryMessage{From: "mid@x.com", Body: long})
+	}
+	msgs = append(msgs, SummaryMessage{From: "new@x.com", Body: "latest reply"})
+	out := renderSummaryMessages(msgs)
+	if !strings.Contains(out, "latest reply") {
+		t.Fatal("the newest message must always be included in full")
+	}
+	if !strings.Contains(out, "1/6 | old@x.com") || !strings.Contains(out, "[…]") {
+		t.Fatalf("older messages beyond the budget should be elided, got %d chars", len(out))
+	}
+}
+
+func quoteJSON(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,59 @@
+package ai
+
+import "strings"
+
+// Usage is the token accounting Mistral returns in the `usage` block of every
+// chat completion. Callers aggregate it per user, per job and per model.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens" bson:"promptTokens"`
+	CompletionTokens int `json:"completion_tokens" bson:"completionTokens"`
+	TotalTokens      int `json:"total_tokens" bson:"totalTokens"`
+}
+
+// Add returns the sum of u and o.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		TotalTokens:      u.TotalTokens + o.TotalTokens,
+	}
+}
+
+// IsZero reports whether no tokens were consumed.
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0
+}
+
+// price is a per-million-token rate in USD.
+type price struct {
+	prompt     float64
+	completion float64
+}
+
+// modelPrices holds list prices per model family, matched by prefix so dated
+// releases ("mistral-large-2411") resolve to their family. Longer prefixes
+// must come first. Unknown models cost 0 — tokens are still counted, which is
+// what the server-wide budget enforces.
+var modelPrices = []struct {
+	prefix string
+	price  price
+}{
+	{"mistral-large", price{2.0, 6.0}},
+	{"mistral-medium", price{0.4, 2.0}},
+	{"mistral-small", price{0.1, 0.3}},
+	{"ministral-8b", price{0.1, 0.1}},
+	{"ministral-3b", price{0.04, 0.04}},
+	{"open-mistral-nemo", price{0.15, 0.15}},
+	{"mistral-embed", price{0.1, 0}},
+}
+
+// CostUSD estimates what u cost on model, in US dollars.
+func CostUSD(model string, u Usage) float64 {
+	m := strings.ToLower(strings.TrimSpace(model))
+	for _, p := range modelPrices {
+		if strings.HasPrefix(m, p.prefix) {
+			return (float64(u.PromptTokens)*p.price.prompt + float64(u.CompletionTokens)*p.price.completion) / 1e6
+		}
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUsageAdd(t *testing.T) {
+	got := Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}.Add(Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3})
+	want := Usage{PromptTokens: 11, CompletionTokens: 7, TotalTokens: 18}
+	if got != want {
+		t.Fatalf("Add = %+v, want %+v", got, want)
+	}
+	if !(Usage{}).IsZero() || want.IsZero() {
+		t.Fatal("IsZero mismatch")
+	}
+}
+
+func TestCostUSD(t *testing.T) {
+	u := Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000, TotalTokens: 2_000_000}
+	cases := map[string]float64{
+		"mistral-small-latest": 0.4,
+		"mistral-large-2411":   8.0, // dated release resolves to its family
+		"Mistral-Large-Latest": 8.0,
+		"some-unknown-model":   0,
+	}
+	for model, want := range cases {
+		if got := CostUSD(model, u); math.Abs(got-want) > 1e-9 {
+			t.Errorf("CostUSD(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
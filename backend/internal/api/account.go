@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/activity"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/digest"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/schedule"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -17,6 +20,13 @@ import (
 // Cache hits and sender auto-pilot do NOT count against it.
 const FreeMonthlyLimit = 200
 
+// Upper bounds on the free-text reply settings; both end up inside an AI prompt
+// or a draft, so they are kept short.
+const (
+	maxReplyToneLen = 200
+	maxSignatureLen = 1000
+)
+
 func currentPeriod() string { return time.Now().UTC().Format("2006-01") }
 
 func (h *Handler) getUsage(ctx context.Context, userEmail string) int {
@@ -74,27 +84,32 @@ func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
 		"period":    currentPeriod(),
 		"plan":      plan,
 		"billingOn": h.billing.Client != nil && h.billing.PriceID != "",
+		"tokens":    h.getTokenTotals(ctx, userEmail),
 	})
 }
 
-// autoApplyRulesEnabled reports whether the user opted into running their
-// deterministic rules automatically on every sync.
-func (h *Handler) autoApplyRulesEnabled(ctx context.Context, userEmail string) bool {
-	return h.userSettings(ctx, userEmail).AutoApplyRules
-}
-
 // userSettings loads the caller's tunable settings, applying the server default
 // digest hour when the user has not picked one (a stored 0 is treated as
 // "unset" rather than midnight, which is rarely what a user means).
 func (h *Handler) userSettings(ctx context.Context, userEmail string) models.UserSettings {
 	var doc struct {
-		AutoApplyRules  bool `bson:"autoApplyRules"`
-		AutoSyncEnabled bool `bson:"autoSyncEnabled"`
-		DigestEnabled   bool `bson:"digestEnabled"`
-		DigestHourUTC   int  `bson:"digestHourUTC"`
+		AutoApplyRules  bool   `bson:"autoApplyRules"`
+		AutoSyncEnabled bool   `bson:"autoSyncEnabled"`
+		DigestEnabled   bool   `bson:"digestEnabled"`
+		DigestHourUTC   int    `bson:"digestHourUTC"`
+		DigestHour      *int   `bson:"digestHour"`
+		Timezone        string `bson:"timezone"`
+		Locale          string `bson:"locale"`
+		ReplyTone       string `bson:"replyTone"`
+		Signature       string `bson:"signature"`
+
+		Digest                 models.DigestPrefs         `bson:"digest"`
+		WorkHours              models.WorkHours           `bson:"workHours"`
+		Autopilot              models.AutopilotThresholds `bson:"autopilot"`
+		UnsubscribeEnforcement string                     `bson:"unsubscribeEnforcement"`
 	}
 	if err := h.db.Users().FindOne(ctx, bson.M{"email": userEmail}).Decode(&doc); err != nil {
-		return models.UserSettings{DigestHourUTC: defaultDigestHour()}
+		return models.UserSettings{DigestHourUTC: defaultDigestHour(), Locale: locale.Default}
 	}
 	hour := doc.DigestHourUTC
 	if hour <= 0 || hour > 23 {
@@ -105,9 +120,41 @@ func (h *Handler) userSettings(ctx context.Context, userEmail string) models.Use
 		AutoSyncEnabled: doc.AutoSyncEnabled,
 		DigestEnabled:   doc.DigestEnabled,
 		DigestHourUTC:   hour,
+		DigestHour:      doc.DigestHour,
+		Timezone:        doc.Timezone,
+		Digest:          effectiveDigestPrefs(doc.Digest),
+		Locale:          locale.Normalize(doc.Locale),
+		ReplyTone:       doc.ReplyTone,
+		Signature:       doc.Signature,
+		WorkHours:       doc.WorkHours,
+		Autopilot:       doc.Autopilot,
+
+		UnsubscribeEnforcement: doc.UnsubscribeEnforcement,
 	}
 }
 
+// calendarFor builds the user's calendar from their settings. Stored values
+// were validated on the way in, so a failure only means a zone the server no
+// longer knows; the user then falls back to UTC with their working week.
+func calendarFor(s models.UserSettings) schedule.Calendar {
+	cal, err := schedule.NewCalendar(s.Timezone, s.WorkHours.Start, s.WorkHours.End, s.WorkHours.Weekend)
+	if err != nil {
+		cal, _ = schedule.NewCalendar("", s.WorkHours.Start, s.WorkHours.End, s.WorkHours.Weekend)
+		cal.Location = time.UTC
+	}
+	return cal
+}
+
+// userCalendar is the caller's wall clock: timezone and working week.
+func (h *Handler) userCalendar(ctx context.Context, userEmail string) schedule.Calendar {
+	return calendarFor(h.userSettings(ctx, userEmail))
+}
+
+// userLocale is the caller's prompt/taxonomy locale (default French).
+func (h *Handler) userLocale(ctx context.Context, userEmail string) string {
+	return h.userSettings(ctx, userEmail).Locale
+}
+
 // GetSettings returns the caller's tunable account settings.
 func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
@@ -140,6 +187,7 @@ func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	set := bson.M{"updatedAt": time.Now()}
+	unset := bson.M{}
 	if in.AutoApplyRules != nil {
 		set["autoApplyRules"] = *in.AutoApplyRules
 	}
@@ -157,14 +205,96 @@ func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
 		}
 		set["digestHourUTC"] = hour
 	}
+	if in.DigestHour != nil {
+		switch hour := *in.DigestHour; {
+		case hour < 0:
+			unset["digestHour"] = ""
+		case hour > 23:
+			writeError(w, http.StatusBadRequest, "Invalid digest hour (0–23)")
+			return
+		default:
+			set["digestHour"] = hour
+		}
+	}
+	if in.Digest != nil {
+		prefs := *in.Digest
+		if prefs.Cadence != "" && !digest.ValidCadence(prefs.Cadence) {
+			writeError(w, http.StatusBadRequest, "Invalid digest cadence (daily, weekly or monthly)")
+			return
+		}
+		for _, sec := range prefs.Sections {
+			if !digest.ValidSection(sec) {
+				writeError(w, http.StatusBadRequest, "Unknown digest section: "+sec)
+				return
+			}
+		}
+		set["digest"] = prefs
+	}
+	if in.Timezone != nil {
+		if _, err := schedule.NewCalendar(*in.Timezone, 0, 0, nil); err != nil {
+			writeError(w, http.StatusBadRequest, "Unknown timezone (IANA name, e.g. America/Montreal)")
+			return
+		}
+		set["timezone"] = *in.Timezone
+	}
+	if in.WorkHours != nil {
+		wh := *in.WorkHours
+		if _, err := schedule.NewCalendar("", wh.Start, wh.End, wh.Weekend); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid working hours: "+err.Error())
+			return
+		}
+		for i, day := range wh.Weekend {
+			wh.Weekend[i] = strings.ToLower(strings.TrimSpace(day))
+		}
+		set["workHours"] = wh
+	}
+	if in.Locale != nil {
+		tag, ok := locale.Lookup(*in.Locale)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Unsupported locale (fr, en, de)")
+			return
+		}
+		set["locale"] = tag
+	}
+	if in.ReplyTone != nil {
+		if len(*in.ReplyTone) > maxReplyToneLen {
+			writeError(w, http.StatusBadRequest, "Reply tone too long")
+			return
+		}
+		set["replyTone"] = strings.TrimSpace(*in.ReplyTone)
+	}
+	if in.Signature != nil {
+		if len(*in.Signature) > maxSignatureLen {
+			writeError(w, http.StatusBadRequest, "Signature too long")
+			return
+		}
+		set["signature"] = strings.TrimRight(*in.Signature, " \n")
+	}
+	if in.Autopilot != nil {
+		if err := validateAutopilot(*in.Autopilot); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		set["autopilot"] = *in.Autopilot
+	}
+	if in.UnsubscribeEnforcement != nil {
+		switch *in.UnsubscribeEnforcement {
+		case "", "archive", "trash":
+			set["unsubscribeEnforcement"] = *in.UnsubscribeEnforcement
+		default:
+			writeError(w, http.StatusBadRequest, "Invalid unsubscribe enforcement (archive, trash or empty)")
+			return
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if _, err := h.db.Users().UpdateOne(ctx,
-		bson.M{"email": userEmail},
-		bson.M{"$set": set},
-	); err != nil {
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if _, err := h.db.Users().UpdateOne(ctx, bson.M{"email": userEmail}, update); err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to update settings")
 		return
 	}
@@ -199,10 +329,16 @@ func (h *Handler) GetActivity(w http.ResponseWriter, r *http.Request) {
 }
 
 // activitySummary loads the trailing-7-day action ledger for a user and folds
-// it into the recap. Shared by GetActivity and GetDigest so both render from
+// it into the recap. Shared by GetActivity and the digest so both render from
 // the exact same numbers.
 func (h *Handler) activitySummary(ctx context.Context, userEmail string) (activity.Summary, error) {
-	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -6)
+	return h.activitySummaryDays(ctx, userEmail, h.userCalendar(ctx, userEmail).In(time.Now()), 7)
+}
+
+// activitySummaryDays is activitySummary over the `days` days ending on now's
+// day, in now's location.
+func (h *Handler) activitySummaryDays(ctx context.Context, userEmail string, now time.Time, days int) (activity.Summary, error) {
+	since := activity.WindowStartDays(now, days)
 
 	cursor, err := h.db.ActionLog().Find(ctx, bson.M{
 		"userId":    userEmail,
@@ -222,13 +358,12 @@ func (h *Handler) activitySummary(ctx context.Context, userEmail string) (activi
 	for _, l := range logs {
 		rows = append(rows, activity.Row{At: l.CreatedAt, Action: l.Action, Source: l.Source})
 	}
-	return activity.Summarize(rows, time.Now()), nil
+	return activity.SummarizeDays(rows, now, days), nil
 }
 
-// GetDigest renders the same 7-day recap as GetActivity into a ready-to-send
-// email digest (subject + plain-text body + HTML body). This is the "Digest
-// quotidien par email" content: previewable now, and the payload a scheduler
-// would hand to a gmail.send (or SMTP) sender once that scope is wired.
+// GetDigest renders the caller's digest — their cadence and sections — into a
+// ready-to-send email (subject + plain-text body + HTML body), exactly as the
+// scheduler would send it now. See GetDigestPreview for when it next goes out.
 func (h *Handler) GetDigest(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -239,12 +374,12 @@ func (h *Handler) GetDigest(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	summary, err := h.activitySummary(ctx, userEmail)
+	d, _, err := h.buildDigest(ctx, userEmail, h.userSettings(ctx, userEmail))
 	if err != nil {
 		http.Error(w, "Failed to load activity", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(digest.Render(summary, time.Now()))
+	json.NewEncoder(w).Encode(d)
 }
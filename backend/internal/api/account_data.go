@@ -28,16 +28,36 @@ func (h *Handler) datasetCollection(ds account.Dataset) *mongo.Collection {
 		return h.db.AISuggestions()
 	case account.DatasetSenderPrefs:
 		return h.db.SenderPreferences()
+	case account.DatasetSenders:
+		return h.db.Senders()
 	case account.DatasetSmartLabels:
 		return h.db.SmartLabels()
 	case account.DatasetUnsubscribes:
 		return h.db.Unsubscribes()
+	case account.DatasetUnsubViolations:
+		return h.db.UnsubscribeViolations()
+	case account.DatasetFollowUps:
+		return h.db.FollowUps()
+	case account.DatasetDeliveries:
+		return h.db.Deliveries()
+	case account.DatasetWebhookDeliveries:
+		return h.db.WebhookDeliveries()
+	case account.DatasetAPITokens:
+		return h.db.APITokens()
 	case account.DatasetUsage:
 		return h.db.Usage()
 	case account.DatasetActionLog:
 		return h.db.ActionLog()
 	case account.DatasetJobs:
 		return h.db.AnalysisJobs()
+	case account.DatasetAnalysisCache:
+		return h.db.UserAnalysisCache()
+	case account.DatasetSummaries:
+		return h.db.Summaries()
+	case account.DatasetEmbeddings:
+		return h.db.Embeddings()
+	case account.DatasetLabelProposals:
+		return h.db.LabelProposals()
 	}
 	return nil
 }
@@ -70,6 +90,11 @@ func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		rows := h.dumpUserRows(ctx, coll, userEmail)
+		if ds == account.DatasetAPITokens {
+			for _, row := range rows {
+				delete(row, "hash")
+			}
+		}
 		export[string(ds)] = rows
 	}
 
@@ -0,0 +1,21 @@
+package api
+
+import "strings"
+
+// AdminEmails lists the accounts allowed to call /api/admin/* endpoints.
+// Overridden from config (ADMIN_EMAILS) at startup; empty means no admins.
+var AdminEmails []string
+
+// isAdmin reports whether userEmail is on the admin allow-list
+// (case-insensitive).
+func isAdmin(userEmail string) bool {
+	if userEmail == "" {
+		return false
+	}
+	for _, a := range AdminEmails {
+		if strings.EqualFold(strings.TrimSpace(a), userEmail) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,12 +3,16 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -55,9 +59,11 @@ func (h *Handler) AnalyzeEmails(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"suggestions": suggestions,
-		"autoApplied": progress.AutoApplied,
-		"cachedHits":  progress.CachedHits,
+		"suggestions":    suggestions,
+		"autoApplied":    progress.AutoApplied,
+		"cachedHits":     progress.CachedHits,
+		"totalTokens":    progress.Usage.TotalTokens,
+		"budgetExceeded": progress.BudgetExceeded,
 	})
 }
 
@@ -77,32 +83,40 @@ func (h *Handler) autoApplySender(ctx context.Context, gmailClient *gmailapi.Ser
 		AppliedAt:  time.Now(),
 	}
 
-	var err error
-	switch pref.DefaultAction {
+	labelID, err := h.applyAction(ctx, gmailClient, userEmail, email.MessageID, pref.DefaultAction, pref.DefaultLabel)
+	if err != nil {
+		return false
+	}
+	suggestion.LabelID = labelID
+
+	h.db.AISuggestions().InsertOne(ctx, suggestion)
+	h.logAction(ctx, userEmail, email.MessageID, pref.DefaultAction, SourceAIAuto)
+	return true
+}
+
+// applyAction performs one suggestion-style action (archive, delete, label,
+// keep) on a message in Gmail, creating the label if needed. It returns the
+// Gmail label id for a label action. Every path that applies an AI verdict —
+// a click, a batch, the sender auto-pilot, the confidence autopilot — goes
+// through here.
+func (h *Handler) applyAction(ctx context.Context, gmailClient *gmailapi.Service, userEmail, messageID, action, labelName string) (string, error) {
+	switch action {
 	case "archive":
-		err = h.gmailService.ModifyMessage(gmailClient, email.MessageID, nil, []string{"INBOX"})
+		return "", h.gmailService.ModifyMessage(gmailClient, messageID, nil, []string{"INBOX"})
 	case "delete":
-		err = h.gmailService.ModifyMessage(gmailClient, email.MessageID, []string{"TRASH"}, nil)
+		return "", h.gmailService.ModifyMessage(gmailClient, messageID, []string{"TRASH"}, nil)
 	case "label":
-		labelID, lerr := h.ensureLabel(ctx, gmailClient, userEmail, pref.DefaultLabel)
-		if lerr != nil {
-			return false
+		labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, labelName)
+		if err != nil {
+			return "", fmt.Errorf("create label: %w", err)
 		}
-		suggestion.LabelID = labelID
-		err = h.gmailService.ModifyMessage(gmailClient, email.MessageID, []string{labelID}, nil)
+		return labelID, h.gmailService.ModifyMessage(gmailClient, messageID, []string{labelID}, nil)
 	case "keep":
 		// Nothing to mutate in Gmail.
+		return "", nil
 	default:
-		return false
-	}
-
-	if err != nil {
-		return false
+		return "", fmt.Errorf("unsupported action %q", action)
 	}
-
-	h.db.AISuggestions().InsertOne(ctx, suggestion)
-	h.logAction(ctx, userEmail, email.MessageID, pref.DefaultAction, SourceAIAuto)
-	return true
 }
 
 // AnalyzeSender analyzes all emails from a specific sender
@@ -148,11 +162,19 @@ func (h *Handler) AnalyzeSender(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A sender analysis is a pure model call; there is nothing to degrade to
+	// once the server's token budget is spent.
+	if h.tokenBudgetExceeded(ctx) {
+		http.Error(w, "Budget IA mensuel du serveur atteint — réessayez le mois prochain.", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Get existing labels
 	existingLabels, _ := h.getSmartLabelNames(ctx, userEmail)
 
 	// Analyze sender
-	analysis, err := h.aiClient.AnalyzeSender(req.SenderEmail, emails, existingLabels)
+	analysis, usage, err := h.aiClient.AnalyzeSender(req.SenderEmail, emails, existingLabels, h.userLocale(ctx, userEmail))
+	h.recordTokens(ctx, userEmail, usage)
 	if err != nil {
 		http.Error(w, "Failed to analyze sender: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -164,6 +186,7 @@ func (h *Handler) AnalyzeSender(w http.ResponseWriter, r *http.Request) {
 	// Create or update sender preference
 	senderPref := models.SenderPreference{
 		UserID:        userEmail,
+		SenderID:      h.senderID(ctx, userEmail, req.SenderEmail),
 		SenderEmail:   req.SenderEmail,
 		SenderDomain:  domain,
 		SenderName:    extractSenderName(emails[0].From),
@@ -234,29 +257,12 @@ func (h *Handler) ApplySuggestion(w http.ResponseWriter, r *http.Request) {
 
 	gmailClient := h.gmailService.GetClient(token)
 
-	// Apply action based on suggestion type
-	switch suggestion.Action {
-	case "archive":
-		err = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, nil, []string{"INBOX"})
-	case "delete":
-		err = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, []string{"TRASH"}, nil)
-	case "label":
-		// Ensure label exists and get its ID
-		labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, suggestion.LabelName)
-		if err != nil {
-			http.Error(w, "Failed to create label: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		err = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, []string{labelID}, nil)
-		suggestion.LabelID = labelID
-	case "keep":
-		// No action needed
-	}
-
+	labelID, err := h.applyAction(ctx, gmailClient, userEmail, suggestion.EmailID, suggestion.Action, suggestion.LabelName)
 	if err != nil {
 		http.Error(w, "Failed to apply action: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	suggestion.LabelID = labelID
 
 	// Update suggestion status
 	h.db.AISuggestions().UpdateOne(ctx,
@@ -326,33 +332,19 @@ func (h *Handler) ApplyBatch(w http.ResponseWriter, r *http.Request) {
 
 		// Shield protected senders from a bulk "apply all" that would archive
 		// or trash their mail. The suggestion is left pending, untouched.
-		if len(protectedList) > 0 && !allows(suggestion.Action, h.senderOf(ctx, userEmail, suggestion.EmailID), protectedList) {
-			protectedSkipped++
-			continue
-		}
-
-		var applyErr error
-		switch suggestion.Action {
-		case "archive":
-			applyErr = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, nil, []string{"INBOX"})
-		case "delete":
-			applyErr = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, []string{"TRASH"}, nil)
-		case "label":
-			labelID, lerr := h.ensureLabel(ctx, gmailClient, userEmail, suggestion.LabelName)
-			if lerr != nil {
-				applyErr = lerr
-				break
+		if len(protectedList) > 0 {
+			if email := h.storedEmail(ctx, userEmail, suggestion.EmailID); !allows(suggestion.Action, email.From, protectedList) {
+				protectedSkipped++
+				continue
 			}
-			applyErr = h.gmailService.ModifyMessage(gmailClient, suggestion.EmailID, []string{labelID}, nil)
-			suggestion.LabelID = labelID
-		case "keep":
-			// No Gmail mutation required.
 		}
 
+		labelID, applyErr := h.applyAction(ctx, gmailClient, userEmail, suggestion.EmailID, suggestion.Action, suggestion.LabelName)
 		if applyErr != nil {
 			failed++
 			continue
 		}
+		suggestion.LabelID = labelID
 
 		h.db.AISuggestions().UpdateOne(ctx,
 			bson.M{"_id": objectID},
@@ -462,7 +454,9 @@ func (h *Handler) ApplyBulk(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetSuggestions returns pending AI suggestions
+// GetSuggestions returns one page of the caller's AI suggestions (pending by
+// default), optionally filtered by action and sorted by confidence. The body
+// stays a bare array; the total matching count is sent in X-Total-Count.
 func (h *Handler) GetSuggestions(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -470,31 +464,40 @@ func (h *Handler) GetSuggestions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	q, err := parseSuggestionQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	status := r.URL.Query().Get("status")
-	if status == "" {
-		status = "pending"
+	filter := bson.M{
+		"userId": userEmail,
+		"status": q.Status,
+	}
+	if q.Action != "" {
+		filter["action"] = q.Action
 	}
 
-	cursor, err := h.db.AISuggestions().Find(ctx, bson.M{
-		"userId": userEmail,
-		"status": status,
-	}, options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(100))
+	cursor, err := h.db.AISuggestions().Find(ctx, filter,
+		options.Find().SetSort(q.Sort).SetSkip(q.Offset).SetLimit(q.Limit))
 	if err != nil {
 		http.Error(w, "Failed to fetch suggestions", http.StatusInternalServerError)
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var suggestions []models.AISuggestion
+	suggestions := make([]models.AISuggestion, 0)
 	if err := cursor.All(ctx, &suggestions); err != nil {
 		http.Error(w, "Failed to decode suggestions", http.StatusInternalServerError)
 		return
 	}
+	total, _ := h.db.AISuggestions().CountDocuments(ctx, filter)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
 	json.NewEncoder(w).Encode(suggestions)
 }
 
@@ -535,73 +538,6 @@ func (h *Handler) RejectSuggestion(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// GetSenders returns aggregated sender statistics
-func (h *Handler) GetSenders(w http.ResponseWriter, r *http.Request) {
-	userEmail := r.Header.Get("X-User-Email")
-	if userEmail == "" {
-		http.Error(w, "User email required", http.StatusUnauthorized)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	// Aggregate emails by sender
-	pipeline := []bson.M{
-		{"$match": bson.M{"userId": userEmail}},
-		{"$group": bson.M{
-			"_id":        "$from",
-			"emailCount": bson.M{"$sum": 1},
-			"lastEmail":  bson.M{"$max": "$receivedDate"},
-		}},
-		{"$sort": bson.M{"emailCount": -1}},
-		{"$limit": 50},
-	}
-
-	cursor, err := h.db.Emails().Aggregate(ctx, pipeline)
-	if err != nil {
-		http.Error(w, "Failed to aggregate senders", http.StatusInternalServerError)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		ID         string    `bson:"_id"`
-		EmailCount int       `bson:"emailCount"`
-		LastEmail  time.Time `bson:"lastEmail"`
-	}
-	if err := cursor.All(ctx, &results); err != nil {
-		http.Error(w, "Failed to decode results", http.StatusInternalServerError)
-		return
-	}
-
-	// Enrich with preferences
-	var senders []models.SenderStats
-	for _, r := range results {
-		sender := models.SenderStats{
-			SenderEmail:  r.ID,
-			SenderDomain: extractDomain(r.ID),
-			SenderName:   extractSenderName(r.ID),
-			EmailCount:   r.EmailCount,
-		}
-
-		// Check for existing preference
-		var pref models.SenderPreference
-		err := h.db.SenderPreferences().FindOne(ctx, bson.M{
-			"userId":      userEmail,
-			"senderEmail": r.ID,
-		}).Decode(&pref)
-		if err == nil {
-			sender.Preference = &pref
-		}
-
-		senders = append(senders, sender)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(senders)
-}
-
 // UpdateSenderPreference updates auto-apply settings for a sender
 func (h *Handler) UpdateSenderPreference(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
@@ -678,7 +614,8 @@ func (h *Handler) GetSmartLabels(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(labels)
 }
 
-// CreateSmartLabel creates a new smart label manually
+// CreateSmartLabel creates a new smart label manually. The name may be a nested
+// path ("Finance/Factures"); missing parents are created in Gmail first.
 func (h *Handler) CreateSmartLabel(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -691,10 +628,17 @@ func (h *Handler) CreateSmartLabel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if label.Name == "" {
-		http.Error(w, "Label name required", http.StatusBadRequest)
+	label.Name = labelpath.Clean(label.Name)
+	if err := labelpath.Validate(label.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	style, err := labelStyle(label.Color, label.LabelListVisibility, label.MessageListVisibility)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	label.Color, label.TextColor = style.BackgroundColor, style.TextColor
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -708,12 +652,15 @@ func (h *Handler) CreateSmartLabel(w http.ResponseWriter, r *http.Request) {
 
 	gmailClient := h.gmailService.GetClient(token)
 
-	// Create Gmail label
-	gmailLabelID, err := h.gmailService.CreateLabel(gmailClient, label.Name)
+	// Create Gmail label (an existing one is restyled instead)
+	gmailLabelID, err := h.gmailService.CreateLabelWithStyle(gmailClient, label.Name, style)
 	if err != nil {
 		http.Error(w, "Failed to create Gmail label: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if style != (gmail.LabelStyle{}) {
+		h.gmailService.UpdateLabelStyle(gmailClient, gmailLabelID, style)
+	}
 
 	label.UserID = userEmail
 	label.GmailLabelID = gmailLabelID
@@ -754,15 +701,13 @@ func (h *Handler) getSmartLabelNames(ctx context.Context, userEmail string) ([]s
 	return names, nil
 }
 
-// senderOf returns the stored From of a message, or "" if unknown. Used to
-// consult the protected list when applying AI suggestions, which don't carry the
-// sender themselves.
-func (h *Handler) senderOf(ctx context.Context, userEmail, messageID string) string {
+// storedEmail returns the stored copy of a message, or a zero Email if unknown.
+// Used to consult the protected list when applying AI suggestions, which carry
+// neither the sender nor its risk themselves.
+func (h *Handler) storedEmail(ctx context.Context, userEmail, messageID string) models.Email {
 	var e models.Email
-	if err := h.db.Emails().FindOne(ctx, bson.M{"userId": userEmail, "messageId": messageID}).Decode(&e); err == nil {
-		return e.From
-	}
-	return ""
+	h.db.Emails().FindOne(ctx, bson.M{"userId": userEmail, "messageId": messageID}).Decode(&e)
+	return e
 }
 
 func (h *Handler) getUserToken(ctx context.Context, userEmail string) (*oauth2.Token, error) {
@@ -796,7 +741,14 @@ func (h *Handler) getUserToken(ctx context.Context, userEmail string) (*oauth2.T
 	return token, nil
 }
 
+// ensureLabel returns the Gmail id of the smart label labelName (a name or a
+// nested path), creating the Gmail label — parents first — and the smart label
+// when missing.
 func (h *Handler) ensureLabel(ctx context.Context, gmailClient interface{}, userEmail, labelName string) (string, error) {
+	labelName = labelpath.Clean(labelName)
+	if err := labelpath.Validate(labelName); err != nil {
+		return "", err
+	}
 	// Check if we already have this smart label
 	var smartLabel models.SmartLabel
 	err := h.db.SmartLabels().FindOne(ctx, bson.M{
@@ -804,7 +756,7 @@ func (h *Handler) ensureLabel(ctx context.Context, gmailClient interface{}, user
 		"name":   labelName,
 	}).Decode(&smartLabel)
 
-	if err == nil {
+	if err == nil && smartLabel.GmailLabelID != "" {
 		return smartLabel.GmailLabelID, nil
 	}
 
@@ -813,6 +765,13 @@ func (h *Handler) ensureLabel(ctx context.Context, gmailClient interface{}, user
 	if err != nil {
 		return "", err
 	}
+	if smartLabel.ID != "" {
+		// The Gmail label was deleted behind our back: recreate and relink it.
+		h.db.SmartLabels().UpdateOne(ctx,
+			bson.M{"userId": userEmail, "name": labelName},
+			bson.M{"$set": bson.M{"gmailLabelId": gmailLabelID, "updatedAt": time.Now()}})
+		return gmailLabelID, nil
+	}
 
 	// Save as smart label
 	newLabel := models.SmartLabel{
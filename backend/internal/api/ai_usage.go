@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MonthlyTokenBudget caps the AI tokens the whole server may spend per calendar
+// month (UTC). Once reached, analysis degrades to sender auto-pilot, rules and
+// cache — no new model calls — until the next period. 0 means unlimited.
+// Overridden from config at startup.
+var MonthlyTokenBudget int64
+
+// serverUsageID is the userId of the per-period usage document that aggregates
+// every account's spend. It can never collide with a real (email) userId.
+const serverUsageID = "_server"
+
+// tokenTotals is the token/cost slice of a usage document.
+type tokenTotals struct {
+	PromptTokens     int64   `json:"promptTokens" bson:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens" bson:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens" bson:"totalTokens"`
+	CostUSD          float64 `json:"costUsd" bson:"costUsd"`
+}
+
+// usageModelKey turns a model name into a safe Mongo field name: dots and
+// dollar signs would otherwise be read as a path or an operator.
+func usageModelKey(model string) string {
+	if model == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer(".", "_", "$", "_").Replace(model)
+}
+
+// usageTokenInc builds the $inc document for one AI call: flat totals plus the
+// same counters broken down under byModel.<model>.
+func usageTokenInc(model string, u ai.Usage) bson.M {
+	cost := ai.CostUSD(model, u)
+	m := "byModel." + usageModelKey(model) + "."
+	return bson.M{
+		"promptTokens":         u.PromptTokens,
+		"completionTokens":     u.CompletionTokens,
+		"totalTokens":          u.TotalTokens,
+		"costUsd":              cost,
+		m + "promptTokens":     u.PromptTokens,
+		m + "completionTokens": u.CompletionTokens,
+		m + "totalTokens":      u.TotalTokens,
+		m + "costUsd":          cost,
+	}
+}
+
+// recordTokens charges one AI call's usage to the caller's period document and
+// to the server-wide one that the monthly budget is enforced against.
+func (h *Handler) recordTokens(ctx context.Context, userEmail string, u ai.Usage) {
+	if h.aiClient == nil {
+		return
+	}
+	h.recordModelTokens(ctx, userEmail, h.aiClient.Model(), u)
+}
+
+// recordModelTokens is recordTokens for a call made to another model than the
+// chat one — the embedding provider's.
+func (h *Handler) recordModelTokens(ctx context.Context, userEmail, model string, u ai.Usage) {
+	if u.IsZero() {
+		return
+	}
+	inc := usageTokenInc(model, u)
+	period := currentPeriod()
+	for _, id := range []string{userEmail, serverUsageID} {
+		h.db.Usage().UpdateOne(ctx,
+			bson.M{"userId": id, "period": period},
+			bson.M{"$inc": inc, "$set": bson.M{"updatedAt": time.Now()}},
+			options.Update().SetUpsert(true),
+		)
+	}
+}
+
+// getTokenTotals reads the token/cost counters of a usage document for the
+// current period (zero when none exists yet).
+func (h *Handler) getTokenTotals(ctx context.Context, userID string) tokenTotals {
+	var t tokenTotals
+	h.db.Usage().FindOne(ctx, bson.M{"userId": userID, "period": currentPeriod()}).Decode(&t)
+	return t
+}
+
+// tokenBudgetExceeded reports whether the server has spent its monthly token
+// budget. It fails open: a read error never blocks analysis.
+func (h *Handler) tokenBudgetExceeded(ctx context.Context) bool {
+	if MonthlyTokenBudget <= 0 {
+		return false
+	}
+	return h.getTokenTotals(ctx, serverUsageID).TotalTokens >= MonthlyTokenBudget
+}
+
+// AdminGetUsage reports the server's spend for a period (default: current), the
+// per-model breakdown and the configured budget, so operators can see what the
+// AI costs before the bill does.
+func (h *Handler) AdminGetUsage(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	if !isAdmin(userEmail) {
+		writeError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = currentPeriod()
+	}
+	if _, err := time.Parse("2006-01", period); err != nil {
+		writeError(w, http.StatusBadRequest, "period must be YYYY-MM")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc struct {
+		tokenTotals `bson:",inline"`
+		ByModel     map[string]tokenTotals `bson:"byModel"`
+	}
+	h.db.Usage().FindOne(ctx, bson.M{"userId": serverUsageID, "period": period}).Decode(&doc)
+	if doc.ByModel == nil {
+		doc.ByModel = map[string]tokenTotals{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"period":         period,
+		"tokens":         doc.tokenTotals,
+		"byModel":        doc.ByModel,
+		"budget":         MonthlyTokenBudget,
+		"budgetExceeded": MonthlyTokenBudget > 0 && doc.TotalTokens >= MonthlyTokenBudget,
+	})
+}
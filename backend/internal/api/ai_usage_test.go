@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+)
+
+func TestUsageModelKey(t *testing.T) {
+	cases := map[string]string{
+		"mistral-small-latest": "mistral-small-latest",
+		"open-mixtral-8x7b.v2": "open-mixtral-8x7b_v2", // dots would nest the path
+		"$weird":               "_weird",
+		"":                     "unknown",
+	}
+	for in, want := range cases {
+		if got := usageModelKey(in); got != want {
+			t.Errorf("usageModelKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUsageTokenIncBreaksDownByModel(t *testing.T) {
+	inc := usageTokenInc("mistral-small-latest", ai.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120})
+	if inc["totalTokens"] != 120 || inc["byModel.mistral-small-latest.totalTokens"] != 120 {
+		t.Fatalf("totals not mirrored per model: %v", inc)
+	}
+	if inc["promptTokens"] != 100 || inc["byModel.mistral-small-latest.completionTokens"] != 20 {
+		t.Fatalf("prompt/completion split lost: %v", inc)
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/risk"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -24,13 +27,19 @@ type analysisProgress struct {
 	AutoApplied        int
 	SuggestionsCreated int
 	CachedHits         int
-	Analyzed           int // emails that actually hit the AI (counts toward quota)
+	Analyzed           int      // emails that actually hit the AI (counts toward quota)
+	Usage              ai.Usage // tokens consumed by this run's AI calls
+	BudgetExceeded     bool     // the server token budget cut the AI pass short
 }
 
 // runAnalysis is the shared engine behind both the synchronous endpoint and the
 // async worker. It auto-applies sender preferences, serves cached verdicts, and
-// batches the remaining emails through the AI. onProgress (nullable) is called
-// after every email so callers can stream progress.
+// batches the remaining emails through the AI. A verdict that clears the user's
+// autopilot threshold for its action is applied at once rather than left
+// pending. onProgress (nullable) is called
+// after every email so callers can stream progress. Once the server-wide token
+// budget is spent, the AI pass is skipped: auto-pilot and cache still resolve
+// what they can and the rest is left for a later run.
 func (h *Handler) runAnalysis(
 	ctx context.Context,
 	userEmail string,
@@ -42,6 +51,9 @@ func (h *Handler) runAnalysis(
 
 	existingLabels, _ := h.getSmartLabelNames(ctx, userEmail)
 	protectedList := h.protectedValues(ctx, userEmail)
+	loc := h.userLocale(ctx, userEmail)
+	version := h.analysisVersion(loc)
+	thresholds := h.userSettings(ctx, userEmail).Autopilot
 
 	emails := make([]models.Email, 0, len(emailIDs))
 	for _, id := range emailIDs {
@@ -61,7 +73,7 @@ func (h *Handler) runAnalysis(
 		}
 	}
 
-	// Best-effort Gmail client for sender auto-pilot.
+	// Best-effort Gmail client for sender and threshold auto-pilot.
 	var gmailClient *gmailapi.Service
 	if token, terr := h.getUserToken(ctx, userEmail); terr == nil {
 		gmailClient = h.gmailService.GetClient(token)
@@ -74,7 +86,7 @@ func (h *Handler) runAnalysis(
 			var pref models.SenderPreference
 			err := h.db.SenderPreferences().FindOne(ctx, bson.M{
 				"userId":      userEmail,
-				"senderEmail": email.From,
+				"senderEmail": bson.M{"$in": []string{email.From, senderAddress(email.From)}},
 				"autoApply":   true,
 			}).Decode(&pref)
 			// A protected sender is never auto-archived/trashed by the sender
@@ -88,12 +100,22 @@ func (h *Handler) runAnalysis(
 			}
 		}
 
-		key := analysisCacheKey(email.From, email.Subject)
-		if cached, ok := h.cacheLookup(ctx, key); ok {
+		// A risky email is always analyzed afresh: the model is warned about
+		// it, and a spoofed sender must not reuse the real sender's verdict.
+		var cached ai.EmailAnalysis
+		ok := false
+		if email.Risk < risk.High {
+			cached, ok = h.cacheLookup(ctx, userEmail, analysisCacheKey(version, email.From, email.Subject))
+		}
+		if ok {
 			cached = protectAnalysis(cached, email.From, protectedList)
 			if s, inserted := h.persistSuggestion(ctx, userEmail, email, cached, existingLabels); inserted {
-				suggestions = append(suggestions, s)
-				p.SuggestionsCreated++
+				if h.applyAboveThreshold(ctx, gmailClient, userEmail, thresholds, protectedList, email, s) {
+					p.AutoApplied++
+				} else {
+					suggestions = append(suggestions, s)
+					p.SuggestionsCreated++
+				}
 			}
 			p.CachedHits++
 			p.Processed++
@@ -109,6 +131,12 @@ func (h *Handler) runAnalysis(
 		if ctx.Err() != nil {
 			break
 		}
+		if h.tokenBudgetExceeded(ctx) {
+			p.BudgetExceeded = true
+			p.Processed += len(pending) - i
+			report()
+			break
+		}
 		end := i + analysisBatchSize
 		if end > len(pending) {
 			end = len(pending)
@@ -117,7 +145,9 @@ func (h *Handler) runAnalysis(
 
 		var analyses []ai.EmailAnalysis
 		if h.aiClient != nil {
-			if res, err := h.aiClient.AnalyzeBatch(chunk, existingLabels); err == nil {
+			res, usage, err := h.aiClient.AnalyzeBatch(chunk, existingLabels, loc)
+			h.chargeTokens(ctx, userEmail, &p, usage)
+			if err == nil {
 				analyses = res
 			}
 		}
@@ -129,7 +159,8 @@ func (h *Handler) runAnalysis(
 				a = analyses[j]
 			case h.aiClient != nil:
 				// Batch failed to align — fall back to a single-email call.
-				single, err := h.aiClient.AnalyzeEmail(email, existingLabels)
+				single, usage, err := h.aiClient.AnalyzeEmail(email, existingLabels, loc)
+				h.chargeTokens(ctx, userEmail, &p, usage)
 				if err != nil {
 					p.Processed++
 					report()
@@ -143,13 +174,20 @@ func (h *Handler) runAnalysis(
 			}
 
 			p.Analyzed++
-			// Cache the model's raw verdict, but never persist a destructive
-			// suggestion for a protected sender.
-			h.cacheStore(ctx, analysisCacheKey(email.From, email.Subject), a)
+			// Cache the model's raw verdict (unless it was warned about this
+			// email's risk), but never persist a destructive suggestion for a
+			// protected sender.
+			if email.Risk < risk.High {
+				h.cacheStore(ctx, userEmail, version, analysisCacheKey(version, email.From, email.Subject), a)
+			}
 			a = protectAnalysis(a, email.From, protectedList)
 			if s, inserted := h.persistSuggestion(ctx, userEmail, email, a, existingLabels); inserted {
-				suggestions = append(suggestions, s)
-				p.SuggestionsCreated++
+				if h.applyAboveThreshold(ctx, gmailClient, userEmail, thresholds, protectedList, email, s) {
+					p.AutoApplied++
+				} else {
+					suggestions = append(suggestions, s)
+					p.SuggestionsCreated++
+				}
 			}
 			p.Processed++
 			report()
@@ -162,6 +200,13 @@ func (h *Handler) runAnalysis(
 	return p, suggestions, nil
 }
 
+// chargeTokens adds one AI call's usage to the run's progress and records it
+// against the user's and the server's period totals.
+func (h *Handler) chargeTokens(ctx context.Context, userEmail string, p *analysisProgress, u ai.Usage) {
+	p.Usage = p.Usage.Add(u)
+	h.recordTokens(ctx, userEmail, u)
+}
+
 // protectAnalysis downgrades a destructive AI verdict to "keep" when the sender
 // is on the user's protected list, so a VIP's mail is never suggested for
 // archive/trash. Non-destructive verdicts (label/keep) pass through untouched.
@@ -179,6 +224,8 @@ func protectAnalysis(a ai.EmailAnalysis, from string, protectedList []string) ai
 }
 
 // persistSuggestion resolves the label, inserts a pending suggestion and returns it.
+// A message keeps at most one pending suggestion: when it already has one, that
+// one is returned with inserted=false.
 func (h *Handler) persistSuggestion(
 	ctx context.Context,
 	userEmail string,
@@ -186,6 +233,13 @@ func (h *Handler) persistSuggestion(
 	a ai.EmailAnalysis,
 	existingLabels []string,
 ) (models.AISuggestion, bool) {
+	var existing models.AISuggestion
+	if err := h.db.AISuggestions().FindOne(ctx, bson.M{
+		"userId": userEmail, "emailId": email.MessageID, "status": "pending",
+	}).Decode(&existing); err == nil {
+		return existing, false
+	}
+
 	suggestion := models.AISuggestion{
 		UserID:     userEmail,
 		EmailID:    email.MessageID,
@@ -215,26 +269,83 @@ func (h *Handler) persistSuggestion(
 }
 
 // localMatchLabel maps a suggested label onto an existing one without an AI call.
+// Labels may be nested paths: an exact path match wins, then a match on the
+// last level ("Factures" for an existing "Finance/Factures"), then a substring
+// of that level; failing that, a label that names the same taxonomy entry in
+// another language ("Invoices" for an existing "Factures") is reused rather
+// than creating a duplicate.
 func localMatchLabel(suggested string, existing []string) string {
-	s := strings.ToLower(strings.TrimSpace(suggested))
+	s := strings.ToLower(labelpath.Clean(suggested))
+	sLeaf := labelpath.Leaf(s)
+	leaf := func(e string) string { return strings.ToLower(labelpath.Leaf(labelpath.Clean(e))) }
+	for _, e := range existing {
+		if strings.ToLower(labelpath.Clean(e)) == s {
+			return e
+		}
+	}
+	for _, e := range existing {
+		if leaf(e) == sLeaf {
+			return e
+		}
+	}
+	for _, e := range existing {
+		if le := leaf(e); le != "" && sLeaf != "" && (strings.Contains(le, sLeaf) || strings.Contains(sLeaf, le)) {
+			return e
+		}
+	}
 	for _, e := range existing {
-		le := strings.ToLower(strings.TrimSpace(e))
-		if le == s || strings.Contains(le, s) || strings.Contains(s, le) {
+		if locale.SameConcept(labelpath.Leaf(suggested), labelpath.Leaf(e)) {
 			return e
 		}
 	}
 	return suggested
 }
 
-func analysisCacheKey(from, subject string) string {
-	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(from)) + "|" + strings.ToLower(strings.TrimSpace(subject))))
+// UserCacheTTL and GlobalCacheTTL bound how long a cached verdict is served from
+// the per-user overlay and the shared global tier. Overridden from config at
+// startup.
+var (
+	UserCacheTTL   = 30 * 24 * time.Hour
+	GlobalCacheTTL = 7 * 24 * time.Hour
+)
+
+// analysisVersion is the model + prompt fingerprint folded into cache keys, so
+// switching model, bumping the prompt or changing locale naturally invalidates
+// old verdicts.
+func (h *Handler) analysisVersion(loc string) string {
+	if h.aiClient == nil {
+		return ai.PromptVersion(loc)
+	}
+	return h.aiClient.CacheVersion(loc)
+}
+
+func analysisCacheKey(version, from, subject string) string {
+	sum := sha256.Sum256([]byte(version + "|" + strings.ToLower(strings.TrimSpace(from)) + "|" + strings.ToLower(strings.TrimSpace(subject))))
 	return hex.EncodeToString(sum[:])
 }
 
-func (h *Handler) cacheLookup(ctx context.Context, key string) (ai.EmailAnalysis, bool) {
+// globalCacheable reports whether a verdict may be shared across users. Only
+// action verdicts qualify: a label verdict names the user's own taxonomy, and
+// an empty action carries no information worth sharing.
+func globalCacheable(a ai.EmailAnalysis) bool {
+	return a.Action != "" && a.Action != "label"
+}
+
+// cacheLookup consults the caller's overlay first, then the shared global tier.
+func (h *Handler) cacheLookup(ctx context.Context, userEmail, key string) (ai.EmailAnalysis, bool) {
+	now := time.Now()
 	var e models.AnalysisCacheEntry
-	if err := h.db.AnalysisCache().FindOne(ctx, bson.M{"key": key}).Decode(&e); err != nil {
-		return ai.EmailAnalysis{}, false
+	// Filtering on expiresAt too hides entries the TTL monitor (which runs about
+	// once a minute) has not reaped yet.
+	err := h.db.UserAnalysisCache().FindOne(ctx, bson.M{"userId": userEmail, "key": key, "expiresAt": bson.M{"$gt": now}}).Decode(&e)
+	if err != nil {
+		if err := h.db.AnalysisCache().FindOne(ctx, bson.M{"key": key, "expiresAt": bson.M{"$gt": now}}).Decode(&e); err != nil {
+			return ai.EmailAnalysis{}, false
+		}
+		// Shared entries never carry a label or the model's reasoning, which
+		// may quote the email it was written about; entries stored before
+		// that rule are scrubbed here.
+		e.LabelName, e.Reasoning = "", ""
 	}
 	return ai.EmailAnalysis{
 		Action:     e.Action,
@@ -244,16 +355,40 @@ func (h *Handler) cacheLookup(ctx context.Context, key string) (ai.EmailAnalysis
 	}, true
 }
 
-func (h *Handler) cacheStore(ctx context.Context, key string, a ai.EmailAnalysis) {
-	h.db.AnalysisCache().UpdateOne(ctx,
-		bson.M{"key": key},
+// cacheStore records a fresh verdict in the caller's overlay and, when it is an
+// action-only verdict, in the global tier as well, without its reasoning.
+func (h *Handler) cacheStore(ctx context.Context, userEmail, version, key string, a ai.EmailAnalysis) {
+	now := time.Now()
+	h.db.UserAnalysisCache().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "key": key},
 		bson.M{"$set": bson.M{
+			"userId":     userEmail,
 			"key":        key,
+			"version":    version,
 			"action":     a.Action,
 			"labelName":  a.LabelName,
 			"confidence": a.Confidence,
 			"reasoning":  a.Reasoning,
-			"createdAt":  time.Now(),
+			"createdAt":  now,
+			"expiresAt":  now.Add(UserCacheTTL),
+		}},
+		options.Update().SetUpsert(true),
+	)
+
+	if !globalCacheable(a) {
+		return
+	}
+	h.db.AnalysisCache().UpdateOne(ctx,
+		bson.M{"key": key},
+		bson.M{"$set": bson.M{
+			"key":        key,
+			"version":    version,
+			"action":     a.Action,
+			"labelName":  "",
+			"confidence": a.Confidence,
+			"reasoning":  "",
+			"createdAt":  now,
+			"expiresAt":  now.Add(GlobalCacheTTL),
 		}},
 		options.Update().SetUpsert(true),
 	)
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PurgeAnalysisCache drops the caller's own cache overlay, so the next analysis
+// re-asks the AI instead of replaying verdicts they no longer agree with. The
+// shared global tier is untouched (it holds no user data).
+func (h *Handler) PurgeAnalysisCache(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := h.db.UserAnalysisCache().DeleteMany(ctx, bson.M{"userId": userEmail})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to purge analysis cache")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": res.DeletedCount})
+}
+
+// AdminPurgeAnalysisCache drops the shared global tier. ?scope=all also wipes
+// every user overlay, e.g. after a prompt regression that versioning alone did
+// not catch.
+func (h *Handler) AdminPurgeAnalysisCache(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	if !isAdmin(userEmail) {
+		writeError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = "global"
+	}
+	if scope != "global" && scope != "all" {
+		writeError(w, http.StatusBadRequest, "scope must be global or all")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	global, err := h.db.AnalysisCache().DeleteMany(ctx, bson.M{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to purge analysis cache")
+		return
+	}
+	out := map[string]interface{}{"scope": scope, "global": global.DeletedCount}
+	if scope == "all" {
+		users, err := h.db.UserAnalysisCache().DeleteMany(ctx, bson.M{})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to purge analysis cache")
+			return
+		}
+		out["user"] = users.DeletedCount
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -1,29 +1,57 @@
 package api
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+)
 
 func TestAnalysisCacheKeyIsStable(t *testing.T) {
-	a := analysisCacheKey("Sender <s@x.com>", "Hello")
-	b := analysisCacheKey("Sender <s@x.com>", "Hello")
+	a := analysisCacheKey("v1", "Sender <s@x.com>", "Hello")
+	b := analysisCacheKey("v1", "Sender <s@x.com>", "Hello")
 	if a != b {
 		t.Fatal("cache key should be deterministic for identical input")
 	}
 }
 
 func TestAnalysisCacheKeyNormalizesCaseAndSpace(t *testing.T) {
-	a := analysisCacheKey("Sender <s@x.com>", "Hello")
-	b := analysisCacheKey("  sender <S@X.COM>  ", "  HELLO  ")
+	a := analysisCacheKey("v1", "Sender <s@x.com>", "Hello")
+	b := analysisCacheKey("v1", "  sender <S@X.COM>  ", "  HELLO  ")
 	if a != b {
 		t.Fatal("cache key should ignore case and surrounding whitespace")
 	}
 }
 
 func TestAnalysisCacheKeyDiffersForDifferentInput(t *testing.T) {
-	if analysisCacheKey("a@x.com", "Subject A") == analysisCacheKey("a@x.com", "Subject B") {
+	if analysisCacheKey("v1", "a@x.com", "Subject A") == analysisCacheKey("v1", "a@x.com", "Subject B") {
 		t.Fatal("different subjects must produce different keys")
 	}
 }
 
+func TestAnalysisCacheKeyDiffersByVersion(t *testing.T) {
+	if analysisCacheKey("m/p1", "a@x.com", "Hi") == analysisCacheKey("m/p2", "a@x.com", "Hi") {
+		t.Fatal("a prompt/model bump must produce a different key")
+	}
+}
+
+func TestGlobalCacheable(t *testing.T) {
+	cases := []struct {
+		action string
+		want   bool
+	}{
+		{"archive", true},
+		{"trash", true},
+		{"keep", true},
+		{"label", false}, // user taxonomy never shared
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := globalCacheable(ai.EmailAnalysis{Action: c.action}); got != c.want {
+			t.Errorf("globalCacheable(%q) = %v, want %v", c.action, got, c.want)
+		}
+	}
+}
+
 func TestLocalMatchLabel(t *testing.T) {
 	existing := []string{"Newsletters", "Factures", "Travail"}
 	cases := []struct {
@@ -35,6 +63,29 @@ func TestLocalMatchLabel(t *testing.T) {
 		{"Factures", "Factures"},       // exact
 		{"Voyages", "Voyages"},         // no match -> returned as-is
 		{"  travail ", "Travail"},      // trimmed + case
+		{"Invoices", "Factures"},       // cross-language synonym
+		{"Arbeit", "Travail"},          // cross-language synonym
+		{"Projects", "Projects"},       // outside the taxonomy -> as-is
+	}
+	for _, c := range cases {
+		if got := localMatchLabel(c.suggested, existing); got != c.want {
+			t.Errorf("localMatchLabel(%q) = %q, want %q", c.suggested, got, c.want)
+		}
+	}
+}
+
+func TestLocalMatchLabelNested(t *testing.T) {
+	existing := []string{"Finance/Factures", "Finance", "Perso/Voyages"}
+	cases := []struct {
+		suggested string
+		want      string
+	}{
+		{"Factures", "Finance/Factures"},           // leaf match
+		{"finance / factures", "Finance/Factures"}, // path, cleaned
+		{"Finance", "Finance"},                     // the parent itself
+		{"Invoices", "Finance/Factures"},           // cross-language leaf
+		{"Travel", "Perso/Voyages"},                // cross-language leaf
+		{"Maison/Travaux", "Maison/Travaux"},       // new nested label -> as-is
 	}
 	for _, c := range cases {
 		if got := localMatchLabel(c.suggested, existing); got != c.want {
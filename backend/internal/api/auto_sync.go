@@ -26,7 +26,8 @@ func (h *Handler) startAutoSyncLoop() {
 	go func() {
 		ticker := time.NewTicker(autoSyncSweepInterval)
 		defer ticker.Stop()
-		for range ticker.C {
+		for now := range ticker.C {
+			h.sentHistory.sweep(now)
 			h.runDueAutoSyncs()
 		}
 	}()
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// minAutopilotThreshold is the lowest non-zero threshold a user may set: below
+// it the model is wrong too often for an unreviewed action.
+const minAutopilotThreshold = 0.5
+
+// validateAutopilot checks that every threshold is 0 (off) or within
+// [minAutopilotThreshold, 1].
+func validateAutopilot(t models.AutopilotThresholds) error {
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"label", t.Label}, {"archive", t.Archive}, {"delete", t.Delete}} {
+		if f.v != 0 && (f.v < minAutopilotThreshold || f.v > 1) {
+			return fmt.Errorf("autopilot.%s must be 0 (off) or between %.1f and 1", f.name, minAutopilotThreshold)
+		}
+	}
+	return nil
+}
+
+// passesThreshold reports whether an AI verdict is confident enough to apply
+// without review. "keep" never is: it changes nothing, so it stays a
+// suggestion the user can confirm.
+func passesThreshold(t models.AutopilotThresholds, action string, confidence float64) bool {
+	var min float64
+	switch action {
+	case "label":
+		min = t.Label
+	case "archive":
+		min = t.Archive
+	case "delete":
+		min = t.Delete
+	}
+	return min > 0 && confidence >= min
+}
+
+// applyAboveThreshold applies a freshly persisted pending suggestion when it
+// clears the user's threshold for its action, marking it applied and logging
+// it under SourceAIThreshold. A protected sender is never touched; on any
+// failure the suggestion simply stays pending.
+func (h *Handler) applyAboveThreshold(
+	ctx context.Context,
+	gmailClient *gmailapi.Service,
+	userEmail string,
+	thresholds models.AutopilotThresholds,
+	protectedList []string,
+	email models.Email,
+	s models.AISuggestion,
+) bool {
+	if gmailClient == nil || !passesThreshold(thresholds, s.Action, s.Confidence) ||
+		!allows(s.Action, email.From, protectedList) {
+		return false
+	}
+	oid, err := primitive.ObjectIDFromHex(s.ID)
+	if err != nil {
+		return false
+	}
+	labelID, err := h.applyAction(ctx, gmailClient, userEmail, s.EmailID, s.Action, s.LabelName)
+	if err != nil {
+		return false
+	}
+	h.db.AISuggestions().UpdateOne(ctx,
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{
+			"status":    "applied",
+			"appliedAt": time.Now(),
+			"labelId":   labelID,
+		}},
+	)
+	h.logAction(ctx, userEmail, s.EmailID, s.Action, SourceAIThreshold)
+	return true
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestPassesThreshold(t *testing.T) {
+	th := models.AutopilotThresholds{Label: 0.9, Archive: 0.95}
+	cases := []struct {
+		action     string
+		confidence float64
+		want       bool
+	}{
+		{"label", 0.9, true},
+		{"label", 0.89, false},
+		{"archive", 0.98, true},
+		{"archive", 0.94, false},
+		{"delete", 1.0, false}, // 0 = never
+		{"keep", 1.0, false},
+	}
+	for _, c := range cases {
+		if got := passesThreshold(th, c.action, c.confidence); got != c.want {
+			t.Errorf("passesThreshold(%s, %.2f) = %v, want %v", c.action, c.confidence, got, c.want)
+		}
+	}
+}
+
+func TestValidateAutopilot(t *testing.T) {
+	if err := validateAutopilot(models.AutopilotThresholds{Label: 0.9, Archive: 1}); err != nil {
+		t.Errorf("valid thresholds rejected: %v", err)
+	}
+	for _, bad := range []models.AutopilotThresholds{{Label: 0.3}, {Archive: 1.2}, {Delete: -0.5}} {
+		if validateAutopilot(bad) == nil {
+			t.Errorf("validateAutopilot(%+v) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,517 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/cluster"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/embed"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/locale"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobKindCluster marks an AnalysisJob that clusters the inbox instead of
+// analyzing a list of emails.
+const jobKindCluster = "cluster"
+
+// clusterEmailCap bounds how many recent emails one clustering run embeds.
+const clusterEmailCap = 1000
+
+// proposalSamples is how many representative emails a proposal shows.
+const proposalSamples = 5
+
+// ruleShare is the minimum fraction of a cluster a sender domain or subject
+// term must cover before it is offered as a rule condition.
+const ruleShare = 0.8
+
+// Proposal statuses.
+const (
+	proposalPending   = "pending"
+	proposalAccepted  = "accepted"
+	proposalDismissed = "dismissed"
+)
+
+var errNoEmbedder = errors.New("embeddings provider not configured")
+
+// EnqueueClustering starts an async job that embeds the caller's recent,
+// not-yet-labelled emails, clusters them, and replaces their pending label
+// proposals. Poll it with GET /api/ai/jobs/{id}.
+func (h *Handler) EnqueueClustering(w http.ResponseWriter, r *http.Request) {
+	if h.embedder == nil {
+		writeError(w, http.StatusServiceUnavailable, "Embeddings not configured")
+		return
+	}
+
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if h.tokenBudgetExceeded(ctx) {
+		writeError(w, http.StatusServiceUnavailable, "Budget IA mensuel du serveur atteint — réessayez le mois prochain.")
+		return
+	}
+
+	job := models.AnalysisJob{
+		UserID:    userEmail,
+		Kind:      jobKindCluster,
+		Status:    "queued",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	res, err := h.db.AnalysisJobs().InsertOne(ctx, job)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create job")
+		return
+	}
+	jobID := res.InsertedID.(primitive.ObjectID).Hex()
+
+	select {
+	case h.jobQueue <- jobID:
+	default:
+		go h.processAnalysisJob(jobID)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"jobId": jobID, "status": "queued"})
+}
+
+// processClusterJob runs a clustering job to completion, mirroring progress
+// and embedding spend onto the job document.
+func (h *Handler) processClusterJob(ctx context.Context, id primitive.ObjectID, job models.AnalysisJob) {
+	var usage ai.Usage
+	fields := func(total, processed, proposals int) bson.M {
+		model := ""
+		if h.embedder != nil {
+			model = h.embedder.Model()
+		}
+		return bson.M{
+			"total":              total,
+			"processed":          processed,
+			"suggestionsCreated": proposals,
+			"model":              model,
+			"promptTokens":       usage.PromptTokens,
+			"totalTokens":        usage.TotalTokens,
+			"costUsd":            ai.CostUSD(model, usage),
+			"updatedAt":          time.Now(),
+		}
+	}
+
+	total, proposals, err := h.runClustering(ctx, job.UserID, &usage, func(total, processed int) {
+		h.updateJob(ctx, id, fields(total, processed, 0))
+	})
+
+	final := fields(total, total, proposals)
+	if err != nil {
+		final["status"] = "error"
+		final["error"] = err.Error()
+		log.Printf("cluster job %s failed: %v", id.Hex(), err)
+	} else {
+		final["status"] = "done"
+	}
+	h.updateJob(ctx, id, final)
+}
+
+// runClustering embeds (reusing stored vectors), clusters and stores the
+// resulting proposals. It returns how many emails were considered and how
+// many proposals were created; usage accumulates the embedding tokens spent.
+func (h *Handler) runClustering(ctx context.Context, userEmail string, usage *ai.Usage, onProgress func(total, processed int)) (int, int, error) {
+	if h.embedder == nil {
+		return 0, 0, errNoEmbedder
+	}
+
+	var smart []models.SmartLabel
+	if cur, err := h.db.SmartLabels().Find(ctx, bson.M{"userId": userEmail}); err == nil {
+		cur.All(ctx, &smart)
+	}
+	labelled := map[string]bool{}
+	existing := make([]string, 0, len(smart))
+	for _, l := range smart {
+		labelled[l.GmailLabelID] = true
+		existing = append(existing, l.Name)
+	}
+
+	// Recent emails no smart label covers yet: those are what new labels are for.
+	cur, err := h.db.Emails().Find(ctx, bson.M{"userId": userEmail},
+		options.Find().
+			SetSort(bson.D{{Key: "receivedDate", Value: -1}}).
+			SetLimit(clusterEmailCap).
+			SetProjection(bson.M{"body": 0}))
+	if err != nil {
+		return 0, 0, err
+	}
+	var all []models.Email
+	if err := cur.All(ctx, &all); err != nil {
+		return 0, 0, err
+	}
+	emails := all[:0]
+	for _, e := range all {
+		if !hasAnyLabel(e.LabelIDs, labelled) {
+			emails = append(emails, e)
+		}
+	}
+	onProgress(len(emails), 0)
+	if len(emails) < 2*cluster.DefaultOptions.MinSize {
+		return len(emails), 0, nil
+	}
+
+	vectors, err := h.emailVectors(ctx, userEmail, emails, usage)
+	if err != nil {
+		return len(emails), 0, err
+	}
+	onProgress(len(emails), len(emails))
+
+	items := make([]cluster.Item, 0, len(emails))
+	kept := make([]models.Email, 0, len(emails))
+	for i, e := range emails {
+		if vectors[i] == nil {
+			continue
+		}
+		items = append(items, cluster.Item{Vector: vectors[i], From: e.From, Subject: e.Subject})
+		kept = append(kept, e)
+	}
+	groups := cluster.Groups(items, cluster.DefaultOptions)
+
+	// Names dismissed before are not proposed again.
+	var dismissed []models.LabelProposal
+	if cur, err := h.db.LabelProposals().Find(ctx, bson.M{"userId": userEmail, "status": proposalDismissed},
+		options.Find().SetProjection(bson.M{"name": 1})); err == nil {
+		cur.All(ctx, &dismissed)
+	}
+	for _, p := range dismissed {
+		existing = append(existing, p.Name)
+	}
+
+	h.db.LabelProposals().DeleteMany(ctx, bson.M{"userId": userEmail, "status": proposalPending})
+	created := 0
+	for _, g := range groups {
+		p, ok := buildProposal(g, kept, existing)
+		if !ok {
+			continue
+		}
+		p.UserID = userEmail
+		p.Status = proposalPending
+		p.CreatedAt = time.Now()
+		p.UpdatedAt = p.CreatedAt
+		if _, err := h.db.LabelProposals().InsertOne(ctx, p); err == nil {
+			created++
+			existing = append(existing, p.Name)
+		}
+	}
+	return len(emails), created, nil
+}
+
+// emailVectors returns one vector per email (nil when it could not be
+// embedded), reading stored vectors for the provider's model and embedding
+// only the missing ones.
+func (h *Handler) emailVectors(ctx context.Context, userEmail string, emails []models.Email, usage *ai.Usage) ([][]float32, error) {
+	model := h.embedder.Model()
+	ids := make([]string, len(emails))
+	for i, e := range emails {
+		ids[i] = e.MessageID
+	}
+
+	stored := map[string][]float32{}
+	cur, err := h.db.Embeddings().Find(ctx, bson.M{"userId": userEmail, "model": model, "messageId": bson.M{"$in": ids}})
+	if err == nil {
+		var docs []models.EmailEmbedding
+		cur.All(ctx, &docs)
+		for _, d := range docs {
+			stored[d.MessageID] = d.Vector
+		}
+	}
+
+	out := make([][]float32, len(emails))
+	var missing []int
+	var texts []string
+	for i, e := range emails {
+		if v, ok := stored[e.MessageID]; ok {
+			out[i] = v
+			continue
+		}
+		missing = append(missing, i)
+		texts = append(texts, embed.EmailText(e.From, e.Subject, e.Snippet))
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	vecs, tokens, err := h.embedder.Embed(texts)
+	u := ai.Usage{PromptTokens: tokens, TotalTokens: tokens}
+	*usage = usage.Add(u)
+	h.recordModelTokens(ctx, userEmail, model, u)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	for j, i := range missing {
+		out[i] = vecs[j]
+		h.db.Embeddings().ReplaceOne(ctx,
+			bson.M{"userId": userEmail, "messageId": emails[i].MessageID},
+			models.EmailEmbedding{
+				UserID:    userEmail,
+				MessageID: emails[i].MessageID,
+				Model:     model,
+				Vector:    vecs[j],
+				CreatedAt: time.Now(),
+			},
+			options.Replace().SetUpsert(true),
+		)
+	}
+	return out, nil
+}
+
+func hasAnyLabel(ids []string, set map[string]bool) bool {
+	for _, id := range ids {
+		if set[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// buildProposal turns a cluster into a label proposal. It declines clusters it
+// cannot name, and names that an existing label (in any language) or an
+// earlier proposal already covers.
+func buildProposal(g cluster.Group, emails []models.Email, existing []string) (models.LabelProposal, bool) {
+	name := proposalName(g)
+	if name == "" {
+		return models.LabelProposal{}, false
+	}
+	for _, e := range existing {
+		if strings.EqualFold(e, name) || locale.SameConcept(e, name) {
+			return models.LabelProposal{}, false
+		}
+	}
+
+	p := models.LabelProposal{
+		Name:     name,
+		Keywords: g.Terms,
+		Size:     len(g.Members),
+		Cohesion: g.Cohesion,
+		Rule:     proposalRule(name, g),
+	}
+	desc := fmt.Sprintf("%d emails similaires", len(g.Members))
+	if len(g.Terms) > 0 {
+		desc += " autour de : " + strings.Join(g.Terms, ", ")
+	}
+	if g.Domain != "" && g.DomainShare >= ruleShare {
+		desc += " (principalement " + g.Domain + ")"
+	}
+	p.Description = desc
+
+	for n, i := range g.Members {
+		p.MessageIDs = append(p.MessageIDs, emails[i].MessageID)
+		if n < proposalSamples {
+			p.Samples = append(p.Samples, models.LabelSample{
+				MessageID: emails[i].MessageID,
+				From:      emails[i].From,
+				Subject:   emails[i].Subject,
+			})
+		}
+	}
+	return p, true
+}
+
+// proposalName names a cluster after its most distinctive subject term, or
+// failing that after its dominant sender ("news.shop.com" → "Shop").
+func proposalName(g cluster.Group) string {
+	if len(g.Terms) > 0 {
+		return capitalize(g.Terms[0])
+	}
+	if g.Domain != "" && g.DomainShare >= ruleShare {
+		parts := strings.Split(g.Domain, ".")
+		if len(parts) >= 2 {
+			return capitalize(parts[len(parts)-2])
+		}
+	}
+	return ""
+}
+
+// proposalRule suggests a rule that labels the cluster's future emails: by
+// sender domain when one dominates, else by a shared subject term. nil when
+// neither is distinctive enough to be safe.
+func proposalRule(name string, g cluster.Group) *models.SortingRuleInput {
+	var cond models.RuleCondition
+	switch {
+	case g.Domain != "" && g.DomainShare >= ruleShare:
+		cond = models.RuleCondition{Field: rules.FieldFrom, Operator: rules.OpContains, Value: "@" + g.Domain}
+	case len(g.Terms) > 0 && g.TermShare >= ruleShare:
+		cond = models.RuleCondition{Field: rules.FieldSubject, Operator: rules.OpContains, Value: g.Terms[0]}
+	default:
+		return nil
+	}
+	return &models.SortingRuleInput{
+		Name:       "Libellé " + name,
+		Enabled:    true,
+		Conditions: []models.RuleCondition{cond},
+		Actions:    []models.RuleAction{{Type: rules.ActionLabel, LabelName: name}},
+	}
+}
+
+func capitalize(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return ""
+	}
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// GetLabelProposals lists the caller's pending label proposals, largest first.
+func (h *Handler) GetLabelProposals(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cur, err := h.db.LabelProposals().Find(ctx, bson.M{"userId": userEmail, "status": proposalPending},
+		options.Find().SetSort(bson.D{{Key: "size", Value: -1}}))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch proposals")
+		return
+	}
+	proposals := make([]models.LabelProposal, 0)
+	if err := cur.All(ctx, &proposals); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to decode proposals")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"proposals": proposals})
+}
+
+// AcceptLabelProposal creates the proposed SmartLabel (and its Gmail label)
+// and, with createRule, the suggested sorting rule.
+func (h *Handler) AcceptLabelProposal(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid proposal ID")
+		return
+	}
+	var req models.AcceptLabelProposalRequest
+	if r.ContentLength > 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var p models.LabelProposal
+	if err := h.db.LabelProposals().FindOne(ctx, bson.M{"_id": oid, "userId": userEmail, "status": proposalPending}).Decode(&p); err != nil {
+		writeError(w, http.StatusNotFound, "Proposal not found")
+		return
+	}
+	// ensureLabel stores the label under its clean path; everything below
+	// must use that same name.
+	proposed := labelpath.Clean(p.Name)
+	name := labelpath.Clean(req.Name)
+	if name == "" {
+		name = proposed
+	}
+	if err := labelpath.Validate(name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var rule *models.SortingRule
+	if req.CreateRule && p.Rule != nil {
+		in := *p.Rule
+		in.Actions = []models.RuleAction{{Type: rules.ActionLabel, LabelName: name}}
+		if name != proposed {
+			in.Name = "Libellé " + name
+		}
+		ru := ruleFromInput(userEmail, in)
+		if err := rules.Validate(ru); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rule = &ru
+	}
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+		return
+	}
+	gmailLabelID, err := h.ensureLabel(ctx, gmailClient, userEmail, name)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Failed to create Gmail label: "+err.Error())
+		return
+	}
+	h.db.SmartLabels().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "name": name},
+		bson.M{"$set": bson.M{"description": p.Description, "keywords": p.Keywords, "updatedAt": time.Now()}},
+	)
+
+	out := map[string]interface{}{"label": map[string]string{"name": name, "gmailLabelId": gmailLabelID}}
+	if rule != nil {
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = rule.CreatedAt
+		res, err := h.db.SortingRules().InsertOne(ctx, rule)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to save rule")
+			return
+		}
+		if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+			rule.ID = id.Hex()
+		}
+		out["rule"] = rule
+	}
+
+	h.db.LabelProposals().UpdateOne(ctx, bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"status": proposalAccepted, "name": name, "updatedAt": time.Now()}})
+	writeJSON(w, http.StatusOK, out)
+}
+
+// DismissLabelProposal discards a proposal; its name is not proposed again.
+func (h *Handler) DismissLabelProposal(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid proposal ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := h.db.LabelProposals().UpdateOne(ctx,
+		bson.M{"_id": oid, "userId": userEmail, "status": proposalPending},
+		bson.M{"$set": bson.M{"status": proposalDismissed, "updatedAt": time.Now()}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to dismiss proposal")
+		return
+	}
+	if res.MatchedCount == 0 {
+		writeError(w, http.StatusNotFound, "Proposal not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": proposalDismissed})
+}
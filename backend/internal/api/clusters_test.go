@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/cluster"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
+)
+
+func TestProposalRule(t *testing.T) {
+	byDomain := proposalRule("Facture", cluster.Group{Terms: []string{"facture"}, Domain: "shop.fr", DomainShare: 0.9, TermShare: 1})
+	if byDomain == nil || byDomain.Conditions[0].Field != rules.FieldFrom || byDomain.Conditions[0].Value != "@shop.fr" {
+		t.Fatalf("domain rule = %+v", byDomain)
+	}
+	if err := rules.Validate(ruleFromInput("u", *byDomain)); err != nil {
+		t.Errorf("proposed rule does not validate: %v", err)
+	}
+
+	bySubject := proposalRule("Webinar", cluster.Group{Terms: []string{"webinar"}, Domain: "a.com", DomainShare: 0.3, TermShare: 0.85})
+	if bySubject == nil || bySubject.Conditions[0].Field != rules.FieldSubject || bySubject.Conditions[0].Value != "webinar" {
+		t.Fatalf("subject rule = %+v", bySubject)
+	}
+
+	if r := proposalRule("Divers", cluster.Group{Terms: []string{"divers"}, DomainShare: 0.5, TermShare: 0.5}); r != nil {
+		t.Errorf("weak cluster got a rule: %+v", r)
+	}
+}
+
+func TestBuildProposal(t *testing.T) {
+	emails := []models.Email{
+		{MessageID: "m0", From: "a@shop.fr", Subject: "Facture 1"},
+		{MessageID: "m1", From: "a@shop.fr", Subject: "Facture 2"},
+	}
+	g := cluster.Group{Members: []int{1, 0}, Terms: []string{"facture"}, Domain: "shop.fr", DomainShare: 1, Cohesion: 0.9}
+
+	p, ok := buildProposal(g, emails, []string{"Travail"})
+	if !ok || p.Name != "Facture" || p.Size != 2 || p.Rule == nil {
+		t.Fatalf("proposal = %+v, ok=%v", p, ok)
+	}
+	if p.Samples[0].MessageID != "m1" || len(p.MessageIDs) != 2 {
+		t.Errorf("samples not in centroid order: %+v", p.Samples)
+	}
+
+	// An existing label of the same concept, in another language, blocks it.
+	if _, ok := buildProposal(cluster.Group{Members: []int{0}, Terms: []string{"invoices"}}, emails, []string{"Factures"}); ok {
+		t.Error("proposed a duplicate of an existing label")
+	}
+	if _, ok := buildProposal(cluster.Group{Members: []int{0}}, emails, nil); ok {
+		t.Error("proposed an unnamed cluster")
+	}
+}
+
+func TestProposalNameFromDomain(t *testing.T) {
+	if got := proposalName(cluster.Group{Domain: "news.deezer.com", DomainShare: 1}); got != "Deezer" {
+		t.Errorf("proposalName = %q, want Deezer", got)
+	}
+}
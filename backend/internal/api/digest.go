@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/activity"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/digest"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// digestListCount caps each list section of the digest (senders, suggestions,
+// subscriptions, rules).
+const digestListCount = 5
+
+// effectiveDigestPrefs is what the digest actually uses for stored prefs:
+// defaults filled in, unknown sections dropped.
+func effectiveDigestPrefs(p models.DigestPrefs) models.DigestPrefs {
+	n := digest.Prefs{Cadence: p.Cadence, Sections: p.Sections}.Normalize()
+	return models.DigestPrefs{Cadence: n.Cadence, Sections: n.Sections}
+}
+
+// buildDigest renders the user's digest as it would go out now, with their
+// cadence and sections in their timezone. It also reports whether there is
+// anything worth sending (see digest.Empty).
+func (h *Handler) buildDigest(ctx context.Context, userEmail string, settings models.UserSettings) (digest.Digest, bool, error) {
+	prefs := digest.Prefs{Cadence: settings.Digest.Cadence, Sections: settings.Digest.Sections}.Normalize()
+	now := calendarFor(settings).In(time.Now())
+	data, err := h.digestData(ctx, userEmail, prefs, now)
+	if err != nil {
+		return digest.Digest{}, false, err
+	}
+	return digest.RenderData(data, prefs, now), digest.Empty(data, prefs), nil
+}
+
+// digestData loads what the selected sections show, over the period the
+// cadence covers. Only the activity summary is required; the other sections
+// are best-effort and simply come out empty on a query failure.
+func (h *Handler) digestData(ctx context.Context, userEmail string, prefs digest.Prefs, now time.Time) (digest.Data, error) {
+	days := digest.Days(prefs.Cadence)
+	summary, err := h.activitySummaryDays(ctx, userEmail, now, days)
+	if err != nil {
+		return digest.Data{}, err
+	}
+	data := digest.Data{Summary: summary}
+	since := activity.WindowStartDays(now, days)
+
+	for _, sec := range prefs.Sections {
+		switch sec {
+		case digest.SectionPriority:
+			data.Top = h.topPriority(ctx, userEmail, now)
+		case digest.SectionTopSenders:
+			data.TopSenders = h.digestTopSenders(ctx, userEmail, since)
+		case digest.SectionPending:
+			data.Pending, data.PendingTotal = h.digestPending(ctx, userEmail)
+		case digest.SectionSnoozes:
+			data.WakingTomorrow = h.digestWakingTomorrow(ctx, userEmail, now)
+		case digest.SectionSubscriptions:
+			data.NewSubscriptions = h.digestNewSubscriptions(ctx, userEmail, since)
+		case digest.SectionRules:
+			data.TopRules = h.digestTopRules(ctx, userEmail)
+		case digest.SectionQuota:
+			data.Quota = digest.Quota{Plan: h.getPlan(ctx, userEmail), Used: h.getUsage(ctx, userEmail), Limit: FreeMonthlyLimit}
+			if data.Quota.Plan == PlanPro {
+				data.Quota.Limit = -1
+			}
+		}
+	}
+	return data, nil
+}
+
+// digestTopSenders counts the emails each From header sent since `since`.
+func (h *Handler) digestTopSenders(ctx context.Context, userEmail string, since time.Time) []digest.SenderCount {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userEmail, "receivedDate": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$from", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: digestListCount}},
+	}
+	cursor, err := h.db.Emails().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil
+	}
+	var rows []struct {
+		From  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if cursor.All(ctx, &rows) != nil {
+		return nil
+	}
+	out := make([]digest.SenderCount, 0, len(rows))
+	for _, r := range rows {
+		if r.From != "" {
+			out = append(out, digest.SenderCount{Sender: r.From, Count: r.Count})
+		}
+	}
+	return out
+}
+
+// digestPending returns the most confident AI suggestions awaiting review,
+// with their email's sender and subject, and how many are pending in all.
+func (h *Handler) digestPending(ctx context.Context, userEmail string) ([]digest.PendingSuggestion, int) {
+	filter := bson.M{"userId": userEmail, "status": "pending"}
+	total, err := h.db.AISuggestions().CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return nil, 0
+	}
+	cursor, err := h.db.AISuggestions().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "confidence", Value: -1}, {Key: "createdAt", Value: -1}}).SetLimit(digestListCount))
+	if err != nil {
+		return nil, int(total)
+	}
+	var suggestions []models.AISuggestion
+	if cursor.All(ctx, &suggestions) != nil {
+		return nil, int(total)
+	}
+
+	ids := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		ids = append(ids, s.EmailID)
+	}
+	emails := map[string]models.Email{}
+	if cur, err := h.db.Emails().Find(ctx, bson.M{"userId": userEmail, "messageId": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"messageId": 1, "from": 1, "subject": 1})); err == nil {
+		var rows []models.Email
+		if cur.All(ctx, &rows) == nil {
+			for _, e := range rows {
+				emails[e.MessageID] = e
+			}
+		}
+	}
+
+	out := make([]digest.PendingSuggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		e := emails[s.EmailID]
+		out = append(out, digest.PendingSuggestion{
+			From: e.From, Subject: e.Subject, Action: s.Action, LabelName: s.LabelName, Confidence: s.Confidence,
+		})
+	}
+	return out, int(total)
+}
+
+// digestWakingTomorrow lists the snoozes due back on the day after now's, in
+// now's location, earliest first.
+func (h *Handler) digestWakingTomorrow(ctx context.Context, userEmail string, now time.Time) []digest.WakingSnooze {
+	y, m, d := now.Date()
+	start := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	end := time.Date(y, m, d+2, 0, 0, 0, 0, now.Location())
+	cursor, err := h.db.Snoozes().Find(ctx, bson.M{
+		"userId": userEmail,
+		"status": "scheduled",
+		"wakeAt": bson.M{"$gte": start, "$lt": end},
+	}, options.Find().SetSort(bson.D{{Key: "wakeAt", Value: 1}}).SetLimit(20))
+	if err != nil {
+		return nil
+	}
+	var rows []models.Snooze
+	if cursor.All(ctx, &rows) != nil {
+		return nil
+	}
+	out := make([]digest.WakingSnooze, 0, len(rows))
+	for _, s := range rows {
+		out = append(out, digest.WakingSnooze{From: s.From, Subject: s.Subject, WakeAt: s.WakeAt.In(now.Location())})
+	}
+	return out
+}
+
+// digestNewSubscriptions lists the mailing lists first seen since `since`,
+// newest first. It reads the senders directory, so it is as fresh as the last
+// directory refresh.
+func (h *Handler) digestNewSubscriptions(ctx context.Context, userEmail string, since time.Time) []digest.NewSubscription {
+	cursor, err := h.db.Senders().Find(ctx, bson.M{
+		"userId":         userEmail,
+		"canUnsubscribe": true,
+		"firstSeen":      bson.M{"$gte": since},
+	}, options.Find().SetSort(bson.D{{Key: "firstSeen", Value: -1}}).SetLimit(digestListCount))
+	if err != nil {
+		return nil
+	}
+	var rows []models.Sender
+	if cursor.All(ctx, &rows) != nil {
+		return nil
+	}
+	out := make([]digest.NewSubscription, 0, len(rows))
+	for _, s := range rows {
+		sender := s.SenderEmail
+		if s.SenderName != "" {
+			sender = s.SenderName + " <" + s.SenderEmail + ">"
+		}
+		out = append(out, digest.NewSubscription{Sender: sender, OneClick: s.OneClick})
+	}
+	return out
+}
+
+// digestTopRules lists the rules that have handled the most emails.
+func (h *Handler) digestTopRules(ctx context.Context, userEmail string) []digest.RuleCount {
+	cursor, err := h.db.SortingRules().Find(ctx,
+		bson.M{"userId": userEmail, "appliedCount": bson.M{"$gt": 0}},
+		options.Find().SetSort(bson.D{{Key: "appliedCount", Value: -1}, {Key: "name", Value: 1}}).SetLimit(digestListCount))
+	if err != nil {
+		return nil
+	}
+	var rows []models.SortingRule
+	if cursor.All(ctx, &rows) != nil {
+		return nil
+	}
+	out := make([]digest.RuleCount, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, digest.RuleCount{Name: r.Name, Count: r.AppliedCount})
+	}
+	return out
+}
+
+// GetDigestPreview shows the caller's next digest exactly as it will be
+// rendered, with their cadence and sections, when it will next go out and
+// whether it would be skipped for having nothing to say.
+func (h *Handler) GetDigestPreview(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	settings := h.userSettings(ctx, userEmail)
+	d, empty, err := h.buildDigest(ctx, userEmail, settings)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load activity")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"digest":   d,
+		"enabled":  settings.DigestEnabled,
+		"cadence":  settings.Digest.Cadence,
+		"sections": settings.Digest.Sections,
+		"empty":    empty,
+	}
+	if settings.DigestEnabled {
+		var u models.User
+		h.db.Users().FindOne(ctx, bson.M{"email": userEmail},
+			options.FindOne().SetProjection(bson.M{"digestLastSentAt": 1, "digestHourUTC": 1})).Decode(&u)
+		now := time.Now()
+		hour, loc := digestHourFor(settings.DigestHour, u.DigestHourUTC, settings.Timezone, now)
+		resp["nextSendAt"] = digest.NextSend(settings.Digest.Cadence, u.DigestLastSentAt, now, hour, loc)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
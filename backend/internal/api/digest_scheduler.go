@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/digest"
-	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -24,25 +24,27 @@ func defaultDigestHour() int {
 }
 
 // digestSweepInterval is how often the scheduler checks who is due for a digest.
-// DueAt makes the send idempotent (at most once per day), so a frequent tick is
+// digest.Due makes the send idempotent (at most once per period), so a frequent tick is
 // safe and just shortens the lag between the target hour and delivery.
 const digestSweepInterval = 15 * time.Minute
 
-// startDigestLoop launches the background scheduler that emails the daily recap.
+// startDigestLoop launches the background scheduler that sends the digest and
+// retries notification deliveries that failed transiently.
 func (h *Handler) startDigestLoop() {
 	go func() {
 		ticker := time.NewTicker(digestSweepInterval)
 		defer ticker.Stop()
 		for range ticker.C {
 			h.sendDueDigests()
+			h.retryDeliveries()
 		}
 	}()
 }
 
-// sendDueDigests emails each opted-in user their 7-day recap when it is due. It
+// sendDueDigests emails each opted-in user their digest when it is due. It
 // is best-effort and resilient: a per-user failure is logged and skipped so one
 // bad account never stalls the rest. Every user that is due is stamped after the
-// attempt, so a transient failure costs at most that day's digest rather than
+// attempt, so a transient failure costs at most that period's digest rather than
 // triggering a retry storm.
 func (h *Handler) sendDueDigests() {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
@@ -63,11 +65,7 @@ func (h *Handler) sendDueDigests() {
 
 	now := time.Now()
 	for _, u := range users {
-		hour := u.DigestHourUTC
-		if hour <= 0 || hour > 23 {
-			hour = defaultDigestHour()
-		}
-		if !mailer.DueAt(u.DigestLastSentAt, now, hour) {
+		if !digestDue(u, now) {
 			continue
 		}
 		h.sendOneDigest(ctx, u.Email)
@@ -75,34 +73,60 @@ func (h *Handler) sendDueDigests() {
 	}
 }
 
-// sendOneDigest renders and sends a single user's recap. An empty week is
-// skipped (no point emailing "0 emails triés"), but the caller still stamps the
-// send time so we don't re-evaluate the same user every tick all day.
-func (h *Handler) sendOneDigest(ctx context.Context, userEmail string) {
-	summary, err := h.activitySummary(ctx, userEmail)
+// digestDue reports whether u's digest should go out at now, at the hour
+// digestHourFor picks, once per period of their cadence.
+func digestDue(u models.User, now time.Time) bool {
+	hour, loc := digestHourFor(u.DigestHour, u.DigestHourUTC, u.Timezone, now)
+	return digest.Due(u.Digest.Cadence, u.DigestLastSentAt, now, hour, loc)
+}
+
+// digestHourFor is the hour and location a digest goes out at on now's day:
+// the local hour when the user picked one; else the UTC hour they picked,
+// converted into their timezone; else the server default read as a local hour,
+// so it does not land in their night. A stored UTC hour of 0 means unset, and
+// without a (known) timezone the location is UTC.
+func digestHourFor(localHour *int, hourUTC int, tz string, now time.Time) (int, *time.Location) {
+	loc, err := time.LoadLocation(tz)
 	if err != nil {
-		log.Printf("digest: activity summary failed for %s: %v", userEmail, err)
-		return
+		loc = time.UTC
 	}
-	if summary.Total == 0 {
-		return
+	if localHour != nil {
+		return *localHour, loc
+	}
+	if hourUTC <= 0 || hourUTC > 23 {
+		return defaultDigestHour(), loc
 	}
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d, hourUTC, 0, 0, 0, time.UTC).In(loc).Hour(), loc
+}
 
-	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+// sendOneDigest renders a single user's digest with their cadence and sections
+// and delivers it over their notification channels (see deliver). A period with nothing to report in those sections is skipped, but
+// the caller still stamps the send time so we don't re-evaluate the same user
+// every tick all day.
+func (h *Handler) sendOneDigest(ctx context.Context, userEmail string) {
+	d, empty, err := h.buildDigest(ctx, userEmail, h.userSettings(ctx, userEmail))
 	if err != nil {
-		log.Printf("digest: no Gmail client for %s: %v", userEmail, err)
+		log.Printf("digest: activity summary failed for %s: %v", userEmail, err)
 		return
 	}
-
-	d := digest.Render(summary, time.Now())
-	raw := mailer.BuildRaw(userEmail, userEmail, d.Subject, d.Text, d.HTML)
-	if err := h.gmailService.SendMessage(gmailClient, raw); err != nil {
-		// A missing gmail.send scope (user connected before the digest feature)
-		// surfaces here; they simply need to reconnect Gmail to grant it.
-		log.Printf("digest: send failed for %s: %v", userEmail, err)
+	if empty {
 		return
 	}
-	log.Printf("digest: sent to %s", userEmail)
+
+	deliveries := h.deliver(ctx, userEmail, notify.Message{
+		Kind: "digest", To: userEmail, Subject: d.Subject, Text: d.Text, HTML: d.HTML,
+	})
+	channels := map[string]string{}
+	for _, dl := range deliveries {
+		channels[dl.Channel] = dl.Status
+		if dl.Status == deliverySent {
+			log.Printf("digest: sent to %s via %s", userEmail, dl.Channel)
+		}
+	}
+	h.emitWebhook(userEmail, notify.HookDigestSent, map[string]interface{}{
+		"subject": d.Subject, "channels": channels,
+	})
 }
 
 // stampDigestSent records that we attempted a digest for the user today so the
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestDigestHourFor(t *testing.T) {
+	nine := 9
+	summer := time.Date(2026, 6, 22, 12, 0, 0, 0, time.UTC)  // Montréal on UTC-4
+	winter := time.Date(2026, 12, 22, 12, 0, 0, 0, time.UTC) // Montréal on UTC-5
+	cases := []struct {
+		name     string
+		local    *int
+		hourUTC  int
+		tz       string
+		now      time.Time
+		wantHour int
+		wantZone string
+	}{
+		{"local hour in timezone", &nine, 5, "America/Montreal", summer, 9, "America/Montreal"},
+		{"timezone only: default hour, local", nil, 0, "America/Montreal", summer, defaultDigestHour(), "America/Montreal"},
+		{"UTC hour converted in summer", nil, 14, "America/Montreal", summer, 10, "America/Montreal"},
+		{"UTC hour converted in winter", nil, 14, "America/Montreal", winter, 9, "America/Montreal"},
+		{"UTC hour under Etc/UTC", nil, 5, "Etc/UTC", summer, 5, "Etc/UTC"},
+		{"UTC hour", nil, 5, "", summer, 5, "UTC"},
+		{"nothing set", nil, 0, "", summer, defaultDigestHour(), "UTC"},
+		{"unknown timezone", nil, 5, "Mars/Olympus", summer, 5, "UTC"},
+	}
+	for _, c := range cases {
+		hour, loc := digestHourFor(c.local, c.hourUTC, c.tz, c.now)
+		if hour != c.wantHour || loc.String() != c.wantZone {
+			t.Errorf("%s: got %d %s, want %d %s", c.name, hour, loc, c.wantHour, c.wantZone)
+		}
+	}
+}
+
+// A Montréal user who only set a timezone gets the digest in their morning,
+// not at the default hour UTC (3 AM local).
+func TestDigestDueTimezoneOnly(t *testing.T) {
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	u := models.User{DigestEnabled: true, Timezone: "America/Montreal"}
+	hour := defaultDigestHour()
+	early := time.Date(2026, 6, 22, hour-1, 30, 0, 0, loc)
+	if digestDue(u, early) {
+		t.Errorf("due at %s, before %dh local", early, hour)
+	}
+	onTime := time.Date(2026, 6, 22, hour, 5, 0, 0, loc)
+	if !digestDue(u, onTime) {
+		t.Errorf("not due at %s", onTime)
+	}
+}
+
+// An explicit UTC hour keeps its instant once a timezone is set.
+func TestDigestDueExplicitUTCHour(t *testing.T) {
+	if _, err := time.LoadLocation("America/Montreal"); err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	u := models.User{DigestEnabled: true, DigestHourUTC: 14, Timezone: "America/Montreal"}
+	if early := time.Date(2026, 6, 22, 13, 30, 0, 0, time.UTC); digestDue(u, early) {
+		t.Errorf("due at %s, before 14h UTC", early)
+	}
+	if onTime := time.Date(2026, 6, 22, 14, 5, 0, 0, time.UTC); !digestDue(u, onTime) {
+		t.Errorf("not due at %s", onTime)
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// DraftReply writes an AI reply to a message, using the rest of its thread as
+// context and the user's tone and signature, and saves it to Gmail drafts in
+// the same conversation. Nothing is ever sent: the user reviews and sends the
+// draft from their mail client.
+func (h *Handler) DraftReply(w http.ResponseWriter, r *http.Request) {
+	if h.aiClient == nil {
+		writeError(w, http.StatusServiceUnavailable, "AI service not configured")
+		return
+	}
+
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	var req models.DraftReplyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.MessageID == "" {
+		writeError(w, http.StatusBadRequest, "Message ID required")
+		return
+	}
+	if len(req.Instructions) > 1000 || len(req.Tone) > maxReplyToneLen {
+		writeError(w, http.StatusBadRequest, "Instructions or tone too long")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+		return
+	}
+
+	if h.quotaExceeded(ctx, userEmail) {
+		writeError(w, http.StatusPaymentRequired, "Quota mensuel atteint. Passez à Pro pour continuer.")
+		return
+	}
+	if h.tokenBudgetExceeded(ctx) {
+		writeError(w, http.StatusServiceUnavailable, "Budget IA mensuel du serveur atteint — réessayez le mois prochain.")
+		return
+	}
+
+	messages, threadID, err := h.summarySource(gmailClient, req.MessageID, true)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Impossible de récupérer l'email : "+err.Error())
+		return
+	}
+	messages = threadUpTo(messages, req.MessageID)
+	parent := messages[len(messages)-1]
+
+	input := make([]ai.SummaryMessage, 0, len(messages))
+	for _, m := range messages {
+		from, subject, _, date := gmail.ParseEmailHeaders(m)
+		body := gmail.MessageText(m)
+		if body == "" {
+			body = m.Snippet
+		}
+		input = append(input, ai.SummaryMessage{From: from, Date: date, Subject: subject, Body: body})
+	}
+
+	settings := h.userSettings(ctx, userEmail)
+	tone := strings.TrimSpace(req.Tone)
+	if tone == "" {
+		tone = settings.ReplyTone
+	}
+
+	text, usage, err := h.aiClient.DraftReply(input, ai.ReplyOptions{
+		Me:           userEmail,
+		Tone:         tone,
+		Instructions: strings.TrimSpace(req.Instructions),
+	}, settings.Locale)
+	h.recordTokens(ctx, userEmail, usage)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Rédaction impossible : "+err.Error())
+		return
+	}
+	h.incrUsage(ctx, userEmail, 1)
+
+	if settings.Signature != "" {
+		text += "\n\n-- \n" + settings.Signature
+	}
+
+	parentFrom, parentSubject, parentTo, _ := gmail.ParseEmailHeaders(parent)
+	to := replyRecipient(parentFrom, gmail.Header(parent, "Reply-To"), parentTo, userEmail)
+	if to == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Aucun destinataire pour cette réponse")
+		return
+	}
+	reply := mailer.Reply{
+		From:       userEmail,
+		To:         to,
+		Subject:    parentSubject,
+		InReplyTo:  gmail.Header(parent, "Message-ID"),
+		References: mailer.ReplyReferences(gmail.Header(parent, "References"), gmail.Header(parent, "Message-ID")),
+		Text:       text,
+	}
+
+	draftID, err := h.gmailService.CreateDraft(gmailClient, mailer.BuildReply(reply), threadID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Impossible de créer le brouillon : "+err.Error())
+		return
+	}
+	h.logAction(ctx, userEmail, req.MessageID, "draft", SourceDraft)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"draftId":  draftID,
+		"threadId": threadID,
+		"to":       to,
+		"subject":  mailer.ReplySubject(parentSubject),
+		"body":     text,
+	})
+}
+
+// threadUpTo trims a thread (oldest first) after messageID, so a reply to an
+// older message is not written as if it answered the later ones. An unknown id
+// leaves the thread untouched.
+func threadUpTo(messages []*gmailapi.Message, messageID string) []*gmailapi.Message {
+	for i, m := range messages {
+		if m.Id == messageID {
+			return messages[:i+1]
+		}
+	}
+	return messages
+}
+
+// replyRecipient picks who a reply goes to: the parent's Reply-To when set,
+// else its sender. When the user wrote the parent themselves (answering their
+// own last message), the reply goes back to that message's other recipients.
+func replyRecipient(from, replyTo string, to []string, me string) string {
+	if strings.TrimSpace(replyTo) != "" {
+		return strings.TrimSpace(replyTo)
+	}
+	if !strings.EqualFold(extractSenderAddress(from), me) {
+		return strings.TrimSpace(from)
+	}
+	var others []string
+	for _, header := range to {
+		for _, addr := range strings.Split(header, ",") {
+			if a := strings.TrimSpace(addr); a != "" && !strings.EqualFold(extractSenderAddress(a), me) {
+				others = append(others, a)
+			}
+		}
+	}
+	return strings.Join(others, ", ")
+}
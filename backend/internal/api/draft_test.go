@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func TestReplyRecipient(t *testing.T) {
+	me := "me@example.com"
+	tests := []struct {
+		name    string
+		from    string
+		replyTo string
+		to      []string
+		want    string
+	}{
+		{"sender", "Alice <alice@example.com>", "", []string{me}, "Alice <alice@example.com>"},
+		{"reply-to wins", "noreply@shop.com", "support@shop.com", []string{me}, "support@shop.com"},
+		{"own message", "Me <ME@example.com>", "", []string{"bob@example.com, me@example.com", "carol@example.com"}, "bob@example.com, carol@example.com"},
+		{"own message to self", me, "", []string{me}, ""},
+	}
+	for _, tt := range tests {
+		if got := replyRecipient(tt.from, tt.replyTo, tt.to, me); got != tt.want {
+			t.Errorf("%s: replyRecipient = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThreadUpTo(t *testing.T) {
+	thread := []*gmailapi.Message{{Id: "a"}, {Id: "b"}, {Id: "c"}}
+	if got := threadUpTo(thread, "b"); len(got) != 2 || got[1].Id != "b" {
+		t.Errorf("threadUpTo(b) = %d messages", len(got))
+	}
+	if got := threadUpTo(thread, "zzz"); len(got) != 3 {
+		t.Errorf("threadUpTo(unknown) = %d messages, want 3", len(got))
+	}
+}
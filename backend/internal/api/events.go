@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/events"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
+)
+
+const (
+	// eventHeartbeat is how often an idle stream gets a comment line, so
+	// proxies keep it open and a dead client is noticed on the next write.
+	eventHeartbeat = 15 * time.Second
+	// eventRetry is the reconnection delay suggested to EventSource clients.
+	eventRetry = 3 * time.Second
+	// eventWriteTimeout bounds each write to a stream; the server-wide
+	// WriteTimeout would otherwise cut every stream after 150s.
+	eventWriteTimeout = 10 * time.Second
+)
+
+// startEventSweep launches the background sweeper that forgets the event
+// buffers of users who went away.
+func (h *Handler) startEventSweep() {
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.events.Sweep(now)
+		}
+	}()
+}
+
+// publish sends an event to the user's open streams. Best-effort and
+// non-blocking, like the ledger: callers never check it.
+func (h *Handler) publish(userEmail, typ string, data interface{}) {
+	if h.events == nil || userEmail == "" {
+		return
+	}
+	h.events.Publish(userEmail, typ, data)
+}
+
+// publishRules reports the rules applied to the mailbox, by rule name, to the
+// event stream and the webhooks.
+func (h *Handler) publishRules(userEmail, trigger string, byRule map[string]int) {
+	if len(byRule) == 0 {
+		return
+	}
+	applied := 0
+	for _, n := range byRule {
+		applied += n
+	}
+	data := map[string]interface{}{"trigger": trigger, "applied": applied, "byRule": byRule}
+	h.publish(userEmail, events.TypeRules, data)
+	h.emitWebhook(userEmail, notify.HookRuleFired, data)
+}
+
+// publishSnoozeWoke reports a snoozed email back in the inbox; reason is
+// "due", "reply" or "manual".
+func (h *Handler) publishSnoozeWoke(s models.Snooze, reason string) {
+	h.publish(s.UserID, events.TypeSnooze, map[string]interface{}{
+		"snoozeId": s.ID, "messageId": s.MessageID, "from": s.From, "subject": s.Subject, "reason": reason,
+	})
+}
+
+// CloseEvents ends every open event stream, so they do not hold up a
+// graceful shutdown.
+func (h *Handler) CloseEvents() {
+	if h.events != nil {
+		h.events.Close()
+	}
+}
+
+// StreamEvents is the caller's live event stream (Server-Sent Events): job
+// progress, finished syncs, rule firings, woken snoozes and ledger entries as
+// they happen. A client reconnecting with Last-Event-ID (or ?lastEventId=)
+// first receives what it missed; when that is no longer buffered it gets a
+// "reset" event and should reload its state from the REST endpoints.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	if h.events == nil {
+		writeError(w, http.StatusServiceUnavailable, "Event stream unavailable")
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	resume, _ := strconv.ParseUint(strings.TrimSpace(lastID), 10, 64)
+
+	sub, err := h.events.Subscribe(userEmail, resume)
+	switch {
+	case errors.Is(err, events.ErrTooManySubscribers):
+		writeError(w, http.StatusTooManyRequests, "Trop de flux d'événements ouverts")
+		return
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, "Event stream unavailable")
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	write := func(chunk string) bool {
+		rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: do not buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	if !write(fmt.Sprintf("retry: %d\n\n", eventRetry.Milliseconds())) {
+		return
+	}
+	if sub.Gap && !write("event: reset\ndata: {}\n\n") {
+		return
+	}
+	for _, e := range sub.Replay {
+		if !write(formatEvent(e)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events:
+			// Closed: the client fell behind or the server is shutting down.
+			// Either way it reconnects and resumes from its last id.
+			if !ok || !write(formatEvent(e)) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(": ping\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// formatEvent renders an event as one SSE message; data is the whole event
+// as JSON, on a single line.
+func formatEvent(e events.Event) string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		data = []byte("{}")
+	}
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,450 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/followup"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/protect"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// followUpLabelName is the Gmail label put on a sent email brought back to the
+// inbox because nobody replied.
+const followUpLabelName = "Mailsorter/En attente de réponse"
+
+// Follow-up statuses.
+const (
+	followUpWaiting   = "waiting"
+	followUpDue       = "due"
+	followUpReplied   = "replied"
+	followUpDismissed = "dismissed"
+	followUpIdle      = "idle"
+)
+
+// Sync looks at the user's sent messages from the last followUpScanDays, at
+// most followUpScanMax of them; a thread is only fetched the first time one
+// of its sent messages shows up.
+const (
+	followUpScanDays = 30
+	followUpScanMax  = 50
+)
+
+// maxFollowUpDelays bounds the per-recipient delays a user can configure.
+const maxFollowUpDelays = 100
+
+// followUpSettings loads the caller's tracker settings, defaulting the delay.
+func (h *Handler) followUpSettings(ctx context.Context, userEmail string) models.FollowUpSettings {
+	var doc struct {
+		FollowUps models.FollowUpSettings `bson:"followUps"`
+	}
+	h.db.Users().FindOne(ctx, bson.M{"email": userEmail},
+		options.FindOne().SetProjection(bson.M{"followUps": 1})).Decode(&doc)
+	fs := doc.FollowUps
+	if !followup.ValidDays(fs.Days) {
+		fs.Days = followup.DefaultDays
+	}
+	if fs.Delays == nil {
+		fs.Delays = []models.FollowUpDelay{}
+	}
+	return fs
+}
+
+// threadMessages projects a thread fetched with GetThreadHeaders onto what
+// followup.Awaiting needs.
+func threadMessages(t *gmailapi.Thread) []followup.Message {
+	out := make([]followup.Message, 0, len(t.Messages))
+	for _, m := range t.Messages {
+		out = append(out, followup.Message{
+			ID:    m.Id,
+			At:    time.UnixMilli(m.InternalDate),
+			Sent:  contains(m.LabelIds, "SENT"),
+			Draft: contains(m.LabelIds, "DRAFT"),
+		})
+	}
+	return out
+}
+
+// threadMessage returns the message of t with id, or nil.
+func threadMessage(t *gmailapi.Thread, id string) *gmailapi.Message {
+	for _, m := range t.Messages {
+		if m.Id == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// sentHeaders returns the subject and the To/Cc values of a message fetched
+// with GetThreadHeaders.
+func sentHeaders(m *gmailapi.Message) (subject string, recipients []string) {
+	if m.Payload == nil {
+		return "", nil
+	}
+	for _, hd := range m.Payload.Headers {
+		switch hd.Name {
+		case "Subject":
+			subject = hd.Value
+		case "To", "Cc":
+			recipients = append(recipients, hd.Value)
+		}
+	}
+	return subject, recipients
+}
+
+// scanSentForFollowUps starts tracking every recent thread the user wrote to
+// last. Threads whose newest sent message is already tracked, or was already
+// checked, are skipped without a Gmail call; a thread where the user wrote
+// again re-arms its follow-up (even a dismissed one) from the new message.
+func (h *Handler) scanSentForFollowUps(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, fs models.FollowUpSettings) {
+	sent, err := h.gmailService.ListMessages(gmailClient, fmt.Sprintf("in:sent newer_than:%dd", followUpScanDays), followUpScanMax)
+	if err != nil {
+		log.Printf("followups: failed to list sent mail for %s: %v", userEmail, err)
+		return
+	}
+	threadIDs := make([]string, 0, len(sent))
+	for _, m := range sent {
+		threadIDs = append(threadIDs, m.ThreadId)
+	}
+	known := map[string]models.FollowUp{}
+	if cursor, err := h.db.FollowUps().Find(ctx, bson.M{"userId": userEmail, "threadId": bson.M{"$in": threadIDs}}); err == nil {
+		var rows []models.FollowUp
+		cursor.All(ctx, &rows)
+		for _, f := range rows {
+			known[f.ThreadID] = f
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, m := range sent { // newest first
+		if seen[m.ThreadId] {
+			continue
+		}
+		seen[m.ThreadId] = true
+		if k := known[m.ThreadId]; k.MessageID == m.Id || k.CheckedID == m.Id {
+			continue
+		}
+		thread, err := h.gmailService.GetThreadHeaders(gmailClient, m.ThreadId)
+		if err != nil {
+			continue
+		}
+		last, ok := followup.Awaiting(threadMessages(thread))
+		if !ok || last.ID == known[m.ThreadId].MessageID {
+			h.markFollowUpChecked(ctx, userEmail, m.ThreadId, m.Id)
+			continue
+		}
+		subject, headers := sentHeaders(threadMessage(thread, last.ID))
+		recipients := followup.Recipients(headers, userEmail)
+		if len(recipients) == 0 {
+			h.markFollowUpChecked(ctx, userEmail, m.ThreadId, m.Id) // a note to self
+			continue
+		}
+		now := time.Now()
+		h.db.FollowUps().UpdateOne(ctx,
+			bson.M{"userId": userEmail, "threadId": m.ThreadId},
+			bson.M{
+				"$set": bson.M{
+					"messageId": last.ID, "subject": subject, "recipients": recipients,
+					"sentAt": last.At, "dueAt": last.At.Add(followup.DelayFor(recipients, fs.Delays, fs.Days)),
+					"status": followUpWaiting, "resurfaced": false, "checkedId": m.Id, "updatedAt": now,
+				},
+				"$setOnInsert": bson.M{"userId": userEmail, "threadId": m.ThreadId, "createdAt": now},
+			},
+			options.Update().SetUpsert(true))
+	}
+}
+
+// markFollowUpChecked records that the thread was fetched for its sent message
+// sentID and awaits no reply, leaving a tracked follow-up's status alone.
+func (h *Handler) markFollowUpChecked(ctx context.Context, userEmail, threadID, sentID string) {
+	now := time.Now()
+	h.db.FollowUps().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "threadId": threadID},
+		bson.M{
+			"$set":         bson.M{"checkedId": sentID, "updatedAt": now},
+			"$setOnInsert": bson.M{"userId": userEmail, "threadId": threadID, "status": followUpIdle, "createdAt": now},
+		},
+		options.Update().SetUpsert(true))
+}
+
+// loadFollowUpWatch returns the user's open (waiting or due) follow-ups keyed
+// by thread, for sync to spot replies among new inbox mail.
+func (h *Handler) loadFollowUpWatch(ctx context.Context, userEmail string) map[string]models.FollowUp {
+	out := map[string]models.FollowUp{}
+	cursor, err := h.db.FollowUps().Find(ctx, bson.M{"userId": userEmail, "status": bson.M{"$in": bson.A{followUpWaiting, followUpDue}}})
+	if err != nil {
+		return out
+	}
+	var rows []models.FollowUp
+	if err := cursor.All(ctx, &rows); err != nil {
+		return out
+	}
+	for _, f := range rows {
+		out[f.ThreadID] = f
+	}
+	return out
+}
+
+// checkFollowUpReply closes the open follow-up of a new inbox email's thread
+// when the email is someone else's answer to the user's message.
+func (h *Handler) checkFollowUpReply(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, watch map[string]models.FollowUp, email models.Email) {
+	f, ok := watch[email.ThreadID]
+	if !ok || email.ThreadID == "" || contains(email.LabelIDs, "SENT") || !email.ReceivedDate.After(f.SentAt) {
+		return
+	}
+	h.closeFollowUp(ctx, gmailClient, userEmail, f, followUpReplied)
+	delete(watch, email.ThreadID)
+}
+
+// closeFollowUp ends a follow-up with status, taking the follow-up label off
+// its message when it was brought back to the inbox (best-effort).
+func (h *Handler) closeFollowUp(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, f models.FollowUp, status string) {
+	if f.Resurfaced && gmailClient != nil {
+		if labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, followUpLabelName); err == nil {
+			h.gmailService.ModifyMessage(gmailClient, f.MessageID, nil, []string{labelID})
+		}
+	}
+	oid, _ := primitive.ObjectIDFromHex(f.ID)
+	h.db.FollowUps().UpdateOne(ctx, bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}})
+}
+
+// surfaceDueFollowUps moves every waiting follow-up whose delay has passed to
+// "due", after checking its thread one last time: an answer sync missed (one
+// filtered out of the inbox) closes it, and a newer message from the user
+// re-arms it instead. With Resurface on, the sent email also comes back to
+// the inbox, unread and labelled, through the snooze wake path. Runs from the
+// snooze sweep.
+func (h *Handler) surfaceDueFollowUps() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cursor, err := h.db.FollowUps().Find(ctx,
+		bson.M{"status": followUpWaiting, "dueAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetLimit(200))
+	if err != nil {
+		return
+	}
+	var due []models.FollowUp
+	if err := cursor.All(ctx, &due); err != nil {
+		return
+	}
+
+	// Cache one Gmail client and settings per user across their follow-ups.
+	clients := map[string]*gmailapi.Service{}
+	settings := map[string]models.FollowUpSettings{}
+	for _, f := range due {
+		if _, ok := settings[f.UserID]; !ok {
+			settings[f.UserID] = h.followUpSettings(ctx, f.UserID)
+			if c, cerr := h.gmailClientFor(ctx, f.UserID); cerr == nil {
+				clients[f.UserID] = c
+			} else {
+				log.Printf("followups: no Gmail client for %s: %v", f.UserID, cerr)
+			}
+		}
+		client, fs := clients[f.UserID], settings[f.UserID]
+		if client == nil {
+			continue
+		}
+		oid, _ := primitive.ObjectIDFromHex(f.ID)
+
+		thread, err := h.gmailService.GetThreadHeaders(client, f.ThreadID)
+		if err != nil {
+			if gmail.IsNotFound(err) {
+				h.closeFollowUp(ctx, nil, f.UserID, f, followUpDismissed)
+			}
+			continue
+		}
+		last, awaiting := followup.Awaiting(threadMessages(thread))
+		switch {
+		case !awaiting:
+			h.closeFollowUp(ctx, nil, f.UserID, f, followUpReplied)
+			continue
+		case last.ID != f.MessageID:
+			h.db.FollowUps().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+				"messageId": last.ID, "sentAt": last.At,
+				"dueAt":     last.At.Add(followup.DelayFor(f.Recipients, fs.Delays, fs.Days)),
+				"updatedAt": time.Now(),
+			}})
+			continue
+		}
+
+		set := bson.M{"status": followUpDue, "updatedAt": time.Now()}
+		if fs.Resurface {
+			labelID, err := h.ensureLabel(ctx, client, f.UserID, followUpLabelName)
+			if err == nil {
+				err = h.restoreSnoozed(ctx, client, f.UserID, f.MessageID, labelID)
+			}
+			if err != nil {
+				log.Printf("followups: failed to resurface %s for %s: %v", f.MessageID, f.UserID, err)
+			} else {
+				set["resurfaced"] = true
+				h.logAction(ctx, f.UserID, f.MessageID, "unarchive", SourceSnooze)
+			}
+		}
+		h.db.FollowUps().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+	}
+}
+
+// GetFollowUps lists the caller's sent emails awaiting a reply. ?status=
+// selects "due" (the default: the delay passed with no answer), "waiting",
+// "replied", "dismissed" or "open" (waiting and due), soonest due first.
+func (h *Handler) GetFollowUps(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	filter := bson.M{"userId": userEmail}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+		filter["status"] = followUpDue
+	case "open":
+		filter["status"] = bson.M{"$in": bson.A{followUpWaiting, followUpDue}}
+	case followUpWaiting, followUpDue, followUpReplied, followUpDismissed:
+		filter["status"] = status
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status (due, waiting, open, replied, dismissed)")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.FollowUps().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "dueAt", Value: 1}}).SetLimit(200))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load follow-ups")
+		return
+	}
+	rows := make([]models.FollowUp, 0)
+	if err := cursor.All(ctx, &rows); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load follow-ups")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"followUps": rows})
+}
+
+// DismissFollowUp stops tracking a thread until the user writes in it again,
+// taking the follow-up label off a resurfaced email.
+func (h *Handler) DismissFollowUp(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid follow-up id")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var f models.FollowUp
+	if err := h.db.FollowUps().FindOne(ctx, bson.M{"_id": oid, "userId": userEmail}).Decode(&f); err != nil {
+		writeError(w, http.StatusNotFound, "Follow-up not found")
+		return
+	}
+	var gmailClient *gmailapi.Service
+	if f.Resurfaced {
+		gmailClient, _ = h.gmailClientFor(ctx, userEmail)
+	}
+	h.closeFollowUp(ctx, gmailClient, userEmail, f, followUpDismissed)
+	writeJSON(w, http.StatusOK, map[string]string{"status": followUpDismissed})
+}
+
+// GetFollowUpSettings returns the caller's follow-up tracker settings.
+func (h *Handler) GetFollowUpSettings(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	writeJSON(w, http.StatusOK, h.followUpSettings(ctx, userEmail))
+}
+
+// normalizeFollowUpSettings validates settings from the client and puts the
+// per-recipient entries in their canonical form. The error is user-facing.
+func normalizeFollowUpSettings(fs models.FollowUpSettings) (models.FollowUpSettings, error) {
+	if fs.Days == 0 {
+		fs.Days = followup.DefaultDays
+	}
+	if !followup.ValidDays(fs.Days) {
+		return fs, fmt.Errorf("Invalid delay (1–%d days)", followup.MaxDays)
+	}
+	if len(fs.Delays) > maxFollowUpDelays {
+		return fs, fmt.Errorf("Too many per-recipient delays (max %d)", maxFollowUpDelays)
+	}
+	seen := map[string]bool{}
+	delays := make([]models.FollowUpDelay, 0, len(fs.Delays))
+	for _, d := range fs.Delays {
+		value, _ := protect.NormalizeEntry(d.Recipient)
+		if value == "" {
+			return fs, fmt.Errorf("Invalid recipient %q", d.Recipient)
+		}
+		if !followup.ValidDays(d.Days) {
+			return fs, fmt.Errorf("Invalid delay for %s (1–%d days)", value, followup.MaxDays)
+		}
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		delays = append(delays, models.FollowUpDelay{Recipient: value, Days: d.Days})
+	}
+	fs.Delays = delays
+	return fs, nil
+}
+
+// UpdateFollowUpSettings replaces the caller's follow-up tracker settings and
+// recomputes when each waiting follow-up comes due under the new delays.
+func (h *Handler) UpdateFollowUpSettings(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	var in models.FollowUpSettings
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	fs, err := normalizeFollowUpSettings(in)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.db.Users().UpdateOne(ctx, bson.M{"email": userEmail},
+		bson.M{"$set": bson.M{"followUps": fs, "updatedAt": time.Now()}}); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update follow-up settings")
+		return
+	}
+
+	if cursor, err := h.db.FollowUps().Find(ctx, bson.M{"userId": userEmail, "status": followUpWaiting}); err == nil {
+		var waiting []models.FollowUp
+		cursor.All(ctx, &waiting)
+		for _, f := range waiting {
+			oid, _ := primitive.ObjectIDFromHex(f.ID)
+			h.db.FollowUps().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+				"dueAt": f.SentAt.Add(followup.DelayFor(f.Recipients, fs.Delays, fs.Days)),
+			}})
+		}
+	}
+	writeJSON(w, http.StatusOK, fs)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/followup"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func TestNormalizeFollowUpSettings(t *testing.T) {
+	fs, err := normalizeFollowUpSettings(models.FollowUpSettings{
+		Enabled: true,
+		Delays: []models.FollowUpDelay{
+			{Recipient: "Boss <Boss@Acme.example>", Days: 1},
+			{Recipient: "@slow.example", Days: 10},
+			{Recipient: "boss@acme.example", Days: 5}, // duplicate: first wins
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.Days != followup.DefaultDays {
+		t.Errorf("Days = %d, want the default", fs.Days)
+	}
+	want := []models.FollowUpDelay{{Recipient: "boss@acme.example", Days: 1}, {Recipient: "slow.example", Days: 10}}
+	if len(fs.Delays) != len(want) || fs.Delays[0] != want[0] || fs.Delays[1] != want[1] {
+		t.Errorf("Delays = %+v, want %+v", fs.Delays, want)
+	}
+
+	for _, bad := range []models.FollowUpSettings{
+		{Days: followup.MaxDays + 1},
+		{Delays: []models.FollowUpDelay{{Recipient: "", Days: 2}}},
+		{Delays: []models.FollowUpDelay{{Recipient: "a@b.example", Days: 0}}},
+	} {
+		if _, err := normalizeFollowUpSettings(bad); err == nil {
+			t.Errorf("normalizeFollowUpSettings(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestThreadMessagesAwaiting(t *testing.T) {
+	thread := &gmailapi.Thread{Messages: []*gmailapi.Message{
+		{Id: "in", InternalDate: 1000, LabelIds: []string{"INBOX"}},
+		{Id: "out", InternalDate: 2000, LabelIds: []string{"SENT"}},
+		{Id: "draft", InternalDate: 3000, LabelIds: []string{"DRAFT"}},
+	}}
+	last, ok := followup.Awaiting(threadMessages(thread))
+	if !ok || last.ID != "out" {
+		t.Errorf("Awaiting = %q, %v; want the sent message", last.ID, ok)
+	}
+}
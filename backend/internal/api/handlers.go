@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -12,11 +13,15 @@ import (
 	"github.com/nohe-sohbi/mailsorter/backend/internal/billing"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/crypto"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/database"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/embed"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/events"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/metrics"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	gmailapi "google.golang.org/api/gmail/v1"
 )
@@ -61,36 +66,77 @@ type Handler struct {
 	gmailService *gmail.Service
 	encryptor    *crypto.Encryptor
 	aiClient     *ai.MistralClient
+	embedder     embed.Provider
 	billing      BillingConfig
 	auth         *auth.Manager
 	jobQueue     chan string
-	metrics      *metrics.Registry
-	startedAt    time.Time
+	// unsubQueue carries bulk unsubscribe jobs to their own workers.
+	unsubQueue chan string
+	// mailtoLimiter caps, per user, the unsubscribe requests sent from their
+	// Gmail account.
+	mailtoLimiter *rateLimiter
+	metrics       *metrics.Registry
+	// notifyClient carries webhook deliveries; it only dials public addresses.
+	notifyClient *http.Client
+	// events fans live updates out to the users' open streams.
+	events *events.Bus
+	// hooks carries events to the users' webhooks (see emitWebhook), and
+	// hookSubs remembers which events each user's webhooks listen to.
+	hooks    *hookQueue
+	hookSubs *hookSubscriptions
+	// sentHistory caches who each user writes to, for priority scoring.
+	sentHistory *sentHistories
+	startedAt   time.Time
 }
 
 func NewHandler(db *database.Database, gmailService *gmail.Service, encryptor *crypto.Encryptor, aiClient *ai.MistralClient, billingCfg BillingConfig, authManager *auth.Manager) *Handler {
 	h := &Handler{
-		db:           db,
-		gmailService: gmailService,
-		encryptor:    encryptor,
-		aiClient:     aiClient,
-		billing:      billingCfg,
-		auth:         authManager,
-		jobQueue:     make(chan string, 256),
-		metrics:      metrics.New(),
-		startedAt:    time.Now(),
+		db:            db,
+		gmailService:  gmailService,
+		encryptor:     encryptor,
+		aiClient:      aiClient,
+		billing:       billingCfg,
+		auth:          authManager,
+		jobQueue:      make(chan string, 256),
+		unsubQueue:    make(chan string, 256),
+		mailtoLimiter: newRateLimiter(1.0/30, 5), // burst 5, then one every 30s
+		metrics:       metrics.New(),
+		notifyClient:  notify.NewHTTPClient(gmail.PublicIP),
+		events:        events.New(events.Options{}),
+		hooks:         newHookQueue(webhookUserBacklog, webhookQueueSize),
+		hookSubs:      newHookSubscriptions(webhookSubsTTL),
+		sentHistory:   newSentHistories(sentHistoryTTL),
+		startedAt:     time.Now(),
 	}
 	// Background pool that drains async analysis jobs.
 	h.startAnalysisWorkers(3)
+	// Separate workers for bulk unsubscribes, which wait on the mailto: limit.
+	h.startUnsubscribeWorkers(2)
 	// Background sweeper that returns due snoozed emails to the inbox.
 	h.startSnoozeLoop()
 	// Background scheduler that sends the daily email digest to opted-in users.
 	h.startDigestLoop()
 	// Background scheduler that periodically syncs opted-in users' inboxes.
 	h.startAutoSyncLoop()
+	// Background reconciler that keeps smart labels in line with Gmail.
+	h.startLabelSyncLoop()
+	// Background reconciler that closes stale and expired AI suggestions.
+	h.startSuggestionSyncLoop()
+	// Background sweeper that forgets idle users' event buffers.
+	h.startEventSweep()
+	// Background delivery workers and retry sweeper for the users' webhooks.
+	h.startWebhookLoop(webhookWorkers)
+	// One-off migration building sender aggregates for accounts that predate them.
+	go h.rebuildAllSenders(true)
 	return h
 }
 
+// SetEmbedder installs the embeddings provider behind inbox clustering. Left
+// unset (nil), clustering endpoints answer 503 like the other AI features.
+func (h *Handler) SetEmbedder(p embed.Provider) {
+	h.embedder = p
+}
+
 // HealthCheck is a readiness probe: it verifies the process can still reach
 // MongoDB (not just that the HTTP server is up) and reports the running build
 // and uptime. A failed datastore ping yields 503 so an orchestrator can pull
@@ -216,6 +262,10 @@ func (h *Handler) HandleAuthCallback(w http.ResponseWriter, r *http.Request) {
 }
 
 // Email endpoints
+
+// GetEmails lists a page of Gmail messages matching q (default the inbox),
+// each carrying the priority score stored at its last sync. sort=priority
+// orders the page by score and minPriority drops messages scored below it.
 func (h *Handler) GetEmails(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -251,6 +301,14 @@ func (h *Handler) GetEmails(w http.ResponseWriter, r *http.Request) {
 	// Get page token for pagination
 	pageToken := r.URL.Query().Get("pageToken")
 
+	byPriority := r.URL.Query().Get("sort") == "priority"
+	minPriority := 0
+	if v := r.URL.Query().Get("minPriority"); v != "" {
+		if n, err := parseInt64(v); err == nil && n > 0 {
+			minPriority = int(n)
+		}
+	}
+
 	resp, err := h.gmailService.ListMessagesWithPagination(gmailClient, query, maxResults, pageToken)
 	if err != nil {
 		http.Error(w, "Failed to fetch emails: "+err.Error(), http.StatusInternalServerError)
@@ -281,6 +339,19 @@ func (h *Handler) GetEmails(w http.ResponseWriter, r *http.Request) {
 		emails = append(emails, email)
 	}
 
+	ids := make([]string, 0, len(emails))
+	for _, e := range emails {
+		ids = append(ids, e.MessageID)
+	}
+	stored := h.storedScores(ctx, userEmail, ids)
+	for i := range emails {
+		if s, ok := stored[emails[i].MessageID]; ok {
+			emails[i].Priority, emails[i].PriorityReasons = s.Priority, s.PriorityReasons
+			emails[i].Risk, emails[i].RiskReasons = s.Risk, s.RiskReasons
+		}
+	}
+	emails = rankEmails(emails, minPriority, byPriority)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"emails":             emails,
@@ -352,6 +423,10 @@ func (h *Handler) syncInbox(ctx context.Context, userEmail string) (synced, tota
 		return 0, 0, 0, err
 	}
 
+	// Loaded before listing, see loadSnoozeWatch.
+	snoozes := h.loadSnoozeWatch(ctx, userEmail)
+	followUps := h.loadFollowUpWatch(ctx, userEmail)
+
 	messages, err := h.gmailService.ListMessages(gmailClient, "in:inbox", 100)
 	if err != nil {
 		return 0, 0, 0, err
@@ -359,15 +434,24 @@ func (h *Handler) syncInbox(ctx context.Context, userEmail string) (synced, tota
 
 	// Autopilot: when the user opted in, run their deterministic rules over each
 	// freshly synced email — instant, AI-free, quota-free triage at sync time.
+	settings := h.userSettings(ctx, userEmail)
 	var autoRules []models.SortingRule
 	var protectedList []string
-	if h.autoApplyRulesEnabled(ctx, userEmail) {
+	if settings.AutoApplyRules {
 		autoRules = h.enabledRules(ctx, userEmail)
 		protectedList = h.protectedValues(ctx, userEmail)
 	}
 	labelCache := map[string]string{}
 	byRule := map[string]int{}
 
+	ids := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.Id)
+	}
+	sc := h.newScorer(ctx, gmailClient, userEmail, ids)
+	trusted := sc.trustedDomains()
+	unsubscribed := h.doneUnsubscribes(ctx, userEmail)
+
 	for _, msg := range messages {
 		from, subject, to, date := gmail.ParseEmailHeaders(msg)
 		body := gmail.GetEmailBody(msg)
@@ -390,12 +474,36 @@ func (h *Handler) syncInbox(ctx context.Context, userEmail string) (synced, tota
 			UnsubOneClick: oneClick,
 			CreatedAt:     time.Now(),
 		}
+		rk := assessRisk(msg, from, trusted)
+		email.Risk, email.RiskReasons = rk.Score, rk.Reasons
+		pr := sc.score(email)
+		email.Priority, email.PriorityReasons = pr.Score, pr.Reasons
 
+		// The copy stored before this sync tells the sender aggregate whether
+		// the message is new or only changed read state.
 		filter := bson.M{"messageId": msg.Id, "userId": userEmail}
 		update := bson.M{"$set": email}
-		opts := options.Update().SetUpsert(true)
-		if _, uErr := h.db.Emails().UpdateOne(ctx, filter, update, opts); uErr == nil {
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before).
+			SetProjection(bson.M{"isRead": 1})
+		var prev models.Email
+		switch uErr := h.db.Emails().FindOneAndUpdate(ctx, filter, update, opts).Decode(&prev); {
+		case uErr == nil:
+			synced++
+			h.trackSender(ctx, userEmail, &prev, email)
+			h.checkSnoozes(ctx, gmailClient, userEmail, snoozes, email, false)
+		case errors.Is(uErr, mongo.ErrNoDocuments):
 			synced++
+			h.trackSender(ctx, userEmail, nil, email)
+			h.checkSnoozes(ctx, gmailClient, userEmail, snoozes, email, true)
+			h.checkFollowUpReply(ctx, gmailClient, userEmail, followUps, email)
+			// New mail from a sender the user unsubscribed from, past the
+			// grace period: keep the evidence and, if the user asked for it,
+			// an archive/trash rule that runs from this very email on.
+			if u, ok := unsubscribed[senderAddress(from)]; ok && ignoresUnsubscribe(u, email.ReceivedDate) {
+				if rule := h.noteIgnoredUnsubscribe(ctx, userEmail, settings.UnsubscribeEnforcement, u, email); rule != nil && settings.AutoApplyRules {
+					autoRules = append(autoRules, *rule)
+				}
+			}
 		}
 
 		if len(autoRules) > 0 {
@@ -419,7 +527,15 @@ func (h *Handler) syncInbox(ctx context.Context, userEmail string) (synced, tota
 			bson.M{"$inc": bson.M{"appliedCount": n}},
 		)
 	}
+	h.publishRules(userEmail, "sync", byRule)
 
+	if fs := h.followUpSettings(ctx, userEmail); fs.Enabled {
+		h.scanSentForFollowUps(ctx, gmailClient, userEmail, fs)
+	}
+
+	h.publish(userEmail, events.TypeSync, map[string]int{
+		"synced": synced, "total": len(messages), "rulesApplied": rulesApplied,
+	})
 	return synced, len(messages), rulesApplied, nil
 }
 
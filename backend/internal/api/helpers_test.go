@@ -77,3 +77,21 @@ func TestRuleForSender(t *testing.T) {
 		t.Error("a label rule without a label name should fail validation")
 	}
 }
+
+func TestIsAdmin(t *testing.T) {
+	prev := AdminEmails
+	defer func() { AdminEmails = prev }()
+
+	AdminEmails = []string{"ops@mailsorter.dev", " Boss@Example.com "}
+	cases := map[string]bool{
+		"ops@mailsorter.dev": true,
+		"boss@example.com":   true, // case-insensitive, trimmed
+		"user@example.com":   false,
+		"":                   false,
+	}
+	for in, want := range cases {
+		if got := isAdmin(in); got != want {
+			t.Errorf("isAdmin(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -134,6 +134,11 @@ func (h *Handler) UndoAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Un-archiving a snoozed email ends its snooze, label included.
+	if entry.Source == SourceSnooze && inverse == "unarchive" {
+		h.closeSnoozes(ctx, gmailClient, userEmail, entry.MessageID, snoozeCancelled)
+	}
+
 	h.db.ActionLog().UpdateOne(ctx,
 		bson.M{"_id": oid},
 		bson.M{"$set": bson.M{"undone": true, "undoneAt": time.Now()}},
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+// hookQueue holds the webhook events waiting for a dispatcher worker, one FIFO
+// per user. Users take turns: a worker serves the user at the head of the
+// ready line, and that user rejoins the back of the line only once its
+// delivery is over. A user's events thus go out one at a time and in order,
+// and a bulk action or a slow endpoint holds up that user alone.
+type hookQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string][]hookEvent
+	ready   []string        // users with pending events and none in flight
+	busy    map[string]bool // users with a delivery in flight
+	queued  int
+	perUser int
+	max     int
+	closed  bool
+}
+
+func newHookQueue(perUser, max int) *hookQueue {
+	q := &hookQueue{pending: map[string][]hookEvent{}, busy: map[string]bool{}, perUser: perUser, max: max}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues ev, reporting false when the user's backlog or the whole queue
+// is full (or closed) and the event was dropped.
+func (q *hookQueue) push(ev hookEvent) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.queued >= q.max || len(q.pending[ev.user]) >= q.perUser {
+		return false
+	}
+	q.pending[ev.user] = append(q.pending[ev.user], ev)
+	q.queued++
+	if len(q.pending[ev.user]) == 1 && !q.busy[ev.user] {
+		q.ready = append(q.ready, ev.user)
+		q.cond.Signal()
+	}
+	return true
+}
+
+// next blocks until a user's event is ready and hands it out, marking the user
+// busy until done. It reports false once the queue is closed.
+func (q *hookQueue) next() (hookEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ready) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return hookEvent{}, false
+	}
+	user := q.ready[0]
+	q.ready = q.ready[1:]
+	ev := q.pending[user][0]
+	if rest := q.pending[user][1:]; len(rest) > 0 {
+		q.pending[user] = rest
+	} else {
+		delete(q.pending, user)
+	}
+	q.queued--
+	q.busy[user] = true
+	return ev, true
+}
+
+// done ends the user's delivery in flight and puts the user back in line,
+// behind the others, when more of its events are waiting.
+func (q *hookQueue) done(user string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.busy, user)
+	if len(q.pending[user]) > 0 {
+		q.ready = append(q.ready, user)
+		q.cond.Signal()
+	}
+}
+
+// close wakes the workers up and makes them return.
+func (q *hookQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// hookSubscriptions caches, per user, the events their enabled webhooks listen
+// to, so emitting an event nobody wants costs no lookup and no queue slot.
+// Changes made through the API forget the user's entry at once; the TTL
+// bounds how long other instances keep a stale one.
+type hookSubscriptions struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	users map[string]hookSubEntry
+}
+
+type hookSubEntry struct {
+	events map[string]bool
+	at     time.Time
+}
+
+func newHookSubscriptions(ttl time.Duration) *hookSubscriptions {
+	return &hookSubscriptions{ttl: ttl, users: map[string]hookSubEntry{}}
+}
+
+// get returns the user's cached event set, if still fresh.
+func (c *hookSubscriptions) get(user string, now time.Time) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.users[user]
+	if !ok || now.Sub(e.at) > c.ttl {
+		return nil, false
+	}
+	return e.events, true
+}
+
+// set caches the events the user's enabled webhooks subscribe to.
+func (c *hookSubscriptions) set(user string, hooks []models.Webhook, now time.Time) map[string]bool {
+	events := map[string]bool{}
+	for _, w := range hooks {
+		if !w.Enabled {
+			continue
+		}
+		for _, e := range w.Events {
+			events[e] = true
+		}
+	}
+	c.mu.Lock()
+	c.users[user] = hookSubEntry{events: events, at: now}
+	c.mu.Unlock()
+	return events
+}
+
+// forget drops the user's entry after a change to their webhooks.
+func (c *hookSubscriptions) forget(user string) {
+	c.mu.Lock()
+	delete(c.users, user)
+	c.mu.Unlock()
+}
+
+// sweep drops the stale entries.
+func (c *hookSubscriptions) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for user, e := range c.users {
+		if now.Sub(e.at) > c.ttl {
+			delete(c.users, user)
+		}
+	}
+}
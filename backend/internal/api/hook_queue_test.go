@@ -0,0 +1,105 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+// A user with a long backlog gets one turn, then waits behind the others.
+func TestHookQueueTakesTurns(t *testing.T) {
+	q := newHookQueue(10, 100)
+	for _, ev := range []hookEvent{
+		{user: "bulk", id: "b1"}, {user: "bulk", id: "b2"}, {user: "bulk", id: "b3"},
+		{user: "alice", id: "a1"},
+	} {
+		if !q.push(ev) {
+			t.Fatalf("push %s refused", ev.id)
+		}
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		ev, ok := q.next()
+		if !ok {
+			t.Fatal("queue closed")
+		}
+		got = append(got, ev.id)
+		q.done(ev.user)
+	}
+	want := []string{"b1", "a1", "b2", "b3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+// A user's events are never handed out concurrently, so they stay in order.
+func TestHookQueueOneInFlightPerUser(t *testing.T) {
+	q := newHookQueue(10, 100)
+	q.push(hookEvent{user: "bob", id: "1"})
+	q.push(hookEvent{user: "bob", id: "2"})
+	q.push(hookEvent{user: "carol", id: "3"})
+
+	first, _ := q.next()
+	second, _ := q.next()
+	if first.user == second.user {
+		t.Fatalf("%s handed out twice while in flight", first.user)
+	}
+	q.done(first.user)
+	third, _ := q.next()
+	if third.id != "2" {
+		t.Errorf("next = %s, want bob's second event", third.id)
+	}
+}
+
+func TestHookQueueBounds(t *testing.T) {
+	q := newHookQueue(2, 3)
+	if !q.push(hookEvent{user: "a"}) || !q.push(hookEvent{user: "a"}) {
+		t.Fatal("backlog refused too early")
+	}
+	if q.push(hookEvent{user: "a"}) {
+		t.Error("user backlog exceeded")
+	}
+	if !q.push(hookEvent{user: "b"}) {
+		t.Error("another user starved by a's backlog")
+	}
+	if q.push(hookEvent{user: "c"}) {
+		t.Error("queue size exceeded")
+	}
+
+	done := make(chan bool)
+	empty := newHookQueue(1, 1)
+	go func() {
+		_, ok := empty.next()
+		done <- ok
+	}()
+	empty.close()
+	if <-done {
+		t.Error("next returned an event after close")
+	}
+}
+
+func TestHookSubscriptions(t *testing.T) {
+	c := newHookSubscriptions(time.Minute)
+	now := time.Now()
+	if _, ok := c.get("me", now); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	events := c.set("me", []models.Webhook{
+		{Enabled: true, Events: []string{"rule.fired"}},
+		{Enabled: false, Events: []string{"job.finished"}},
+	}, now)
+	if !events["rule.fired"] || events["job.finished"] {
+		t.Errorf("events = %v; disabled webhooks must not count", events)
+	}
+	if _, ok := c.get("me", now.Add(2*time.Minute)); ok {
+		t.Error("stale entry served")
+	}
+	c.forget("me")
+	if _, ok := c.get("me", now); ok {
+		t.Error("entry kept after forget")
+	}
+}
@@ -8,9 +8,13 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/events"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // analysisJobCap bounds how many emails a single async job will process.
@@ -43,28 +47,22 @@ func (h *Handler) processAnalysisJob(jobID string) {
 	}
 
 	h.updateJob(ctx, objectID, bson.M{"status": "running", "updatedAt": time.Now()})
+	switch job.Kind {
+	case jobKindCluster:
+		h.processClusterJob(ctx, objectID, job)
+		return
+	case jobKindUnsubscribe:
+		h.processUnsubscribeJob(objectID, job)
+		return
+	}
 
 	onProgress := func(p analysisProgress) {
-		h.updateJob(ctx, objectID, bson.M{
-			"total":              p.Total,
-			"processed":          p.Processed,
-			"autoApplied":        p.AutoApplied,
-			"suggestionsCreated": p.SuggestionsCreated,
-			"cachedHits":         p.CachedHits,
-			"updatedAt":          time.Now(),
-		})
+		h.updateJob(ctx, objectID, h.jobProgressFields(p))
 	}
 
 	p, _, runErr := h.runAnalysis(ctx, job.UserID, job.EmailIDs, onProgress)
 
-	final := bson.M{
-		"total":              p.Total,
-		"processed":          p.Processed,
-		"autoApplied":        p.AutoApplied,
-		"suggestionsCreated": p.SuggestionsCreated,
-		"cachedHits":         p.CachedHits,
-		"updatedAt":          time.Now(),
-	}
+	final := h.jobProgressFields(p)
 	if runErr != nil {
 		final["status"] = "error"
 		final["error"] = runErr.Error()
@@ -75,8 +73,47 @@ func (h *Handler) processAnalysisJob(jobID string) {
 	h.updateJob(ctx, objectID, final)
 }
 
+// jobProgressFields maps a run's progress, token usage included, onto the job
+// document.
+func (h *Handler) jobProgressFields(p analysisProgress) bson.M {
+	model := ""
+	if h.aiClient != nil {
+		model = h.aiClient.Model()
+	}
+	return bson.M{
+		"total":              p.Total,
+		"processed":          p.Processed,
+		"autoApplied":        p.AutoApplied,
+		"suggestionsCreated": p.SuggestionsCreated,
+		"cachedHits":         p.CachedHits,
+		"model":              model,
+		"promptTokens":       p.Usage.PromptTokens,
+		"completionTokens":   p.Usage.CompletionTokens,
+		"totalTokens":        p.Usage.TotalTokens,
+		"costUsd":            ai.CostUSD(model, p.Usage),
+		"budgetExceeded":     p.BudgetExceeded,
+		"updatedAt":          time.Now(),
+	}
+}
+
 func (h *Handler) updateJob(ctx context.Context, id primitive.ObjectID, set bson.M) {
-	h.db.AnalysisJobs().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	h.changeJob(ctx, id, bson.M{"$set": set})
+}
+
+// changeJob applies update to a job and streams the updated job to its owner,
+// in the shape GetJob returns. The update that ends the job also goes to the
+// owner's webhooks.
+func (h *Handler) changeJob(ctx context.Context, id primitive.ObjectID, update bson.M) {
+	var job models.AnalysisJob
+	err := h.db.AnalysisJobs().FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&job)
+	if err != nil {
+		return
+	}
+	h.publish(job.UserID, events.TypeJob, job)
+	if set, ok := update["$set"].(bson.M); ok && (set["status"] == "done" || set["status"] == "error") {
+		h.emitWebhook(job.UserID, notify.HookJobFinished, job)
+	}
 }
 
 // EnqueueAnalyze creates an async analysis job and returns its id immediately,
@@ -140,8 +177,21 @@ func (h *Handler) EnqueueAnalyze(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"jobId": jobID, "status": "queued"})
 }
 
-// GetJob returns the live status of an analysis job (polled by the client).
+// GetJob returns the live status of an async job — analysis, clustering or
+// bulk unsubscribe (polled by the client).
 func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	h.serveJob(w, r, "")
+}
+
+// GetUnsubscribeJob is GetJob limited to bulk unsubscribe jobs, so the route
+// only reveals what its API token scope (emails:act) covers.
+func (h *Handler) GetUnsubscribeJob(w http.ResponseWriter, r *http.Request) {
+	h.serveJob(w, r, jobKindUnsubscribe)
+}
+
+// serveJob answers with the caller's job {id}, of the given kind unless kind
+// is empty.
+func (h *Handler) serveJob(w http.ResponseWriter, r *http.Request, kind string) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
 		http.Error(w, "User email required", http.StatusUnauthorized)
@@ -157,8 +207,12 @@ func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	filter := bson.M{"_id": objectID, "userId": userEmail}
+	if kind != "" {
+		filter["kind"] = kind
+	}
 	var job models.AnalysisJob
-	if err := h.db.AnalysisJobs().FindOne(ctx, bson.M{"_id": objectID, "userId": userEmail}).Decode(&job); err != nil {
+	if err := h.db.AnalysisJobs().FindOne(ctx, filter).Decode(&job); err != nil {
 		http.Error(w, "Job not found", http.StatusNotFound)
 		return
 	}
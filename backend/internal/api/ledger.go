@@ -4,20 +4,24 @@ import (
 	"context"
 	"time"
 
+	"github.com/nohe-sohbi/mailsorter/backend/internal/events"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 )
 
 // Action ledger sources. Every mutating Gmail action is tagged with where it
 // originated, so the activity recap can attribute work truthfully.
 const (
-	SourceDirect      = "direct"      // single explicit action from the reader/shortcuts
-	SourceRule        = "rule"        // deterministic rule (manual apply or at-sync autopilot)
-	SourceAI          = "ai"          // an AI suggestion the user applied
-	SourceAIAuto      = "ai-auto"     // sender auto-pilot (preference auto-applied)
-	SourceBulk        = "bulk"        // bulk action across a sender
-	SourceSnooze      = "snooze"      // snooze out of / back into the inbox
-	SourceUnsubscribe = "unsubscribe" // archive triggered by an unsubscribe sweep
-	SourceUndo        = "undo"        // a reversal performed from the action history
+	SourceDirect      = "direct"       // single explicit action from the reader/shortcuts
+	SourceRule        = "rule"         // deterministic rule (manual apply or at-sync autopilot)
+	SourceAI          = "ai"           // an AI suggestion the user applied
+	SourceAIAuto      = "ai-auto"      // sender auto-pilot (preference auto-applied)
+	SourceAIThreshold = "ai-threshold" // AI suggestion applied above the user's confidence threshold
+	SourceBulk        = "bulk"         // bulk action across a sender
+	SourceSnooze      = "snooze"       // snooze out of / back into the inbox
+	SourceUnsubscribe = "unsubscribe"  // an unsubscribe performed server-side, or the archive sweep after it
+	SourceUndo        = "undo"         // a reversal performed from the action history
+	SourceDraft       = "draft"        // an AI reply saved to Gmail drafts (never sent)
 )
 
 // logAction appends one entry to the action ledger. Best-effort: a ledger
@@ -33,4 +37,8 @@ func (h *Handler) logAction(ctx context.Context, userEmail, messageID, action, s
 		Source:    source,
 		CreatedAt: time.Now(),
 	})
+	h.noteSenderAction(ctx, userEmail, messageID, action)
+	entry := map[string]string{"messageId": messageID, "action": action, "source": source}
+	h.publish(userEmail, events.TypeAction, entry)
+	h.emitWebhook(userEmail, notify.HookActionLogged, entry)
 }
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/auth"
 )
 
 // ctxKey is a private type for request-scoped context values.
@@ -37,7 +39,8 @@ func isPublicPath(path string) bool {
 	return false
 }
 
-// authMiddleware enforces a valid session token on every non-public route.
+// authMiddleware enforces a valid session token, or a personal access token
+// carrying the route's scope (see routeScopes), on every non-public route.
 //
 // Crucially, it ALWAYS strips any client-supplied X-User-Email header first and
 // only re-sets it after verifying the bearer token. Downstream handlers keep
@@ -53,11 +56,34 @@ func (h *Handler) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := bearerToken(r)
+		token := sessionToken(r)
 		if token == "" {
 			http.Error(w, "Authentication required", http.StatusUnauthorized)
 			return
 		}
+		if auth.IsPersonalToken(token) {
+			// Personal access tokens are long-lived: header only, never from a
+			// query string that ends up in logs, and limited to their scopes.
+			if bearerToken(r) != token {
+				http.Error(w, "API tokens must be sent in the Authorization header", http.StatusUnauthorized)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			email, scopes, err := h.verifyAPIToken(ctx, token, remoteIP(r))
+			cancel()
+			if err != nil {
+				http.Error(w, "Invalid, expired or revoked API token", http.StatusUnauthorized)
+				return
+			}
+			if msg := tokenScopeError(r, scopes); msg != "" {
+				http.Error(w, msg, http.StatusForbidden)
+				return
+			}
+			r.Header.Set("X-User-Email", email)
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		email, err := h.auth.VerifySession(token)
 		if err != nil {
 			http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
@@ -82,6 +108,25 @@ func bearerToken(r *http.Request) string {
 	return h
 }
 
+// queryTokenPaths are the routes that also accept the session token as a
+// ?token= query parameter, for browser clients that cannot set headers
+// (EventSource). Kept to the strict minimum: query strings end up in logs.
+var queryTokenPaths = map[string]bool{
+	"/api/events": true,
+}
+
+// sessionToken is the request's session token: the Authorization header, or
+// the token query parameter on queryTokenPaths.
+func sessionToken(r *http.Request) string {
+	if t := bearerToken(r); t != "" {
+		return t
+	}
+	if queryTokenPaths[r.URL.Path] {
+		return strings.TrimSpace(r.URL.Query().Get("token"))
+	}
+	return ""
+}
+
 // recoverMiddleware turns a panic in any handler into a 500 instead of crashing
 // the whole server process, logging the offending request for diagnosis.
 func recoverMiddleware(next http.Handler) http.Handler {
@@ -129,6 +174,12 @@ func (s *statusRecorder) WriteHeader(code int) {
 	s.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController, so
+// streaming handlers can still flush and set deadlines through the recorder.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
 // loggingMiddleware emits one structured line per request once it completes.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -230,11 +281,16 @@ func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
 // clientKey identifies the caller for rate-limiting: prefer the bearer token,
 // fall back to the network address.
 func clientKey(r *http.Request) string {
-	if t := bearerToken(r); t != "" {
+	if t := sessionToken(r); t != "" {
 		return "tok:" + t
 	}
+	return "ip:" + remoteIP(r)
+}
+
+// remoteIP is the client address without its port.
+func remoteIP(r *http.Request) string {
 	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return "ip:" + ip
+		return ip
 	}
-	return "ip:" + r.RemoteAddr
+	return r.RemoteAddr
 }
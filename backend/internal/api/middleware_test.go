@@ -52,6 +52,25 @@ func TestBearerToken(t *testing.T) {
 	}
 }
 
+func TestSessionTokenQueryOnlyOnStreams(t *testing.T) {
+	cases := []struct {
+		url, header, want string
+	}{
+		{"/api/events?token=abc", "", "abc"},
+		{"/api/events?token=abc", "Bearer hdr", "hdr"},
+		{"/api/usage?token=abc", "", ""}, // query tokens are not accepted elsewhere
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		if got := sessionToken(r); got != c.want {
+			t.Errorf("sessionToken(%s, %q) = %q, want %q", c.url, c.header, got, c.want)
+		}
+	}
+}
+
 func TestRateLimiterAllowsBurstThenBlocks(t *testing.T) {
 	// Effectively no refill during the test window so we measure the burst.
 	rl := newRateLimiter(0.0001, 3)
@@ -0,0 +1,435 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SMTPRelay is the operator's mail relay behind the "smtp" channel; nil when
+// SMTP_HOST is not set, which makes the channel unavailable. Set from config
+// at startup.
+var SMTPRelay *notify.SMTP
+
+// Delivery statuses (see models.Delivery).
+const (
+	deliveryPending  = "pending"
+	deliveryRetrying = "retrying"
+	deliverySent     = "sent"
+	deliveryFailed   = "failed"
+)
+
+// maxNotifyChannels caps how many channels a user can configure; one per type.
+const maxNotifyChannels = 4
+
+// minWebhookSecretLen is the shortest signing secret a user may supply.
+const minWebhookSecretLen = 16
+
+// deliveryRetryBatch caps how many due retries one sweep resends.
+const deliveryRetryBatch = 100
+
+// userChannels loads the user's notification channels; none configured means
+// their own Gmail account, which is where the digest always went.
+func (h *Handler) userChannels(ctx context.Context, userEmail string) []models.NotifyChannel {
+	var doc struct {
+		NotifyChannels []models.NotifyChannel `bson:"notifyChannels"`
+	}
+	h.db.Users().FindOne(ctx, bson.M{"email": userEmail},
+		options.FindOne().SetProjection(bson.M{"notifyChannels": 1})).Decode(&doc)
+	if len(doc.NotifyChannels) == 0 {
+		return []models.NotifyChannel{{Type: notify.ChannelGmail}}
+	}
+	return doc.NotifyChannels
+}
+
+// notifyDriver builds the driver for one of the user's channels. An error
+// means the channel cannot work at all (relay not configured, credentials
+// that no longer decrypt) and is recorded as a permanent failure.
+func (h *Handler) notifyDriver(userEmail string, c models.NotifyChannel) (notify.Driver, error) {
+	switch c.Type {
+	case notify.ChannelGmail:
+		return &notify.Gmail{From: userEmail, SendRaw: func(ctx context.Context, raw string) error {
+			client, err := h.gmailClientFor(ctx, userEmail)
+			if err != nil {
+				return err
+			}
+			if err := h.gmailService.SendMessage(client, raw); err != nil {
+				// A missing gmail.send scope (user connected before the digest
+				// feature) lands here; they need to reconnect Gmail to grant it.
+				if gmail.IsPermanent(err) {
+					return notify.Permanent(err)
+				}
+				return err
+			}
+			return nil
+		}}, nil
+	case notify.ChannelSMTP:
+		if SMTPRelay == nil {
+			return nil, errors.New("no SMTP relay configured on this server")
+		}
+		return SMTPRelay, nil
+	case notify.ChannelWebhook, notify.ChannelSlack:
+		target, err := h.encryptor.Decrypt(c.URL)
+		if err != nil {
+			return nil, errors.New("cannot decrypt the webhook URL")
+		}
+		if c.Type == notify.ChannelSlack {
+			return &notify.Slack{URL: target, Client: h.notifyClient}, nil
+		}
+		secret, err := h.encryptor.Decrypt(c.Secret)
+		if err != nil {
+			return nil, errors.New("cannot decrypt the webhook secret")
+		}
+		return &notify.Webhook{URL: target, Secret: secret, Client: h.notifyClient}, nil
+	}
+	return nil, fmt.Errorf("unknown channel %q", c.Type)
+}
+
+// deliver sends msg to the user over each of their channels, recording one
+// Delivery per channel. Transient failures are picked up again by
+// retryDeliveries.
+func (h *Handler) deliver(ctx context.Context, userEmail string, msg notify.Message) []models.Delivery {
+	if msg.At.IsZero() {
+		msg.At = time.Now()
+	}
+	var out []models.Delivery
+	for _, c := range h.userChannels(ctx, userEmail) {
+		now := time.Now()
+		d := models.Delivery{
+			UserID: userEmail, Kind: msg.Kind, Channel: c.Type, Status: deliveryPending,
+			Subject: msg.Subject, Text: msg.Text, HTML: msg.HTML, CreatedAt: now, UpdatedAt: now,
+		}
+		if res, err := h.db.Deliveries().InsertOne(ctx, d); err != nil {
+			log.Printf("notify: failed to record %s delivery for %s: %v", c.Type, userEmail, err)
+		} else if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+			d.ID = oid.Hex()
+		}
+		h.attemptDelivery(ctx, &d, c, msg)
+		out = append(out, d)
+	}
+	return out
+}
+
+// attemptDelivery makes one attempt at d over channel c and records the
+// outcome: sent, retrying at notify.RetryAt, or failed.
+func (h *Handler) attemptDelivery(ctx context.Context, d *models.Delivery, c models.NotifyChannel, msg notify.Message) {
+	driver, err := h.notifyDriver(d.UserID, c)
+	if err != nil {
+		err = notify.Permanent(err)
+	} else {
+		err = driver.Send(ctx, msg)
+	}
+	if err != nil && ctx.Err() != nil {
+		return // the caller ran out of time: not an attempt, it stays due
+	}
+
+	now := time.Now()
+	d.Attempts++
+	d.UpdatedAt = now
+	d.NextAttemptAt = time.Time{}
+	if err == nil {
+		d.Status, d.SentAt, d.LastError = deliverySent, now, ""
+	} else {
+		d.LastError = err.Error()
+		d.Status = deliveryFailed
+		if at, ok := notify.RetryAt(d.Attempts, err, now); ok {
+			d.Status, d.NextAttemptAt = deliveryRetrying, at
+		}
+		log.Printf("notify: %s delivery to %s failed (attempt %d, %s): %v", d.Channel, d.UserID, d.Attempts, d.Status, err)
+	}
+
+	if d.ID == "" {
+		return
+	}
+	oid, _ := primitive.ObjectIDFromHex(d.ID)
+	h.db.Deliveries().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+		"status":        d.Status,
+		"attempts":      d.Attempts,
+		"lastError":     d.LastError,
+		"nextAttemptAt": d.NextAttemptAt,
+		"sentAt":        d.SentAt,
+		"updatedAt":     d.UpdatedAt,
+	}})
+}
+
+// retryDeliveries resends the deliveries whose retry is due, over the user's
+// current configuration of that channel; a channel removed since is given up.
+func (h *Handler) retryDeliveries() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	cursor, err := h.db.Deliveries().Find(ctx,
+		bson.M{"status": deliveryRetrying, "nextAttemptAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetLimit(deliveryRetryBatch))
+	if err != nil {
+		log.Printf("notify: failed to list due retries: %v", err)
+		return
+	}
+	var due []models.Delivery
+	if err := cursor.All(ctx, &due); err != nil {
+		log.Printf("notify: failed to decode due retries: %v", err)
+		return
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return // the rest wait for the next sweep
+		}
+		d := &due[i]
+		msg := notify.Message{Kind: d.Kind, To: d.UserID, Subject: d.Subject, Text: d.Text, HTML: d.HTML, At: d.CreatedAt}
+		channel := models.NotifyChannel{Type: d.Channel}
+		found := false
+		for _, c := range h.userChannels(ctx, d.UserID) {
+			if c.Type == d.Channel {
+				channel, found = c, true
+				break
+			}
+		}
+		if !found {
+			oid, _ := primitive.ObjectIDFromHex(d.ID)
+			h.db.Deliveries().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+				"status": deliveryFailed, "lastError": "channel removed", "nextAttemptAt": time.Time{}, "updatedAt": time.Now(),
+			}})
+			continue
+		}
+		h.attemptDelivery(ctx, d, channel, msg)
+	}
+}
+
+// notifyChannelInput is one channel in PUT /api/notify/channels. URL is the
+// webhook or Slack URL; Secret optionally sets the webhook signing secret.
+type notifyChannelInput struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// notifyChannelView is a channel as the API shows it: webhook URLs are
+// credentials, so only their host is echoed back. Secret is only set in the
+// response that generated it.
+type notifyChannelView struct {
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// channelView describes a stored channel without its credentials.
+func (h *Handler) channelView(userEmail string, c models.NotifyChannel) notifyChannelView {
+	v := notifyChannelView{Type: c.Type}
+	switch c.Type {
+	case notify.ChannelGmail, notify.ChannelSMTP:
+		v.Target = userEmail
+	case notify.ChannelWebhook, notify.ChannelSlack:
+		if raw, err := h.encryptor.Decrypt(c.URL); err == nil {
+			if u, err := url.Parse(raw); err == nil {
+				v.Target = u.Host
+			}
+		}
+	}
+	return v
+}
+
+// GetNotifyChannels lists where the caller's notifications go.
+func (h *Handler) GetNotifyChannels(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	views := []notifyChannelView{}
+	for _, c := range h.userChannels(ctx, userEmail) {
+		views = append(views, h.channelView(userEmail, c))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"channels": views, "smtpAvailable": SMTPRelay != nil})
+}
+
+// UpdateNotifyChannels replaces the caller's channels. A webhook keeps its
+// signing secret while its URL is unchanged; a new webhook without a secret
+// gets a generated one, returned once in this response.
+func (h *Handler) UpdateNotifyChannels(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	var in struct {
+		Channels []notifyChannelInput `json:"channels"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if len(in.Channels) > maxNotifyChannels {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many channels (max %d)", maxNotifyChannels))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	current := h.userChannels(ctx, userEmail)
+	seen := map[string]bool{}
+	channels := make([]models.NotifyChannel, 0, len(in.Channels))
+	views := make([]notifyChannelView, 0, len(in.Channels))
+	for _, c := range in.Channels {
+		if !notify.ValidChannel(c.Type) {
+			writeError(w, http.StatusBadRequest, "Unknown channel (gmail, smtp, webhook, slack): "+c.Type)
+			return
+		}
+		if seen[c.Type] {
+			writeError(w, http.StatusBadRequest, "Duplicate channel: "+c.Type)
+			return
+		}
+		seen[c.Type] = true
+
+		stored := models.NotifyChannel{Type: c.Type}
+		generated := ""
+		switch c.Type {
+		case notify.ChannelSMTP:
+			if SMTPRelay == nil {
+				writeError(w, http.StatusBadRequest, "SMTP delivery is not available on this server")
+				return
+			}
+		case notify.ChannelWebhook, notify.ChannelSlack:
+			if err := notify.CheckURL(c.URL, gmail.PublicIP); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid webhook URL: "+err.Error())
+				return
+			}
+			encURL, err := h.encryptor.Encrypt(c.URL)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to store channel")
+				return
+			}
+			stored.URL = encURL
+			if c.Type == notify.ChannelSlack {
+				break
+			}
+			secret := c.Secret
+			if secret != "" && len(secret) < minWebhookSecretLen {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Webhook secret too short (min %d characters)", minWebhookSecretLen))
+				return
+			}
+			if secret == "" {
+				secret = h.existingWebhookSecret(current, c.URL)
+			}
+			if secret == "" {
+				secret = newWebhookSecret()
+				generated = secret
+			}
+			if stored.Secret, err = h.encryptor.Encrypt(secret); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to store channel")
+				return
+			}
+		}
+		channels = append(channels, stored)
+		v := h.channelView(userEmail, stored)
+		v.Secret = generated
+		views = append(views, v)
+	}
+
+	update := bson.M{"$set": bson.M{"notifyChannels": channels, "updatedAt": time.Now()}}
+	if len(channels) == 0 {
+		update = bson.M{"$unset": bson.M{"notifyChannels": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+		views = append(views, h.channelView(userEmail, models.NotifyChannel{Type: notify.ChannelGmail}))
+	}
+	if _, err := h.db.Users().UpdateOne(ctx, bson.M{"email": userEmail}, update); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update channels")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"channels": views, "smtpAvailable": SMTPRelay != nil})
+}
+
+// existingWebhookSecret returns the stored signing secret of the webhook
+// channel at rawURL, if the user already has it.
+func (h *Handler) existingWebhookSecret(current []models.NotifyChannel, rawURL string) string {
+	for _, c := range current {
+		if c.Type != notify.ChannelWebhook {
+			continue
+		}
+		if u, err := h.encryptor.Decrypt(c.URL); err == nil && u == rawURL {
+			if secret, err := h.encryptor.Decrypt(c.Secret); err == nil {
+				return secret
+			}
+		}
+	}
+	return ""
+}
+
+// newWebhookSecret returns 32 random bytes, hex-encoded.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TestNotifyChannels sends a short test notification over each of the
+// caller's channels right away and reports how each attempt went.
+func (h *Handler) TestNotifyChannels(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deliveries := h.deliver(ctx, userEmail, notify.Message{
+		Kind:    "test",
+		To:      userEmail,
+		Subject: "Mailsorter — notification de test",
+		Text:    "Ce canal recevra votre récap Mailsorter.",
+		HTML:    `<p style="font-family:system-ui,-apple-system,Segoe UI,Roboto,sans-serif">Ce canal recevra votre récap Mailsorter.</p>`,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+// GetDeliveries lists the caller's recent deliveries, newest first: each
+// notification sent over each channel, with its attempts and last error.
+func (h *Handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.Deliveries().Find(ctx, bson.M{"userId": userEmail},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load deliveries")
+		return
+	}
+	rows := make([]models.Delivery, 0)
+	if err := cursor.All(ctx, &rows); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": rows})
+}
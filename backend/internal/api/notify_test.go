@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/crypto"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
+)
+
+// A retry sweep that ran out of time neither sends nor counts an attempt.
+func TestAttemptDeliveryAfterDeadline(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+
+	enc := crypto.NewEncryptor("test-key")
+	url, _ := enc.Encrypt(srv.URL)
+	secret, _ := enc.Encrypt("whsec_test")
+	h := &Handler{encryptor: enc, notifyClient: srv.Client()}
+	c := models.NotifyChannel{Type: notify.ChannelWebhook, URL: url, Secret: secret}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := models.Delivery{UserID: "me@example.com", Channel: c.Type, Status: deliveryRetrying, Attempts: 1}
+	h.attemptDelivery(ctx, &d, c, notify.Message{Kind: "digest", To: d.UserID, Subject: "Digest"})
+	if hits != 0 || d.Attempts != 1 || d.Status != deliveryRetrying {
+		t.Errorf("expired sweep: %d hits, attempts %d, status %s", hits, d.Attempts, d.Status)
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/digest"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/priority"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// Sent-mail window read to learn who the user writes to: thread ids come from
+// one list call, recipients cost a call per message so fewer are read.
+const (
+	sentHistoryDays       = 180
+	sentHistoryMax        = 500
+	sentHistoryRecipients = 60
+	// sentHistoryTTL is how long a user's sent history is reused across syncs
+	// before it is read from Gmail again.
+	sentHistoryTTL = 30 * time.Minute
+)
+
+// digestTopCount is how many of today's most important emails the digest lists.
+const digestTopCount = 5
+
+// scorer holds what scoring a batch of one user's emails needs, loaded once.
+type scorer struct {
+	me        string
+	history   *priority.History
+	protected []string
+	verdicts  map[string]models.AISuggestion // latest AI suggestion per message
+}
+
+// newScorer reads the user's sent mail (cached for sentHistoryTTL), protected
+// senders and the AI suggestions already made for messageIDs. A Gmail failure
+// leaves the history empty, and uncached: emails are still scored on the other
+// signals.
+func (h *Handler) newScorer(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, messageIDs []string) scorer {
+	sc := scorer{
+		me:        userEmail,
+		history:   priority.NewHistory(),
+		protected: h.protectedValues(ctx, userEmail),
+		verdicts:  map[string]models.AISuggestion{},
+	}
+	if cached, ok := h.sentHistory.get(userEmail, time.Now()); ok {
+		sc.history = cached
+	} else if sent, err := h.gmailService.ListSentMessages(gmailClient, sentHistoryDays, sentHistoryMax, sentHistoryRecipients); err == nil {
+		for _, m := range sent {
+			sc.history.AddSent(m.ThreadID, m.Recipients...)
+		}
+		h.sentHistory.set(userEmail, sc.history, time.Now())
+	}
+	if len(messageIDs) > 0 {
+		cursor, err := h.db.AISuggestions().Find(ctx,
+			bson.M{"userId": userEmail, "emailId": bson.M{"$in": messageIDs}},
+			options.Find().SetSort(bson.M{"createdAt": 1}))
+		if err == nil {
+			var rows []models.AISuggestion
+			if cursor.All(ctx, &rows) == nil {
+				for _, s := range rows {
+					sc.verdicts[s.EmailID] = s // newest wins
+				}
+			}
+		}
+	}
+	return sc
+}
+
+// score computes one email's priority.
+func (sc scorer) score(e models.Email) priority.Result {
+	sig := priority.Signals{
+		VIP:             vip(e, sc.protected),
+		RepliesToSender: sc.history.SentTo(e.From),
+		InThread:        sc.history.InThread(e.ThreadID),
+		Direct:          priority.Addressed(e.To, sc.me),
+		Deadline:        priority.SubjectDeadline(e.Subject),
+	}
+	if v, ok := sc.verdicts[e.MessageID]; ok {
+		sig.AIAction, sig.AIConfidence = v.Action, v.Confidence
+	}
+	return priority.Score(sig)
+}
+
+// rankEmails drops emails below minPriority and, when byPriority is set,
+// orders the rest by score, highest first (ties keep their order).
+func rankEmails(emails []models.Email, minPriority int, byPriority bool) []models.Email {
+	out := emails[:0]
+	for _, e := range emails {
+		if e.Priority >= minPriority {
+			out = append(out, e)
+		}
+	}
+	if byPriority {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Priority > out[j].Priority })
+	}
+	return out
+}
+
+// storedScores returns the priority and risk scores saved at sync time for
+// messageIDs.
+func (h *Handler) storedScores(ctx context.Context, userEmail string, messageIDs []string) map[string]models.Email {
+	out := map[string]models.Email{}
+	cursor, err := h.db.Emails().Find(ctx,
+		bson.M{"userId": userEmail, "messageId": bson.M{"$in": messageIDs}},
+		options.Find().SetProjection(bson.M{"messageId": 1, "priority": 1, "priorityReasons": 1, "risk": 1, "riskReasons": 1}))
+	if err != nil {
+		return out
+	}
+	var rows []models.Email
+	if cursor.All(ctx, &rows) == nil {
+		for _, e := range rows {
+			out[e.MessageID] = e
+		}
+	}
+	return out
+}
+
+// topPriority returns the user's most important inbox emails received since
+// the start of today in now's location (the user's timezone), for the digest.
+func (h *Handler) topPriority(ctx context.Context, userEmail string, now time.Time) []digest.Highlight {
+	y, m, d := now.Date()
+	cursor, err := h.db.Emails().Find(ctx, bson.M{
+		"userId":       userEmail,
+		"labelIds":     "INBOX",
+		"receivedDate": bson.M{"$gte": time.Date(y, m, d, 0, 0, 0, 0, now.Location())},
+		"priority":     bson.M{"$gt": 0},
+	}, options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "receivedDate", Value: -1}}).SetLimit(digestTopCount))
+	if err != nil {
+		return nil
+	}
+	var rows []models.Email
+	if cursor.All(ctx, &rows) != nil {
+		return nil
+	}
+	out := make([]digest.Highlight, 0, len(rows))
+	for _, e := range rows {
+		out = append(out, digest.Highlight{From: e.From, Subject: e.Subject, Score: e.Priority})
+	}
+	return out
+}
+
+// sentHistories caches each user's sent history, so a sync reuses it instead of
+// reading hundreds of sent messages again. A cached History is only read.
+type sentHistories struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	users map[string]sentHistoryEntry
+}
+
+type sentHistoryEntry struct {
+	history *priority.History
+	at      time.Time
+}
+
+func newSentHistories(ttl time.Duration) *sentHistories {
+	return &sentHistories{ttl: ttl, users: map[string]sentHistoryEntry{}}
+}
+
+// get returns the user's cached history, if still fresh.
+func (c *sentHistories) get(user string, now time.Time) (*priority.History, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.users[user]
+	if !ok || now.Sub(e.at) > c.ttl {
+		return nil, false
+	}
+	return e.history, true
+}
+
+// set caches the user's freshly read history.
+func (c *sentHistories) set(user string, h *priority.History, now time.Time) {
+	c.mu.Lock()
+	c.users[user] = sentHistoryEntry{history: h, at: now}
+	c.mu.Unlock()
+}
+
+// sweep drops the stale entries.
+func (c *sentHistories) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for user, e := range c.users {
+		if now.Sub(e.at) > c.ttl {
+			delete(c.users, user)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/priority"
+)
+
+func TestRankEmails(t *testing.T) {
+	in := []models.Email{
+		{MessageID: "a", Priority: 20},
+		{MessageID: "b", Priority: 70},
+		{MessageID: "c", Priority: 5},
+		{MessageID: "d", Priority: 70},
+	}
+	got := rankEmails(append([]models.Email(nil), in...), 10, true)
+	want := []string{"b", "d", "a"}
+	if len(got) != len(want) {
+		t.Fatalf("rankEmails = %+v", got)
+	}
+	for i, id := range want {
+		if got[i].MessageID != id {
+			t.Errorf("position %d = %s, want %s", i, got[i].MessageID, id)
+		}
+	}
+
+	unsorted := rankEmails(append([]models.Email(nil), in...), 0, false)
+	if len(unsorted) != 4 || unsorted[0].MessageID != "a" {
+		t.Errorf("without sort the Gmail order must be kept: %+v", unsorted)
+	}
+}
+
+func TestSentHistoriesExpire(t *testing.T) {
+	c := newSentHistories(time.Hour)
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	h := priority.NewHistory()
+	h.AddSent("t1", "jane@client.fr")
+	c.set("me@x.com", h, at)
+
+	if got, ok := c.get("me@x.com", at.Add(59*time.Minute)); !ok || got.SentTo("jane@client.fr") != 1 {
+		t.Errorf("fresh history not reused: %v, %v", got, ok)
+	}
+	if _, ok := c.get("other@x.com", at); ok {
+		t.Error("history shared across users")
+	}
+	if _, ok := c.get("me@x.com", at.Add(61*time.Minute)); ok {
+		t.Error("stale history reused")
+	}
+	c.sweep(at.Add(2 * time.Hour))
+	if len(c.users) != 0 {
+		t.Errorf("sweep kept %d stale entries", len(c.users))
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/protect"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/risk"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// trustedDomains returns the domains the user demonstrably deals with: those
+// they wrote to and those they protect. Domains merely seen in the inbox are
+// left out, or a lookalike would vouch for itself from its second message on.
+func (sc scorer) trustedDomains() []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(v string) {
+		if d := protect.Domain(v); d != "" && !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	for _, addr := range sc.history.Correspondents() {
+		add(addr)
+	}
+	for _, v := range sc.protected {
+		add(v)
+	}
+	return out
+}
+
+// assessRisk scores a fetched message from its authentication headers and its
+// sender, against the user's trusted domains.
+func assessRisk(msg *gmailapi.Message, from string, trusted []string) risk.Result {
+	return risk.Assess(risk.Input{
+		From:    from,
+		ReplyTo: gmail.Header(msg, "Reply-To"),
+		Auth: risk.ParseAuth(
+			gmail.HeaderValues(msg, "Authentication-Results"),
+			gmail.HeaderValues(msg, "Received-SPF")),
+		Known: trusted,
+	})
+}
+
+// vip reports whether e comes from a protected sender and earns their VIP
+// priority. Mail whose authentication failed keeps the sender's shield against
+// archive, trash and delete, but a possibly forged From is not promoted.
+func vip(e models.Email, protectedList []string) bool {
+	return !risk.Untrusted(e.RiskReasons) && protect.Match(e.From, protectedList)
+}
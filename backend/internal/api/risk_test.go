@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/priority"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func TestAuthFailureKeepsProtection(t *testing.T) {
+	protected := []string{"boss@acme.com"}
+	failed := models.Email{From: "boss@acme.com", RiskReasons: []string{"auth-fail"}}
+	genuine := models.Email{From: "boss@acme.com", RiskReasons: []string{"reply-to-mismatch"}}
+
+	for _, e := range []models.Email{genuine, failed} {
+		for _, action := range []string{"archive", "trash", "delete"} {
+			if allows(action, e.From, protected) {
+				t.Errorf("%s allowed on a protected sender with reasons %v", action, e.RiskReasons)
+			}
+		}
+	}
+
+	sc := scorer{me: "me@x.com", history: priority.NewHistory(), protected: protected}
+	if got := sc.score(failed); contains(got.Reasons, priority.ReasonVIP) {
+		t.Errorf("unauthenticated sender scored as VIP: %v", got.Reasons)
+	}
+	if got := sc.score(genuine); !contains(got.Reasons, priority.ReasonVIP) {
+		t.Errorf("genuine protected sender lost VIP: %v", got.Reasons)
+	}
+}
+
+func TestTrustedDomains(t *testing.T) {
+	h := priority.NewHistory()
+	h.AddSent("t1", "Jane <jane@client.fr>, bob@client.fr", "team@acme.com")
+	sc := scorer{history: h, protected: []string{"boss@acme.com", "bank.de"}}
+
+	got := sc.trustedDomains()
+	sort.Strings(got)
+	want := []string{"acme.com", "bank.de", "client.fr"}
+	if len(got) != len(want) {
+		t.Fatalf("trustedDomains = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trustedDomains = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAssessRiskReadsHeaders(t *testing.T) {
+	msg := &gmailapi.Message{Payload: &gmailapi.MessagePart{Headers: []*gmailapi.MessagePartHeader{
+		{Name: "From", Value: "Bank Support <support@rnybank.de>"},
+		{Name: "Reply-To", Value: "refund@collect.example"},
+		{Name: "Authentication-Results", Value: "mx.google.com; spf=fail smtp.mailfrom=rnybank.de; dmarc=fail"},
+	}}}
+	got := assessRisk(msg, "Bank Support <support@rnybank.de>", []string{"mybank.de"})
+	for _, want := range []string{"auth-fail", "lookalike-domain", "reply-to-mismatch"} {
+		if !contains(got.Reasons, want) {
+			t.Errorf("reasons %v miss %q", got.Reasons, want)
+		}
+	}
+	if got.Score < 60 {
+		t.Errorf("score = %d, want a high risk", got.Score)
+	}
+}
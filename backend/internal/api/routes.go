@@ -7,6 +7,32 @@ import (
 )
 
 func (h *Handler) SetupRoutes() http.Handler {
+	r := h.routes()
+
+	// Middleware chain (applied to every matched route, innermost last):
+	// recover → request-id → metrics → logging → rate-limit → auth → handler.
+	rl := newRateLimiter(20, 40) // ~20 req/s sustained, burst 40, per client
+	r.Use(recoverMiddleware)
+	r.Use(requestIDMiddleware)
+	r.Use(h.metricsMiddleware)
+	r.Use(loggingMiddleware)
+	r.Use(rl.middleware)
+	r.Use(h.authMiddleware)
+
+	// Setup CORS (allow-list configurable via ALLOWED_ORIGINS at startup)
+	c := cors.New(cors.Options{
+		AllowedOrigins:   AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-User-Email", "Last-Event-ID"},
+		ExposedHeaders:   []string{"X-Total-Count", "X-Next-Cursor"},
+		AllowCredentials: true,
+	})
+
+	return c.Handler(r)
+}
+
+// routes registers every endpoint on a new router, without the middleware.
+func (h *Handler) routes() *mux.Router {
 	r := mux.NewRouter()
 
 	// Health check + ops metrics
@@ -25,6 +51,7 @@ func (h *Handler) SetupRoutes() http.Handler {
 	r.HandleFunc("/api/stats", h.GetMailboxStats).Methods("GET")
 	r.HandleFunc("/api/stats/activity", h.GetActivity).Methods("GET")
 	r.HandleFunc("/api/stats/digest", h.GetDigest).Methods("GET")
+	r.HandleFunc("/api/stats/digest/preview", h.GetDigestPreview).Methods("GET")
 
 	// Action history (audit trail) + one-click undo of automated actions.
 	r.HandleFunc("/api/activity/log", h.GetActionLog).Methods("GET")
@@ -33,6 +60,24 @@ func (h *Handler) SetupRoutes() http.Handler {
 	// Snooze ("Reporter") — return-to-inbox scheduling
 	r.HandleFunc("/api/snoozes", h.GetSnoozes).Methods("GET")
 	r.HandleFunc("/api/snoozes/{id}/wake", h.WakeSnooze).Methods("POST")
+	r.HandleFunc("/api/followups", h.GetFollowUps).Methods("GET")
+	r.HandleFunc("/api/followups/settings", h.GetFollowUpSettings).Methods("GET")
+	r.HandleFunc("/api/followups/settings", h.UpdateFollowUpSettings).Methods("PUT")
+	r.HandleFunc("/api/followups/{id}/dismiss", h.DismissFollowUp).Methods("POST")
+
+	// Notification channels (where the digest goes) and delivery results
+	r.HandleFunc("/api/notify/channels", h.GetNotifyChannels).Methods("GET")
+	r.HandleFunc("/api/notify/channels", h.UpdateNotifyChannels).Methods("PUT")
+	r.HandleFunc("/api/notify/test", h.TestNotifyChannels).Methods("POST")
+	r.HandleFunc("/api/notify/deliveries", h.GetDeliveries).Methods("GET")
+	r.HandleFunc("/api/webhooks", h.GetWebhooks).Methods("GET")
+	r.HandleFunc("/api/webhooks", h.CreateWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/deliveries/{id}/redeliver", h.RedeliverWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/{id}", h.UpdateWebhook).Methods("PUT")
+	r.HandleFunc("/api/webhooks/{id}", h.DeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/api/webhooks/{id}/deliveries", h.GetWebhookDeliveries).Methods("GET")
+	r.HandleFunc("/api/webhooks/{id}/ping", h.PingWebhook).Methods("POST")
+	r.HandleFunc("/api/events", h.StreamEvents).Methods("GET")
 
 	// Protected senders (VIP) — never auto-archived/trashed/deleted
 	r.HandleFunc("/api/protected", h.GetProtected).Methods("GET")
@@ -46,6 +91,10 @@ func (h *Handler) SetupRoutes() http.Handler {
 	// RGPD — data portability (export) and right to erasure (delete).
 	r.HandleFunc("/api/account/export", h.ExportAccount).Methods("GET")
 	r.HandleFunc("/api/account", h.DeleteAccount).Methods("DELETE")
+	// Personal access tokens (scoped, for scripts and integrations)
+	r.HandleFunc("/api/tokens", h.GetAPITokens).Methods("GET")
+	r.HandleFunc("/api/tokens", h.CreateAPIToken).Methods("POST")
+	r.HandleFunc("/api/tokens/{id}", h.RevokeAPIToken).Methods("DELETE")
 
 	// Billing (Stripe)
 	r.HandleFunc("/api/billing/checkout", h.CreateCheckout).Methods("POST")
@@ -63,6 +112,9 @@ func (h *Handler) SetupRoutes() http.Handler {
 	// Unsubscribe / subscriptions cleanup
 	r.HandleFunc("/api/subscriptions", h.GetSubscriptions).Methods("GET")
 	r.HandleFunc("/api/unsubscribe", h.Unsubscribe).Methods("POST")
+	r.HandleFunc("/api/unsubscribe/bulk", h.EnqueueBulkUnsubscribe).Methods("POST")
+	r.HandleFunc("/api/unsubscribe/bulk/{id}", h.GetUnsubscribeJob).Methods("GET")
+	r.HandleFunc("/api/unsubscribes/violations", h.GetUnsubscribeViolations).Methods("GET")
 
 	// Labels routes
 	r.HandleFunc("/api/labels", h.GetLabels).Methods("GET")
@@ -77,6 +129,18 @@ func (h *Handler) SetupRoutes() http.Handler {
 	r.HandleFunc("/api/ai/apply-bulk", h.ApplyBulk).Methods("POST")
 	r.HandleFunc("/api/ai/suggestions", h.GetSuggestions).Methods("GET")
 	r.HandleFunc("/api/ai/suggestions/{id}/reject", h.RejectSuggestion).Methods("POST")
+	r.HandleFunc("/api/ai/summarize", h.Summarize).Methods("POST")
+	r.HandleFunc("/api/ai/draft-reply", h.DraftReply).Methods("POST")
+	r.HandleFunc("/api/ai/cache", h.PurgeAnalysisCache).Methods("DELETE")
+	r.HandleFunc("/api/ai/clusters", h.EnqueueClustering).Methods("POST")
+	r.HandleFunc("/api/ai/label-proposals", h.GetLabelProposals).Methods("GET")
+	r.HandleFunc("/api/ai/label-proposals/{id}/accept", h.AcceptLabelProposal).Methods("POST")
+	r.HandleFunc("/api/ai/label-proposals/{id}", h.DismissLabelProposal).Methods("DELETE")
+
+	// Admin (ADMIN_EMAILS allow-list)
+	r.HandleFunc("/api/admin/ai/cache", h.AdminPurgeAnalysisCache).Methods("DELETE")
+	r.HandleFunc("/api/admin/usage", h.AdminGetUsage).Methods("GET")
+	r.HandleFunc("/api/admin/senders/rebuild", h.AdminRebuildSenders).Methods("POST")
 
 	// Senders routes
 	r.HandleFunc("/api/senders", h.GetSenders).Methods("GET")
@@ -86,29 +150,15 @@ func (h *Handler) SetupRoutes() http.Handler {
 	// Smart Labels routes
 	r.HandleFunc("/api/smart-labels", h.GetSmartLabels).Methods("GET")
 	r.HandleFunc("/api/smart-labels", h.CreateSmartLabel).Methods("POST")
+	r.HandleFunc("/api/smart-labels/sync", h.SyncSmartLabels).Methods("POST")
+	r.HandleFunc("/api/smart-labels/{id}", h.UpdateSmartLabel).Methods("PUT")
+	r.HandleFunc("/api/smart-labels/{id}", h.DeleteSmartLabel).Methods("DELETE")
+	r.HandleFunc("/api/smart-labels/{id}/merge", h.MergeSmartLabel).Methods("POST")
 
 	// Config routes (no auth required for initial setup)
 	r.HandleFunc("/api/config/status", h.GetConfigStatus).Methods("GET")
 	r.HandleFunc("/api/config/gmail", h.GetGmailConfig).Methods("GET")
 	r.HandleFunc("/api/config/gmail", h.SaveGmailConfig).Methods("POST")
 
-	// Middleware chain (applied to every matched route, innermost last):
-	// recover → request-id → metrics → logging → rate-limit → auth → handler.
-	rl := newRateLimiter(20, 40) // ~20 req/s sustained, burst 40, per client
-	r.Use(recoverMiddleware)
-	r.Use(requestIDMiddleware)
-	r.Use(h.metricsMiddleware)
-	r.Use(loggingMiddleware)
-	r.Use(rl.middleware)
-	r.Use(h.authMiddleware)
-
-	// Setup CORS (allow-list configurable via ALLOWED_ORIGINS at startup)
-	c := cors.New(cors.Options{
-		AllowedOrigins:   AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-User-Email"},
-		AllowCredentials: true,
-	})
-
-	return c.Handler(r)
+	return r
 }
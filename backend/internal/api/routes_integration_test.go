@@ -1,15 +1,19 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/auth"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/database"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/events"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/metrics"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -21,6 +25,14 @@ import (
 // client points at a dead address on purpose so the /health datastore ping
 // fails fast, letting us assert the degraded (503) path for real.
 func newRoutedTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	_, srv := newRoutedTestHandler(t)
+	return srv
+}
+
+// newRoutedTestHandler is newRoutedTestServer, also returning the handler
+// behind it.
+func newRoutedTestHandler(t *testing.T) (*Handler, *httptest.Server) {
 	t.Helper()
 	cli, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
 	if err != nil {
@@ -30,11 +42,13 @@ func newRoutedTestServer(t *testing.T) *httptest.Server {
 		db:        &database.Database{Client: cli},
 		auth:      auth.NewManager("integration-test-secret-key-1234567890"),
 		metrics:   metrics.New(),
+		events:    events.New(events.Options{}),
 		startedAt: time.Now(),
 	}
 	srv := httptest.NewServer(h.SetupRoutes())
 	t.Cleanup(srv.Close)
-	return srv
+	t.Cleanup(h.CloseEvents)
+	return h, srv
 }
 
 func TestMetricsEndpointLive(t *testing.T) {
@@ -131,3 +145,68 @@ func TestMetricsAggregatesStatusClassesLive(t *testing.T) {
 		}
 	}
 }
+
+// readSSE reads one SSE message (up to its blank line) and returns its fields.
+func readSSE(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	msg := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return msg
+		}
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			msg[k] = v
+		}
+	}
+}
+
+// The event stream goes through the whole chain (auth by ?token=, the
+// recorders that must still let it flush) and resumes from Last-Event-ID.
+func TestEventStreamLive(t *testing.T) {
+	h, srv := newRoutedTestHandler(t)
+	token := h.auth.IssueSession("alice@example.com")
+
+	if res, err := http.Get(srv.URL + "/api/events?token=bogus"); err == nil {
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("bad token = %d, want 401", res.StatusCode)
+		}
+	}
+
+	first := h.events.Publish("alice@example.com", events.TypeSync, map[string]int{"synced": 1})
+	h.events.Publish("bob@example.com", events.TypeSync, nil)
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/events?token="+token, nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprint(first.ID-1))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/events: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream = %d %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	r := bufio.NewReader(res.Body)
+
+	if msg := readSSE(t, r); msg["retry"] == "" {
+		t.Fatalf("first message %v, want the retry hint", msg)
+	}
+	if msg := readSSE(t, r); msg["id"] != fmt.Sprint(first.ID) || msg["event"] != events.TypeSync {
+		t.Fatalf("replayed %v, want alice's sync event %d", msg, first.ID)
+	}
+
+	live := h.events.Publish("alice@example.com", events.TypeAction, map[string]string{"action": "archive"})
+	msg := readSSE(t, r)
+	if msg["id"] != fmt.Sprint(live.ID) || msg["event"] != events.TypeAction {
+		t.Fatalf("live message %v, want action event %d", msg, live.ID)
+	}
+	var e events.Event
+	if err := json.Unmarshal([]byte(msg["data"]), &e); err != nil || e.ID != live.ID {
+		t.Fatalf("data %q does not decode to the event: %v", msg["data"], err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
 	"go.mongodb.org/mongo-driver/bson"
@@ -227,6 +228,13 @@ func (h *Handler) ApplyRules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Risk is scored at sync; messages never synced count as unscored.
+	ids := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.Id)
+	}
+	stored := h.storedScores(ctx, userEmail, ids)
+
 	protectedList := h.protectedValues(ctx, userEmail)
 	labelCache := map[string]string{} // labelName -> Gmail label ID
 	byRule := map[string]int{}        // rule name -> count applied
@@ -244,6 +252,9 @@ func (h *Handler) ApplyRules(w http.ResponseWriter, r *http.Request) {
 			Body:         gmail.GetEmailBody(msg),
 			ReceivedDate: date,
 		}
+		if s, ok := stored[msg.Id]; ok {
+			email.Risk, email.RiskReasons = s.Risk, s.RiskReasons
+		}
 
 		match := rules.FirstMatch(email, enabled)
 		if match == nil {
@@ -272,6 +283,7 @@ func (h *Handler) ApplyRules(w http.ResponseWriter, r *http.Request) {
 			bson.M{"$inc": bson.M{"appliedCount": n}},
 		)
 	}
+	h.publishRules(userEmail, "manual", byRule)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -477,6 +489,14 @@ func ruleFromInput(userEmail string, in models.SortingRuleInput) models.SortingR
 		Actions:    in.Actions,
 		Priority:   in.Priority,
 	}
+	for i, a := range rule.Actions {
+		if a.LabelName != "" {
+			rule.Actions[i].LabelName = labelpath.Clean(a.LabelName)
+		}
+	}
+	if rule.LabelName != "" {
+		rule.LabelName = labelpath.Clean(rule.LabelName)
+	}
 	if len(rule.Actions) > 0 {
 		rule.Action = rule.Actions[0].Type
 		rule.LabelName = rule.Actions[0].LabelName
@@ -0,0 +1,485 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/protect"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// senderSorts maps the sort query values to the stored field they order by,
+// highest first.
+var senderSorts = map[string]string{
+	"volume": "emailCount",
+	"unread": "unreadRate",
+	"recent": "lastSeen",
+}
+
+// senderQuery is a parsed GET /api/senders (or /api/subscriptions) query.
+type senderQuery struct {
+	Sort   string // key of senderSorts
+	Search string
+	Limit  int64
+	After  *senderCursor
+}
+
+// senderCursor marks the last sender of a page: its sort value and id. Sort
+// is recorded so a cursor is never replayed under another order.
+type senderCursor struct {
+	Sort  string    `json:"s"`
+	Count float64   `json:"n,omitempty"` // emailCount or unreadRate
+	Time  time.Time `json:"t,omitempty"` // lastSeen
+	ID    string    `json:"id"`
+}
+
+// parseSenderQuery reads sort ("volume", the default, "unread" or "recent"),
+// q (a search on address and name), limit (default defaultLimit, capped at
+// 200) and cursor (the X-Next-Cursor of the previous page).
+func parseSenderQuery(q url.Values, defaultLimit int64) (senderQuery, error) {
+	sq := senderQuery{Sort: q.Get("sort"), Search: strings.TrimSpace(q.Get("q")), Limit: defaultLimit}
+	if sq.Sort == "" {
+		sq.Sort = "volume"
+	}
+	if _, ok := senderSorts[sq.Sort]; !ok {
+		return sq, fmt.Errorf("invalid sort %q (volume, unread, recent)", sq.Sort)
+	}
+	if v := q.Get("limit"); v != "" {
+		if n, err := parseInt64(v); err == nil && n > 0 {
+			sq.Limit = n
+			if sq.Limit > 200 {
+				sq.Limit = 200
+			}
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeSenderCursor(v)
+		if err != nil || c.Sort != sq.Sort {
+			return sq, fmt.Errorf("invalid cursor")
+		}
+		sq.After = &c
+	}
+	return sq, nil
+}
+
+func encodeSenderCursor(sort string, s models.Sender) string {
+	c := senderCursor{Sort: sort, ID: s.ID}
+	switch sort {
+	case "volume":
+		c.Count = float64(s.EmailCount)
+	case "unread":
+		c.Count = s.UnreadRate
+	case "recent":
+		c.Time = s.LastSeen
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSenderCursor(v string) (senderCursor, error) {
+	var c senderCursor
+	raw, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if _, err := primitive.ObjectIDFromHex(c.ID); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// filter builds the Mongo filter for one page: the user's senders, matching
+// the search, strictly after the cursor in (sort field, _id) descending order.
+// extra narrows the set further (e.g. subscriptions only).
+func (sq senderQuery) filter(userEmail string, extra bson.M) bson.M {
+	f := bson.M{"userId": userEmail}
+	for k, v := range extra {
+		f[k] = v
+	}
+	var and []bson.M
+	if sq.Search != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(sq.Search), Options: "i"}
+		and = append(and, bson.M{"$or": []bson.M{{"senderEmail": re}, {"senderName": re}}})
+	}
+	if sq.After != nil {
+		field := senderSorts[sq.Sort]
+		var v interface{} = sq.After.Count
+		if sq.Sort == "recent" {
+			v = sq.After.Time
+		}
+		id, _ := primitive.ObjectIDFromHex(sq.After.ID)
+		and = append(and, bson.M{"$or": []bson.M{
+			{field: bson.M{"$lt": v}},
+			{field: v, "_id": bson.M{"$lt": id}},
+		}})
+	}
+	if len(and) > 0 {
+		f["$and"] = and
+	}
+	return f
+}
+
+// listSenders returns one page of the user's senders and the cursor of the
+// next page ("" on the last one).
+func (h *Handler) listSenders(ctx context.Context, userEmail string, sq senderQuery, extra bson.M) ([]models.Sender, string, error) {
+	field := senderSorts[sq.Sort]
+	cursor, err := h.db.Senders().Find(ctx, sq.filter(userEmail, extra),
+		options.Find().SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}}).SetLimit(sq.Limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	senders := make([]models.Sender, 0, sq.Limit+1)
+	if err := cursor.All(ctx, &senders); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if int64(len(senders)) > sq.Limit {
+		senders = senders[:sq.Limit]
+		next = encodeSenderCursor(sq.Sort, senders[len(senders)-1])
+	}
+	return senders, next, nil
+}
+
+// attachPreferences links each sender of a page to its preference, matched by
+// senderId or, for preferences saved before senders existed, by address.
+func (h *Handler) attachPreferences(ctx context.Context, userEmail string, senders []models.Sender) {
+	if len(senders) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(senders))
+	addrs := make([]string, 0, len(senders))
+	for _, s := range senders {
+		ids = append(ids, s.ID)
+		addrs = append(addrs, s.SenderEmail)
+	}
+	cursor, err := h.db.SenderPreferences().Find(ctx, bson.M{
+		"userId": userEmail,
+		"$or":    []bson.M{{"senderId": bson.M{"$in": ids}}, {"senderEmail": bson.M{"$in": addrs}}},
+	})
+	if err != nil {
+		return
+	}
+	var prefs []models.SenderPreference
+	if cursor.All(ctx, &prefs) != nil {
+		return
+	}
+	byKey := map[string]*models.SenderPreference{}
+	for i := range prefs {
+		if prefs[i].SenderID != "" {
+			byKey[prefs[i].SenderID] = &prefs[i]
+		}
+		byKey[senderAddress(prefs[i].SenderEmail)] = &prefs[i]
+	}
+	for i := range senders {
+		if p, ok := byKey[senders[i].ID]; ok {
+			senders[i].Preference = p
+		} else if p, ok := byKey[senders[i].SenderEmail]; ok {
+			senders[i].Preference = p
+		}
+	}
+}
+
+// senderAddress is the key a From header is aggregated under: its bare,
+// lower-cased address.
+func senderAddress(from string) string {
+	return protect.NormalizeAddress(from)
+}
+
+// senderDelta returns how syncing cur changes its sender's counters, given the
+// copy stored before (nil when the message is new): a new message counts once,
+// and a known one only moves the unread count when it was read or unread since.
+func senderDelta(prev *models.Email, cur models.Email) (emails, unread int) {
+	unreadNow := 0
+	if !cur.IsRead {
+		unreadNow = 1
+	}
+	if prev == nil {
+		return 1, unreadNow
+	}
+	if !prev.IsRead {
+		unreadNow--
+	}
+	return 0, unreadNow
+}
+
+// trackSender folds one synced email into its sender's aggregate. The update
+// is a pipeline so the unread rate is recomputed from the new counts in the
+// same write. Best-effort: a failure only leaves the aggregate stale until the
+// next rebuild.
+func (h *Handler) trackSender(ctx context.Context, userEmail string, prev *models.Email, cur models.Email) {
+	addr := senderAddress(cur.From)
+	if addr == "" {
+		return
+	}
+	dEmails, dUnread := senderDelta(prev, cur)
+	seen := cur.ReceivedDate
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+
+	set := bson.M{
+		"senderDomain": bson.M{"$literal": protect.Domain(addr)},
+		"emailCount":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$emailCount", 0}}, dEmails}},
+		"unreadCount":  bson.M{"$max": bson.A{0, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$unreadCount", 0}}, dUnread}}}},
+		"firstSeen":    bson.M{"$min": bson.A{bson.M{"$ifNull": bson.A{"$firstSeen", seen}}, seen}},
+		"lastSeen":     bson.M{"$max": bson.A{"$lastSeen", seen}},
+		"updatedAt":    time.Now(),
+	}
+	if name := extractSenderName(cur.From); name != "" && name != cur.From {
+		set["senderName"] = bson.M{"$literal": name}
+	}
+	if cur.UnsubURL != "" || cur.UnsubMailto != "" {
+		set["canUnsubscribe"] = true
+		set["oneClick"] = bson.M{"$or": bson.A{bson.M{"$ifNull": bson.A{"$oneClick", false}}, cur.UnsubOneClick}}
+		set["sampleMessageId"] = bson.M{"$literal": cur.MessageID}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: set}},
+		{{Key: "$set", Value: bson.M{"unreadRate": bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$emailCount", 0}},
+			bson.M{"$divide": bson.A{"$unreadCount", "$emailCount"}},
+			0,
+		}}}}},
+	}
+	h.db.Senders().UpdateOne(ctx, bson.M{"userId": userEmail, "senderEmail": addr}, pipeline,
+		options.Update().SetUpsert(true))
+}
+
+// noteSenderAction stamps the last action taken on a message's sender.
+func (h *Handler) noteSenderAction(ctx context.Context, userEmail, messageID, action string) {
+	var e models.Email
+	if err := h.db.Emails().FindOne(ctx, bson.M{"userId": userEmail, "messageId": messageID},
+		options.FindOne().SetProjection(bson.M{"from": 1})).Decode(&e); err != nil {
+		return
+	}
+	if addr := senderAddress(e.From); addr != "" {
+		h.db.Senders().UpdateOne(ctx, bson.M{"userId": userEmail, "senderEmail": addr},
+			bson.M{"$set": bson.M{"lastAction": action, "lastActionAt": time.Now()}})
+	}
+}
+
+// senderID returns the id of the sender aggregate behind a From header or
+// address, or "" when it has none yet.
+func (h *Handler) senderID(ctx context.Context, userEmail, from string) string {
+	var s models.Sender
+	if err := h.db.Senders().FindOne(ctx, bson.M{"userId": userEmail, "senderEmail": senderAddress(from)},
+		options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&s); err != nil {
+		return ""
+	}
+	return s.ID
+}
+
+// rebuildSenders recomputes a user's sender aggregates from the stored emails
+// with one aggregation merged into the senders collection, drops senders no
+// email refers to anymore and links sender preferences to their sender. Last
+// actions survive: the merge only overwrites the aggregated fields. It returns
+// how many senders the user has afterwards.
+func (h *Handler) rebuildSenders(ctx context.Context, userEmail string) (int64, error) {
+	started := time.Now()
+	// The address inside <...>, else the whole header; the display name before it.
+	addr := bson.M{"$let": bson.M{
+		"vars": bson.M{"m": bson.M{"$regexFind": bson.M{"input": "$from", "regex": "<([^>]*)>"}}},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$ne": bson.A{"$$m", nil}},
+			bson.M{"$arrayElemAt": bson.A{"$$m.captures", 0}},
+			bson.M{"$ifNull": bson.A{"$from", ""}},
+		}},
+	}}
+	name := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$indexOfCP": bson.A{bson.M{"$ifNull": bson.A{"$from", ""}}, "<"}}, 0}},
+		bson.M{"$trim": bson.M{"input": bson.M{"$arrayElemAt": bson.A{bson.M{"$split": bson.A{"$from", "<"}}, 0}}, "chars": " \""}},
+		"",
+	}}
+	hasUnsub := bson.M{"$or": bson.A{
+		bson.M{"$gt": bson.A{"$unsubUrl", ""}},
+		bson.M{"$gt": bson.A{"$unsubMailto", ""}},
+	}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userEmail}}},
+		{{Key: "$project", Value: bson.M{
+			"userId":        1,
+			"messageId":     1,
+			"isRead":        1,
+			"receivedDate":  1,
+			"unsubOneClick": bson.M{"$ifNull": bson.A{"$unsubOneClick", false}},
+			"hasUnsub":      hasUnsub,
+			"senderEmail":   bson.M{"$toLower": bson.M{"$trim": bson.M{"input": addr, "chars": " \"'\t"}}},
+			"senderName":    name,
+		}}},
+		{{Key: "$match", Value: bson.M{"senderEmail": bson.M{"$ne": ""}}}},
+		// Oldest first, subscription emails last: $last picks the newest name
+		// and the newest email carrying an unsubscribe link.
+		{{Key: "$sort", Value: bson.D{{Key: "hasUnsub", Value: 1}, {Key: "receivedDate", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             "$senderEmail",
+			"userId":          bson.M{"$first": "$userId"},
+			"senderName":      bson.M{"$last": "$senderName"},
+			"emailCount":      bson.M{"$sum": 1},
+			"unreadCount":     bson.M{"$sum": bson.M{"$cond": bson.A{"$isRead", 0, 1}}},
+			"firstSeen":       bson.M{"$min": "$receivedDate"},
+			"lastSeen":        bson.M{"$max": "$receivedDate"},
+			"canUnsubscribe":  bson.M{"$max": "$hasUnsub"},
+			"oneClick":        bson.M{"$max": bson.M{"$and": bson.A{"$hasUnsub", "$unsubOneClick"}}},
+			"sampleMessageId": bson.M{"$last": "$messageId"},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"senderEmail":     "$_id",
+			"senderDomain":    bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{bson.M{"$split": bson.A{"$_id", "@"}}, 1}}, ""}},
+			"unreadRate":      bson.M{"$divide": bson.A{"$unreadCount", "$emailCount"}},
+			"sampleMessageId": bson.M{"$cond": bson.A{"$canUnsubscribe", "$sampleMessageId", ""}},
+			"updatedAt":       started,
+		}}},
+		{{Key: "$unset", Value: "_id"}},
+		{{Key: "$merge", Value: bson.M{
+			"into":           h.db.Senders().Name(),
+			"on":             bson.A{"userId", "senderEmail"},
+			"whenMatched":    "merge",
+			"whenNotMatched": "insert",
+		}}},
+	}
+	cursor, err := h.db.Emails().Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	cursor.Close(ctx)
+
+	if _, err := h.db.Senders().DeleteMany(ctx, bson.M{"userId": userEmail, "updatedAt": bson.M{"$lt": started}}); err != nil {
+		return 0, err
+	}
+	h.linkSenderPreferences(ctx, userEmail)
+	return h.db.Senders().CountDocuments(ctx, bson.M{"userId": userEmail})
+}
+
+// linkSenderPreferences points every preference of a user at its sender.
+func (h *Handler) linkSenderPreferences(ctx context.Context, userEmail string) {
+	cursor, err := h.db.SenderPreferences().Find(ctx, bson.M{"userId": userEmail})
+	if err != nil {
+		return
+	}
+	var prefs []models.SenderPreference
+	if cursor.All(ctx, &prefs) != nil {
+		return
+	}
+	for _, p := range prefs {
+		id := h.senderID(ctx, userEmail, p.SenderEmail)
+		if id == "" || id == p.SenderID {
+			continue
+		}
+		if oid, err := primitive.ObjectIDFromHex(p.ID); err == nil {
+			h.db.SenderPreferences().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"senderId": id}})
+		}
+	}
+}
+
+// rebuildAllSenders rebuilds the sender aggregates of every user with stored
+// emails; onlyMissing restricts it to users who have none yet — accounts
+// created before the collection existed, migrated once at startup while later
+// syncs keep aggregates current. A failing account is logged and skipped.
+func (h *Handler) rebuildAllSenders(onlyMissing bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	users, err := h.db.Emails().Distinct(ctx, "userId", bson.M{})
+	if err != nil {
+		log.Printf("senders: failed to list users: %v", err)
+		return
+	}
+	for _, u := range users {
+		email, ok := u.(string)
+		if !ok || email == "" {
+			continue
+		}
+		if onlyMissing {
+			if n, err := h.db.Senders().CountDocuments(ctx, bson.M{"userId": email}, options.Count().SetLimit(1)); err != nil || n > 0 {
+				continue
+			}
+		}
+		if n, err := h.rebuildSenders(ctx, email); err != nil {
+			log.Printf("senders: %s: %v", email, err)
+		} else {
+			log.Printf("senders: %s — rebuilt %d senders", email, n)
+		}
+	}
+}
+
+// GetSenders returns one page of the caller's senders with their preference,
+// sorted by volume, unread rate or recency and optionally searched. The body
+// is a bare array; the next page's cursor is sent in X-Next-Cursor.
+func (h *Handler) GetSenders(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		http.Error(w, "User email required", http.StatusUnauthorized)
+		return
+	}
+
+	sq, err := parseSenderQuery(r.URL.Query(), 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	senders, next, err := h.listSenders(ctx, userEmail, sq, nil)
+	if err != nil {
+		http.Error(w, "Failed to list senders", http.StatusInternalServerError)
+		return
+	}
+	h.attachPreferences(ctx, userEmail, senders)
+
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(senders)
+}
+
+// AdminRebuildSenders recomputes sender aggregates from the stored emails:
+// ?user= rebuilds one account and waits; without it every account is rebuilt
+// in the background (202), e.g. after a migration changed how senders are
+// keyed.
+func (h *Handler) AdminRebuildSenders(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	if !isAdmin(userEmail) {
+		writeError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	target := r.URL.Query().Get("user")
+	if target == "" {
+		go h.rebuildAllSenders(false)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	n, err := h.rebuildSenders(ctx, target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to rebuild senders")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user": target, "senders": n})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseSenderQuery(t *testing.T) {
+	sq, err := parseSenderQuery(url.Values{}, 50)
+	if err != nil || sq.Sort != "volume" || sq.Limit != 50 || sq.After != nil {
+		t.Fatalf("defaults = %+v, %v", sq, err)
+	}
+
+	sq, err = parseSenderQuery(url.Values{"sort": {"unread"}, "limit": {"1000"}, "q": {"  news "}}, 50)
+	if err != nil || sq.Sort != "unread" || sq.Limit != 200 || sq.Search != "news" {
+		t.Fatalf("parsed = %+v, %v", sq, err)
+	}
+
+	for _, bad := range []url.Values{
+		{"sort": {"alpha"}},
+		{"cursor": {"not-base64!"}},
+	} {
+		if _, err := parseSenderQuery(bad, 50); err == nil {
+			t.Errorf("%v should be rejected", bad)
+		}
+	}
+}
+
+func TestSenderCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	last := time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)
+	s := models.Sender{ID: id, EmailCount: 42, UnreadRate: 0.25, LastSeen: last}
+
+	for sort, check := range map[string]func(senderCursor) bool{
+		"volume": func(c senderCursor) bool { return c.Count == 42 },
+		"unread": func(c senderCursor) bool { return c.Count == 0.25 },
+		"recent": func(c senderCursor) bool { return c.Time.Equal(last) },
+	} {
+		q := url.Values{"sort": {sort}, "cursor": {encodeSenderCursor(sort, s)}}
+		sq, err := parseSenderQuery(q, 50)
+		if err != nil || sq.After == nil || sq.After.ID != id || !check(*sq.After) {
+			t.Errorf("%s: cursor = %+v, %v", sort, sq.After, err)
+		}
+	}
+
+	// A cursor is bound to the order it was issued for.
+	q := url.Values{"sort": {"recent"}, "cursor": {encodeSenderCursor("volume", s)}}
+	if _, err := parseSenderQuery(q, 50); err == nil {
+		t.Error("a volume cursor must not be accepted for sort=recent")
+	}
+}
+
+func TestSenderFilter(t *testing.T) {
+	sq := senderQuery{Sort: "volume"}
+	if f := sq.filter("u@x.com", nil); len(f) != 1 || f["userId"] != "u@x.com" {
+		t.Errorf("plain filter = %v", f)
+	}
+
+	id := primitive.NewObjectID()
+	sq = senderQuery{Sort: "volume", Search: "a.b", After: &senderCursor{Sort: "volume", Count: 7, ID: id.Hex()}}
+	f := sq.filter("u@x.com", bson.M{"canUnsubscribe": true})
+	if f["canUnsubscribe"] != true {
+		t.Errorf("extra condition lost: %v", f)
+	}
+	and, ok := f["$and"].([]bson.M)
+	if !ok || len(and) != 2 {
+		t.Fatalf("$and = %v", f["$and"])
+	}
+	search := and[0]["$or"].([]bson.M)[0]["senderEmail"].(primitive.Regex)
+	if search.Pattern != `a\.b` || search.Options != "i" {
+		t.Errorf("search must be escaped and case-insensitive: %+v", search)
+	}
+	after := and[1]["$or"].([]bson.M)
+	if after[0]["emailCount"].(bson.M)["$lt"] != float64(7) || after[1]["_id"].(bson.M)["$lt"] != id {
+		t.Errorf("cursor condition = %v", after)
+	}
+}
+
+func TestSenderDelta(t *testing.T) {
+	cases := []struct {
+		name           string
+		prev           *models.Email
+		cur            models.Email
+		emails, unread int
+	}{
+		{"new unread", nil, models.Email{IsRead: false}, 1, 1},
+		{"new read", nil, models.Email{IsRead: true}, 1, 0},
+		{"read since", &models.Email{IsRead: false}, models.Email{IsRead: true}, 0, -1},
+		{"marked unread", &models.Email{IsRead: true}, models.Email{IsRead: false}, 0, 1},
+		{"unchanged", &models.Email{IsRead: false}, models.Email{IsRead: false}, 0, 0},
+	}
+	for _, tc := range cases {
+		if e, u := senderDelta(tc.prev, tc.cur); e != tc.emails || u != tc.unread {
+			t.Errorf("%s: senderDelta = %d, %d; want %d, %d", tc.name, e, u, tc.emails, tc.unread)
+		}
+	}
+}
+
+func TestSenderAddress(t *testing.T) {
+	if got := senderAddress(`"News" <News@Shop.COM>`); got != "news@shop.com" {
+		t.Errorf("senderAddress = %q", got)
+	}
+}
@@ -0,0 +1,653 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// labelSyncInterval is how often the reconciler brings smart labels back in
+// line with Gmail (renames and deletions done in Gmail, message counts).
+const labelSyncInterval = 6 * time.Hour
+
+// mergeMessageCap bounds how many messages one merge round lists and relabels.
+const mergeMessageCap = 10000
+
+// mergeReserve is the time a merge keeps, out of its budget, for the Mongo
+// writes that follow the relabelling.
+const mergeReserve = 30 * time.Second
+
+// labelCascade counts what a rename, merge or deletion rewrote.
+type labelCascade struct {
+	Rules       int `json:"rules"`
+	Senders     int `json:"senders"`
+	Suggestions int `json:"suggestions"`
+}
+
+func (c *labelCascade) add(o labelCascade) {
+	c.Rules += o.Rules
+	c.Senders += o.Senders
+	c.Suggestions += o.Suggestions
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// relabelRule rewrites every label action of rule that targets from so it
+// targets to instead. An empty to drops those actions; a rule left with no
+// action at all is disabled rather than deleted, so the user keeps its
+// conditions. It reports whether the rule changed.
+func relabelRule(rule models.SortingRule, from, to string) (models.SortingRule, bool) {
+	acts := rules.EffectiveActions(rule)
+	out := make([]models.RuleAction, 0, len(acts))
+	changed := false
+	for _, a := range acts {
+		if a.Type == rules.ActionLabel && strings.EqualFold(a.LabelName, from) {
+			changed = true
+			if to == "" {
+				continue
+			}
+			a.LabelName = to
+		}
+		out = append(out, a)
+	}
+	if !changed {
+		return rule, false
+	}
+	if len(out) == 0 {
+		rule.Enabled = false
+		return rule, true
+	}
+	rule.Actions = out
+	rule.Action = out[0].Type
+	rule.LabelName = out[0].LabelName
+	return rule, true
+}
+
+// renameNestedLabels moves the Gmail labels nested under oldRoot to newRoot,
+// along with the smart labels and references that name them. Smart labels not
+// linked to Gmail move too; with a nil gmailClient, only they do.
+func (h *Handler) renameNestedLabels(ctx context.Context, gmailClient *gmailapi.Service, userEmail, oldRoot, newRoot string) labelCascade {
+	var c labelCascade
+	if gmailClient != nil {
+		if gmailLabels, err := h.gmailService.ListLabels(gmailClient); err == nil {
+			for _, l := range gmailLabels {
+				if l.Type == "system" || strings.EqualFold(l.Name, oldRoot) || !labelpath.IsWithin(l.Name, oldRoot) {
+					continue
+				}
+				to := labelpath.Reparent(l.Name, oldRoot, newRoot)
+				if err := h.gmailService.RenameLabel(gmailClient, l.Id, to); err != nil {
+					log.Printf("rename: failed to move nested label %q for %s: %v", l.Name, userEmail, err)
+					continue
+				}
+				h.db.SmartLabels().UpdateOne(ctx,
+					bson.M{"userId": userEmail, "name": labelNameFilter(l.Name)},
+					bson.M{"$set": bson.M{"name": to, "updatedAt": time.Now()}})
+				c.add(h.cascadeLabel(ctx, userEmail, l.Name, to, l.Id))
+			}
+		}
+	}
+
+	cursor, err := h.db.SmartLabels().Find(ctx, bson.M{
+		"userId": userEmail, "name": nestedLabelFilter(oldRoot), "gmailLabelId": bson.M{"$in": bson.A{"", nil}},
+	})
+	if err != nil {
+		return c
+	}
+	var unlinked []models.SmartLabel
+	cursor.All(ctx, &unlinked)
+	for _, l := range unlinked {
+		oid, err := primitive.ObjectIDFromHex(l.ID)
+		if err != nil {
+			continue
+		}
+		to := labelpath.Reparent(l.Name, oldRoot, newRoot)
+		h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"name": to, "updatedAt": time.Now()}})
+		c.add(h.cascadeLabel(ctx, userEmail, l.Name, to, ""))
+	}
+	return c
+}
+
+// labelNameFilter matches a label name case-insensitively, the way Gmail
+// compares them.
+func labelNameFilter(name string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}
+}
+
+// nestedLabelFilter matches the label names nested under root, at any depth.
+func nestedLabelFilter(root string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(root+labelpath.Separator), Options: "i"}
+}
+
+// hasNestedLabels reports whether the user has smart labels nested under name.
+func (h *Handler) hasNestedLabels(ctx context.Context, userEmail, name string) bool {
+	n, _ := h.db.SmartLabels().CountDocuments(ctx, bson.M{"userId": userEmail, "name": nestedLabelFilter(name)})
+	return n > 0
+}
+
+// cascadeLabel propagates a label rename (to != "") or deletion (to == "") to
+// everything that names it: sorting rule actions, sender default labels and
+// pending AI suggestions. toID is the Gmail label id suggestions should carry.
+func (h *Handler) cascadeLabel(ctx context.Context, userEmail, from, to, toID string) labelCascade {
+	var c labelCascade
+
+	ruleset, _ := h.loadRules(ctx, userEmail)
+	for _, ru := range ruleset {
+		updated, changed := relabelRule(ru, from, to)
+		if !changed {
+			continue
+		}
+		oid, err := primitive.ObjectIDFromHex(ru.ID)
+		if err != nil {
+			continue
+		}
+		if _, err := h.db.SortingRules().UpdateOne(ctx, bson.M{"_id": oid, "userId": userEmail}, bson.M{"$set": bson.M{
+			"enabled":   updated.Enabled,
+			"action":    updated.Action,
+			"labelName": updated.LabelName,
+			"actions":   updated.Actions,
+			"updatedAt": time.Now(),
+		}}); err == nil {
+			c.Rules++
+		}
+	}
+
+	match := labelNameFilter(from)
+	if to != "" {
+		if res, err := h.db.SenderPreferences().UpdateMany(ctx,
+			bson.M{"userId": userEmail, "defaultLabel": match},
+			bson.M{"$set": bson.M{"defaultLabel": to, "updatedAt": time.Now()}}); err == nil {
+			c.Senders = int(res.ModifiedCount)
+		}
+		if res, err := h.db.AISuggestions().UpdateMany(ctx,
+			bson.M{"userId": userEmail, "status": "pending", "labelName": match},
+			bson.M{"$set": bson.M{"labelName": to, "labelId": toID}}); err == nil {
+			c.Suggestions = int(res.ModifiedCount)
+		}
+		return c
+	}
+
+	// Deletion: a sender whose default was "label it X" no longer has a default.
+	if res, err := h.db.SenderPreferences().UpdateMany(ctx,
+		bson.M{"userId": userEmail, "defaultLabel": match, "defaultAction": "label"},
+		bson.M{"$set": bson.M{"defaultLabel": "", "defaultAction": "", "autoApply": false, "updatedAt": time.Now()}}); err == nil {
+		c.Senders = int(res.ModifiedCount)
+	}
+	if res, err := h.db.SenderPreferences().UpdateMany(ctx,
+		bson.M{"userId": userEmail, "defaultLabel": match},
+		bson.M{"$set": bson.M{"defaultLabel": "", "updatedAt": time.Now()}}); err == nil {
+		c.Senders += int(res.ModifiedCount)
+	}
+	if res, err := h.db.AISuggestions().UpdateMany(ctx,
+		bson.M{"userId": userEmail, "status": "pending", "action": "label", "labelName": match},
+		bson.M{"$set": bson.M{"status": "rejected"}}); err == nil {
+		c.Suggestions = int(res.ModifiedCount)
+	}
+	return c
+}
+
+// findSmartLabel loads one of the caller's smart labels by id.
+func (h *Handler) findSmartLabel(ctx context.Context, userEmail, id string) (models.SmartLabel, primitive.ObjectID, bool) {
+	var l models.SmartLabel
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return l, oid, false
+	}
+	err = h.db.SmartLabels().FindOne(ctx, bson.M{"_id": oid, "userId": userEmail}).Decode(&l)
+	return l, oid, err == nil
+}
+
+// labelStyle validates and resolves the appearance settings of a label: a
+// colour name or palette hex, and the two Gmail visibilities.
+func labelStyle(color, labelList, messageList string) (gmail.LabelStyle, error) {
+	var st gmail.LabelStyle
+	if strings.TrimSpace(color) != "" {
+		bg, text, err := gmail.ResolveColor(color)
+		if err != nil {
+			return st, err
+		}
+		st.BackgroundColor, st.TextColor = bg, text
+	}
+	if err := gmail.ValidateVisibility(labelList, messageList); err != nil {
+		return st, err
+	}
+	st.LabelListVisibility, st.MessageListVisibility = labelList, messageList
+	return st, nil
+}
+
+// UpdateSmartLabel edits a smart label. A rename is applied to the Gmail label
+// in place (messages keep it) and cascaded to rules, sender defaults and
+// pending suggestions. Renaming a parent ("Finance" → "Argent") moves its
+// nested labels along ("Finance/Factures" → "Argent/Factures"), since Gmail
+// only nests by name.
+func (h *Handler) UpdateSmartLabel(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	var in models.SmartLabelUpdate
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	label, oid, ok := h.findSmartLabel(ctx, userEmail, mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if in.Description != nil {
+		set["description"] = strings.TrimSpace(*in.Description)
+	}
+	if in.Keywords != nil {
+		set["keywords"] = in.Keywords
+	}
+
+	var style gmail.LabelStyle
+	if in.Color != nil || in.LabelListVisibility != nil || in.MessageListVisibility != nil {
+		var err error
+		style, err = labelStyle(deref(in.Color), deref(in.LabelListVisibility), deref(in.MessageListVisibility))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if style.BackgroundColor != "" {
+			set["color"], set["textColor"] = style.BackgroundColor, style.TextColor
+		}
+		if style.LabelListVisibility != "" {
+			set["labelListVisibility"] = style.LabelListVisibility
+		}
+		if style.MessageListVisibility != "" {
+			set["messageListVisibility"] = style.MessageListVisibility
+		}
+	}
+
+	name := label.Name
+	if in.Name != nil {
+		name = labelpath.Clean(*in.Name)
+		if err := labelpath.Validate(name); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	renamed := name != label.Name
+	if renamed {
+		if n, _ := h.db.SmartLabels().CountDocuments(ctx, bson.M{
+			"userId": userEmail, "name": labelNameFilter(name), "_id": bson.M{"$ne": oid},
+		}); n > 0 && !strings.EqualFold(name, label.Name) {
+			writeError(w, http.StatusConflict, "Un libellé porte déjà ce nom — fusionnez-les plutôt")
+			return
+		}
+	}
+
+	var cascade labelCascade
+	if label.GmailLabelID != "" && (renamed || style != (gmail.LabelStyle{})) {
+		gmailClient, err := h.gmailClientFor(ctx, userEmail)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+			return
+		}
+		if style != (gmail.LabelStyle{}) {
+			if err := h.gmailService.UpdateLabelStyle(gmailClient, label.GmailLabelID, style); err != nil {
+				writeError(w, http.StatusBadGateway, "Failed to update Gmail label: "+err.Error())
+				return
+			}
+		}
+		if renamed {
+			if err := h.gmailService.RenameLabel(gmailClient, label.GmailLabelID, name); err != nil {
+				writeError(w, http.StatusBadGateway, "Failed to rename Gmail label: "+err.Error())
+				return
+			}
+			cascade = h.renameNestedLabels(ctx, gmailClient, userEmail, label.Name, name)
+		}
+	} else if renamed {
+		// A parent not linked to Gmail may still have nested labels there;
+		// without credentials, only the smart labels move.
+		gmailClient, _ := h.gmailClientFor(ctx, userEmail)
+		cascade = h.renameNestedLabels(ctx, gmailClient, userEmail, label.Name, name)
+	}
+	if renamed {
+		set["name"] = name
+		cascade.add(h.cascadeLabel(ctx, userEmail, label.Name, name, label.GmailLabelID))
+	}
+
+	if _, err := h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set}); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update label")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "updated", "cascade": cascade})
+}
+
+// MergeSmartLabel folds label {id} into another: every message carrying it is
+// relabelled in Gmail, references are repointed, and the source label is
+// deleted from Gmail and Mailsorter. A label with nested labels is refused, as
+// they would be left under a parent that no longer exists.
+func (h *Handler) MergeSmartLabel(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	var req models.MergeSmartLabelRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	src, srcOID, ok := h.findSmartLabel(ctx, userEmail, mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	dst, dstOID, ok := h.findSmartLabel(ctx, userEmail, req.IntoID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Target label not found")
+		return
+	}
+	if srcOID == dstOID {
+		writeError(w, http.StatusBadRequest, "Cannot merge a label into itself")
+		return
+	}
+	if h.hasNestedLabels(ctx, userEmail, src.Name) {
+		writeError(w, http.StatusConflict, "Ce libellé contient des sous-libellés — déplacez-les d'abord")
+		return
+	}
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+		return
+	}
+	if dst.GmailLabelID == "" {
+		if dst.GmailLabelID, err = h.ensureLabel(ctx, gmailClient, userEmail, dst.Name); err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to create Gmail label: "+err.Error())
+			return
+		}
+	}
+
+	// Relabel page by page until the source label is empty: each round's
+	// batchModify strips it, so the next listing returns what is left.
+	moved, done := 0, true
+	if src.GmailLabelID != "" {
+		for {
+			ids, err := h.gmailService.ListLabelMessageIDs(gmailClient, src.GmailLabelID, mergeMessageCap)
+			if err != nil {
+				writeError(w, http.StatusBadGateway, "Failed to list labelled messages: "+err.Error())
+				return
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := h.gmailService.BatchModify(gmailClient, ids, []string{dst.GmailLabelID}, []string{src.GmailLabelID}); err != nil {
+				writeError(w, http.StatusBadGateway, "Failed to relabel messages: "+err.Error())
+				return
+			}
+			moved += len(ids)
+			// Stop while there is still time to record the progress.
+			if deadline, _ := ctx.Deadline(); time.Until(deadline) < mergeReserve {
+				done = false
+				break
+			}
+		}
+	}
+
+	if !done {
+		// Out of time with messages left: keep the source label so nothing
+		// loses it; merging again picks up where this stopped.
+		h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": dstOID}, bson.M{"$inc": bson.M{"emailCount": moved}})
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "partial", "into": dst.Name, "moved": moved})
+		return
+	}
+	if src.GmailLabelID != "" {
+		if err := h.gmailService.DeleteLabel(gmailClient, src.GmailLabelID); err != nil {
+			log.Printf("merge: failed to delete Gmail label %s for %s: %v", src.GmailLabelID, userEmail, err)
+		}
+	}
+
+	cascade := h.cascadeLabel(ctx, userEmail, src.Name, dst.Name, dst.GmailLabelID)
+	keywords := mergeKeywords(dst.Keywords, src.Keywords)
+	h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": dstOID}, bson.M{
+		"$set": bson.M{"keywords": keywords, "updatedAt": time.Now()},
+		"$inc": bson.M{"emailCount": moved},
+	})
+	h.db.SmartLabels().DeleteOne(ctx, bson.M{"_id": srcOID})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "merged", "into": dst.Name, "moved": moved, "cascade": cascade})
+}
+
+// mergeKeywords appends the keywords of b that a lacks, case-insensitively.
+func mergeKeywords(a, b []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(a)+len(b))
+	for _, k := range append(append([]string{}, a...), b...) {
+		key := strings.ToLower(strings.TrimSpace(k))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+// DeleteSmartLabel deletes a smart label and, unless keepGmailLabel=true, its
+// Gmail label (Gmail removes it from every message). Rules lose the label
+// action — or are disabled when it was their only one — and sender defaults
+// and pending suggestions that pointed at it are cleared. Like a merge, it is
+// refused while labels are nested under it.
+func (h *Handler) DeleteSmartLabel(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	label, oid, ok := h.findSmartLabel(ctx, userEmail, mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	if h.hasNestedLabels(ctx, userEmail, label.Name) {
+		writeError(w, http.StatusConflict, "Ce libellé contient des sous-libellés — déplacez-les ou supprimez-les d'abord")
+		return
+	}
+
+	if label.GmailLabelID != "" && r.URL.Query().Get("keepGmailLabel") != "true" {
+		gmailClient, err := h.gmailClientFor(ctx, userEmail)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+			return
+		}
+		if err := h.gmailService.DeleteLabel(gmailClient, label.GmailLabelID); err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to delete Gmail label: "+err.Error())
+			return
+		}
+	}
+
+	cascade := h.cascadeLabel(ctx, userEmail, label.Name, "", "")
+	h.db.SmartLabels().DeleteOne(ctx, bson.M{"_id": oid})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "deleted", "cascade": cascade})
+}
+
+// SyncSmartLabels runs the Gmail reconciler for the caller right away.
+func (h *Handler) SyncSmartLabels(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	changed, err := h.reconcileUserLabels(ctx, userEmail)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Label sync failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"changed": changed})
+}
+
+// labelFix is what the reconciler changes on one smart label.
+type labelFix struct {
+	Label        models.SmartLabel
+	Name         string // new name when the label was renamed in Gmail
+	GmailLabelID string // relinked id, or "" when the Gmail label is gone
+	Relinked     bool
+}
+
+// reconcileLabels compares smart labels with the mailbox's labels. A label
+// renamed in Gmail takes its Gmail name; one whose id vanished is relinked by
+// name, or unlinked when Gmail no longer has it (it is recreated on next use).
+func reconcileLabels(smart []models.SmartLabel, gmailLabels []*gmailapi.Label) []labelFix {
+	byID := map[string]*gmailapi.Label{}
+	byName := map[string]*gmailapi.Label{}
+	for _, l := range gmailLabels {
+		byID[l.Id] = l
+		byName[strings.ToLower(l.Name)] = l
+	}
+	var fixes []labelFix
+	for _, s := range smart {
+		if l, ok := byID[s.GmailLabelID]; ok && s.GmailLabelID != "" {
+			if l.Name != s.Name {
+				fixes = append(fixes, labelFix{Label: s, Name: l.Name, GmailLabelID: l.Id})
+			}
+			continue
+		}
+		if l, ok := byName[strings.ToLower(s.Name)]; ok {
+			fixes = append(fixes, labelFix{Label: s, GmailLabelID: l.Id, Relinked: true})
+			continue
+		}
+		if s.GmailLabelID != "" {
+			fixes = append(fixes, labelFix{Label: s, Relinked: true})
+		}
+	}
+	return fixes
+}
+
+// reconcileUserLabels applies reconcileLabels for one user and refreshes every
+// smart label's EmailCount from Gmail. It returns how many labels changed.
+func (h *Handler) reconcileUserLabels(ctx context.Context, userEmail string) (int, error) {
+	cur, err := h.db.SmartLabels().Find(ctx, bson.M{"userId": userEmail})
+	if err != nil {
+		return 0, err
+	}
+	var smart []models.SmartLabel
+	if err := cur.All(ctx, &smart); err != nil {
+		return 0, err
+	}
+	if len(smart) == 0 {
+		return 0, nil
+	}
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		return 0, err
+	}
+	gmailLabels, err := h.gmailService.ListLabels(gmailClient)
+	if err != nil {
+		return 0, err
+	}
+
+	fixes := reconcileLabels(smart, gmailLabels)
+	linked := map[string]string{}
+	for _, s := range smart {
+		linked[s.Name] = s.GmailLabelID
+	}
+	for _, f := range fixes {
+		oid, err := primitive.ObjectIDFromHex(f.Label.ID)
+		if err != nil {
+			continue
+		}
+		set := bson.M{"gmailLabelId": f.GmailLabelID, "updatedAt": time.Now()}
+		if f.Name != "" {
+			set["name"] = f.Name
+			h.cascadeLabel(ctx, userEmail, f.Label.Name, f.Name, f.GmailLabelID)
+		}
+		h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+		linked[f.Label.Name] = f.GmailLabelID
+	}
+
+	for _, s := range smart {
+		oid, err := primitive.ObjectIDFromHex(s.ID)
+		if err != nil {
+			continue
+		}
+		count := 0
+		if id := linked[s.Name]; id != "" {
+			l, err := h.gmailService.GetLabel(gmailClient, id)
+			if err != nil {
+				continue
+			}
+			count = int(l.MessagesTotal)
+		}
+		if count != s.EmailCount {
+			h.db.SmartLabels().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"emailCount": count}})
+		}
+	}
+	return len(fixes), nil
+}
+
+// startLabelSyncLoop launches the background reconciler that keeps smart
+// labels in sync with each mailbox.
+func (h *Handler) startLabelSyncLoop() {
+	go func() {
+		ticker := time.NewTicker(labelSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.reconcileAllLabels()
+		}
+	}()
+}
+
+// reconcileAllLabels reconciles every user that has smart labels. A failing
+// account is logged and skipped.
+func (h *Handler) reconcileAllLabels() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	users, err := h.db.SmartLabels().Distinct(ctx, "userId", bson.M{})
+	if err != nil {
+		log.Printf("labelsync: failed to list users: %v", err)
+		return
+	}
+	for _, u := range users {
+		email, ok := u.(string)
+		if !ok || email == "" {
+			continue
+		}
+		if n, err := h.reconcileUserLabels(ctx, email); err != nil {
+			log.Printf("labelsync: %s: %v", email, err)
+		} else if n > 0 {
+			log.Printf("labelsync: %s — %d label(s) reconciled", email, n)
+		}
+	}
+}
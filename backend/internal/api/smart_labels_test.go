@@ -0,0 +1,98 @@
+package api
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func TestRelabelRuleRename(t *testing.T) {
+	legacy := models.SortingRule{Enabled: true, Action: "label", LabelName: "Suivi Colis"}
+	got, changed := relabelRule(legacy, "suivi colis", "Livraison")
+	if !changed || got.LabelName != "Livraison" || got.Actions[0].LabelName != "Livraison" {
+		t.Fatalf("legacy rule = %+v, changed=%v", got, changed)
+	}
+
+	multi := models.SortingRule{Enabled: true, Actions: []models.RuleAction{
+		{Type: "label", LabelName: "Suivi Colis"}, {Type: "archive"},
+	}}
+	got, _ = relabelRule(multi, "Suivi Colis", "Livraison")
+	want := []models.RuleAction{{Type: "label", LabelName: "Livraison"}, {Type: "archive"}}
+	if !reflect.DeepEqual(got.Actions, want) {
+		t.Errorf("actions = %+v, want %+v", got.Actions, want)
+	}
+
+	other := models.SortingRule{Action: "label", LabelName: "Travail"}
+	if _, changed := relabelRule(other, "Suivi Colis", "Livraison"); changed {
+		t.Error("unrelated rule reported as changed")
+	}
+}
+
+func TestRelabelRuleDelete(t *testing.T) {
+	multi := models.SortingRule{Enabled: true, Actions: []models.RuleAction{
+		{Type: "label", LabelName: "Promo"}, {Type: "archive"},
+	}}
+	got, changed := relabelRule(multi, "Promo", "")
+	if !changed || !got.Enabled || got.Action != "archive" || len(got.Actions) != 1 {
+		t.Errorf("multi-action rule after delete = %+v", got)
+	}
+
+	only := models.SortingRule{Enabled: true, Action: "label", LabelName: "Promo"}
+	got, changed = relabelRule(only, "Promo", "")
+	if !changed || got.Enabled {
+		t.Errorf("label-only rule should be disabled: %+v", got)
+	}
+}
+
+func TestReconcileLabels(t *testing.T) {
+	smart := []models.SmartLabel{
+		{ID: "1", Name: "Factures", GmailLabelID: "L1"},   // in sync
+		{ID: "2", Name: "Colis", GmailLabelID: "L2"},      // renamed in Gmail
+		{ID: "3", Name: "Voyages", GmailLabelID: "stale"}, // id changed, name still there
+		{ID: "4", Name: "Promo", GmailLabelID: "L9"},      // deleted in Gmail
+		{ID: "5", Name: "Banque"},                         // never linked, not in Gmail
+	}
+	gmailLabels := []*gmailapi.Label{
+		{Id: "L1", Name: "Factures"},
+		{Id: "L2", Name: "Livraison"},
+		{Id: "L3", Name: "voyages"},
+	}
+	fixes := reconcileLabels(smart, gmailLabels)
+	if len(fixes) != 3 {
+		t.Fatalf("fixes = %+v, want 3", fixes)
+	}
+	if f := fixes[0]; f.Label.ID != "2" || f.Name != "Livraison" {
+		t.Errorf("rename fix = %+v", f)
+	}
+	if f := fixes[1]; f.Label.ID != "3" || f.GmailLabelID != "L3" || !f.Relinked {
+		t.Errorf("relink fix = %+v", f)
+	}
+	if f := fixes[2]; f.Label.ID != "4" || f.GmailLabelID != "" {
+		t.Errorf("unlink fix = %+v", f)
+	}
+}
+
+func TestMergeKeywords(t *testing.T) {
+	got := mergeKeywords([]string{"colis", "Suivi"}, []string{"suivi", "livraison", ""})
+	if !reflect.DeepEqual(got, []string{"colis", "Suivi", "livraison"}) {
+		t.Errorf("mergeKeywords = %v", got)
+	}
+}
+
+func TestNestedLabelFilter(t *testing.T) {
+	re := regexp.MustCompile("(?i)" + nestedLabelFilter("Finance (Perso)").Pattern)
+	for name, want := range map[string]bool{
+		"Finance (Perso)/Factures":        true,
+		"finance (perso)/Impôts/2026":     true,
+		"Finance (Perso)":                 false,
+		"Finance (Perso)-Archives/Divers": false,
+		"Finance Perso/Factures":          false,
+	} {
+		if got := re.MatchString(name); got != want {
+			t.Errorf("nested under Finance (Perso): %q = %v, want %v", name, got, want)
+		}
+	}
+}
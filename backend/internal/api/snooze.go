@@ -3,13 +3,17 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/schedule"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/snooze"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -25,10 +29,25 @@ const snoozeLabelName = "Mailsorter/Reporté"
 // snooze has elapsed and brings them back.
 const snoozeSweepInterval = time.Minute
 
+// Snooze statuses set besides scheduled and done.
+const (
+	snoozeReplied   = "replied"   // ended by a reply in the thread
+	snoozeCancelled = "cancelled" // the email came back or disappeared another way
+)
+
+// snoozeOptions are the optional recurrence and wake condition of a snooze.
+type snoozeOptions struct {
+	Repeat    string
+	Until     time.Time
+	Condition string
+}
+
 // Snooze pulls a message out of the inbox until a chosen wake time. It resolves
-// the wake time from a friendly preset (or an explicit timestamp), archives the
-// message (removing INBOX) and tags it with the snooze label so it is easy to
-// find. A background loop returns it to the inbox, marked unread, when due.
+// the wake time from a friendly preset (or an explicit timestamp, or the first
+// occurrence of a recurrence), archives the message (removing INBOX) and tags
+// it with the snooze label so it is easy to find. A background loop returns it
+// to the inbox, marked unread, when due — again at every occurrence of a
+// recurring snooze — and sync ends it early when its thread condition is met.
 func (h *Handler) Snooze(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -45,24 +64,52 @@ func (h *Handler) Snooze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resolve the wake time: an explicit future timestamp wins, otherwise a preset.
+	if !snooze.ValidRepeat(req.Repeat) {
+		http.Error(w, "Récurrence inconnue", http.StatusBadRequest)
+		return
+	}
+	if !snooze.ValidCondition(req.Condition) {
+		http.Error(w, "Condition de réveil inconnue", http.StatusBadRequest)
+		return
+	}
+	if req.Repeat != "" && req.Condition != "" {
+		http.Error(w, "Un report récurrent ne peut pas dépendre d'une réponse", http.StatusBadRequest)
+		return
+	}
+	if !req.Until.IsZero() && req.Repeat == "" {
+		http.Error(w, "Une date de fin n'a de sens que pour un report récurrent", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Resolve the wake time on the user's calendar: an explicit future
+	// timestamp wins, then a preset, then the recurrence's first occurrence.
 	wakeAt := req.WakeAt
 	now := time.Now()
+	cal := h.userCalendar(ctx, userEmail)
 	if wakeAt.IsZero() {
-		resolved, err := snooze.Resolve(req.Preset, now)
+		var err error
+		if req.Preset == "" && req.Repeat != "" {
+			wakeAt, err = snooze.Next(req.Repeat, now, cal)
+		} else {
+			wakeAt, err = snooze.ResolveIn(req.Preset, now, cal)
+		}
 		if err != nil {
 			http.Error(w, "Choisissez une échéance valide", http.StatusBadRequest)
 			return
 		}
-		wakeAt = resolved
 	}
 	if !wakeAt.After(now) {
 		http.Error(w, "L'échéance doit être dans le futur", http.StatusBadRequest)
 		return
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	if !req.Until.IsZero() && req.Until.Before(wakeAt) {
+		http.Error(w, "La date de fin précède le premier réveil", http.StatusBadRequest)
+		return
+	}
+	opts := snoozeOptions{Repeat: req.Repeat, Until: req.Until, Condition: req.Condition}
 
 	gmailClient, err := h.gmailClientFor(ctx, userEmail)
 	if err != nil {
@@ -70,49 +117,76 @@ func (h *Handler) Snooze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.scheduleSnooze(ctx, gmailClient, userEmail, req.MessageID, wakeAt, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "snoozed",
+		"wakeAt":    wakeAt,
+		"repeat":    req.Repeat,
+		"condition": req.Condition,
+	})
+}
+
+// scheduleSnooze takes a message out of the inbox until wakeAt: it tags it with
+// the snooze label, removes INBOX, upserts the scheduled snooze (with its
+// recurrence and wake condition, if any) and records the archive in the
+// ledger. Shared by the Snooze endpoint and deadline reminders. Errors carry a
+// user-facing (French) message.
+func (h *Handler) scheduleSnooze(ctx context.Context, gmailClient *gmailapi.Service, userEmail, messageID string, wakeAt time.Time, opts snoozeOptions) error {
+	now := time.Now()
+
 	// Enrich the record with sender/subject for the snoozed list (best-effort).
 	from, subject, threadID := "", "", ""
-	if msg, mErr := h.gmailService.GetMessage(gmailClient, req.MessageID); mErr == nil {
+	if msg, mErr := h.gmailService.GetMessage(gmailClient, messageID); mErr == nil {
 		from, subject, _, _ = gmail.ParseEmailHeaders(msg)
 		threadID = msg.ThreadId
 	}
 
 	labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, snoozeLabelName)
 	if err != nil {
-		http.Error(w, "Impossible de préparer le report", http.StatusBadGateway)
-		return
+		return errors.New("Impossible de préparer le report")
 	}
 	// Out of the inbox, tagged as snoozed.
-	if err := h.gmailService.ModifyMessage(gmailClient, req.MessageID, []string{labelID}, []string{"INBOX"}); err != nil {
-		http.Error(w, "Report impossible : "+err.Error(), http.StatusBadGateway)
-		return
+	if err := h.gmailService.ModifyMessage(gmailClient, messageID, []string{labelID}, []string{"INBOX"}); err != nil {
+		return fmt.Errorf("Report impossible : %v", err)
 	}
 
-	_, err = h.db.Snoozes().UpdateOne(ctx,
-		bson.M{"userId": userEmail, "messageId": req.MessageID, "status": "scheduled"},
-		bson.M{
-			"$set": bson.M{
-				"from": from, "subject": subject, "threadId": threadID,
-				"wakeAt": wakeAt, "status": "scheduled", "updatedAt": now,
-			},
-			"$setOnInsert": bson.M{
-				"userId": userEmail, "messageId": req.MessageID, "createdAt": now,
-			},
+	set := bson.M{
+		"from": from, "subject": subject, "threadId": threadID,
+		"wakeAt": wakeAt, "status": "scheduled", "updatedAt": now,
+	}
+	unset := bson.M{}
+	for field, v := range map[string]interface{}{"repeat": opts.Repeat, "until": opts.Until, "condition": opts.Condition} {
+		if v == "" || v == (time.Time{}) {
+			unset[field] = ""
+		} else {
+			set[field] = v
+		}
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"userId": userEmail, "messageId": messageID, "createdAt": now,
 		},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err = h.db.Snoozes().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "messageId": messageID, "status": "scheduled"},
+		update,
 		options.Update().SetUpsert(true),
 	)
 	if err != nil {
-		http.Error(w, "Failed to save snooze", http.StatusInternalServerError)
-		return
+		return errors.New("Failed to save snooze")
 	}
 
-	h.logAction(ctx, userEmail, req.MessageID, "archive", SourceSnooze)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "snoozed",
-		"wakeAt": wakeAt,
-	})
+	h.logAction(ctx, userEmail, messageID, "archive", SourceSnooze)
+	return nil
 }
 
 // GetSnoozes lists the caller's snoozes (scheduled by default), soonest first.
@@ -151,7 +225,8 @@ func (h *Handler) GetSnoozes(w http.ResponseWriter, r *http.Request) {
 }
 
 // WakeSnooze brings a snoozed email back to the inbox immediately (the user
-// changed their mind), marking it unread so it is not missed.
+// changed their mind), marking it unread so it is not missed. It also ends a
+// recurring snooze.
 func (h *Handler) WakeSnooze(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -188,15 +263,16 @@ func (h *Handler) WakeSnooze(w http.ResponseWriter, r *http.Request) {
 	h.db.Snoozes().UpdateOne(ctx, bson.M{"_id": oid},
 		bson.M{"$set": bson.M{"status": "done", "updatedAt": time.Now()}})
 	h.logAction(ctx, userEmail, s.MessageID, "unarchive", SourceSnooze)
+	h.publishSnoozeWoke(s, "manual")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "woken"})
 }
 
-// restoreSnoozed returns a message to the inbox, marks it unread and strips the
-// snooze label.
-func (h *Handler) restoreSnoozed(ctx context.Context, gmailClient *gmailapi.Service, userEmail, messageID string) error {
-	add := []string{"INBOX", "UNREAD"}
+// restoreSnoozed returns a message to the inbox, marks it unread, strips the
+// snooze label and adds addLabelIDs (the follow-up label, for a follow-up).
+func (h *Handler) restoreSnoozed(ctx context.Context, gmailClient *gmailapi.Service, userEmail, messageID string, addLabelIDs ...string) error {
+	add := append([]string{"INBOX", "UNREAD"}, addLabelIDs...)
 	remove := []string{}
 	if labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, snoozeLabelName); err == nil {
 		remove = append(remove, labelID)
@@ -204,20 +280,97 @@ func (h *Handler) restoreSnoozed(ctx context.Context, gmailClient *gmailapi.Serv
 	return h.gmailService.ModifyMessage(gmailClient, messageID, add, remove)
 }
 
-// startSnoozeLoop launches the background sweeper that resurfaces due snoozes.
+// closeSnoozes ends the scheduled snoozes of a message with status without
+// bringing it back, and strips the snooze label (best-effort) so Gmail no
+// longer shows it as snoozed.
+func (h *Handler) closeSnoozes(ctx context.Context, gmailClient *gmailapi.Service, userEmail, messageID, status string) {
+	if labelID, err := h.ensureLabel(ctx, gmailClient, userEmail, snoozeLabelName); err == nil {
+		h.gmailService.ModifyMessage(gmailClient, messageID, nil, []string{labelID})
+	}
+	h.db.Snoozes().UpdateMany(ctx,
+		bson.M{"userId": userEmail, "messageId": messageID, "status": "scheduled"},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}})
+}
+
+// snoozeWatch indexes a user's scheduled snoozes for the checks sync runs on
+// every message.
+type snoozeWatch struct {
+	byMessage map[string]models.Snooze
+	byThread  map[string][]models.Snooze // conditional snoozes only
+}
+
+// loadSnoozeWatch loads the user's scheduled snoozes. Sync loads it before
+// listing the inbox, so a snooze created meanwhile is never mistaken for one
+// the user undid.
+func (h *Handler) loadSnoozeWatch(ctx context.Context, userEmail string) snoozeWatch {
+	w := snoozeWatch{byMessage: map[string]models.Snooze{}, byThread: map[string][]models.Snooze{}}
+	cursor, err := h.db.Snoozes().Find(ctx, bson.M{"userId": userEmail, "status": "scheduled"})
+	if err != nil {
+		return w
+	}
+	var rows []models.Snooze
+	if err := cursor.All(ctx, &rows); err != nil {
+		return w
+	}
+	for _, s := range rows {
+		w.byMessage[s.MessageID] = s
+		if s.Condition != "" && s.ThreadID != "" {
+			w.byThread[s.ThreadID] = append(w.byThread[s.ThreadID], s)
+		}
+	}
+	return w
+}
+
+// checkSnoozes applies what a synced email means for the user's snoozes. A
+// one-off snoozed email found back in the inbox was brought back by other
+// means (Gmail itself, undo), so its snooze is cancelled. A new email from
+// someone else in the thread of a conditional snooze is a reply: it wakes a
+// "reply" snooze now and settles a "noReply" follow-up without waking it.
+func (h *Handler) checkSnoozes(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, watch snoozeWatch, email models.Email, isNew bool) {
+	if s, ok := watch.byMessage[email.MessageID]; ok && s.Repeat == "" && contains(email.LabelIDs, "INBOX") {
+		h.closeSnoozes(ctx, gmailClient, userEmail, email.MessageID, snoozeCancelled)
+	}
+	if !isNew || email.ThreadID == "" || senderAddress(email.From) == strings.ToLower(userEmail) {
+		return
+	}
+	for _, s := range watch.byThread[email.ThreadID] {
+		if s.MessageID == email.MessageID || !email.ReceivedDate.After(s.CreatedAt) {
+			continue
+		}
+		switch snooze.OnReply(s.Condition) {
+		case snooze.ReplyWakes:
+			if err := h.restoreSnoozed(ctx, gmailClient, userEmail, s.MessageID); err != nil {
+				log.Printf("snooze: failed to wake %s on reply for %s: %v", s.MessageID, userEmail, err)
+				continue
+			}
+			oid, _ := primitive.ObjectIDFromHex(s.ID)
+			h.db.Snoozes().UpdateOne(ctx, bson.M{"_id": oid},
+				bson.M{"$set": bson.M{"status": snoozeReplied, "updatedAt": time.Now()}})
+			h.logAction(ctx, userEmail, s.MessageID, "unarchive", SourceSnooze)
+			h.publishSnoozeWoke(s, "reply")
+		case snooze.ReplyResolves:
+			h.closeSnoozes(ctx, gmailClient, userEmail, s.MessageID, snoozeReplied)
+		}
+	}
+}
+
+// startSnoozeLoop launches the background sweeper that resurfaces due snoozes
+// and follow-ups.
 func (h *Handler) startSnoozeLoop() {
 	go func() {
 		ticker := time.NewTicker(snoozeSweepInterval)
 		defer ticker.Stop()
 		for range ticker.C {
 			h.wakeDueSnoozes()
+			h.surfaceDueFollowUps()
 		}
 	}()
 }
 
-// wakeDueSnoozes brings back every snooze whose wake time has passed. It is
-// best-effort and resilient: a per-message Gmail failure is logged and skipped
-// so one bad message never stalls the rest.
+// wakeDueSnoozes brings back every snooze whose wake time has passed and moves
+// a recurring one on to its next occurrence. It is best-effort and resilient:
+// a per-message Gmail failure is logged and skipped so one bad message never
+// stalls the rest, and a message that no longer exists cancels its snooze.
 func (h *Handler) wakeDueSnoozes() {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
@@ -235,12 +388,14 @@ func (h *Handler) wakeDueSnoozes() {
 		return
 	}
 
-	// Cache one Gmail client per user across their due snoozes.
+	// Cache one Gmail client and calendar per user across their due snoozes.
 	clients := map[string]*gmailapi.Service{}
+	calendars := map[string]schedule.Calendar{}
 	seen := map[string]bool{}
 	for _, s := range due {
 		if !seen[s.UserID] {
 			seen[s.UserID] = true
+			calendars[s.UserID] = h.userCalendar(ctx, s.UserID)
 			if c, cerr := h.gmailClientFor(ctx, s.UserID); cerr == nil {
 				clients[s.UserID] = c
 			} else {
@@ -252,13 +407,27 @@ func (h *Handler) wakeDueSnoozes() {
 			continue
 		}
 
+		oid, _ := primitive.ObjectIDFromHex(s.ID)
 		if err := h.restoreSnoozed(ctx, client, s.UserID, s.MessageID); err != nil {
+			if gmail.IsNotFound(err) {
+				h.db.Snoozes().UpdateOne(ctx, bson.M{"_id": oid},
+					bson.M{"$set": bson.M{"status": snoozeCancelled, "updatedAt": time.Now()}})
+				continue
+			}
 			log.Printf("snooze: failed to restore %s for %s: %v", s.MessageID, s.UserID, err)
 			continue
 		}
-		oid, _ := primitive.ObjectIDFromHex(s.ID)
-		h.db.Snoozes().UpdateOne(ctx, bson.M{"_id": oid},
-			bson.M{"$set": bson.M{"status": "done", "updatedAt": time.Now()}})
+		update := bson.M{"$set": bson.M{"status": "done", "updatedAt": time.Now()}}
+		if next, again := snooze.AfterWake(s.Repeat, s.Until, time.Now(), calendars[s.UserID]); again {
+			update = bson.M{
+				"$set": bson.M{"wakeAt": next, "updatedAt": time.Now()},
+				"$inc": bson.M{"occurrences": 1},
+			}
+		} else if s.Repeat != "" {
+			update["$inc"] = bson.M{"occurrences": 1}
+		}
+		h.db.Snoozes().UpdateOne(ctx, bson.M{"_id": oid}, update)
 		h.logAction(ctx, s.UserID, s.MessageID, "unarchive", SourceSnooze)
+		h.publishSnoozeWoke(s, "due")
 	}
 }
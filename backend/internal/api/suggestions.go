@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SuggestionMaxAge is how long a suggestion may stay pending before it
+// expires; 0 disables expiry. Overridden from config at startup.
+var SuggestionMaxAge = 14 * 24 * time.Hour
+
+// suggestionSyncInterval is how often pending suggestions are checked against
+// Gmail.
+const suggestionSyncInterval = time.Hour
+
+// suggestionCheckCap bounds how many pending suggestions one pass checks per
+// user, newest first; the rest wait for the next pass (or expire).
+const suggestionCheckCap = 500
+
+// Suggestion statuses set by the reconciler, alongside pending, applied and
+// rejected.
+const (
+	suggestionObsolete = "obsolete" // the message is gone or already in the suggested state
+	suggestionExpired  = "expired"  // pending for longer than SuggestionMaxAge
+)
+
+// suggestionQuery is a parsed GET /api/ai/suggestions query.
+type suggestionQuery struct {
+	Status string
+	Action string // "" = every action
+	Sort   bson.D
+	Limit  int64
+	Offset int64
+}
+
+// parseSuggestionQuery reads status (default pending), action, sort ("recent",
+// the default, or "confidence", highest first), limit (default 100, capped at
+// 200) and offset.
+func parseSuggestionQuery(q url.Values) (suggestionQuery, error) {
+	sq := suggestionQuery{Status: q.Get("status"), Action: q.Get("action"), Limit: 100}
+	if sq.Status == "" {
+		sq.Status = "pending"
+	}
+	switch sq.Action {
+	case "", "archive", "delete", "label", "keep":
+	default:
+		return sq, fmt.Errorf("invalid action %q", sq.Action)
+	}
+	switch q.Get("sort") {
+	case "", "recent":
+		sq.Sort = bson.D{{Key: "createdAt", Value: -1}}
+	case "confidence":
+		sq.Sort = bson.D{{Key: "confidence", Value: -1}, {Key: "createdAt", Value: -1}}
+	default:
+		return sq, fmt.Errorf("invalid sort %q (recent, confidence)", q.Get("sort"))
+	}
+	if v := q.Get("limit"); v != "" {
+		if n, err := parseInt64(v); err == nil && n > 0 {
+			sq.Limit = n
+			if sq.Limit > 200 {
+				sq.Limit = 200
+			}
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if n, err := parseInt64(v); err == nil && n > 0 {
+			sq.Offset = n
+		}
+	}
+	return sq, nil
+}
+
+// isSuggestionObsolete reports whether a pending suggestion no longer needs the
+// user, given the message's current Gmail labels: a trashed or spammed message
+// is out of reach, an archive of a message already out of the inbox or a label
+// already present has nothing left to do, and "keep" is moot once the user
+// archived it themselves.
+func isSuggestionObsolete(s models.AISuggestion, labelIDs []string) bool {
+	has := make(map[string]bool, len(labelIDs))
+	for _, id := range labelIDs {
+		has[id] = true
+	}
+	if has["TRASH"] || has["SPAM"] {
+		return true
+	}
+	switch s.Action {
+	case "archive", "keep":
+		return !has["INBOX"]
+	case "label":
+		return s.LabelID != "" && has[s.LabelID]
+	}
+	return false
+}
+
+// duplicateSuggestions returns the ids of pending suggestions that repeat a
+// message already covered by a newer one. in must be sorted newest first.
+func duplicateSuggestions(in []models.AISuggestion) []string {
+	seen := make(map[string]bool, len(in))
+	var dups []string
+	for _, s := range in {
+		if seen[s.EmailID] {
+			dups = append(dups, s.ID)
+			continue
+		}
+		seen[s.EmailID] = true
+	}
+	return dups
+}
+
+// reconcileUserSuggestions expires old pending suggestions, then checks the
+// newest ones against Gmail and closes those that are duplicated or obsolete.
+// It returns how many were expired and how many marked obsolete.
+func (h *Handler) reconcileUserSuggestions(ctx context.Context, userEmail string) (expired, obsolete int, err error) {
+	if SuggestionMaxAge > 0 {
+		res, err := h.db.AISuggestions().UpdateMany(ctx, bson.M{
+			"userId":    userEmail,
+			"status":    "pending",
+			"createdAt": bson.M{"$lt": time.Now().Add(-SuggestionMaxAge)},
+		}, bson.M{"$set": bson.M{"status": suggestionExpired}})
+		if err != nil {
+			return 0, 0, err
+		}
+		expired = int(res.ModifiedCount)
+	}
+
+	cursor, err := h.db.AISuggestions().Find(ctx,
+		bson.M{"userId": userEmail, "status": "pending"},
+		options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(suggestionCheckCap))
+	if err != nil {
+		return expired, 0, err
+	}
+	var pending []models.AISuggestion
+	if err := cursor.All(ctx, &pending); err != nil {
+		return expired, 0, err
+	}
+	if len(pending) == 0 {
+		return expired, 0, nil
+	}
+
+	stale := duplicateSuggestions(pending)
+	dup := make(map[string]bool, len(stale))
+	for _, id := range stale {
+		dup[id] = true
+	}
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		return expired, 0, err
+	}
+	for _, s := range pending {
+		if dup[s.ID] {
+			continue
+		}
+		labelIDs, err := h.gmailService.GetMessageLabels(gmailClient, s.EmailID)
+		switch {
+		case gmail.IsNotFound(err):
+			stale = append(stale, s.ID)
+		case err != nil:
+			continue
+		case isSuggestionObsolete(s, labelIDs):
+			stale = append(stale, s.ID)
+		}
+	}
+	if len(stale) == 0 {
+		return expired, 0, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(stale))
+	for _, id := range stale {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			ids = append(ids, oid)
+		}
+	}
+	res, err := h.db.AISuggestions().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "userId": userEmail, "status": "pending"},
+		bson.M{"$set": bson.M{"status": suggestionObsolete}})
+	if err != nil {
+		return expired, 0, err
+	}
+	return expired, int(res.ModifiedCount), nil
+}
+
+// startSuggestionSyncLoop periodically reconciles every user's pending
+// suggestions.
+func (h *Handler) startSuggestionSyncLoop() {
+	go func() {
+		ticker := time.NewTicker(suggestionSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.reconcileAllSuggestions()
+		}
+	}()
+}
+
+// reconcileAllSuggestions reconciles every user with pending suggestions. A
+// failing account is logged and skipped.
+func (h *Handler) reconcileAllSuggestions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	defer cancel()
+
+	users, err := h.db.AISuggestions().Distinct(ctx, "userId", bson.M{"status": "pending"})
+	if err != nil {
+		log.Printf("suggestionsync: failed to list users: %v", err)
+		return
+	}
+	for _, u := range users {
+		email, ok := u.(string)
+		if !ok || email == "" {
+			continue
+		}
+		expired, obsolete, err := h.reconcileUserSuggestions(ctx, email)
+		if err != nil {
+			log.Printf("suggestionsync: %s: %v", email, err)
+		} else if expired+obsolete > 0 {
+			log.Printf("suggestionsync: %s — %d expired, %d obsolete", email, expired, obsolete)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIsSuggestionObsolete(t *testing.T) {
+	cases := []struct {
+		name   string
+		s      models.AISuggestion
+		labels []string
+		want   bool
+	}{
+		{"archive still in inbox", models.AISuggestion{Action: "archive"}, []string{"INBOX", "UNREAD"}, false},
+		{"archive already archived", models.AISuggestion{Action: "archive"}, []string{"UNREAD"}, true},
+		{"label already applied", models.AISuggestion{Action: "label", LabelID: "L1"}, []string{"INBOX", "L1"}, true},
+		{"label not yet applied", models.AISuggestion{Action: "label", LabelID: "L1"}, []string{"INBOX"}, false},
+		{"label without id", models.AISuggestion{Action: "label"}, []string{"INBOX"}, false},
+		{"delete already trashed", models.AISuggestion{Action: "delete"}, []string{"TRASH"}, true},
+		{"delete still in inbox", models.AISuggestion{Action: "delete"}, []string{"INBOX"}, false},
+		{"keep after user archived", models.AISuggestion{Action: "keep"}, nil, true},
+		{"spammed", models.AISuggestion{Action: "label", LabelID: "L1"}, []string{"SPAM"}, true},
+	}
+	for _, c := range cases {
+		if got := isSuggestionObsolete(c.s, c.labels); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDuplicateSuggestions(t *testing.T) {
+	newestFirst := []models.AISuggestion{
+		{ID: "3", EmailID: "m1"},
+		{ID: "2", EmailID: "m2"},
+		{ID: "1", EmailID: "m1"},
+	}
+	if got := duplicateSuggestions(newestFirst); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("duplicateSuggestions = %v, want [1]", got)
+	}
+}
+
+func TestParseSuggestionQuery(t *testing.T) {
+	q, err := parseSuggestionQuery(url.Values{})
+	if err != nil || q.Status != "pending" || q.Limit != 100 || q.Offset != 0 {
+		t.Fatalf("defaults = %+v, %v", q, err)
+	}
+
+	q, err = parseSuggestionQuery(url.Values{
+		"action": {"archive"}, "sort": {"confidence"}, "limit": {"500"}, "offset": {"40"},
+	})
+	if err != nil || q.Action != "archive" || q.Limit != 200 || q.Offset != 40 {
+		t.Fatalf("parsed = %+v, %v", q, err)
+	}
+	if q.Sort[0] != (bson.E{Key: "confidence", Value: -1}) {
+		t.Errorf("sort = %v", q.Sort)
+	}
+
+	if _, err := parseSuggestionQuery(url.Values{"sort": {"random"}}); err == nil {
+		t.Error("unknown sort accepted")
+	}
+	if _, err := parseSuggestionQuery(url.Values{"action": {"explode"}}); err == nil {
+		t.Error("unknown action accepted")
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/ai"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/snooze"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+// Summary scopes.
+const (
+	summaryScopeMessage = "message"
+	summaryScopeThread  = "thread"
+)
+
+// Summarize condenses a message, or the whole thread it belongs to, into a
+// short summary plus structured action items, deadlines and amounts. Results
+// are cached per message id (for a thread: its latest message), so re-opening
+// the same email is free. With snoozeUntilDeadline, the message is snoozed
+// until the morning before its earliest upcoming deadline.
+func (h *Handler) Summarize(w http.ResponseWriter, r *http.Request) {
+	if h.aiClient == nil {
+		writeError(w, http.StatusServiceUnavailable, "AI service not configured")
+		return
+	}
+
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	var req models.SummarizeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.MessageID == "" {
+		writeError(w, http.StatusBadRequest, "Message ID required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	gmailClient, err := h.gmailClientFor(ctx, userEmail)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to get user credentials")
+		return
+	}
+
+	scope := summaryScopeMessage
+	if req.Thread {
+		scope = summaryScopeThread
+	}
+	loc := h.userLocale(ctx, userEmail)
+	version := h.analysisVersion(loc)
+
+	messages, threadID, err := h.summarySource(gmailClient, req.MessageID, req.Thread)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Impossible de récupérer l'email : "+err.Error())
+		return
+	}
+	// A thread summary is keyed by the conversation's latest message, so a new
+	// reply invalidates it without any bookkeeping.
+	cacheID := req.MessageID
+	if req.Thread {
+		cacheID = messages[len(messages)-1].Id
+	}
+
+	var doc models.EmailSummary
+	cached := false
+	if !req.Refresh {
+		err := h.db.Summaries().FindOne(ctx, bson.M{
+			"userId": userEmail, "messageId": cacheID, "scope": scope, "version": version,
+		}).Decode(&doc)
+		cached = err == nil
+	}
+
+	if !cached {
+		if h.quotaExceeded(ctx, userEmail) {
+			writeError(w, http.StatusPaymentRequired, "Quota mensuel atteint. Passez à Pro pour continuer.")
+			return
+		}
+		if h.tokenBudgetExceeded(ctx) {
+			writeError(w, http.StatusServiceUnavailable, "Budget IA mensuel du serveur atteint — réessayez le mois prochain.")
+			return
+		}
+
+		input := make([]ai.SummaryMessage, 0, len(messages))
+		for _, m := range messages {
+			from, subject, _, date := gmail.ParseEmailHeaders(m)
+			body := gmail.MessageText(m)
+			if body == "" {
+				body = m.Snippet
+			}
+			input = append(input, ai.SummaryMessage{From: from, Date: date, Subject: subject, Body: body})
+		}
+
+		s, usage, err := h.aiClient.Summarize(input, loc, time.Now().UTC())
+		h.recordTokens(ctx, userEmail, usage)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Résumé impossible : "+err.Error())
+			return
+		}
+		h.incrUsage(ctx, userEmail, 1)
+
+		doc = models.EmailSummary{
+			UserID:      userEmail,
+			MessageID:   cacheID,
+			ThreadID:    threadID,
+			Scope:       scope,
+			Messages:    len(messages),
+			Version:     version,
+			Summary:     s.Summary,
+			ActionItems: s.ActionItems,
+			Deadlines:   s.Deadlines,
+			Amounts:     s.Amounts,
+			CreatedAt:   time.Now(),
+		}
+		h.db.Summaries().ReplaceOne(ctx,
+			bson.M{"userId": userEmail, "messageId": cacheID, "scope": scope},
+			doc,
+			options.Replace().SetUpsert(true),
+		)
+	}
+
+	out := map[string]interface{}{"summary": doc, "cached": cached}
+	if req.SnoozeUntilDeadline {
+		wakeAt, deadline, ok := deadlineWake(doc.Deadlines, time.Now(), h.userCalendar(ctx, userEmail).Location)
+		if !ok {
+			out["snooze"] = nil
+		} else if err := h.scheduleSnooze(ctx, gmailClient, userEmail, req.MessageID, wakeAt, snoozeOptions{}); err != nil {
+			out["snoozeError"] = err.Error()
+		} else {
+			out["snooze"] = map[string]interface{}{"wakeAt": wakeAt, "deadline": deadline}
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// summarySource fetches what is to be summarized: the message alone, or every
+// message of its thread (oldest first). It always returns at least one message.
+func (h *Handler) summarySource(gmailClient *gmailapi.Service, messageID string, thread bool) ([]*gmailapi.Message, string, error) {
+	msg, err := h.gmailService.GetMessage(gmailClient, messageID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !thread || msg.ThreadId == "" {
+		return []*gmailapi.Message{msg}, msg.ThreadId, nil
+	}
+	t, err := h.gmailService.GetThread(gmailClient, msg.ThreadId)
+	if err != nil || len(t.Messages) == 0 {
+		return []*gmailapi.Message{msg}, msg.ThreadId, nil
+	}
+	return t.Messages, msg.ThreadId, nil
+}
+
+// deadlineWake picks the earliest deadline whose day-before reminder is still
+// ahead of now, and returns that reminder time. Deadlines are calendar dates,
+// read in the user's timezone loc.
+func deadlineWake(deadlines []models.Deadline, now time.Time, loc *time.Location) (time.Time, models.Deadline, bool) {
+	var (
+		best   time.Time
+		bestDL models.Deadline
+		found  bool
+	)
+	for _, d := range deadlines {
+		due, err := time.ParseInLocation("2006-01-02", d.Date, loc)
+		if err != nil {
+			continue
+		}
+		wake, ok := snooze.DayBefore(due, now)
+		if !ok {
+			continue
+		}
+		if !found || wake.Before(best) {
+			best, bestDL, found = wake, d, true
+		}
+	}
+	return best, bestDL, found
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestDeadlineWakePicksEarliestUpcoming(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	deadlines := []models.Deadline{
+		{Description: "loyer", Date: "2026-11-05"},
+		{Description: "demain", Date: "2026-10-19"}, // reminder would be today 08:00 -> skipped
+		{Description: "facture", Date: "2026-10-25"},
+		{Description: "flou", Date: "bientôt"},
+	}
+	wake, d, ok := deadlineWake(deadlines, now, time.UTC)
+	if !ok || d.Description != "facture" || !wake.Equal(time.Date(2026, 10, 24, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("deadlineWake = %v, %+v, %v", wake, d, ok)
+	}
+	if _, _, ok := deadlineWake(nil, now, time.UTC); ok {
+		t.Fatal("no deadlines should yield no reminder")
+	}
+
+	// It is only 06:00 in Montréal, so tomorrow's deadline still gets its
+	// reminder at 08:00 local today.
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wake, d, ok = deadlineWake(deadlines, now, loc)
+	if !ok || d.Description != "demain" || !wake.Equal(time.Date(2026, 10, 18, 8, 0, 0, 0, loc)) {
+		t.Fatalf("deadlineWake in Montréal = %v, %+v, %v", wake, d, ok)
+	}
+}
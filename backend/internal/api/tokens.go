@@ -0,0 +1,330 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/auth"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxAPITokens caps the live (unrevoked, unexpired) tokens per user.
+	maxAPITokens = 20
+	// defaultTokenDays and maxTokenDays bound a token's lifetime.
+	defaultTokenDays = 90
+	maxTokenDays     = 365
+	// tokenTouchInterval throttles the last-used bookkeeping to one write per
+	// token per interval, instead of one per request.
+	tokenTouchInterval = time.Minute
+)
+
+// routeScopes is the scope a personal access token needs for each route,
+// keyed by method and route template. Every authenticated route is listed;
+// those mapped to "" are session-only: account settings and deletion, billing,
+// token, webhook and notification management, label and protection changes,
+// admin.
+var routeScopes = map[string]string{
+	"GET /api/emails":                  auth.ScopeEmailsRead,
+	"GET /api/stats":                   auth.ScopeEmailsRead,
+	"GET /api/stats/activity":          auth.ScopeEmailsRead,
+	"GET /api/stats/digest":            auth.ScopeEmailsRead,
+	"GET /api/activity/log":            auth.ScopeEmailsRead,
+	"GET /api/snoozes":                 auth.ScopeEmailsRead,
+	"GET /api/followups":               auth.ScopeEmailsRead,
+	"GET /api/subscriptions":           auth.ScopeEmailsRead,
+	"GET /api/unsubscribes/violations": auth.ScopeEmailsRead,
+	"GET /api/labels":                  auth.ScopeEmailsRead,
+	"GET /api/senders":                 auth.ScopeEmailsRead,
+	"GET /api/smart-labels":            auth.ScopeEmailsRead,
+	"GET /api/protected":               auth.ScopeEmailsRead,
+	"GET /api/usage":                   auth.ScopeEmailsRead,
+	"GET /api/events":                  auth.ScopeEmailsRead,
+
+	"POST /api/emails/sync":                auth.ScopeEmailsAct,
+	"POST /api/emails/action":              auth.ScopeEmailsAct,
+	"POST /api/emails/snooze":              auth.ScopeEmailsAct,
+	"POST /api/snoozes/{id}/wake":          auth.ScopeEmailsAct,
+	"POST /api/followups/{id}/dismiss":     auth.ScopeEmailsAct,
+	"POST /api/activity/undo":              auth.ScopeEmailsAct,
+	"POST /api/rules/apply":                auth.ScopeEmailsAct,
+	"POST /api/unsubscribe":                auth.ScopeEmailsAct,
+	"POST /api/unsubscribe/bulk":           auth.ScopeEmailsAct,
+	"GET /api/unsubscribe/bulk/{id}":       auth.ScopeEmailsAct,
+	"POST /api/ai/apply":                   auth.ScopeEmailsAct,
+	"POST /api/ai/apply-batch":             auth.ScopeEmailsAct,
+	"POST /api/ai/apply-bulk":              auth.ScopeEmailsAct,
+	"POST /api/ai/suggestions/{id}/reject": auth.ScopeEmailsAct,
+
+	"GET /api/rules":          auth.ScopeRulesRead,
+	"POST /api/rules/preview": auth.ScopeRulesRead,
+
+	"POST /api/rules":                   auth.ScopeRulesWrite,
+	"PUT /api/rules/{id}":               auth.ScopeRulesWrite,
+	"DELETE /api/rules/{id}":            auth.ScopeRulesWrite,
+	"POST /api/senders/rule":            auth.ScopeRulesWrite,
+	"PUT /api/senders/{id}/preferences": auth.ScopeRulesWrite,
+
+	"POST /api/ai/analyze":        auth.ScopeAIAnalyze,
+	"POST /api/ai/analyze-async":  auth.ScopeAIAnalyze,
+	"GET /api/ai/jobs/{id}":       auth.ScopeAIAnalyze,
+	"POST /api/ai/analyze-sender": auth.ScopeAIAnalyze,
+	"GET /api/ai/suggestions":     auth.ScopeAIAnalyze,
+	"POST /api/ai/summarize":      auth.ScopeAIAnalyze,
+	"POST /api/ai/draft-reply":    auth.ScopeAIAnalyze,
+	"POST /api/ai/clusters":       auth.ScopeAIAnalyze,
+	"GET /api/ai/label-proposals": auth.ScopeAIAnalyze,
+
+	"GET /api/account/export": auth.ScopeExport,
+
+	"GET /api/account/settings":     "",
+	"PUT /api/account/settings":     "",
+	"DELETE /api/account":           "",
+	"GET /api/stats/digest/preview": "",
+	"GET /api/followups/settings":   "",
+	"PUT /api/followups/settings":   "",
+	"POST /api/billing/checkout":    "",
+	"POST /api/billing/portal":      "",
+	"GET /api/tokens":               "",
+	"POST /api/tokens":              "",
+	"DELETE /api/tokens/{id}":       "",
+
+	"GET /api/notify/channels":                     "",
+	"PUT /api/notify/channels":                     "",
+	"POST /api/notify/test":                        "",
+	"GET /api/notify/deliveries":                   "",
+	"GET /api/webhooks":                            "",
+	"POST /api/webhooks":                           "",
+	"PUT /api/webhooks/{id}":                       "",
+	"DELETE /api/webhooks/{id}":                    "",
+	"GET /api/webhooks/{id}/deliveries":            "",
+	"POST /api/webhooks/{id}/ping":                 "",
+	"POST /api/webhooks/deliveries/{id}/redeliver": "",
+
+	"POST /api/protected":                      "",
+	"DELETE /api/protected/{id}":               "",
+	"POST /api/smart-labels":                   "",
+	"PUT /api/smart-labels/{id}":               "",
+	"DELETE /api/smart-labels/{id}":            "",
+	"POST /api/smart-labels/{id}/merge":        "",
+	"POST /api/smart-labels/sync":              "",
+	"DELETE /api/ai/cache":                     "",
+	"POST /api/ai/label-proposals/{id}/accept": "",
+	"DELETE /api/ai/label-proposals/{id}":      "",
+
+	"DELETE /api/admin/ai/cache":      "",
+	"GET /api/admin/usage":            "",
+	"POST /api/admin/senders/rebuild": "",
+}
+
+// routeScope returns the scope the matched route requires of a personal
+// access token, "" when tokens may not use it.
+func routeScope(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return routeScopes[r.Method+" "+tpl]
+}
+
+// tokenScopeError says why a personal access token granted scopes may not call
+// r's route, "" when it may.
+func tokenScopeError(r *http.Request, scopes []string) string {
+	scope := routeScope(r)
+	if scope == "" {
+		return "This endpoint is not available to API tokens"
+	}
+	if !hasScope(scopes, scope) {
+		return "API token lacks the " + scope + " scope"
+	}
+	return ""
+}
+
+// hasScope reports whether scopes grant scope.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errTokenUnknown = errors.New("unknown API token")
+	errTokenRevoked = errors.New("API token revoked")
+	errTokenExpired = errors.New("API token expired")
+)
+
+// verifyAPIToken looks a personal access token up by hash and returns its
+// owner and scopes, recording when and from where it was last used.
+func (h *Handler) verifyAPIToken(ctx context.Context, token, ip string) (string, []string, error) {
+	var t models.APIToken
+	if err := h.db.APITokens().FindOne(ctx, bson.M{"hash": h.auth.HashToken(token)}).Decode(&t); err != nil {
+		return "", nil, errTokenUnknown
+	}
+	now := time.Now()
+	switch {
+	case !t.RevokedAt.IsZero():
+		return "", nil, errTokenRevoked
+	case !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt):
+		return "", nil, errTokenExpired
+	}
+	if now.Sub(t.LastUsedAt) > tokenTouchInterval || t.LastUsedIP != ip {
+		oid, _ := primitive.ObjectIDFromHex(t.ID)
+		h.db.APITokens().UpdateOne(ctx, bson.M{"_id": oid},
+			bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip}})
+	}
+	return t.UserID, t.Scopes, nil
+}
+
+// GetAPITokens lists the caller's personal access tokens, revoked and expired
+// ones included, newest first, with the scopes a token can be granted.
+func (h *Handler) GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.APITokens().Find(ctx, bson.M{"userId": userEmail},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load tokens")
+		return
+	}
+	tokens := make([]models.APIToken, 0)
+	if err := cursor.All(ctx, &tokens); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load tokens")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens, "scopes": auth.Scopes})
+}
+
+// CreateAPIToken creates a personal access token. The token itself is in this
+// response only; the server keeps nothing it could be recovered from.
+func (h *Handler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	var in struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays *int     `json:"expiresInDays"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		writeError(w, http.StatusBadRequest, "Name required (100 characters max)")
+		return
+	}
+	if len(in.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "At least one scope required")
+		return
+	}
+	seen := map[string]bool{}
+	scopes := make([]string, 0, len(in.Scopes))
+	for _, s := range in.Scopes {
+		if !auth.ValidScope(s) {
+			writeError(w, http.StatusBadRequest, "Unknown scope: "+s)
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	days := defaultTokenDays
+	if in.ExpiresInDays != nil {
+		days = *in.ExpiresInDays
+	}
+	if days < 1 || days > maxTokenDays {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("expiresInDays must be between 1 and %d", maxTokenDays))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	live, err := h.db.APITokens().CountDocuments(ctx, bson.M{
+		"userId":    userEmail,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	if live >= maxAPITokens {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many active tokens (max %d)", maxAPITokens))
+		return
+	}
+
+	secret, prefix, err := auth.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	t := models.APIToken{
+		UserID: userEmail, Name: in.Name, Prefix: prefix, Hash: h.auth.HashToken(secret), Scopes: scopes,
+		ExpiresAt: now.AddDate(0, 0, days), CreatedAt: now,
+	}
+	res, err := h.db.APITokens().InsertOne(ctx, t)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	t.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"token": t, "secret": secret})
+}
+
+// RevokeAPIToken ends a personal access token at once. The record stays, so
+// the list still shows when it was last used.
+func (h *Handler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := h.db.APITokens().UpdateOne(ctx,
+		bson.M{"_id": oid, "userId": userEmail, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+	if res.MatchedCount == 0 {
+		writeError(w, http.StatusNotFound, "Token introuvable ou déjà révoqué")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
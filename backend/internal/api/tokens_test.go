@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/auth"
+)
+
+// TestRouteScopesMatchRoutes guards the scope table against drift: every
+// authenticated /api route needs an entry ("" for session-only), and every
+// entry must name a registered route and method, or tokens silently lose access.
+func TestRouteScopesMatchRoutes(t *testing.T) {
+	registered := map[string]bool{}
+	err := (&Handler{}).routes().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, "/api/") || isPublicPath(tpl) {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			key := m + " " + tpl
+			registered[key] = true
+			if _, ok := routeScopes[key]; !ok {
+				t.Errorf("%s: no routeScopes entry (use \"\" for session-only)", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, scope := range routeScopes {
+		if !registered[key] {
+			t.Errorf("%s: not a registered route", key)
+		}
+		if scope != "" && !auth.ValidScope(scope) {
+			t.Errorf("%s: unknown scope %q", key, scope)
+		}
+	}
+}
+
+// A token reaches a route with its scope only, and never a session-only one.
+func TestTokenScopeEnforced(t *testing.T) {
+	var granted []string
+	router := (&Handler{}).routes()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if msg := tokenScopeError(r, granted); msg != "" {
+				http.Error(w, msg, http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	status := func(key string, scopes []string) int {
+		granted = scopes
+		method, path, _ := strings.Cut(key, " ")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, strings.ReplaceAll(path, "{id}", "x"), nil))
+		return rec.Code
+	}
+
+	for key, scope := range routeScopes {
+		if scope == "" {
+			if got := status(key, auth.Scopes); got != http.StatusForbidden {
+				t.Errorf("%s: session-only route gave %d to a token with every scope", key, got)
+			}
+			continue
+		}
+		others := make([]string, 0, len(auth.Scopes))
+		for _, s := range auth.Scopes {
+			if s != scope {
+				others = append(others, s)
+			}
+		}
+		if got := status(key, others); got != http.StatusForbidden {
+			t.Errorf("%s: %d without %s, want 403", key, got, scope)
+		}
+		if got := status(key, []string{scope}); got != http.StatusNoContent {
+			t.Errorf("%s: %d with %s, want it let through", key, got, scope)
+		}
+	}
+}
+
+func TestSessionOnlyRoutesHaveNoScope(t *testing.T) {
+	for _, key := range []string{
+		"GET /api/tokens", "POST /api/tokens", "DELETE /api/tokens/{id}",
+		"POST /api/webhooks", "PUT /api/account/settings", "DELETE /api/account",
+		"POST /api/billing/checkout", "GET /api/admin/usage",
+	} {
+		if s := routeScopes[key]; s != "" {
+			t.Errorf("%s must stay session-only, mapped to %q", key, s)
+		}
+	}
+}
+
+func TestAPITokenRefusedInQueryString(t *testing.T) {
+	_, srv := newRoutedTestHandler(t)
+	token, _, err := auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/api/events?token=" + token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	scopes := []string{auth.ScopeRulesRead, auth.ScopeExport}
+	if !hasScope(scopes, auth.ScopeExport) {
+		t.Error("export should be granted")
+	}
+	if hasScope(scopes, auth.ScopeRulesWrite) {
+		t.Error("rules:read must not imply rules:write")
+	}
+}
@@ -3,12 +3,18 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"html"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	gmailapi "google.golang.org/api/gmail/v1"
@@ -16,9 +22,11 @@ import (
 
 // Unsubscribe performs a one-click (or assisted) unsubscribe for the sender of a
 // given message. When the sender advertises RFC 8058 one-click support the POST
-// is fired server-side and the user never leaves the app; otherwise the https
-// link or mailto: address is returned for the client to open. The action is
-// recorded idempotently per sender and can optionally archive the backlog.
+// is fired server-side, and a mailto:-only sender gets its unsubscribe request
+// sent from the user's Gmail account, so the user never leaves the app;
+// otherwise the https link (or, if sending failed, the mailto: address) is
+// returned for the client to open. The action is recorded idempotently per
+// sender and can optionally archive the backlog.
 func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -51,71 +59,175 @@ func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	httpURL, mailto, oneClick := gmail.ParseUnsubscribe(msg)
-	from, _, _, _ := gmail.ParseEmailHeaders(msg)
-	senderAddr := extractSenderAddress(from)
-	senderName := extractSenderName(from)
-
-	if httpURL == "" && mailto == "" {
+	out, err := h.unsubscribeFromMessage(ctx, gmailClient, userEmail, msg, false, func() bool {
+		return h.mailtoLimiter.allow(userEmail)
+	})
+	switch {
+	case errors.Is(err, errNoUnsubscribeLink):
 		http.Error(w, "Cet expéditeur ne propose pas de lien de désabonnement.", http.StatusUnprocessableEntity)
 		return
+	case errors.Is(err, errMailtoRateLimited):
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Trop de désabonnements envoyés, réessayez dans un instant.", http.StatusTooManyRequests)
+		return
 	}
 
-	method := "browser"
-	status := "opened"
-	done := false
+	archived := 0
+	if req.AlsoArchive {
+		archived = h.archiveBySender(ctx, gmailClient, userEmail, out.Sender)
+	}
 
-	switch {
-	case oneClick:
-		if err := h.gmailService.OneClickUnsubscribe(httpURL); err == nil {
-			method, status, done = "one-click", "done", true
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"done":     out.Done,
+		"method":   out.Method,
+		"url":      out.URL,
+		"mailto":   out.Mailto,
+		"archived": archived,
+		"sender":   out.Sender,
+	})
+}
+
+var (
+	errNoUnsubscribeLink = errors.New("sender exposes no unsubscribe link")
+	errMailtoRateLimited = errors.New("too many mailto: unsubscribes sent")
+)
+
+// unsubscribeOutcome is what unsubscribeFromMessage did for one sender.
+// Method is "one-click" or "mailto-sent" when Done, otherwise "browser" or
+// "mailto": the link the user has to open themselves.
+type unsubscribeOutcome struct {
+	Sender string
+	Method string
+	Done   bool
+	URL    string
+	Mailto string
+}
+
+// unsubscribeFromMessage unsubscribes from the sender of msg: a one-click POST
+// when advertised, else an unsubscribe mail sent through Gmail when the sender
+// only offers mailto: — or, with mailtoFallback, whenever one-click is missing
+// or failed. allowMailto gates each send (per-user rate limit); a refusal
+// returns errMailtoRateLimited. The outcome is recorded on the sender's
+// unsubscribe record and, when done, in the ledger.
+func (h *Handler) unsubscribeFromMessage(ctx context.Context, gmailClient *gmailapi.Service, userEmail string, msg *gmailapi.Message, mailtoFallback bool, allowMailto func() bool) (unsubscribeOutcome, error) {
+	httpURL, mailto, oneClick := gmail.ParseUnsubscribe(msg)
+	from, _, _, _ := gmail.ParseEmailHeaders(msg)
+	out := unsubscribeOutcome{Sender: senderAddress(from), Method: "browser", URL: httpURL, Mailto: mailto}
+	if httpURL == "" && mailto == "" {
+		return out, errNoUnsubscribeLink
+	}
+
+	var attempt *models.UnsubscribeAttempt
+	if oneClick {
+		res, err := h.gmailService.OneClickUnsubscribe(httpURL)
+		attempt = unsubscribeAttempt(httpURL, res, err)
+		if err == nil {
+			out.Method, out.Done = "one-click", true
+		}
+		// On failure the https link is handed to the client.
+	}
+	if !out.Done && mailto != "" && (httpURL == "" || mailtoFallback) {
+		out.Method = "mailto"
+		if !allowMailto() {
+			if attempt != nil {
+				h.recordUnsubscribe(ctx, userEmail, extractSenderName(from), out, attempt)
+			}
+			return out, errMailtoRateLimited
+		}
+		if err := h.sendUnsubscribeMail(gmailClient, userEmail, mailto); err == nil {
+			out.Method, out.Done = "mailto-sent", true
 		}
-		// On failure we fall through and hand the https link to the client.
-	case httpURL == "" && mailto != "":
-		method = "mailto"
+		// On failure the mailto: address is handed to the client.
+	}
+	if out.Done {
+		h.logAction(ctx, userEmail, msg.Id, "unsubscribe", SourceUnsubscribe)
+		h.emitWebhook(userEmail, notify.HookUnsubscribeDone, map[string]string{
+			"sender": out.Sender, "senderName": extractSenderName(from), "method": out.Method, "messageId": msg.Id,
+		})
 	}
+	h.recordUnsubscribe(ctx, userEmail, extractSenderName(from), out, attempt)
+	return out, nil
+}
 
-	h.db.Unsubscribes().UpdateOne(ctx,
-		bson.M{"userId": userEmail, "senderEmail": senderAddr},
-		bson.M{
-			"$set": bson.M{
-				"method":     method,
-				"status":     status,
-				"senderName": senderName,
-				"updatedAt":  time.Now(),
-			},
-			"$setOnInsert": bson.M{
-				"userId":      userEmail,
-				"senderEmail": senderAddr,
-				"createdAt":   time.Now(),
-			},
+// recordUnsubscribe upserts the sender's unsubscribe record with an outcome
+// and, when there was one, the one-click attempt's audit entry.
+func (h *Handler) recordUnsubscribe(ctx context.Context, userEmail, senderName string, out unsubscribeOutcome, attempt *models.UnsubscribeAttempt) {
+	status := "opened"
+	if out.Done {
+		status = "done"
+	}
+	set := bson.M{
+		"method":     out.Method,
+		"status":     status,
+		"senderName": senderName,
+		"updatedAt":  time.Now(),
+	}
+	if out.Done {
+		// Restarts the grace period the sender has to stop mailing.
+		set["doneAt"] = time.Now()
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"userId":      userEmail,
+			"senderEmail": out.Sender,
+			"createdAt":   time.Now(),
 		},
+	}
+	if attempt != nil {
+		update["$push"] = bson.M{"attempts": bson.M{"$each": []models.UnsubscribeAttempt{*attempt}, "$slice": -maxUnsubscribeAttempts}}
+	}
+	h.db.Unsubscribes().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "senderEmail": out.Sender},
+		update,
 		options.Update().SetUpsert(true),
 	)
+}
 
-	archived := 0
-	if req.AlsoArchive {
-		archived = h.archiveBySender(ctx, gmailClient, userEmail, senderAddr)
+// maxUnsubscribeAttempts bounds the audit trail kept per sender.
+const maxUnsubscribeAttempts = 10
+
+// unsubscribeAttempt turns the outcome of a one-click POST into its audit
+// entry.
+func unsubscribeAttempt(rawURL string, res gmail.UnsubscribeResult, err error) *models.UnsubscribeAttempt {
+	a := &models.UnsubscribeAttempt{
+		At:         time.Now(),
+		StatusCode: res.StatusCode,
+		LatencyMs:  res.Latency.Milliseconds(),
+	}
+	if u, perr := url.Parse(rawURL); perr == nil {
+		a.Host = u.Host
 	}
+	if err != nil {
+		// The client's *url.Error quotes the full URL, token included.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		a.Error = err.Error()
+	}
+	return a
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"done":     done,
-		"method":   method,
-		"url":      httpURL,
-		"mailto":   mailto,
-		"archived": archived,
-		"sender":   senderAddr,
-	})
+// sendUnsubscribeMail sends the unsubscribe request described by a
+// List-Unsubscribe mailto: URI from the user's own address.
+func (h *Handler) sendUnsubscribeMail(gmailClient *gmailapi.Service, userEmail, uri string) error {
+	m, err := mailer.ParseMailto(uri)
+	if err != nil {
+		return err
+	}
+	raw := mailer.BuildRaw(userEmail, strings.Join(m.To, ", "), m.Subject, m.Body, html.EscapeString(m.Body))
+	return h.gmailService.SendMessage(gmailClient, raw)
 }
 
-// archiveBySender removes INBOX from every stored email of a sender and returns
-// how many were archived. Best-effort: individual failures are skipped.
+// archiveBySender removes INBOX from every stored email of a sender with
+// batch modify calls and returns how many were archived.
 func (h *Handler) archiveBySender(ctx context.Context, gmailClient *gmailapi.Service, userEmail, senderAddr string) int {
 	cursor, err := h.db.Emails().Find(ctx, bson.M{
 		"userId": userEmail,
 		"from":   bson.M{"$regex": regexp.QuoteMeta(senderAddr), "$options": "i"},
-	})
+	}, options.Find().SetProjection(bson.M{"messageId": 1}))
 	if err != nil {
 		return 0
 	}
@@ -125,20 +237,27 @@ func (h *Handler) archiveBySender(ctx context.Context, gmailClient *gmailapi.Ser
 	if err := cursor.All(ctx, &emails); err != nil {
 		return 0
 	}
-
-	n := 0
+	ids := make([]string, 0, len(emails))
 	for _, e := range emails {
-		if err := h.gmailService.ModifyMessage(gmailClient, e.MessageID, nil, []string{"INBOX"}); err == nil {
-			n++
-			h.logAction(ctx, userEmail, e.MessageID, "archive", SourceUnsubscribe)
-		}
+		ids = append(ids, e.MessageID)
+	}
+	if len(ids) == 0 {
+		return 0
+	}
+	if err := h.gmailService.BatchModify(gmailClient, ids, nil, []string{"INBOX"}); err != nil {
+		return 0
+	}
+	for _, id := range ids {
+		h.logAction(ctx, userEmail, id, "archive", SourceUnsubscribe)
 	}
-	return n
+	return len(ids)
 }
 
-// GetSubscriptions aggregates the mailing-list senders in the user's stored
-// mailbox that advertise an unsubscribe link, ranked by volume, and flags those
-// already unsubscribed. Powers the in-app subscriptions cleanup view.
+// GetSubscriptions returns one page of the mailing-list senders in the
+// user's mailbox that advertise an unsubscribe link, ranked by volume by
+// default (same sort, search and cursor as GET /api/senders), and flags those
+// already unsubscribed and those still mailing after the unsubscribe's grace
+// period. Powers the in-app subscriptions cleanup view.
 func (h *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
 	userEmail := r.Header.Get("X-User-Email")
 	if userEmail == "" {
@@ -146,73 +265,56 @@ func (h *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	pipeline := []bson.M{
-		{"$match": bson.M{
-			"userId": userEmail,
-			"$or": []bson.M{
-				{"unsubUrl": bson.M{"$nin": []interface{}{nil, ""}}},
-				{"unsubMailto": bson.M{"$nin": []interface{}{nil, ""}}},
-			},
-		}},
-		{"$sort": bson.M{"receivedDate": -1}},
-		{"$group": bson.M{
-			"_id":          "$from",
-			"emailCount":   bson.M{"$sum": 1},
-			"lastReceived": bson.M{"$max": "$receivedDate"},
-			"sampleMsgId":  bson.M{"$first": "$messageId"},
-			"oneClick":     bson.M{"$max": "$unsubOneClick"},
-		}},
-		{"$sort": bson.M{"emailCount": -1}},
-		{"$limit": 100},
-	}
-
-	cursor, err := h.db.Emails().Aggregate(ctx, pipeline)
+	sq, err := parseSenderQuery(r.URL.Query(), 100)
 	if err != nil {
-		http.Error(w, "Failed to aggregate subscriptions", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var results []struct {
-		ID           string    `bson:"_id"`
-		EmailCount   int       `bson:"emailCount"`
-		LastReceived time.Time `bson:"lastReceived"`
-		SampleMsgID  string    `bson:"sampleMsgId"`
-		OneClick     bool      `bson:"oneClick"`
-	}
-	if err := cursor.All(ctx, &results); err != nil {
-		http.Error(w, "Failed to decode subscriptions", http.StatusInternalServerError)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	senders, next, err := h.listSenders(ctx, userEmail, sq, bson.M{"canUnsubscribe": true})
+	if err != nil {
+		http.Error(w, "Failed to list subscriptions", http.StatusInternalServerError)
 		return
 	}
 
-	// Build the set of already-unsubscribed senders for this user.
-	done := map[string]bool{}
-	if uc, err := h.db.Unsubscribes().Find(ctx, bson.M{"userId": userEmail}); err == nil {
+	// Which of this page's senders are already unsubscribed.
+	addrs := make([]string, 0, len(senders))
+	for _, s := range senders {
+		addrs = append(addrs, s.SenderEmail)
+	}
+	done := map[string]models.Unsubscribe{}
+	if uc, err := h.db.Unsubscribes().Find(ctx, bson.M{"userId": userEmail, "senderEmail": bson.M{"$in": addrs}}); err == nil {
 		var records []models.Unsubscribe
 		if uc.All(ctx, &records) == nil {
 			for _, rec := range records {
-				done[rec.SenderEmail] = true
+				done[strings.ToLower(rec.SenderEmail)] = rec
 			}
 		}
 	}
 
-	subscriptions := make([]models.Subscription, 0, len(results))
-	for _, res := range results {
-		addr := extractSenderAddress(res.ID)
+	subscriptions := make([]models.Subscription, 0, len(senders))
+	for _, s := range senders {
+		rec, unsubscribed := done[s.SenderEmail]
 		subscriptions = append(subscriptions, models.Subscription{
-			SenderEmail:     addr,
-			SenderName:      extractSenderName(res.ID),
-			EmailCount:      res.EmailCount,
-			LastReceived:    res.LastReceived,
-			SampleMessageID: res.SampleMsgID,
-			OneClick:        res.OneClick,
-			Unsubscribed:    done[addr],
+			SenderEmail:     s.SenderEmail,
+			SenderName:      s.SenderName,
+			EmailCount:      s.EmailCount,
+			LastReceived:    s.LastSeen,
+			SampleMessageID: s.SampleMessageID,
+			OneClick:        s.OneClick,
+			Unsubscribed:    unsubscribed,
+			// Only a completed unsubscribe can be ignored.
+			IgnoringUnsubscribe: rec.Status == "done" && rec.IgnoredCount > 0,
+			IgnoredCount:        rec.IgnoredCount,
 		})
 	}
 
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(subscriptions)
 }
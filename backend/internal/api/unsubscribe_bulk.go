@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobKindUnsubscribe marks an AnalysisJob that unsubscribes from a list of
+// senders.
+const jobKindUnsubscribe = "unsubscribe"
+
+// unsubscribeJobCap bounds how many senders one bulk unsubscribe handles.
+const unsubscribeJobCap = 100
+
+// unsubscribeJobTimeout leaves room for the mailto: rate limit, which the job
+// waits out rather than failing senders.
+const unsubscribeJobTimeout = time.Hour
+
+// unsubscribeSampleScan is how many of a sender's newest unsubscribable emails
+// are considered when picking the one to unsubscribe from.
+const unsubscribeSampleScan = 20
+
+// bulkSenders normalizes, dedupes and caps the requested sender addresses,
+// dropping anything that is not an address.
+func bulkSenders(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		addr := senderAddress(s)
+		if !strings.Contains(addr, "@") || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		out = append(out, addr)
+		if len(out) == unsubscribeJobCap {
+			break
+		}
+	}
+	return out
+}
+
+// bestUnsubscribeSample picks the email to unsubscribe from among a sender's
+// emails: one advertising one-click first, then a mailto: address (which the
+// server can send), then a plain link; newest first within each. ok is false
+// when none advertises anything.
+func bestUnsubscribeSample(emails []models.Email) (best models.Email, ok bool) {
+	rank := func(e models.Email) int {
+		switch {
+		case e.UnsubOneClick && e.UnsubURL != "":
+			return 3
+		case e.UnsubMailto != "":
+			return 2
+		case e.UnsubURL != "":
+			return 1
+		}
+		return 0
+	}
+	for _, e := range emails {
+		r := rank(e)
+		if r == 0 {
+			continue
+		}
+		if !ok || r > rank(best) || (r == rank(best) && e.ReceivedDate.After(best.ReceivedDate)) {
+			best, ok = e, true
+		}
+	}
+	return best, ok
+}
+
+// unsubscribeSample loads the best stored email to unsubscribe from a sender.
+func (h *Handler) unsubscribeSample(ctx context.Context, userEmail, senderAddr string) (models.Email, bool) {
+	cursor, err := h.db.Emails().Find(ctx, bson.M{
+		"userId": userEmail,
+		"from":   bson.M{"$regex": regexp.QuoteMeta(senderAddr), "$options": "i"},
+		"$or":    bson.A{bson.M{"unsubUrl": bson.M{"$gt": ""}}, bson.M{"unsubMailto": bson.M{"$gt": ""}}},
+	}, options.Find().
+		SetSort(bson.D{{Key: "receivedDate", Value: -1}}).
+		SetLimit(unsubscribeSampleScan).
+		SetProjection(bson.M{"messageId": 1, "receivedDate": 1, "unsubUrl": 1, "unsubMailto": 1, "unsubOneClick": 1}))
+	if err != nil {
+		return models.Email{}, false
+	}
+	var emails []models.Email
+	if err := cursor.All(ctx, &emails); err != nil {
+		return models.Email{}, false
+	}
+	return bestUnsubscribeSample(emails)
+}
+
+// startUnsubscribeWorkers launches the pool that runs bulk unsubscribe jobs.
+// It is kept apart from the analysis pool: these jobs sleep out the mailto:
+// rate limit, for up to unsubscribeJobTimeout, and must not hold up AI work.
+func (h *Handler) startUnsubscribeWorkers(n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for jobID := range h.unsubQueue {
+				h.processAnalysisJob(jobID)
+			}
+		}()
+	}
+}
+
+// waitMailtoSlot blocks until the user may send another mailto: unsubscribe,
+// or ctx ends.
+func (h *Handler) waitMailtoSlot(ctx context.Context, userEmail string) bool {
+	for !h.mailtoLimiter.allow(userEmail) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return true
+}
+
+// EnqueueBulkUnsubscribe creates an async job unsubscribing from a list of
+// senders (up to unsubscribeJobCap) and returns its id immediately; progress
+// and per-sender results are polled from GET /api/unsubscribe/bulk/{id}.
+func (h *Handler) EnqueueBulkUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	var req models.BulkUnsubscribeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	senders := bulkSenders(req.Senders)
+	if len(senders) == 0 {
+		writeError(w, http.StatusBadRequest, "No sender provided")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job := models.AnalysisJob{
+		UserID:      userEmail,
+		Kind:        jobKindUnsubscribe,
+		Status:      "queued",
+		Total:       len(senders),
+		Senders:     senders,
+		AlsoArchive: req.AlsoArchive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	res, err := h.db.AnalysisJobs().InsertOne(ctx, job)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create job")
+		return
+	}
+	jobID := res.InsertedID.(primitive.ObjectID).Hex()
+
+	select {
+	case h.unsubQueue <- jobID:
+	default:
+		go h.processAnalysisJob(jobID)
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"jobId": jobID, "status": "queued", "total": len(senders)})
+}
+
+// processUnsubscribeJob unsubscribes from each sender of a bulk job in turn,
+// appending its result to the job as it goes.
+func (h *Handler) processUnsubscribeJob(id primitive.ObjectID, job models.AnalysisJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeJobTimeout)
+	defer cancel()
+
+	gmailClient, err := h.gmailClientFor(ctx, job.UserID)
+	if err != nil {
+		h.updateJob(ctx, id, bson.M{"status": "error", "error": err.Error(), "updatedAt": time.Now()})
+		return
+	}
+
+	for i, sender := range job.Senders {
+		result := models.UnsubscribeJobResult{Sender: sender}
+		if sample, ok := h.unsubscribeSample(ctx, job.UserID, sender); !ok {
+			result.Error = errNoUnsubscribeLink.Error()
+		} else if msg, err := h.gmailService.GetMessage(gmailClient, sample.MessageID); err != nil {
+			result.MessageID, result.Error = sample.MessageID, "message not found"
+		} else {
+			result.MessageID = sample.MessageID
+			out, err := h.unsubscribeFromMessage(ctx, gmailClient, job.UserID, msg, true, func() bool {
+				return h.waitMailtoSlot(ctx, job.UserID)
+			})
+			result.Method, result.Done, result.URL, result.Mailto = out.Method, out.Done, out.URL, out.Mailto
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if job.AlsoArchive && !errors.Is(err, errNoUnsubscribeLink) {
+				result.Archived = h.archiveBySender(ctx, gmailClient, job.UserID, sender)
+			}
+		}
+
+		h.changeJob(ctx, id, bson.M{
+			"$push": bson.M{"results": result},
+			"$set":  bson.M{"processed": i + 1, "updatedAt": time.Now()},
+		})
+		if ctx.Err() != nil {
+			h.updateJob(context.Background(), id, bson.M{"status": "error", "error": "timed out", "updatedAt": time.Now()})
+			log.Printf("unsubscribe job %s timed out after %d senders", id.Hex(), i+1)
+			return
+		}
+	}
+	h.updateJob(ctx, id, bson.M{"status": "done", "updatedAt": time.Now()})
+}
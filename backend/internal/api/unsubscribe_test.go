@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestUnsubscribeAttemptKeepsTokenOut(t *testing.T) {
+	raw := "https://list.example.com/unsub?token=s3cret"
+	err := &url.Error{Op: "Post", URL: raw, Err: errors.New("connection refused")}
+
+	a := unsubscribeAttempt(raw, gmail.UnsubscribeResult{Latency: 1500 * time.Millisecond}, err)
+	if a.Host != "list.example.com" || a.LatencyMs != 1500 || a.StatusCode != 0 {
+		t.Errorf("attempt = %+v", a)
+	}
+	if a.Error != "connection refused" || strings.Contains(a.Error, "s3cret") {
+		t.Errorf("error = %q, must not quote the URL", a.Error)
+	}
+
+	ok := unsubscribeAttempt(raw, gmail.UnsubscribeResult{StatusCode: 200}, nil)
+	if ok.StatusCode != 200 || ok.Error != "" {
+		t.Errorf("attempt = %+v", ok)
+	}
+}
+
+func TestBulkSenders(t *testing.T) {
+	got := bulkSenders([]string{"News <News@Shop.example>", "news@shop.example", "", "not-an-address", "deals@x.example"})
+	want := []string{"news@shop.example", "deals@x.example"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("bulkSenders = %v, want %v", got, want)
+	}
+
+	many := make([]string, 0, unsubscribeJobCap+10)
+	for i := 0; i < unsubscribeJobCap+10; i++ {
+		many = append(many, fmt.Sprintf("s%d@x.example", i))
+	}
+	if n := len(bulkSenders(many)); n != unsubscribeJobCap {
+		t.Errorf("bulkSenders kept %d senders, want the cap %d", n, unsubscribeJobCap)
+	}
+}
+
+func TestBestUnsubscribeSample(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 0, 0, 0, 0, time.UTC) }
+	link := models.Email{MessageID: "link", ReceivedDate: day(9), UnsubURL: "https://x.example/u"}
+	mailto := models.Email{MessageID: "mailto", ReceivedDate: day(2), UnsubMailto: "mailto:u@x.example"}
+	oneClickOld := models.Email{MessageID: "oc-old", ReceivedDate: day(1), UnsubURL: "https://x.example/u", UnsubOneClick: true}
+	oneClickNew := models.Email{MessageID: "oc-new", ReceivedDate: day(5), UnsubURL: "https://x.example/u", UnsubOneClick: true}
+	none := models.Email{MessageID: "none", ReceivedDate: day(10)}
+
+	cases := []struct {
+		name   string
+		emails []models.Email
+		want   string
+	}{
+		{"newest one-click wins", []models.Email{link, oneClickOld, mailto, oneClickNew}, "oc-new"},
+		{"mailto beats a plain link", []models.Email{link, mailto}, "mailto"},
+		{"plain link as a last resort", []models.Email{none, link}, "link"},
+		{"nothing to unsubscribe from", []models.Email{none}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := bestUnsubscribeSample(tc.emails)
+			if ok != (tc.want != "") || got.MessageID != tc.want {
+				t.Errorf("bestUnsubscribeSample = %q (ok=%v), want %q", got.MessageID, ok, tc.want)
+			}
+		})
+	}
+}
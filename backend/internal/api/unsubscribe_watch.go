@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UnsubscribeGrace is how long a sender has to honour a completed unsubscribe
+// before new mail from it counts as ignoring it. Overridden from config at
+// startup.
+var UnsubscribeGrace = 10 * 24 * time.Hour
+
+// ignoresUnsubscribe reports whether an email received at received shows the
+// sender ignoring the unsubscribe u: it must be done, and the email must
+// arrive after the grace period. Records that predate DoneAt fall back to
+// their last update.
+func ignoresUnsubscribe(u models.Unsubscribe, received time.Time) bool {
+	if u.Status != "done" || received.IsZero() {
+		return false
+	}
+	at := u.DoneAt
+	if at.IsZero() {
+		at = u.UpdatedAt
+	}
+	return received.After(at.Add(UnsubscribeGrace))
+}
+
+// ruleForIgnoredUnsubscribe builds the rule that enforces an unsubscribe the
+// sender ignores, with action "archive" or "trash".
+func ruleForIgnoredUnsubscribe(userEmail, senderAddr, action string) models.SortingRule {
+	now := time.Now()
+	return models.SortingRule{
+		UserID:   userEmail,
+		Name:     "Désabonnement ignoré : " + senderAddr,
+		Enabled:  true,
+		MatchAll: true,
+		Conditions: []models.RuleCondition{
+			{Field: rules.FieldFrom, Operator: rules.OpContains, Value: senderAddr},
+		},
+		Action:    action,
+		Priority:  0,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// doneUnsubscribes returns the user's completed unsubscribes keyed by sender
+// address, for sync to check new mail against.
+func (h *Handler) doneUnsubscribes(ctx context.Context, userEmail string) map[string]models.Unsubscribe {
+	out := map[string]models.Unsubscribe{}
+	cursor, err := h.db.Unsubscribes().Find(ctx, bson.M{"userId": userEmail, "status": "done"})
+	if err != nil {
+		return out
+	}
+	var records []models.Unsubscribe
+	if err := cursor.All(ctx, &records); err != nil {
+		return out
+	}
+	for _, u := range records {
+		out[u.SenderEmail] = u
+	}
+	return out
+}
+
+// noteIgnoredUnsubscribe records email as evidence that its sender ignores
+// the unsubscribe u and bumps the unsubscribe's ignored count. When the user
+// chose an enforcement action and the sender has no enforcement rule yet, the
+// rule is created and returned so the caller can run it straight away; nil
+// otherwise. An email already recorded is a no-op.
+func (h *Handler) noteIgnoredUnsubscribe(ctx context.Context, userEmail, enforcement string, u models.Unsubscribe, email models.Email) *models.SortingRule {
+	unsubscribedAt := u.DoneAt
+	if unsubscribedAt.IsZero() {
+		unsubscribedAt = u.UpdatedAt
+	}
+	if _, err := h.db.UnsubscribeViolations().InsertOne(ctx, models.UnsubscribeViolation{
+		UserID:         userEmail,
+		SenderEmail:    u.SenderEmail,
+		SenderName:     u.SenderName,
+		MessageID:      email.MessageID,
+		Subject:        email.Subject,
+		ReceivedAt:     email.ReceivedDate,
+		UnsubscribedAt: unsubscribedAt,
+		Method:         u.Method,
+		CreatedAt:      time.Now(),
+	}); err != nil {
+		return nil // duplicate: this email was already recorded
+	}
+	h.db.Unsubscribes().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "senderEmail": u.SenderEmail},
+		bson.M{"$inc": bson.M{"ignoredCount": 1}, "$set": bson.M{"lastIgnoredAt": email.ReceivedDate}})
+	log.Printf("unsubscribe: %s still mails %s %s after unsubscribing", u.SenderEmail, userEmail,
+		email.ReceivedDate.Sub(unsubscribedAt).Round(time.Hour))
+
+	if enforcement == "" {
+		return nil
+	}
+	rule := ruleForIgnoredUnsubscribe(userEmail, u.SenderEmail, enforcement)
+	res, err := h.db.SortingRules().UpdateOne(ctx,
+		bson.M{"userId": userEmail, "name": rule.Name},
+		bson.M{"$setOnInsert": rule},
+		options.Update().SetUpsert(true))
+	if err != nil || res.UpsertedCount == 0 {
+		return nil // failed, or the user already has (or edited) this rule
+	}
+	if oid, ok := res.UpsertedID.(primitive.ObjectID); ok {
+		rule.ID = oid.Hex()
+	}
+	return &rule
+}
+
+// csvCell defuses a sender-controlled value for a spreadsheet: a cell starting
+// with = + - @, a tab or a carriage return would be read as a formula
+// (=HYPERLINK(…)), so it is prefixed with a quote and shown as text.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeViolationsCSV writes violations as a CSV report, one row per email.
+// The report is meant to be opened in a spreadsheet, so the text fields are
+// passed through csvCell.
+func writeViolationsCSV(w io.Writer, violations []models.UnsubscribeViolation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"sender_email", "sender_name", "unsubscribed_at", "unsubscribe_method", "received_at", "days_after_unsubscribe", "subject", "message_id"})
+	for _, v := range violations {
+		cw.Write([]string{
+			csvCell(v.SenderEmail),
+			csvCell(v.SenderName),
+			v.UnsubscribedAt.UTC().Format(time.RFC3339),
+			v.Method,
+			v.ReceivedAt.UTC().Format(time.RFC3339),
+			fmt.Sprintf("%d", int(v.ReceivedAt.Sub(v.UnsubscribedAt).Hours()/24)),
+			csvCell(v.Subject),
+			csvCell(v.MessageID),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GetUnsubscribeViolations returns every email received from a sender after
+// the grace period that followed the user's unsubscribe, newest first. With
+// ?format=csv it is served as a downloadable report to attach to a complaint.
+func (h *Handler) GetUnsubscribeViolations(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "Invalid format (json, csv)")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"userId": userEmail}
+	if sender := r.URL.Query().Get("sender"); sender != "" {
+		filter["senderEmail"] = senderAddress(sender)
+	}
+	cursor, err := h.db.UnsubscribeViolations().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "receivedAt", Value: -1}}))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load unsubscribe violations")
+		return
+	}
+	violations := []models.UnsubscribeViolation{}
+	if err := cursor.All(ctx, &violations); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load unsubscribe violations")
+		return
+	}
+
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, violations)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"mailsorter-unsubscribe-report-%s.csv\"", time.Now().UTC().Format("2006-01-02")))
+	writeViolationsCSV(w, violations)
+}
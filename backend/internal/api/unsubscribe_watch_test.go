@@ -0,0 +1,102 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/rules"
+)
+
+func TestIgnoresUnsubscribe(t *testing.T) {
+	doneAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	done := models.Unsubscribe{Status: "done", DoneAt: doneAt}
+	day := 24 * time.Hour
+
+	cases := []struct {
+		name     string
+		u        models.Unsubscribe
+		received time.Time
+		want     bool
+	}{
+		{"within grace", done, doneAt.Add(3 * day), false},
+		{"right at the end of grace", done, doneAt.Add(UnsubscribeGrace), false},
+		{"after grace", done, doneAt.Add(UnsubscribeGrace + time.Hour), true},
+		{"before unsubscribing", done, doneAt.Add(-day), false},
+		{"only opened", models.Unsubscribe{Status: "opened", DoneAt: doneAt}, doneAt.Add(30 * day), false},
+		{"legacy record falls back to updatedAt", models.Unsubscribe{Status: "done", UpdatedAt: doneAt}, doneAt.Add(30 * day), true},
+		{"unknown received date", done, time.Time{}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ignoresUnsubscribe(tc.u, tc.received); got != tc.want {
+				t.Errorf("ignoresUnsubscribe = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleForIgnoredUnsubscribeIsValid(t *testing.T) {
+	for _, action := range []string{"archive", "trash"} {
+		rule := ruleForIgnoredUnsubscribe("me@example.com", "news@shop.example", action)
+		if err := rules.Validate(rule); err != nil {
+			t.Fatalf("%s rule should validate: %v", action, err)
+		}
+		if !rules.Matches(models.Email{From: "Shop <news@shop.example>"}, rule) {
+			t.Errorf("%s rule should match the sender", action)
+		}
+	}
+}
+
+func TestWriteViolationsCSV(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	err := writeViolationsCSV(&b, []models.UnsubscribeViolation{{
+		SenderEmail:    "news@shop.example",
+		SenderName:     "Shop, Inc",
+		MessageID:      "m1",
+		Subject:        "Encore une promo",
+		UnsubscribedAt: at,
+		ReceivedAt:     at.Add(12 * 24 * time.Hour),
+		Method:         "one-click",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want header + 1 row, got %d lines:\n%s", len(lines), b.String())
+	}
+	want := `news@shop.example,"Shop, Inc",2026-03-01T09:00:00Z,one-click,2026-03-13T09:00:00Z,12,Encore une promo,m1`
+	if lines[1] != want {
+		t.Errorf("row = %q, want %q", lines[1], want)
+	}
+}
+
+func TestWriteViolationsCSVDefusesFormulas(t *testing.T) {
+	var b strings.Builder
+	err := writeViolationsCSV(&b, []models.UnsubscribeViolation{{
+		SenderEmail: "news@shop.example",
+		SenderName:  "@SUM(A1)",
+		Subject:     `=HYPERLINK("https://evil.example","Cliquez")`,
+		MessageID:   "-m1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := strings.Split(strings.TrimSpace(b.String()), "\n")[1]
+	for _, want := range []string{`'@SUM(A1)`, `"'=HYPERLINK(""https://evil.example"",""Cliquez"")"`, `'-m1`} {
+		if !strings.Contains(row, want) {
+			t.Errorf("row %q lacks %q", row, want)
+		}
+	}
+	for _, in := range []string{"+1", "\tx", "\rx"} {
+		if got := csvCell(in); got != "'"+in {
+			t.Errorf("csvCell(%q) = %q", in, got)
+		}
+	}
+	if got := csvCell("Encore une promo"); got != "Encore une promo" {
+		t.Errorf("plain text changed: %q", got)
+	}
+}
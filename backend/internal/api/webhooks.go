@@ -0,0 +1,659 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/gmail"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxWebhooks caps how many webhooks a user can register.
+	maxWebhooks = 10
+	// webhookDisableAfter is how many deliveries in a row may fail (out of
+	// retries, or refused outright) before the webhook is disabled.
+	webhookDisableAfter = 10
+	// webhookQueueSize bounds the events waiting to be dispatched, and
+	// webhookUserBacklog one user's share of them; past either, events are
+	// dropped rather than slowing down the actions behind them.
+	webhookQueueSize   = 4096
+	webhookUserBacklog = 256
+	// webhookWorkers is how many deliveries run at once, each for a different
+	// user (see hookQueue).
+	webhookWorkers = 8
+	// webhookSubsTTL bounds how long a user's cached subscriptions are trusted.
+	webhookSubsTTL = time.Minute
+	// webhookRetryInterval is how often due webhook retries are resent.
+	webhookRetryInterval = time.Minute
+)
+
+// hookEvent is one event waiting to be dispatched to a user's webhooks.
+type hookEvent struct {
+	user    string
+	event   string
+	id      string
+	payload []byte
+}
+
+// hookPayload is the JSON body of a webhook delivery.
+type hookPayload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// newHookEvent renders an event's payload. The id is shared by every delivery
+// of the event, redeliveries included, so receivers can deduplicate.
+func newHookEvent(userEmail, event string, data interface{}) (hookEvent, error) {
+	id := "evt_" + primitive.NewObjectID().Hex()
+	body, err := json.Marshal(hookPayload{ID: id, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return hookEvent{}, err
+	}
+	return hookEvent{user: userEmail, event: event, id: id, payload: body}, nil
+}
+
+// startWebhookLoop launches the workers that deliver queued events to the
+// users' webhooks, and the sweeper that resends due retries.
+func (h *Handler) startWebhookLoop(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				ev, ok := h.hooks.next()
+				if !ok {
+					return
+				}
+				h.dispatchHook(ev)
+				h.hooks.done(ev.user)
+			}
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(webhookRetryInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.hookSubs.sweep(now)
+			h.retryWebhookDeliveries()
+		}
+	}()
+}
+
+// emitWebhook queues an event for the user's webhooks subscribed to it; it is
+// not even encoded when none is. Best-effort and non-blocking, like the
+// ledger: callers never check it.
+func (h *Handler) emitWebhook(userEmail, event string, data interface{}) {
+	if h.hooks == nil || userEmail == "" || !h.wantsHook(userEmail, event) {
+		return
+	}
+	ev, err := newHookEvent(userEmail, event, data)
+	if err != nil {
+		log.Printf("webhooks: cannot encode %s for %s: %v", event, userEmail, err)
+		return
+	}
+	if !h.hooks.push(ev) {
+		log.Printf("webhooks: queue full, dropping %s for %s", event, userEmail)
+	}
+}
+
+// wantsHook reports whether one of the user's enabled webhooks subscribes to
+// event, from the cache when it is fresh.
+func (h *Handler) wantsHook(userEmail, event string) bool {
+	now := time.Now()
+	events, ok := h.hookSubs.get(userEmail, now)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		events = h.hookSubs.set(userEmail, h.userWebhooks(ctx, userEmail), now)
+		cancel()
+	}
+	return events[event]
+}
+
+// userWebhooks loads the user's registered webhooks.
+func (h *Handler) userWebhooks(ctx context.Context, userEmail string) []models.Webhook {
+	var doc struct {
+		Webhooks []models.Webhook `bson:"webhooks"`
+	}
+	h.db.Users().FindOne(ctx, bson.M{"email": userEmail},
+		options.FindOne().SetProjection(bson.M{"webhooks": 1})).Decode(&doc)
+	return doc.Webhooks
+}
+
+// subscribed reports whether the webhook wants event.
+func subscribed(w models.Webhook, event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchHook delivers an event to each enabled webhook of the user that
+// subscribes to it.
+func (h *Handler) dispatchHook(ev hookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	for _, w := range h.userWebhooks(ctx, ev.user) {
+		if w.Enabled && subscribed(w, ev.event) {
+			h.deliverHook(ctx, w, ev, "")
+		}
+	}
+}
+
+// deliverHook records a delivery of ev to w and makes its first attempt.
+func (h *Handler) deliverHook(ctx context.Context, w models.Webhook, ev hookEvent, redeliveryOf string) models.WebhookDelivery {
+	now := time.Now()
+	d := models.WebhookDelivery{
+		UserID: ev.user, WebhookID: w.ID, EventID: ev.id, Event: ev.event, Payload: string(ev.payload),
+		Status: deliveryPending, RedeliveryOf: redeliveryOf, CreatedAt: now, UpdatedAt: now,
+	}
+	if res, err := h.db.WebhookDeliveries().InsertOne(ctx, d); err != nil {
+		log.Printf("webhooks: failed to record delivery for %s: %v", ev.user, err)
+	} else if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		d.ID = oid.Hex()
+	}
+	h.attemptHook(ctx, &d, w)
+	return d
+}
+
+// attemptHook makes one attempt at d and records the outcome: sent,
+// retrying at notify.HookRetryAt, or failed. A final outcome also updates the
+// webhook's run of failures, which disables it at webhookDisableAfter.
+func (h *Handler) attemptHook(ctx context.Context, d *models.WebhookDelivery, w models.Webhook) {
+	var err error
+	code := 0
+	target, uerr := h.encryptor.Decrypt(w.URL)
+	secret, serr := h.encryptor.Decrypt(w.Secret)
+	if uerr != nil || serr != nil {
+		err = notify.Permanent(errors.New("cannot decrypt the webhook credentials"))
+	} else {
+		hook := &notify.Hook{URL: target, Secret: secret, Client: h.notifyClient}
+		code, err = hook.Send(ctx, d.Event, d.ID, []byte(d.Payload), time.Now())
+	}
+	if err != nil && ctx.Err() != nil {
+		return // the caller ran out of time: not an attempt, it stays due
+	}
+
+	now := time.Now()
+	d.Attempts++
+	d.UpdatedAt = now
+	d.ResponseStatus = code
+	d.NextAttemptAt = time.Time{}
+	if err == nil {
+		d.Status, d.DeliveredAt, d.LastError = deliverySent, now, ""
+	} else {
+		d.LastError = err.Error()
+		d.Status = deliveryFailed
+		if at, ok := notify.HookRetryAt(d.Attempts, err, now); ok {
+			d.Status, d.NextAttemptAt = deliveryRetrying, at
+		}
+	}
+
+	if d.ID != "" {
+		oid, _ := primitive.ObjectIDFromHex(d.ID)
+		h.db.WebhookDeliveries().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+			"status":         d.Status,
+			"attempts":       d.Attempts,
+			"responseStatus": d.ResponseStatus,
+			"lastError":      d.LastError,
+			"nextAttemptAt":  d.NextAttemptAt,
+			"deliveredAt":    d.DeliveredAt,
+			"updatedAt":      d.UpdatedAt,
+		}})
+	}
+	if d.Status != deliveryRetrying {
+		h.noteWebhookOutcome(ctx, d.UserID, w.ID, d.Status == deliverySent)
+	}
+}
+
+// noteWebhookOutcome resets the webhook's run of failures on a success, and
+// extends it on a failure, disabling the webhook once the run reaches
+// webhookDisableAfter.
+func (h *Handler) noteWebhookOutcome(ctx context.Context, userEmail, webhookID string, ok bool) {
+	filter := bson.M{"email": userEmail, "webhooks.id": webhookID}
+	if ok {
+		h.db.Users().UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+			"webhooks.$.failures": 0, "webhooks.$.lastDeliveryAt": time.Now(),
+		}})
+		return
+	}
+	h.db.Users().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"webhooks.$.failures": 1}})
+	res, err := h.db.Users().UpdateOne(ctx, bson.M{
+		"email": userEmail,
+		"webhooks": bson.M{"$elemMatch": bson.M{
+			"id": webhookID, "enabled": true, "failures": bson.M{"$gte": webhookDisableAfter},
+		}},
+	}, bson.M{"$set": bson.M{
+		"webhooks.$.enabled":        false,
+		"webhooks.$.disabledReason": fmt.Sprintf("%d livraisons échouées d'affilée", webhookDisableAfter),
+		"webhooks.$.updatedAt":      time.Now(),
+	}})
+	if err == nil && res.ModifiedCount > 0 {
+		h.hookSubs.forget(userEmail)
+		log.Printf("webhooks: disabled %s for %s after %d failed deliveries", webhookID, userEmail, webhookDisableAfter)
+	}
+}
+
+// retryWebhookDeliveries resends the deliveries whose retry is due. Those of
+// a webhook deleted or disabled since are given up.
+func (h *Handler) retryWebhookDeliveries() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	cursor, err := h.db.WebhookDeliveries().Find(ctx,
+		bson.M{"status": deliveryRetrying, "nextAttemptAt": bson.M{"$lte": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).SetLimit(deliveryRetryBatch))
+	if err != nil {
+		log.Printf("webhooks: failed to list due retries: %v", err)
+		return
+	}
+	var due []models.WebhookDelivery
+	if err := cursor.All(ctx, &due); err != nil {
+		log.Printf("webhooks: failed to decode due retries: %v", err)
+		return
+	}
+
+	hooks := map[string][]models.Webhook{}
+	for i := range due {
+		if ctx.Err() != nil {
+			return // the rest wait for the next sweep
+		}
+		d := &due[i]
+		if _, ok := hooks[d.UserID]; !ok {
+			hooks[d.UserID] = h.userWebhooks(ctx, d.UserID)
+		}
+		w, found := findWebhook(hooks[d.UserID], d.WebhookID)
+		reason := ""
+		switch {
+		case !found:
+			reason = "webhook deleted"
+		case !w.Enabled:
+			reason = "webhook disabled"
+		}
+		if reason != "" {
+			oid, _ := primitive.ObjectIDFromHex(d.ID)
+			h.db.WebhookDeliveries().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+				"status": deliveryFailed, "lastError": reason, "nextAttemptAt": time.Time{}, "updatedAt": time.Now(),
+			}})
+			continue
+		}
+		h.attemptHook(ctx, d, w)
+	}
+}
+
+// findWebhook picks the webhook with the given id.
+func findWebhook(hooks []models.Webhook, id string) (models.Webhook, bool) {
+	for _, w := range hooks {
+		if w.ID == id {
+			return w, true
+		}
+	}
+	return models.Webhook{}, false
+}
+
+// webhookInput is the body of POST /api/webhooks and PUT /api/webhooks/{id}.
+// On update, absent fields are left unchanged.
+type webhookInput struct {
+	URL          *string   `json:"url"`
+	Events       *[]string `json:"events"`
+	Description  *string   `json:"description"`
+	Enabled      *bool     `json:"enabled"`
+	Secret       string    `json:"secret"`
+	RotateSecret bool      `json:"rotateSecret"`
+}
+
+// webhookView is a webhook as the API shows it: the URL is a credential, so
+// only its host is echoed back. Secret is only set in the response that
+// generated it.
+type webhookView struct {
+	models.Webhook
+	Target string `json:"target"`
+	Secret string `json:"secret,omitempty"`
+}
+
+func (h *Handler) webhookView(w models.Webhook) webhookView {
+	v := webhookView{Webhook: w}
+	if v.Events == nil {
+		v.Events = []string{}
+	}
+	if raw, err := h.encryptor.Decrypt(w.URL); err == nil {
+		if u, err := url.Parse(raw); err == nil {
+			v.Target = u.Host
+		}
+	}
+	return v
+}
+
+// checkHookEvents validates a webhook's event filter.
+func checkHookEvents(events []string) error {
+	if len(events) == 0 {
+		return errors.New("At least one event required")
+	}
+	for _, e := range events {
+		if !notify.ValidHookEvent(e) {
+			return fmt.Errorf("Unknown event: %s", e)
+		}
+	}
+	return nil
+}
+
+// webhookSecret picks the signing secret for a webhook: the one supplied, or
+// a generated one, which is then also returned to be shown once.
+func webhookSecret(supplied string) (secret, generated string, err error) {
+	if supplied != "" {
+		if len(supplied) < minWebhookSecretLen {
+			return "", "", fmt.Errorf("Webhook secret too short (min %d characters)", minWebhookSecretLen)
+		}
+		return supplied, "", nil
+	}
+	s := newWebhookSecret()
+	return s, s, nil
+}
+
+// GetWebhooks lists the caller's webhooks and the events they can listen to.
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	views := []webhookView{}
+	for _, hook := range h.userWebhooks(ctx, userEmail) {
+		views = append(views, h.webhookView(hook))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": views, "events": notify.HookEvents})
+}
+
+// CreateWebhook registers a webhook. Without a secret in the request one is
+// generated and returned once in this response.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	var in webhookInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if in.URL == nil {
+		writeError(w, http.StatusBadRequest, "URL required")
+		return
+	}
+	if err := notify.CheckURL(*in.URL, gmail.PublicIP); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid webhook URL: "+err.Error())
+		return
+	}
+	var events []string
+	if in.Events != nil {
+		events = *in.Events
+	}
+	if err := checkHookEvents(events); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	secret, generated, err := webhookSecret(in.Secret)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	encURL, uerr := h.encryptor.Encrypt(*in.URL)
+	encSecret, serr := h.encryptor.Encrypt(secret)
+	if uerr != nil || serr != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to store webhook")
+		return
+	}
+
+	now := time.Now()
+	hook := models.Webhook{
+		ID: primitive.NewObjectID().Hex(), URL: encURL, Secret: encSecret, Events: events,
+		Enabled: true, CreatedAt: now, UpdatedAt: now,
+	}
+	if in.Description != nil {
+		hook.Description = *in.Description
+	}
+	if in.Enabled != nil {
+		hook.Enabled = *in.Enabled
+	}
+	// The cap is checked in the filter itself, so concurrent creates cannot
+	// go past it.
+	res, err := h.db.Users().UpdateOne(ctx,
+		bson.M{"email": userEmail, fmt.Sprintf("webhooks.%d", maxWebhooks-1): bson.M{"$exists": false}},
+		bson.M{"$push": bson.M{"webhooks": hook}})
+	if err != nil || res.MatchedCount == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many webhooks (max %d)", maxWebhooks))
+		return
+	}
+	h.hookSubs.forget(userEmail)
+
+	v := h.webhookView(hook)
+	v.Secret = generated
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// UpdateWebhook changes a webhook's URL, events, description or state, or
+// rotates its secret (the new one is returned once). Re-enabling a webhook
+// clears its run of failures.
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	var in webhookInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+
+	set := bson.M{"webhooks.$.updatedAt": time.Now()}
+	if in.URL != nil {
+		if err := notify.CheckURL(*in.URL, gmail.PublicIP); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid webhook URL: "+err.Error())
+			return
+		}
+		enc, err := h.encryptor.Encrypt(*in.URL)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to store webhook")
+			return
+		}
+		set["webhooks.$.url"] = enc
+	}
+	if in.Events != nil {
+		if err := checkHookEvents(*in.Events); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		set["webhooks.$.events"] = *in.Events
+	}
+	if in.Description != nil {
+		set["webhooks.$.description"] = *in.Description
+	}
+	if in.Enabled != nil {
+		set["webhooks.$.enabled"] = *in.Enabled
+		if *in.Enabled {
+			set["webhooks.$.failures"] = 0
+			set["webhooks.$.disabledReason"] = ""
+		}
+	}
+	generated := ""
+	if in.Secret != "" || in.RotateSecret {
+		secret, gen, err := webhookSecret(in.Secret)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		enc, err := h.encryptor.Encrypt(secret)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to store webhook")
+			return
+		}
+		set["webhooks.$.secret"], generated = enc, gen
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := h.db.Users().UpdateOne(ctx, bson.M{"email": userEmail, "webhooks.id": id}, bson.M{"$set": set})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+	if res.MatchedCount == 0 {
+		writeError(w, http.StatusNotFound, "Webhook introuvable")
+		return
+	}
+	h.hookSubs.forget(userEmail)
+	hook, _ := findWebhook(h.userWebhooks(ctx, userEmail), id)
+	v := h.webhookView(hook)
+	v.Secret = generated
+	writeJSON(w, http.StatusOK, v)
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := h.db.Users().UpdateOne(ctx, bson.M{"email": userEmail, "webhooks.id": id},
+		bson.M{"$pull": bson.M{"webhooks": bson.M{"id": id}}})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	if res.MatchedCount == 0 {
+		writeError(w, http.StatusNotFound, "Webhook introuvable")
+		return
+	}
+	h.hookSubs.forget(userEmail)
+	h.db.WebhookDeliveries().DeleteMany(ctx, bson.M{"userId": userEmail, "webhookId": id})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// GetWebhookDeliveries lists a webhook's recent deliveries, newest first,
+// optionally only those with ?status=.
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := findWebhook(h.userWebhooks(ctx, userEmail), id); !ok {
+		writeError(w, http.StatusNotFound, "Webhook introuvable")
+		return
+	}
+	filter := bson.M{"userId": userEmail, "webhookId": id}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter["status"] = status
+	}
+	cursor, err := h.db.WebhookDeliveries().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load deliveries")
+		return
+	}
+	rows := make([]models.WebhookDelivery, 0)
+	if err := cursor.All(ctx, &rows); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": rows})
+}
+
+// RedeliverWebhook sends a past delivery's event again, right away, as a new
+// delivery of the same event id — also to a disabled webhook, so a fixed
+// endpoint can be checked before re-enabling it.
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var orig models.WebhookDelivery
+	if err := h.db.WebhookDeliveries().FindOne(ctx, bson.M{"_id": oid, "userId": userEmail}).Decode(&orig); err != nil {
+		writeError(w, http.StatusNotFound, "Livraison introuvable")
+		return
+	}
+	hook, ok := findWebhook(h.userWebhooks(ctx, userEmail), orig.WebhookID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Webhook introuvable")
+		return
+	}
+	ev := hookEvent{user: userEmail, event: orig.Event, id: orig.EventID, payload: []byte(orig.Payload)}
+	writeJSON(w, http.StatusOK, h.deliverHook(ctx, hook, ev, orig.ID))
+}
+
+// PingWebhook sends a "ping" event to a webhook right away, whatever its
+// events, and returns the delivery.
+func (h *Handler) PingWebhook(w http.ResponseWriter, r *http.Request) {
+	userEmail := r.Header.Get("X-User-Email")
+	if userEmail == "" {
+		writeError(w, http.StatusUnauthorized, "User email required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	hook, ok := findWebhook(h.userWebhooks(ctx, userEmail), mux.Vars(r)["id"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Webhook introuvable")
+		return
+	}
+	ev, err := newHookEvent(userEmail, notify.HookPing, map[string]string{"webhookId": hook.ID})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build ping")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.deliverHook(ctx, hook, ev, ""))
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/crypto"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/notify"
+)
+
+func TestCheckHookEvents(t *testing.T) {
+	if err := checkHookEvents([]string{notify.HookRuleFired, notify.HookDigestSent}); err != nil {
+		t.Errorf("valid events rejected: %v", err)
+	}
+	for _, events := range [][]string{nil, {"rule.fired", "mail.read"}, {notify.HookPing}} {
+		if checkHookEvents(events) == nil {
+			t.Errorf("%v accepted", events)
+		}
+	}
+}
+
+func TestWebhookSecret(t *testing.T) {
+	if _, _, err := webhookSecret("short"); err == nil {
+		t.Error("short secret accepted")
+	}
+	s, gen, err := webhookSecret("0123456789abcdef")
+	if err != nil || s != "0123456789abcdef" || gen != "" {
+		t.Errorf("supplied secret = %q %q %v; it must be kept and not echoed", s, gen, err)
+	}
+	s, gen, err = webhookSecret("")
+	if err != nil || len(s) != 64 || gen != s {
+		t.Errorf("generated secret = %q %q %v", s, gen, err)
+	}
+}
+
+func TestHookEventPayload(t *testing.T) {
+	ev, err := newHookEvent("me@example.com", notify.HookActionLogged, map[string]string{"action": "archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p struct {
+		ID   string            `json:"id"`
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(ev.payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != ev.id || p.Type != notify.HookActionLogged || p.Data["action"] != "archive" {
+		t.Errorf("payload = %s", ev.payload)
+	}
+}
+
+func TestSubscribedAndFindWebhook(t *testing.T) {
+	hooks := []models.Webhook{
+		{ID: "a", Events: []string{notify.HookJobFinished}},
+		{ID: "b", Events: []string{notify.HookRuleFired, notify.HookActionLogged}},
+	}
+	w, ok := findWebhook(hooks, "b")
+	if !ok || !subscribed(w, notify.HookActionLogged) || subscribed(w, notify.HookJobFinished) {
+		t.Errorf("findWebhook/subscribed wrong for %+v", w)
+	}
+	if _, ok := findWebhook(hooks, "c"); ok {
+		t.Error("found a missing webhook")
+	}
+}
+
+// A full backlog drops the event instead of blocking the action behind it, and
+// events no webhook listens to are never queued.
+func TestEmitWebhookNeverBlocks(t *testing.T) {
+	h := &Handler{hooks: newHookQueue(1, 10), hookSubs: newHookSubscriptions(time.Minute)}
+	h.hookSubs.set("me@example.com", []models.Webhook{
+		{Enabled: true, Events: []string{notify.HookActionLogged}},
+		{Enabled: false, Events: []string{notify.HookRuleFired}},
+	}, time.Now())
+	h.emitWebhook("me@example.com", notify.HookRuleFired, nil)
+	h.emitWebhook("me@example.com", notify.HookActionLogged, nil)
+	h.emitWebhook("me@example.com", notify.HookActionLogged, nil)
+	if h.hooks.queued != 1 {
+		t.Errorf("queue holds %d events, want 1", h.hooks.queued)
+	}
+}
+
+// A retry sweep that ran out of time neither sends nor counts an attempt.
+func TestAttemptHookAfterDeadline(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+
+	enc := crypto.NewEncryptor("test-key")
+	url, _ := enc.Encrypt(srv.URL)
+	secret, _ := enc.Encrypt("whsec_test")
+	h := &Handler{encryptor: enc, notifyClient: srv.Client()}
+	w := models.Webhook{ID: "w1", URL: url, Secret: secret, Enabled: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := models.WebhookDelivery{Status: deliveryRetrying, Attempts: 2, Payload: "{}"}
+	h.attemptHook(ctx, &d, w)
+	if hits != 0 || d.Attempts != 2 || d.Status != deliveryRetrying {
+		t.Errorf("expired sweep: %d hits, attempts %d, status %s", hits, d.Attempts, d.Status)
+	}
+}
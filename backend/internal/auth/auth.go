@@ -5,6 +5,9 @@
 // Everything is self-contained (no external dependency, no server-side session
 // store): a token carries its own payload and a signature derived from the
 // server's secret, so any tampering or expiry is detected on verification.
+//
+// Personal access tokens are the exception: scoped and revocable, they are
+// stored server-side by hash (see tokens.go).
 package auth
 
 import (
@@ -36,6 +39,7 @@ var (
 type Manager struct {
 	sessionKey []byte
 	stateKey   []byte
+	tokenKey   []byte
 	sessionTTL time.Duration
 	stateTTL   time.Duration
 }
@@ -46,6 +50,7 @@ func NewManager(masterSecret string) *Manager {
 	return &Manager{
 		sessionKey: deriveKey(masterSecret, "mailsorter-session-v1"),
 		stateKey:   deriveKey(masterSecret, "mailsorter-oauth-state-v1"),
+		tokenKey:   deriveKey(masterSecret, "mailsorter-personal-token-v1"),
 		sessionTTL: DefaultSessionTTL,
 		stateTTL:   DefaultStateTTL,
 	}
@@ -100,3 +100,39 @@ func mutate(s string) string {
 	}
 	return string(c) + s[1:]
 }
+
+func TestPersonalTokens(t *testing.T) {
+	tok, display, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPersonalToken(tok) || !strings.HasPrefix(tok, display) || len(tok) != len(TokenPrefix)+64 {
+		t.Fatalf("token %q / display %q malformed", tok, display)
+	}
+	other, _, _ := NewToken()
+	if other == tok {
+		t.Fatal("two tokens are equal")
+	}
+
+	m := NewManager("test-secret")
+	if IsPersonalToken(m.IssueSession("alice@example.com")) {
+		t.Fatal("a session taken for a personal token")
+	}
+	if m.HashToken(tok) != m.HashToken(tok) || m.HashToken(tok) == m.HashToken(other) {
+		t.Fatal("hash not deterministic per token")
+	}
+	if NewManager("other-secret").HashToken(tok) == m.HashToken(tok) {
+		t.Fatal("hash not keyed with the server secret")
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, s := range Scopes {
+		if !ValidScope(s) {
+			t.Errorf("%s rejected", s)
+		}
+	}
+	if ValidScope("admin") || ValidScope("") {
+		t.Error("unknown scope accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Personal access tokens are long random secrets a user creates for scripts
+// and the CLI. Unlike sessions they are not self-contained: the server stores
+// their hash (see HashToken) with the token's scopes, expiry and revocation,
+// and looks them up on every request.
+const (
+	// TokenPrefix starts every personal access token, so they are told apart
+	// from session tokens (a base64 email never encodes to it) and are easy
+	// to spot by secret scanners.
+	TokenPrefix = "ms_pat_"
+	// tokenBytes is the entropy of a personal access token.
+	tokenBytes = 32
+	// displayLen is how many characters of a token are kept in clear to
+	// recognize it in a list.
+	displayLen = len(TokenPrefix) + 8
+)
+
+// Scopes a personal access token can be granted. A session carries them all.
+const (
+	ScopeEmailsRead = "emails:read" // list emails, stats, history, senders, labels, live events
+	ScopeEmailsAct  = "emails:act"  // act on emails: sync, actions, snoozes, unsubscribes, applying rules and suggestions
+	ScopeRulesRead  = "rules:read"  // list and preview sorting rules
+	ScopeRulesWrite = "rules:write" // create, change and delete sorting rules
+	ScopeAIAnalyze  = "ai:analyze"  // run AI analysis, summaries, drafts and clustering
+	ScopeExport     = "export"      // download the account export
+)
+
+// Scopes lists every scope, in documentation order.
+var Scopes = []string{ScopeEmailsRead, ScopeEmailsAct, ScopeRulesRead, ScopeRulesWrite, ScopeAIAnalyze, ScopeExport}
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s string) bool {
+	for _, v := range Scopes {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPersonalToken reports whether a bearer token is a personal access token
+// rather than a session.
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+// NewToken returns a fresh personal access token and the short clear prefix
+// shown to identify it. The token itself is only ever shown once.
+func NewToken() (token, display string, err error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = TokenPrefix + hex.EncodeToString(b)
+	return token, token[:displayLen], nil
+}
+
+// HashToken is what the server stores and looks a personal access token up
+// by. It is keyed with the server secret, so a database dump alone does not
+// even allow checking guesses.
+func (m *Manager) HashToken(token string) string {
+	mac := hmac.New(sha256.New, m.tokenKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
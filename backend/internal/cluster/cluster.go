@@ -0,0 +1,381 @@
+// Package cluster groups email embeddings into coherent topics and describes
+// each group well enough to propose it as a new label.
+//
+// KMeans partitions unit vectors by cosine similarity (k-means++ seeding, a
+// fixed seed so the same inbox always yields the same proposals). Groups then
+// keeps only the clusters that are both large and tight, and characterizes
+// each one with its most distinctive subject terms (TF-IDF against the whole
+// inbox) and, when one dominates, its sender domain — the two signals a
+// deterministic rule can match on. Everything here is pure: no I/O, no clock.
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Item is one email to cluster.
+type Item struct {
+	Vector  []float32
+	From    string
+	Subject string
+}
+
+// Options bound what counts as a proposal-worthy cluster.
+type Options struct {
+	K           int     // number of clusters; 0 picks one from the inbox size
+	MinSize     int     // smallest cluster worth a label
+	MinCohesion float64 // minimum mean cosine similarity to the centroid
+	Seed        int64
+}
+
+// DefaultOptions are tuned for sender + subject + snippet embeddings, where
+// unrelated emails still score around 0.6 against each other.
+var DefaultOptions = Options{MinSize: 5, MinCohesion: 0.8, Seed: 1}
+
+// Group is a cluster that passed the size and cohesion filters.
+type Group struct {
+	Members     []int    // indexes into the input items, closest to the centroid first
+	Cohesion    float64  // mean cosine similarity to the centroid
+	Terms       []string // most distinctive subject terms, best first
+	Domain      string   // dominant sender domain, "" when none
+	DomainShare float64  // fraction of members sent from Domain
+	TermShare   float64  // fraction of members whose subject contains Terms[0]
+}
+
+// ChooseK picks a cluster count for n items: roughly sqrt(n/2), clamped so a
+// small inbox still splits and a large one does not shatter into noise.
+func ChooseK(n int) int {
+	k := int(math.Round(math.Sqrt(float64(n) / 2)))
+	if k < 2 {
+		k = 2
+	}
+	if k > 20 {
+		k = 20
+	}
+	if k > n {
+		k = n
+	}
+	return k
+}
+
+// KMeans assigns each vector to one of k clusters under cosine similarity and
+// returns the assignment plus the (unit) centroids. Vectors are not modified.
+func KMeans(vectors [][]float32, k, maxIter int, seed int64) ([]int, [][]float32) {
+	n := len(vectors)
+	if n == 0 || k <= 0 {
+		return nil, nil
+	}
+	if k > n {
+		k = n
+	}
+	points := make([][]float32, n)
+	for i, v := range vectors {
+		points[i] = unit(v)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	centroids := seedPlusPlus(points, k, rng)
+	assign := make([]int, n)
+	for i := range assign {
+		assign[i] = -1
+	}
+
+	for iter := 0; iter < maxIter; iter++ {
+		changed := false
+		for i, p := range points {
+			best, bestSim := 0, math.Inf(-1)
+			for c, ctr := range centroids {
+				if s := dot(p, ctr); s > bestSim {
+					best, bestSim = c, s
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		centroids = recenter(points, assign, centroids)
+	}
+	return assign, centroids
+}
+
+// seedPlusPlus picks k initial centroids: the first at random, each next one
+// with probability proportional to its distance from the closest pick.
+func seedPlusPlus(points [][]float32, k int, rng *rand.Rand) [][]float32 {
+	centroids := [][]float32{clone(points[rng.Intn(len(points))])}
+	dist := make([]float64, len(points))
+	for len(centroids) < k {
+		total := 0.0
+		for i, p := range points {
+			d := math.Inf(1)
+			for _, c := range centroids {
+				if x := 1 - dot(p, c); x < d {
+					d = x
+				}
+			}
+			if d < 0 {
+				d = 0
+			}
+			dist[i] = d * d
+			total += dist[i]
+		}
+		if total == 0 {
+			break // every remaining point duplicates a centroid
+		}
+		r := rng.Float64() * total
+		pick := len(points) - 1
+		for i, d := range dist {
+			if r -= d; r <= 0 {
+				pick = i
+				break
+			}
+		}
+		centroids = append(centroids, clone(points[pick]))
+	}
+	return centroids
+}
+
+// recenter averages each cluster's members; an emptied cluster keeps its
+// previous centroid.
+func recenter(points [][]float32, assign []int, prev [][]float32) [][]float32 {
+	dim := len(points[0])
+	sums := make([][]float32, len(prev))
+	counts := make([]int, len(prev))
+	for i := range sums {
+		sums[i] = make([]float32, dim)
+	}
+	for i, p := range points {
+		c := assign[i]
+		counts[c]++
+		for j, x := range p {
+			sums[c][j] += x
+		}
+	}
+	for c := range sums {
+		if counts[c] == 0 {
+			sums[c] = prev[c]
+			continue
+		}
+		sums[c] = unit(sums[c])
+	}
+	return sums
+}
+
+// Groups clusters items and returns the clusters worth proposing, largest
+// first.
+func Groups(items []Item, opts Options) []Group {
+	if len(items) < opts.MinSize || len(items) < 2 {
+		return nil
+	}
+	k := opts.K
+	if k <= 0 {
+		k = ChooseK(len(items))
+	}
+	vectors := make([][]float32, len(items))
+	for i, it := range items {
+		vectors[i] = it.Vector
+	}
+	assign, centroids := KMeans(vectors, k, 50, opts.Seed)
+
+	members := make([][]int, len(centroids))
+	for i, c := range assign {
+		members[c] = append(members[c], i)
+	}
+
+	subjects := make([]string, len(items))
+	for i, it := range items {
+		subjects[i] = it.Subject
+	}
+	df := docFreq(subjects)
+
+	var out []Group
+	for c, idx := range members {
+		if len(idx) < opts.MinSize {
+			continue
+		}
+		sims := make(map[int]float64, len(idx))
+		total := 0.0
+		for _, i := range idx {
+			sims[i] = dot(unit(vectors[i]), centroids[c])
+			total += sims[i]
+		}
+		cohesion := total / float64(len(idx))
+		if cohesion < opts.MinCohesion {
+			continue
+		}
+		sort.SliceStable(idx, func(a, b int) bool { return sims[idx[a]] > sims[idx[b]] })
+
+		g := Group{Members: idx, Cohesion: cohesion}
+		var clusterSubjects, froms []string
+		for _, i := range idx {
+			clusterSubjects = append(clusterSubjects, items[i].Subject)
+			froms = append(froms, items[i].From)
+		}
+		g.Terms = TopTerms(clusterSubjects, df, len(items), 5)
+		g.Domain, g.DomainShare = DominantDomain(froms)
+		if len(g.Terms) > 0 {
+			g.TermShare = share(clusterSubjects, g.Terms[0])
+		}
+		out = append(out, g)
+	}
+	sort.SliceStable(out, func(a, b int) bool { return len(out[a].Members) > len(out[b].Members) })
+	return out
+}
+
+// stopwords are words too common in subjects to name anything, across the
+// supported locales.
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "you": true, "your": true, "with": true, "from": true, "our": true, "new": true, "now": true, "are": true, "this": true, "has": true, "have": true, "fwd": true, "fw": true,
+	"les": true, "des": true, "pour": true, "vous": true, "votre": true, "vos": true, "une": true, "dans": true, "avec": true, "sur": true, "est": true, "nos": true, "notre": true, "aux": true, "par": true, "qui": true, "que": true, "tr": true,
+	"der": true, "die": true, "das": true, "und": true, "für": true, "mit": true, "ihre": true, "ihr": true, "von": true, "ein": true, "eine": true, "aw": true, "wg": true,
+}
+
+// terms splits a subject into lowercase content words: letters only, three
+// runes or more, no stopwords, each counted once.
+func terms(s string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if len([]rune(w)) < 3 || stopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	return out
+}
+
+func docFreq(docs []string) map[string]int {
+	df := map[string]int{}
+	for _, d := range docs {
+		for _, t := range terms(d) {
+			df[t]++
+		}
+	}
+	return df
+}
+
+// TopTerms ranks the terms of docs by TF-IDF against a corpus of corpusSize
+// documents with document frequencies df, and returns the n best. A term must
+// appear in at least two docs to count as a theme.
+func TopTerms(docs []string, df map[string]int, corpusSize, n int) []string {
+	tf := docFreq(docs)
+	type scored struct {
+		term  string
+		score float64
+	}
+	var ranked []scored
+	for t, f := range tf {
+		if f < 2 {
+			continue
+		}
+		idf := math.Log(float64(corpusSize+1) / float64(df[t]+1))
+		ranked = append(ranked, scored{t, float64(f) * (idf + 1)})
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if ranked[a].score != ranked[b].score {
+			return ranked[a].score > ranked[b].score
+		}
+		return ranked[a].term < ranked[b].term
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	out := make([]string, len(ranked))
+	for i, s := range ranked {
+		out[i] = s.term
+	}
+	return out
+}
+
+// DominantDomain returns the most frequent sender domain among froms ("Name
+// <a@b.c>" or bare addresses) and the share of senders it accounts for.
+func DominantDomain(froms []string) (string, float64) {
+	counts := map[string]int{}
+	for _, f := range froms {
+		if d := domainOf(f); d != "" {
+			counts[d]++
+		}
+	}
+	best, bestN := "", 0
+	for d, n := range counts {
+		if n > bestN || (n == bestN && d < best) {
+			best, bestN = d, n
+		}
+	}
+	if len(froms) == 0 {
+		return "", 0
+	}
+	return best, float64(bestN) / float64(len(froms))
+}
+
+func domainOf(from string) string {
+	if i := strings.Index(from, "<"); i >= 0 {
+		from = from[i+1:]
+		if j := strings.Index(from, ">"); j >= 0 {
+			from = from[:j]
+		}
+	}
+	at := strings.LastIndex(from, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(from[at+1:]))
+}
+
+// share is the fraction of docs containing term.
+func share(docs []string, term string) float64 {
+	if len(docs) == 0 {
+		return 0
+	}
+	n := 0
+	for _, d := range docs {
+		for _, t := range terms(d) {
+			if t == term {
+				n++
+				break
+			}
+		}
+	}
+	return float64(n) / float64(len(docs))
+}
+
+func unit(v []float32) []float32 {
+	out := clone(v)
+	var sum float64
+	for _, x := range out {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return out
+	}
+	n := float32(math.Sqrt(sum))
+	for i := range out {
+		out[i] /= n
+	}
+	return out
+}
+
+func clone(v []float32) []float32 {
+	return append([]float32(nil), v...)
+}
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
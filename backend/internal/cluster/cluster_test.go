@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// blob returns n noisy vectors around center.
+func blob(rng *rand.Rand, center []float32, n int) [][]float32 {
+	out := make([][]float32, n)
+	for i := range out {
+		v := make([]float32, len(center))
+		for j, x := range center {
+			v[j] = x + float32(rng.NormFloat64()*0.05)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func TestKMeansSeparatesBlobs(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	a := blob(rng, []float32{1, 0, 0}, 10)
+	b := blob(rng, []float32{0, 1, 0}, 10)
+	assign, centroids := KMeans(append(a, b...), 2, 50, 1)
+	if len(centroids) != 2 {
+		t.Fatalf("centroids = %d, want 2", len(centroids))
+	}
+	for i := 1; i < 10; i++ {
+		if assign[i] != assign[0] || assign[10+i] != assign[10] {
+			t.Fatalf("blobs split across clusters: %v", assign)
+		}
+	}
+	if assign[0] == assign[10] {
+		t.Fatalf("blobs merged: %v", assign)
+	}
+
+	again, _ := KMeans(append(a, b...), 2, 50, 1)
+	if !reflect.DeepEqual(assign, again) {
+		t.Error("KMeans is not deterministic for a fixed seed")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	var items []Item
+	for i, v := range blob(rng, []float32{1, 0, 0, 0}, 8) {
+		items = append(items, Item{Vector: v, From: "Shop <news@shop.example>", Subject: fmt.Sprintf("Votre facture n°%d est disponible", i)})
+	}
+	for i, v := range blob(rng, []float32{0, 0, 1, 0}, 8) {
+		items = append(items, Item{Vector: v, From: fmt.Sprintf("friend%d@mail.example", i%3), Subject: "Photos du week-end randonnée"})
+	}
+	// Scattered singletons must not form a group.
+	items = append(items,
+		Item{Vector: []float32{0, 1, 0, 0}, Subject: "Alerte"},
+		Item{Vector: []float32{0, 0, 0, 1}, Subject: "Divers"},
+	)
+
+	groups := Groups(items, Options{K: 4, MinSize: 5, MinCohesion: 0.8, Seed: 1})
+	if len(groups) != 2 {
+		t.Fatalf("groups = %d, want 2: %+v", len(groups), groups)
+	}
+	var invoice Group
+	for _, g := range groups {
+		if g.Domain == "shop.example" {
+			invoice = g
+		}
+	}
+	if invoice.Members == nil {
+		t.Fatalf("no group dominated by shop.example: %+v", groups)
+	}
+	if invoice.DomainShare != 1 || len(invoice.Members) != 8 {
+		t.Errorf("invoice group = %d members, share %v", len(invoice.Members), invoice.DomainShare)
+	}
+	if len(invoice.Terms) == 0 || (invoice.Terms[0] != "facture" && invoice.Terms[0] != "disponible") {
+		t.Errorf("terms = %v", invoice.Terms)
+	}
+	if invoice.TermShare != 1 {
+		t.Errorf("term share = %v, want 1", invoice.TermShare)
+	}
+}
+
+func TestTopTermsSkipsStopwordsAndOneOffs(t *testing.T) {
+	docs := []string{"Your order has shipped", "Your order is on the way", "Weekly digest"}
+	df := docFreq(append(docs, "Order history", "Hello"))
+	got := TopTerms(docs, df, 5, 3)
+	if !reflect.DeepEqual(got, []string{"order"}) {
+		t.Errorf("TopTerms = %v, want [order]", got)
+	}
+}
+
+func TestDominantDomain(t *testing.T) {
+	d, s := DominantDomain([]string{"A <a@x.com>", "b@X.com", "c@y.com", "garbage"})
+	if d != "x.com" || s != 0.5 {
+		t.Errorf("DominantDomain = %q, %v", d, s)
+	}
+}
+
+func TestChooseK(t *testing.T) {
+	for n, want := range map[int]int{1: 1, 3: 2, 50: 5, 200: 10, 5000: 20} {
+		if got := ChooseK(n); got != want {
+			t.Errorf("ChooseK(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
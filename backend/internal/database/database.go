@@ -69,6 +69,12 @@ func (d *Database) SenderPreferences() *mongo.Collection {
 	return d.DB.Collection("sender_preferences")
 }
 
+// Senders holds one aggregate per (user, sender address), kept up to date at
+// sync time.
+func (d *Database) Senders() *mongo.Collection {
+	return d.DB.Collection("senders")
+}
+
 func (d *Database) SmartLabels() *mongo.Collection {
 	return d.DB.Collection("smart_labels")
 }
@@ -81,6 +87,24 @@ func (d *Database) AnalysisCache() *mongo.Collection {
 	return d.DB.Collection("analysis_cache")
 }
 
+// UserAnalysisCache is the per-user overlay in front of AnalysisCache.
+func (d *Database) UserAnalysisCache() *mongo.Collection {
+	return d.DB.Collection("analysis_cache_user")
+}
+
+func (d *Database) Summaries() *mongo.Collection {
+	return d.DB.Collection("summaries")
+}
+
+// Embeddings stores one vector per (user, email), used for clustering.
+func (d *Database) Embeddings() *mongo.Collection {
+	return d.DB.Collection("email_embeddings")
+}
+
+func (d *Database) LabelProposals() *mongo.Collection {
+	return d.DB.Collection("label_proposals")
+}
+
 func (d *Database) Usage() *mongo.Collection {
 	return d.DB.Collection("usage")
 }
@@ -89,6 +113,26 @@ func (d *Database) Unsubscribes() *mongo.Collection {
 	return d.DB.Collection("unsubscribes")
 }
 
+func (d *Database) UnsubscribeViolations() *mongo.Collection {
+	return d.DB.Collection("unsubscribe_violations")
+}
+
+func (d *Database) FollowUps() *mongo.Collection {
+	return d.DB.Collection("follow_ups")
+}
+
+func (d *Database) Deliveries() *mongo.Collection {
+	return d.DB.Collection("deliveries")
+}
+
+func (d *Database) WebhookDeliveries() *mongo.Collection {
+	return d.DB.Collection("webhook_deliveries")
+}
+
+func (d *Database) APITokens() *mongo.Collection {
+	return d.DB.Collection("api_tokens")
+}
+
 func (d *Database) SortingRules() *mongo.Collection {
 	return d.DB.Collection("sorting_rules")
 }
@@ -105,8 +149,9 @@ func (d *Database) ActionLog() *mongo.Collection {
 	return d.DB.Collection("action_log")
 }
 
-// EnsureIndexes creates the indexes that keep hot queries fast at scale.
-// It is best-effort: a failure on one index does not block the others.
+// EnsureIndexes creates the indexes that keep hot queries fast at scale, and
+// clears the analysis cache entries those TTL indexes cannot expire. It is
+// best-effort: a failure on one index does not block the others.
 func (d *Database) EnsureIndexes(ctx context.Context) error {
 	specs := []struct {
 		coll  *mongo.Collection
@@ -114,12 +159,41 @@ func (d *Database) EnsureIndexes(ctx context.Context) error {
 	}{
 		{d.Emails(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "messageId", Value: 1}}}},
 		{d.Emails(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "from", Value: 1}}}},
+		{d.Emails(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "priority", Value: -1}}}},
 		{d.AISuggestions(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}}},
+		{d.AISuggestions(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "emailId", Value: 1}, {Key: "status", Value: 1}}}},
 		{d.SenderPreferences(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "senderEmail", Value: 1}}}},
+		{d.Senders(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "senderEmail", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.Senders(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "emailCount", Value: -1}, {Key: "_id", Value: -1}}}},
+		{d.Senders(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "unreadRate", Value: -1}, {Key: "_id", Value: -1}}}},
+		{d.Senders(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastSeen", Value: -1}, {Key: "_id", Value: -1}}}},
 		{d.AnalysisCache(), mongo.IndexModel{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.AnalysisCache(), mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{d.UserAnalysisCache(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.UserAnalysisCache(), mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}},
+		{d.Summaries(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "messageId", Value: 1}, {Key: "scope", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.Embeddings(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "messageId", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.LabelProposals(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}}},
 		{d.AnalysisJobs(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}}},
 		{d.Usage(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "period", Value: 1}}, Options: options.Index().SetUnique(true)}},
 		{d.Unsubscribes(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "senderEmail", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.UnsubscribeViolations(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "messageId", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.UnsubscribeViolations(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "receivedAt", Value: -1}}}},
+		{d.FollowUps(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "threadId", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.FollowUps(), mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "dueAt", Value: 1}}}},
+		// Threads the follow-up scan checked and found awaiting nothing are
+		// forgotten once their sent message is out of the scan window.
+		{d.FollowUps(), mongo.IndexModel{Keys: bson.D{{Key: "updatedAt", Value: 1}}, Options: options.Index().
+			SetExpireAfterSeconds(31 * 24 * 3600).SetPartialFilterExpression(bson.M{"status": "idle"})}},
+		{d.Deliveries(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		{d.Deliveries(), mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}}},
+		// Delivery records (and the message copy kept for retries) expire after 30 days.
+		{d.Deliveries(), mongo.IndexModel{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(30 * 24 * 3600)}},
+		{d.WebhookDeliveries(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		{d.WebhookDeliveries(), mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}}},
+		{d.WebhookDeliveries(), mongo.IndexModel{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(30 * 24 * 3600)}},
+		{d.APITokens(), mongo.IndexModel{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		{d.APITokens(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}}},
 		{d.Users(), mongo.IndexModel{Keys: bson.D{{Key: "stripeSubscriptionId", Value: 1}}, Options: options.Index().SetSparse(true)}},
 		{d.SortingRules(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "priority", Value: 1}}}},
 		{d.ProtectedSenders(), mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "value", Value: 1}}, Options: options.Index().SetUnique(true)}},
@@ -134,5 +208,13 @@ func (d *Database) EnsureIndexes(ctx context.Context) error {
 			firstErr = err
 		}
 	}
+	// One-off migration: analysis cache entries written before the TTL index
+	// have no expiresAt, so it would never remove them (and lookups already
+	// ignore them). Once deleted, every run finds nothing to do.
+	for _, coll := range []*mongo.Collection{d.AnalysisCache(), d.UserAnalysisCache()} {
+		if _, err := coll.DeleteMany(ctx, bson.M{"expiresAt": bson.M{"$exists": false}}); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 	return firstErr
 }
@@ -0,0 +1,72 @@
+package digest
+
+import (
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
+)
+
+// Cadences: how often a user receives their digest. A weekly digest goes out
+// on Monday, a monthly one on the 1st, both at the user's digest hour.
+const (
+	CadenceDaily   = "daily"
+	CadenceWeekly  = "weekly"
+	CadenceMonthly = "monthly"
+)
+
+// ValidCadence reports whether cadence is supported.
+func ValidCadence(cadence string) bool {
+	return cadence == CadenceDaily || cadence == CadenceWeekly || cadence == CadenceMonthly
+}
+
+// Days is how many days of activity a digest of cadence covers: the trailing
+// week for daily and weekly digests, 30 days for a monthly one.
+func Days(cadence string) int {
+	if cadence == CadenceMonthly {
+		return 30
+	}
+	return 7
+}
+
+// PeriodStart returns midnight, in now's location, of the day the current
+// period of cadence began: today, this week's Monday or the 1st of the month.
+func PeriodStart(cadence string, now time.Time) time.Time {
+	y, m, d := now.Date()
+	switch cadence {
+	case CadenceWeekly:
+		back := (int(now.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-back, 0, 0, 0, 0, now.Location())
+	case CadenceMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+}
+
+// Due reports whether a digest of cadence last sent at last should go out at
+// now: the send hour (in loc) has come today and none went out yet in the
+// current period. A period whose first day was missed (server down) is caught
+// up on the next day.
+func Due(cadence string, last, now time.Time, hour int, loc *time.Location) bool {
+	if !mailer.DueAtIn(last, now, hour, loc) {
+		return false
+	}
+	return last.IsZero() || last.Before(PeriodStart(cadence, now.In(loc)))
+}
+
+// NextSend returns when the next digest of cadence will go out, given it was
+// last sent at last: now if it is due, otherwise the first send hour (in loc)
+// at which Due holds.
+func NextSend(cadence string, last, now time.Time, hour int, loc *time.Location) time.Time {
+	if Due(cadence, last, now, hour, loc) {
+		return now
+	}
+	local := now.In(loc)
+	y, m, d := local.Date()
+	for i := 0; i <= 62; i++ {
+		t := time.Date(y, m, d+i, hour, 0, 0, 0, loc)
+		if t.After(now) && Due(cadence, last, t, hour, loc) {
+			return t
+		}
+	}
+	return time.Time{}
+}
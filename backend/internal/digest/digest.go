@@ -1,18 +1,21 @@
 // Package digest renders Mailsorter's action-ledger recap into a ready-to-send
-// daily email digest (subject + plain-text body + HTML body).
+// email digest (subject + plain-text body + HTML body).
 //
 // The data already exists (see internal/activity, surfaced by
 // GET /api/stats/activity); what was missing to ship "Digest quotidien par
 // email" is the rendering of that data into something a human reads in their
-// inbox. Keeping the rendering pure (no DB, no clock beyond the `now` argument,
-// no network) makes the output deterministic and cheap to test — actual
-// delivery (a gmail.send scope + a scheduler) can sit on top of this without
-// touching the formatting.
+// inbox. Users choose how often it comes (daily, weekly, monthly) and which
+// sections it carries; each section is a text/template and an html/template,
+// rendered in the order the user picked. Keeping the rendering pure (no DB, no
+// clock beyond the `now` argument, no network) makes the output deterministic
+// and cheap to test — actual delivery (a gmail.send scope + a scheduler) sits
+// on top of this without touching the formatting.
 package digest
 
 import (
+	"bytes"
 	"fmt"
-	"html"
+	"io"
 	"sort"
 	"strings"
 	"time"
@@ -27,6 +30,14 @@ type Digest struct {
 	HTML    string `json:"html"`
 }
 
+// Highlight is one of today's most important inbox emails (see
+// internal/priority), listed at the top of the digest.
+type Highlight struct {
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	Score   int    `json:"score"`
+}
+
 // actionLabels maps the canonical action buckets to human French labels. The
 // order is the headline-priority order the UI uses (archive, delete, label,
 // keep), so the digest reads consistently.
@@ -42,13 +53,15 @@ var actionOrder = []struct {
 
 // sourceLabels maps ledger sources to human French labels.
 var sourceLabels = map[string]string{
-	"direct":      "à la main",
-	"rule":        "par vos règles",
-	"ai":          "par l'IA",
-	"ai-auto":     "par l'auto-pilote IA",
-	"bulk":        "en masse",
-	"snooze":      "reportés",
-	"unsubscribe": "désabonnements",
+	"direct":       "à la main",
+	"rule":         "par vos règles",
+	"ai":           "par l'IA",
+	"ai-auto":      "par l'auto-pilote IA",
+	"ai-threshold": "par l'IA (confiance élevée)",
+	"bulk":         "en masse",
+	"snooze":       "reportés",
+	"unsubscribe":  "désabonnements",
+	"draft":        "brouillons de réponse",
 }
 
 // pluralize returns "email" or "emails" depending on count (French rule: plural
@@ -60,8 +73,8 @@ func pluralize(n int) string {
 	return "email"
 }
 
-// todayCount is the count for the most recent day in the trailing-7 window,
-// which Summarize guarantees is the last element of Days.
+// todayCount is the count for the most recent day in the window, which
+// Summarize guarantees is the last element of Days.
 func todayCount(s activity.Summary) int {
 	if len(s.Days) == 0 {
 		return 0
@@ -69,23 +82,106 @@ func todayCount(s activity.Summary) int {
 	return s.Days[len(s.Days)-1].Count
 }
 
-// Render turns a 7-day activity summary into a digest. `now` dates the recap
-// (its day is treated as "today"). The subject leads with today's number so the
-// recipient sees the value before opening.
-func Render(s activity.Summary, now time.Time) Digest {
-	today := todayCount(s)
-	date := now.UTC().Format("02/01/2006")
+// Render turns a 7-day activity summary into the default daily digest: today's
+// priority emails, then the activity breakdown. See RenderData.
+func Render(s activity.Summary, top []Highlight, now time.Time) Digest {
+	return RenderData(Data{Summary: s, Top: top}, Prefs{}, now)
+}
 
-	subject := fmt.Sprintf("Mailsorter — %d %s triés aujourd'hui", today, pluralize(today))
-	if today == 0 {
-		subject = "Mailsorter — votre récap de la semaine"
+// RenderData renders the sections prefs selects from data. `now` dates the
+// recap (its day is treated as "today"). The subject leads with the number of
+// emails sorted — today's for a daily digest, the period's otherwise — so the
+// recipient sees the value before opening. Sections with nothing to show are
+// left out.
+func RenderData(data Data, prefs Prefs, now time.Time) Digest {
+	prefs = prefs.Normalize()
+	v := newView(data, prefs, now)
+
+	var subject string
+	switch prefs.Cadence {
+	case CadenceWeekly:
+		subject = fmt.Sprintf("Mailsorter — votre semaine : %d %s triés", data.Summary.Total, pluralize(data.Summary.Total))
+	case CadenceMonthly:
+		subject = fmt.Sprintf("Mailsorter — votre mois : %d %s triés", data.Summary.Total, pluralize(data.Summary.Total))
+	default:
+		subject = fmt.Sprintf("Mailsorter — %d %s triés aujourd'hui", v.Today, pluralize(v.Today))
+		if v.Today == 0 {
+			subject = "Mailsorter — votre récap de la semaine"
+		}
+	}
+
+	var text, htm []string
+	for _, sec := range prefs.Sections {
+		if t := execute(textTemplates, sec, v); strings.TrimSpace(t) != "" {
+			text = append(text, t)
+		}
+		if hm := execute(htmlTemplates, sec, v); strings.TrimSpace(hm) != "" {
+			htm = append(htm, hm)
+		}
+	}
+	if len(text) == 0 {
+		text = []string{"Rien de nouveau cette fois-ci.\n"}
+		htm = []string{`<p style="margin:0 0 16px;color:#374151">Rien de nouveau cette fois-ci.</p>`}
 	}
 
 	return Digest{
 		Subject: subject,
-		Text:    renderText(s, today, date),
-		HTML:    renderHTML(s, today, date),
+		Text:    fmt.Sprintf("Votre récap Mailsorter — %s\n\n", v.Date) + strings.Join(text, "\n") + "\nBoîte plus légère, esprit plus clair. — Mailsorter\n",
+		HTML: `<div style="font-family:system-ui,-apple-system,Segoe UI,Roboto,sans-serif;color:#1f2937">` +
+			execute(htmlTemplates, "header", v) + strings.Join(htm, "") +
+			`<p style="color:#6b7280;font-size:13px;margin:0">Boîte plus légère, esprit plus clair. — Mailsorter</p></div>`,
+	}
+}
+
+// Empty reports whether a digest of data would have nothing to say in the
+// sections prefs selects, so the scheduler can skip it. The quota section is
+// informational and never makes a digest worth sending on its own.
+func Empty(data Data, prefs Prefs) bool {
+	for _, sec := range prefs.Normalize().Sections {
+		switch sec {
+		case SectionPriority:
+			if len(data.Top) > 0 {
+				return false
+			}
+		case SectionActivity:
+			if data.Summary.Total > 0 {
+				return false
+			}
+		case SectionTopSenders:
+			if len(data.TopSenders) > 0 {
+				return false
+			}
+		case SectionPending:
+			if data.PendingTotal > 0 {
+				return false
+			}
+		case SectionSnoozes:
+			if len(data.WakingTomorrow) > 0 {
+				return false
+			}
+		case SectionSubscriptions:
+			if len(data.NewSubscriptions) > 0 {
+				return false
+			}
+		case SectionRules:
+			if len(data.TopRules) > 0 {
+				return false
+			}
+		}
 	}
+	return true
+}
+
+// execute runs one named template; a failure (a template bug, caught by the
+// tests) leaves the section out rather than sending a broken digest.
+func execute(t interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}, name string, v view) string {
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, name, v); err != nil {
+		return ""
+	}
+	return b.String()
 }
 
 // breakdownByAction returns the non-zero action buckets in headline order.
@@ -123,45 +219,22 @@ func breakdownBySource(s activity.Summary) []string {
 	return out
 }
 
-func renderText(s activity.Summary, today int, date string) string {
-	var b strings.Builder
-	fmt.Fprintf(&b, "Votre récap Mailsorter — %s\n\n", date)
-	fmt.Fprintf(&b, "Aujourd'hui : %d %s triés.\n", today, pluralize(today))
-	fmt.Fprintf(&b, "Cette semaine : %d %s triés.\n", s.Total, pluralize(s.Total))
-
-	if actions := breakdownByAction(s); len(actions) > 0 {
-		fmt.Fprintf(&b, "\nDétail : %s.\n", strings.Join(actions, ", "))
-	}
-	if sources := breakdownBySource(s); len(sources) > 0 {
-		fmt.Fprintf(&b, "Sources : %s.\n", strings.Join(sources, ", "))
+// highlightLine is "Sender — Subject", with a placeholder for an empty subject.
+func highlightLine(h Highlight) string {
+	subject := h.Subject
+	if strings.TrimSpace(subject) == "" {
+		subject = "(sans objet)"
 	}
-
-	b.WriteString("\nBoîte plus légère, esprit plus clair. — Mailsorter\n")
-	return b.String()
+	return fmt.Sprintf("%s — %s", senderName(h.From), subject)
 }
 
-func renderHTML(s activity.Summary, today int, date string) string {
-	var b strings.Builder
-	b.WriteString(`<div style="font-family:system-ui,-apple-system,Segoe UI,Roboto,sans-serif;color:#1f2937">`)
-	fmt.Fprintf(&b, `<p style="color:#6b7280;font-size:13px;margin:0 0 8px">Votre récap Mailsorter — %s</p>`, html.EscapeString(date))
-	fmt.Fprintf(&b, `<h2 style="margin:0 0 4px;font-size:22px">%d %s triés aujourd'hui</h2>`, today, pluralize(today))
-	fmt.Fprintf(&b, `<p style="margin:0 0 16px;color:#374151">%d %s triés cette semaine.</p>`, s.Total, pluralize(s.Total))
-
-	if actions := breakdownByAction(s); len(actions) > 0 {
-		b.WriteString(`<p style="margin:0 0 4px;font-weight:600">Détail</p><ul style="margin:0 0 16px;padding-left:18px;color:#374151">`)
-		for _, a := range actions {
-			fmt.Fprintf(&b, `<li>%s</li>`, html.EscapeString(a))
-		}
-		b.WriteString(`</ul>`)
-	}
-	if sources := breakdownBySource(s); len(sources) > 0 {
-		b.WriteString(`<p style="margin:0 0 4px;font-weight:600">Sources</p><ul style="margin:0 0 16px;padding-left:18px;color:#374151">`)
-		for _, src := range sources {
-			fmt.Fprintf(&b, `<li>%s</li>`, html.EscapeString(src))
+// senderName keeps the display name of a From header ("Jane <j@x.com>" →
+// "Jane"), or the bare address.
+func senderName(from string) string {
+	if i := strings.Index(from, "<"); i > 0 {
+		if name := strings.Trim(strings.TrimSpace(from[:i]), `"`); name != "" {
+			return name
 		}
-		b.WriteString(`</ul>`)
 	}
-
-	b.WriteString(`<p style="color:#6b7280;font-size:13px;margin:0">Boîte plus légère, esprit plus clair. — Mailsorter</p></div>`)
-	return b.String()
+	return strings.Trim(strings.TrimSpace(from), "<>")
 }
@@ -22,7 +22,7 @@ func sampleSummary() activity.Summary {
 
 func TestRenderSubjectLeadsWithToday(t *testing.T) {
 	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
-	d := Render(sampleSummary(), now)
+	d := Render(sampleSummary(), nil, now)
 
 	// Today (2026-06-21) had 3 actions: two archives + one trash.
 	if !strings.Contains(d.Subject, "3 emails triés aujourd'hui") {
@@ -32,7 +32,7 @@ func TestRenderSubjectLeadsWithToday(t *testing.T) {
 
 func TestRenderEmptyFallbackSubject(t *testing.T) {
 	now := time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
-	d := Render(activity.Summarize(nil, now), now)
+	d := Render(activity.Summarize(nil, now), nil, now)
 	if !strings.Contains(d.Subject, "récap de la semaine") {
 		t.Errorf("empty subject = %q, want weekly-recap fallback", d.Subject)
 	}
@@ -44,7 +44,7 @@ func TestRenderEmptyFallbackSubject(t *testing.T) {
 
 func TestRenderBodiesContainBreakdowns(t *testing.T) {
 	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
-	d := Render(sampleSummary(), now)
+	d := Render(sampleSummary(), nil, now)
 
 	// Week total is 5; today is 3.
 	for _, want := range []string{"5 emails triés", "2 archivés", "1 supprimés", "1 étiquetés", "1 gardés"} {
@@ -64,7 +64,7 @@ func TestRenderBodiesContainBreakdowns(t *testing.T) {
 func TestSingularPluralization(t *testing.T) {
 	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
 	one := activity.Summarize([]activity.Row{{At: now, Action: "archive", Source: "direct"}}, now)
-	d := Render(one, now)
+	d := Render(one, nil, now)
 	if !strings.Contains(d.Subject, "1 email triés aujourd'hui") {
 		// note: French keeps "email" singular at 1; the verb agreement is left simple.
 		t.Errorf("subject = %q, want singular 'email' at count 1", d.Subject)
@@ -87,3 +87,145 @@ func TestBySourceOrderingIsDeterministic(t *testing.T) {
 		t.Errorf("unexpected source ordering: %#v", out)
 	}
 }
+
+func TestRenderTopPriority(t *testing.T) {
+	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
+	top := []Highlight{
+		{From: `"Jane Doe" <jane@corp.com>`, Subject: "Contrat à signer avant le 23/06", Score: 85},
+		{From: "boss@corp.com", Subject: "", Score: 60},
+	}
+	d := Render(sampleSummary(), top, now)
+	for _, want := range []string{"À lire en priorité aujourd'hui", "- Jane Doe — Contrat à signer avant le 23/06", "- boss@corp.com — (sans objet)"} {
+		if !strings.Contains(d.Text, want) {
+			t.Errorf("text body missing %q\n%s", want, d.Text)
+		}
+	}
+	if !strings.Contains(d.HTML, "<li>Jane Doe — Contrat à signer avant le 23/06</li>") {
+		t.Errorf("HTML body missing the top email\n%s", d.HTML)
+	}
+	if strings.Contains(Render(sampleSummary(), nil, now).Text, "priorité") {
+		t.Error("priority section rendered without highlights")
+	}
+}
+
+func TestRenderDataSectionsInOrder(t *testing.T) {
+	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
+	data := Data{
+		Summary:      sampleSummary(),
+		TopSenders:   []SenderCount{{Sender: "News <news@shop.com>", Count: 12}},
+		PendingTotal: 3,
+		Pending: []PendingSuggestion{
+			{From: "promo@shop.com", Subject: "Soldes", Action: "label", LabelName: "Promos", Confidence: 0.82},
+		},
+		WakingTomorrow: []WakingSnooze{{From: "boss@corp.com", Subject: "Relance", WakeAt: time.Date(2026, 6, 22, 9, 0, 0, 0, time.UTC)}},
+		TopRules:       []RuleCount{{Name: "Factures", Count: 4}},
+		Quota:          Quota{Plan: "free", Used: 40, Limit: 100},
+	}
+	prefs := Prefs{Cadence: CadenceWeekly, Sections: []string{SectionRules, SectionPending, SectionTopSenders, SectionSnoozes, SectionQuota, "bogus"}}
+	d := RenderData(data, prefs, now)
+
+	if d.Subject != "Mailsorter — votre semaine : 5 emails triés" {
+		t.Errorf("subject = %q", d.Subject)
+	}
+	wants := []string{
+		"- Factures : 4 emails",
+		"3 suggestions en attente de validation",
+		"- promo@shop.com — Soldes : étiqueter « Promos » (82 %)",
+		"- News (12 emails)",
+		"- 09:00 boss@corp.com — Relance",
+		"Quota IA : 40 / 100 emails analysés ce mois-ci.",
+	}
+	last := -1
+	for _, want := range wants {
+		i := strings.Index(d.Text, want)
+		if i < 0 {
+			t.Fatalf("text body missing %q\n%s", want, d.Text)
+		}
+		if i < last {
+			t.Errorf("%q out of order\n%s", want, d.Text)
+		}
+		last = i
+	}
+	if strings.Contains(d.Text, "priorité") || strings.Contains(d.Text, "Détail") {
+		t.Errorf("unselected sections rendered\n%s", d.Text)
+	}
+	if !strings.Contains(d.HTML, "<li>Factures : 4 emails</li>") {
+		t.Errorf("HTML body missing the rules section\n%s", d.HTML)
+	}
+}
+
+func TestRenderDataEscapesHTML(t *testing.T) {
+	now := time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)
+	data := Data{Top: []Highlight{{From: "x@y.com", Subject: "<script>alert(1)</script>"}}}
+	d := RenderData(data, Prefs{}, now)
+	if strings.Contains(d.HTML, "<script>") {
+		t.Errorf("subject not escaped\n%s", d.HTML)
+	}
+	if !strings.Contains(d.Text, "<script>") {
+		t.Errorf("text body should keep the subject verbatim\n%s", d.Text)
+	}
+}
+
+func TestEmptyIgnoresQuota(t *testing.T) {
+	prefs := Prefs{Sections: []string{SectionQuota, SectionPending}}
+	if !Empty(Data{Quota: Quota{Used: 10, Limit: 100}}, prefs) {
+		t.Error("a quota-only digest should count as empty")
+	}
+	if Empty(Data{PendingTotal: 1}, prefs) {
+		t.Error("a pending suggestion should make the digest worth sending")
+	}
+	if Empty(Data{Summary: sampleSummary()}, prefs) != true {
+		t.Error("activity outside the selected sections should not count")
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 6, 18, 15, 0, 0, 0, time.UTC) // a Thursday
+	cases := map[string]time.Time{
+		CadenceDaily:   time.Date(2026, 6, 18, 0, 0, 0, 0, time.UTC),
+		CadenceWeekly:  time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC),
+		CadenceMonthly: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for cadence, want := range cases {
+		if got := PeriodStart(cadence, now); !got.Equal(want) {
+			t.Errorf("PeriodStart(%s) = %v, want %v", cadence, got, want)
+		}
+	}
+}
+
+func TestDueByCadence(t *testing.T) {
+	mon := time.Date(2026, 6, 15, 7, 30, 0, 0, time.UTC)
+	lastWeek := time.Date(2026, 6, 8, 7, 0, 0, 0, time.UTC)
+	if !Due(CadenceWeekly, lastWeek, mon, 7, time.UTC) {
+		t.Error("weekly digest should go out on Monday")
+	}
+	if Due(CadenceWeekly, mon, mon.AddDate(0, 0, 1), 7, time.UTC) {
+		t.Error("weekly digest went out twice in the same week")
+	}
+	// Missed Monday (server down): caught up on Tuesday.
+	if !Due(CadenceWeekly, lastWeek, mon.AddDate(0, 0, 1), 7, time.UTC) {
+		t.Error("missed weekly digest should be caught up")
+	}
+	if Due(CadenceMonthly, time.Date(2026, 6, 1, 7, 0, 0, 0, time.UTC), mon, 7, time.UTC) {
+		t.Error("monthly digest went out twice in the same month")
+	}
+}
+
+func TestNextSend(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	now := time.Date(2026, 6, 17, 10, 0, 0, 0, paris) // Wednesday
+	last := time.Date(2026, 6, 17, 8, 0, 0, 0, paris) // this morning
+	cases := map[string]time.Time{
+		CadenceDaily:   time.Date(2026, 6, 18, 8, 0, 0, 0, paris),
+		CadenceWeekly:  time.Date(2026, 6, 22, 8, 0, 0, 0, paris),
+		CadenceMonthly: time.Date(2026, 7, 1, 8, 0, 0, 0, paris),
+	}
+	for cadence, want := range cases {
+		if got := NextSend(cadence, last, now, 8, paris); !got.Equal(want) {
+			t.Errorf("NextSend(%s) = %v, want %v", cadence, got, want)
+		}
+	}
+	if got := NextSend(CadenceWeekly, time.Time{}, now, 8, paris); !got.Equal(now) {
+		t.Errorf("never-sent digest should be due now, got %v", got)
+	}
+}
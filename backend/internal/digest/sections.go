@@ -0,0 +1,251 @@
+package digest
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/activity"
+)
+
+// Sections a digest can carry, rendered in the order the user lists them.
+const (
+	SectionPriority      = "priority"           // today's most important inbox emails
+	SectionActivity      = "activity"           // emails sorted, by action and by source
+	SectionTopSenders    = "topSenders"         // who mailed the most over the period
+	SectionPending       = "pendingSuggestions" // AI suggestions awaiting review
+	SectionSnoozes       = "snoozesTomorrow"    // snoozed emails coming back tomorrow
+	SectionSubscriptions = "newSubscriptions"   // mailing lists first seen over the period
+	SectionRules         = "topRules"           // the rules that fired the most
+	SectionQuota         = "quota"              // AI quota used this month
+)
+
+// DefaultSections is what a digest carries until the user picks.
+var DefaultSections = []string{SectionPriority, SectionActivity}
+
+var knownSections = map[string]bool{
+	SectionPriority: true, SectionActivity: true, SectionTopSenders: true, SectionPending: true,
+	SectionSnoozes: true, SectionSubscriptions: true, SectionRules: true, SectionQuota: true,
+}
+
+// ValidSection reports whether name is a known section.
+func ValidSection(name string) bool {
+	return knownSections[name]
+}
+
+// Prefs are a user's digest preferences. The zero value is the daily digest
+// with DefaultSections.
+type Prefs struct {
+	Cadence  string
+	Sections []string
+}
+
+// Normalize fills in the defaults and drops unknown or repeated sections.
+func (p Prefs) Normalize() Prefs {
+	if !ValidCadence(p.Cadence) {
+		p.Cadence = CadenceDaily
+	}
+	seen := map[string]bool{}
+	sections := make([]string, 0, len(p.Sections))
+	for _, s := range p.Sections {
+		if ValidSection(s) && !seen[s] {
+			seen[s] = true
+			sections = append(sections, s)
+		}
+	}
+	if len(sections) == 0 {
+		sections = append(sections, DefaultSections...)
+	}
+	p.Sections = sections
+	return p
+}
+
+// SenderCount is one of the period's busiest senders.
+type SenderCount struct {
+	Sender string `json:"sender"`
+	Count  int    `json:"count"`
+}
+
+// PendingSuggestion is an AI suggestion awaiting the user's review.
+type PendingSuggestion struct {
+	From       string  `json:"from"`
+	Subject    string  `json:"subject"`
+	Action     string  `json:"action"`
+	LabelName  string  `json:"labelName,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
+
+// WakingSnooze is a snoozed email due back tomorrow; WakeAt is in the user's
+// timezone.
+type WakingSnooze struct {
+	From    string    `json:"from"`
+	Subject string    `json:"subject"`
+	WakeAt  time.Time `json:"wakeAt"`
+}
+
+// NewSubscription is a mailing list first seen over the period.
+type NewSubscription struct {
+	Sender   string `json:"sender"`
+	OneClick bool   `json:"oneClick"`
+}
+
+// RuleCount is a sorting rule and how many emails it has handled.
+type RuleCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Quota is the user's AI usage this month; Limit < 0 means unlimited.
+type Quota struct {
+	Plan  string `json:"plan"`
+	Used  int    `json:"used"`
+	Limit int    `json:"limit"`
+}
+
+// Data is everything a digest may show. Callers only need to fill in what the
+// selected sections use.
+type Data struct {
+	Summary          activity.Summary
+	Top              []Highlight
+	TopSenders       []SenderCount
+	Pending          []PendingSuggestion
+	PendingTotal     int
+	WakingTomorrow   []WakingSnooze
+	NewSubscriptions []NewSubscription
+	TopRules         []RuleCount
+	Quota            Quota
+}
+
+// view is what the section templates render.
+type view struct {
+	Data
+	Date        string
+	Daily       bool
+	Today       int
+	PeriodLabel string
+	Actions     []string
+	Sources     []string
+}
+
+func newView(data Data, prefs Prefs, now time.Time) view {
+	label := "Cette semaine"
+	if prefs.Cadence == CadenceMonthly {
+		label = "Ces 30 derniers jours"
+	}
+	return view{
+		Data:        data,
+		Date:        now.Format("02/01/2006"),
+		Daily:       prefs.Cadence == CadenceDaily,
+		Today:       todayCount(data.Summary),
+		PeriodLabel: label,
+		Actions:     breakdownByAction(data.Summary),
+		Sources:     breakdownBySource(data.Summary),
+	}
+}
+
+// suggestionVerbs words an AI suggestion's action for the reader.
+var suggestionVerbs = map[string]string{
+	"archive": "archiver",
+	"delete":  "supprimer",
+	"label":   "étiqueter",
+	"keep":    "garder",
+}
+
+// suggestionLine is "Sender — Subject : verb", with the label when there is one.
+func suggestionLine(p PendingSuggestion) string {
+	verb := suggestionVerbs[p.Action]
+	if verb == "" {
+		verb = p.Action
+	}
+	if p.Action == "label" && p.LabelName != "" {
+		verb += " « " + p.LabelName + " »"
+	}
+	return highlightLine(Highlight{From: p.From, Subject: p.Subject}) + " : " + verb
+}
+
+var funcs = map[string]interface{}{
+	"plural":     pluralize,
+	"line":       highlightLine,
+	"highlight":  func(from, subject string) Highlight { return Highlight{From: from, Subject: subject} },
+	"sender":     senderName,
+	"suggestion": suggestionLine,
+	"join":       strings.Join,
+	"percent":    func(f float64) int { return int(f*100 + 0.5) },
+}
+
+// Plain-text sections. Each ends with a newline; RenderData separates them
+// with a blank line.
+var textTemplates = texttemplate.Must(texttemplate.New("text").Funcs(funcs).Parse(`
+{{- define "priority"}}{{if .Top}}À lire en priorité aujourd'hui :
+{{range .Top}}- {{line .}}
+{{end}}{{end}}{{end}}
+
+{{- define "activity"}}{{if .Daily}}Aujourd'hui : {{.Today}} {{plural .Today}} triés.
+{{end}}{{.PeriodLabel}} : {{.Summary.Total}} {{plural .Summary.Total}} triés.
+{{with .Actions}}
+Détail : {{join . ", "}}.
+{{end}}{{with .Sources}}Sources : {{join . ", "}}.
+{{end}}{{end}}
+
+{{- define "topSenders"}}{{if .TopSenders}}Vos principaux expéditeurs :
+{{range .TopSenders}}- {{sender .Sender}} ({{.Count}} {{plural .Count}})
+{{end}}{{end}}{{end}}
+
+{{- define "pendingSuggestions"}}{{if .PendingTotal}}{{.PendingTotal}} suggestion{{if gt .PendingTotal 1}}s{{end}} en attente de validation :
+{{range .Pending}}- {{suggestion .}} ({{percent .Confidence}} %)
+{{end}}{{end}}{{end}}
+
+{{- define "snoozesTomorrow"}}{{if .WakingTomorrow}}De retour demain :
+{{range .WakingTomorrow}}- {{.WakeAt.Format "15:04"}} {{line (highlight .From .Subject)}}
+{{end}}{{end}}{{end}}
+
+{{- define "newSubscriptions"}}{{if .NewSubscriptions}}Nouveaux abonnements détectés :
+{{range .NewSubscriptions}}- {{sender .Sender}}{{if .OneClick}} (désabonnement en un clic){{end}}
+{{end}}{{end}}{{end}}
+
+{{- define "topRules"}}{{if .TopRules}}Vos règles les plus actives :
+{{range .TopRules}}- {{.Name}} : {{.Count}} {{plural .Count}}
+{{end}}{{end}}{{end}}
+
+{{- define "quota"}}{{if lt .Quota.Limit 0}}Quota IA : illimité (forfait {{.Quota.Plan}}), {{.Quota.Used}} {{plural .Quota.Used}} analysés ce mois-ci.
+{{else}}Quota IA : {{.Quota.Used}} / {{.Quota.Limit}} emails analysés ce mois-ci.
+{{end}}{{end}}
+`))
+
+// HTML sections, escaped by html/template.
+var htmlTemplates = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`
+{{- define "header"}}<p style="color:#6b7280;font-size:13px;margin:0 0 8px">Votre récap Mailsorter — {{.Date}}</p>{{end}}
+
+{{- define "title"}}<p style="margin:0 0 4px;font-weight:600">{{.}}</p>{{end}}
+
+{{- define "priority"}}{{if .Top}}{{template "title" "À lire en priorité aujourd'hui"}}<ol style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .Top}}<li>{{line .}}</li>{{end}}</ol>{{end}}{{end}}
+
+{{- define "activity"}}{{if .Daily}}<h2 style="margin:0 0 4px;font-size:22px">{{.Today}} {{plural .Today}} triés aujourd'hui</h2>
+{{- else}}<h2 style="margin:0 0 4px;font-size:22px">{{.Summary.Total}} {{plural .Summary.Total}} triés</h2>{{end}}
+{{- if .Daily}}<p style="margin:0 0 16px;color:#374151">{{.Summary.Total}} {{plural .Summary.Total}} triés cette semaine.</p>
+{{- else}}<p style="margin:0 0 16px;color:#374151">{{.PeriodLabel}}.</p>{{end}}
+{{- with .Actions}}{{template "title" "Détail"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{- with .Sources}}{{template "title" "Sources"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{- end}}
+
+{{- define "topSenders"}}{{if .TopSenders}}{{template "title" "Vos principaux expéditeurs"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .TopSenders}}<li>{{sender .Sender}} ({{.Count}} {{plural .Count}})</li>{{end}}</ul>{{end}}{{end}}
+
+{{- define "pendingSuggestions"}}{{if .PendingTotal}}<p style="margin:0 0 4px;font-weight:600">{{.PendingTotal}} suggestion{{if gt .PendingTotal 1}}s{{end}} en attente de validation</p><ul style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .Pending}}<li>{{suggestion .}} ({{percent .Confidence}} %)</li>{{end}}</ul>{{end}}{{end}}
+
+{{- define "snoozesTomorrow"}}{{if .WakingTomorrow}}{{template "title" "De retour demain"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .WakingTomorrow}}<li>{{.WakeAt.Format "15:04"}} {{line (highlight .From .Subject)}}</li>{{end}}</ul>{{end}}{{end}}
+
+{{- define "newSubscriptions"}}{{if .NewSubscriptions}}{{template "title" "Nouveaux abonnements détectés"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .NewSubscriptions}}<li>{{sender .Sender}}{{if .OneClick}} (désabonnement en un clic){{end}}</li>{{end}}</ul>{{end}}{{end}}
+
+{{- define "topRules"}}{{if .TopRules}}{{template "title" "Vos règles les plus actives"}}<ul style="margin:0 0 16px;padding-left:18px;color:#374151">
+{{- range .TopRules}}<li>{{.Name}} : {{.Count}} {{plural .Count}}</li>{{end}}</ul>{{end}}{{end}}
+
+{{- define "quota"}}<p style="margin:0 0 16px;color:#374151">Quota IA :
+{{- if lt .Quota.Limit 0}} illimité (forfait {{.Quota.Plan}}), {{.Quota.Used}} {{plural .Quota.Used}} analysés ce mois-ci.
+{{- else}} {{.Quota.Used}} / {{.Quota.Limit}} emails analysés ce mois-ci.{{end}}</p>{{end}}
+`))
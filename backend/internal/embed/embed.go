@@ -0,0 +1,204 @@
+// Package embed turns short texts (an email's sender, subject and snippet) into
+// dense vectors, so mail can be compared by meaning rather than by keyword.
+//
+// Providers are pluggable behind the Provider interface. The one concrete
+// client speaks the OpenAI-compatible /embeddings shape, which both Mistral's
+// hosted API and most local embedding servers (a sentence-transformers model
+// behind a small HTTP stand-in, Ollama, TEI…) expose — so switching between a
+// hosted and a self-hosted model is a configuration change, not a code change.
+package embed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MistralURL is Mistral's hosted embeddings endpoint.
+const MistralURL = "https://api.mistral.ai/v1/embeddings"
+
+// DefaultModel is the embedding model used when none is configured.
+const DefaultModel = "mistral-embed"
+
+// MaxBatch is the largest number of inputs sent in one request; Embed splits
+// longer slices transparently.
+const MaxBatch = 64
+
+// Provider embeds texts. Vectors come back in input order, one per text, and
+// tokens is what the call consumed (0 when the provider does not report it).
+type Provider interface {
+	Embed(texts []string) (vectors [][]float32, tokens int, err error)
+	Model() string
+}
+
+// Client is an HTTP embeddings provider.
+type Client struct {
+	url        string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewMistral returns a provider backed by Mistral's hosted embeddings API.
+func NewMistral(apiKey, model string) *Client {
+	return NewHTTP(MistralURL, apiKey, model)
+}
+
+// NewHTTP returns a provider for any OpenAI-compatible embeddings endpoint at
+// url. apiKey may be empty for a local, unauthenticated server.
+func NewHTTP(url, apiKey, model string) *Client {
+	if model == "" {
+		model = DefaultModel
+	}
+	return &Client{
+		url:        url,
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Model returns the embedding model name; vectors from different models live
+// in different spaces and must never be compared.
+func (c *Client) Model() string {
+	return c.model
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// Embed implements Provider.
+func (c *Client) Embed(texts []string) ([][]float32, int, error) {
+	out := make([][]float32, 0, len(texts))
+	tokens := 0
+	for start := 0; start < len(texts); start += MaxBatch {
+		end := start + MaxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		vecs, n, err := c.embedBatch(texts[start:end])
+		tokens += n
+		if err != nil {
+			return nil, tokens, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, tokens, nil
+}
+
+func (c *Client) embedBatch(texts []string) ([][]float32, int, error) {
+	body, err := json.Marshal(embedRequest{Model: c.model, Input: texts})
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("embeddings API returned status %d: %s", resp.StatusCode, string(raw))
+	}
+
+	var er embedResponse
+	if err := json.Unmarshal(raw, &er); err != nil {
+		return nil, 0, err
+	}
+	tokens := er.Usage.TotalTokens
+	if tokens == 0 {
+		tokens = er.Usage.PromptTokens
+	}
+	if len(er.Data) != len(texts) {
+		return nil, tokens, fmt.Errorf("embeddings API returned %d vectors for %d inputs", len(er.Data), len(texts))
+	}
+	vecs := make([][]float32, len(texts))
+	for i, d := range er.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(texts) || vecs[idx] != nil {
+			idx = i // index missing or inconsistent: trust response order
+		}
+		vecs[idx] = d.Embedding
+	}
+	return vecs, tokens, nil
+}
+
+// EmailText is the text an email is embedded from: who sent it, what it is
+// about and how it starts. Bodies are left out — they are long, noisy and
+// mostly boilerplate for the bulk mail clustering is meant to organize.
+func EmailText(from, subject, snippet string) string {
+	var b strings.Builder
+	b.WriteString("From: " + strings.TrimSpace(from) + "\n")
+	b.WriteString("Subject: " + strings.TrimSpace(subject) + "\n")
+	s := strings.TrimSpace(snippet)
+	if r := []rune(s); len(r) > 300 {
+		s = string(r[:300])
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// Normalize scales v to unit length in place and returns it, so cosine
+// similarity reduces to a dot product. A zero vector is left untouched.
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	n := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= n
+	}
+	return v
+}
+
+// Cosine returns the cosine similarity of a and b (0 when either is zero or
+// their dimensions differ).
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
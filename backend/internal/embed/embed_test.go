@@ -0,0 +1,92 @@
+package embed
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmbedBatchesAndOrders(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("local provider sent Authorization %q", got)
+		}
+		var req embedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		// Answer in reverse order to check the index is honoured.
+		var resp embedResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{i, []float32{float32(len(req.Input[i]))}})
+		}
+		resp.Usage.TotalTokens = len(req.Input)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	texts := make([]string, MaxBatch+3)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	vecs, tokens, err := NewHTTP(srv.URL, "", "local").Embed(texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 batches", calls)
+	}
+	if tokens != len(texts) {
+		t.Errorf("tokens = %d, want %d", tokens, len(texts))
+	}
+	for i, v := range vecs {
+		if int(v[0]) != i+1 {
+			t.Fatalf("vector %d = %v, out of order", i, v)
+		}
+	}
+}
+
+func TestEmbedError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	if _, _, err := NewMistral("bad", "").Embed(nil); err != nil {
+		t.Fatalf("empty input should not call the API: %v", err)
+	}
+	if _, _, err := NewHTTP(srv.URL, "bad", "").Embed([]string{"a"}); err == nil {
+		t.Fatal("expected an error on 401")
+	}
+}
+
+func TestCosineAndNormalize(t *testing.T) {
+	if got := Cosine([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal cosine = %v", got)
+	}
+	if got := Cosine([]float32{1, 1}, []float32{2, 2}); math.Abs(got-1) > 1e-6 {
+		t.Errorf("parallel cosine = %v", got)
+	}
+	if got := Cosine([]float32{1}, []float32{1, 2}); got != 0 {
+		t.Errorf("mismatched dims cosine = %v", got)
+	}
+	v := Normalize([]float32{3, 4})
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Errorf("Normalize = %v", v)
+	}
+}
+
+func TestEmailText(t *testing.T) {
+	got := EmailText(" a@b.c ", "Hello", strings.Repeat("é", 400))
+	if !strings.HasPrefix(got, "From: a@b.c\nSubject: Hello\n") {
+		t.Errorf("EmailText header = %q", got[:30])
+	}
+	if n := len([]rune(got)); n != len([]rune("From: a@b.c\nSubject: Hello\n"))+300 {
+		t.Errorf("snippet not truncated to 300 runes: %d", n)
+	}
+}
@@ -0,0 +1,241 @@
+// Package events is Mailsorter's in-process event bus: handlers and background
+// loops publish what they did for a user (a job's progress, a finished sync, a
+// rule firing, a snooze waking, a ledger entry) and every open stream of that
+// user receives it — the push counterpart to polling the REST endpoints.
+//
+// The bus keeps the last events of each user in a bounded buffer so a client
+// that reconnects with the id of the last event it saw (SSE's Last-Event-ID)
+// resumes without a gap. Publishing never blocks: a subscriber that falls
+// behind its queue is disconnected and catches up from the buffer when it
+// reconnects. Everything lives in memory; a restart starts from empty buffers,
+// which a resuming client learns through Subscription.Gap.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TypeJob    = "job"    // an async job's state or progress changed
+	TypeSync   = "sync"   // an inbox sync finished
+	TypeRules  = "rules"  // rules were applied to the mailbox
+	TypeSnooze = "snooze" // a snoozed email came back to the inbox
+	TypeAction = "action" // an entry was added to the action ledger
+)
+
+// Event is one thing that happened for a user. IDs increase across the whole
+// bus, and are larger after a restart than any id issued before it.
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	At   time.Time   `json:"at"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Defaults for Options left at zero.
+const (
+	DefaultBuffer         = 256
+	DefaultQueue          = 64
+	DefaultMaxSubscribers = 5
+	DefaultIdle           = 15 * time.Minute
+)
+
+// ErrTooManySubscribers is returned when a user already has MaxSubscribers
+// open streams.
+var ErrTooManySubscribers = errors.New("too many event streams")
+
+// ErrClosed is returned by Subscribe once the bus is closed.
+var ErrClosed = errors.New("event bus closed")
+
+// Options bound the bus's memory and fan-out.
+type Options struct {
+	Buffer         int           // events kept per user for resuming
+	Queue          int           // events queued per subscriber before it is dropped
+	MaxSubscribers int           // open streams per user
+	Idle           time.Duration // a user with no stream and no event for this long is forgotten
+}
+
+// Bus fans events out to each user's subscribers.
+type Bus struct {
+	opts   Options
+	mu     sync.Mutex
+	last   uint64
+	users  map[string]*stream
+	closed bool
+}
+
+// stream is one user's buffer and subscribers. floor is the id below which
+// the buffer may be missing events: a resume from an older id has a gap.
+type stream struct {
+	buf   []Event
+	floor uint64
+	subs  map[*Subscription]struct{}
+	seen  time.Time
+}
+
+// New returns an empty bus. IDs start from the current time in microseconds,
+// so ids from a previous process are always older than this one's.
+func New(opts Options) *Bus {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = DefaultQueue
+	}
+	if opts.MaxSubscribers <= 0 {
+		opts.MaxSubscribers = DefaultMaxSubscribers
+	}
+	if opts.Idle <= 0 {
+		opts.Idle = DefaultIdle
+	}
+	return &Bus{opts: opts, last: uint64(time.Now().UnixMicro()), users: map[string]*stream{}}
+}
+
+// userStream returns the user's stream, creating it. Called with mu held.
+func (b *Bus) userStream(user string) *stream {
+	s, ok := b.users[user]
+	if !ok {
+		s = &stream{floor: b.last + 1, subs: map[*Subscription]struct{}{}}
+		b.users[user] = s
+	}
+	s.seen = time.Now()
+	return s
+}
+
+// Publish records an event for user and hands it to their subscribers. It
+// never blocks; a subscriber whose queue is full is closed with Overflowed.
+func (b *Bus) Publish(user, typ string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.userStream(user)
+	b.last++
+	e := Event{ID: b.last, Type: typ, At: time.Now(), Data: data}
+	if len(s.buf) == b.opts.Buffer {
+		s.floor = s.buf[0].ID + 1
+		s.buf = append(s.buf[:0], s.buf[1:]...)
+	}
+	s.buf = append(s.buf, e)
+
+	for sub := range s.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.overflowed = true
+			b.remove(s, sub)
+		}
+	}
+	return e
+}
+
+// Subscription is one open stream. Events arrives in id order and is closed
+// when the subscription ends: by Close, by the bus closing, or by the bus
+// dropping a subscriber that fell behind (Overflowed).
+type Subscription struct {
+	// Replay holds the buffered events after the id the subscriber resumed
+	// from, to be sent before anything on Events.
+	Replay []Event
+	// Gap is set when the resume id is older than the buffer reaches back:
+	// some events are lost and the client should reload its state.
+	Gap bool
+
+	Events     <-chan Event
+	ch         chan Event
+	bus        *Bus
+	user       string
+	overflowed bool
+}
+
+// Subscribe opens a stream of user's events. lastID is the id of the last
+// event the client saw, 0 for a fresh start (no replay).
+func (b *Bus) Subscribe(user string, lastID uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	s := b.userStream(user)
+	if len(s.subs) >= b.opts.MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	ch := make(chan Event, b.opts.Queue)
+	sub := &Subscription{Events: ch, ch: ch, bus: b, user: user}
+	if lastID > 0 {
+		sub.Gap = lastID+1 < s.floor
+		for _, e := range s.buf {
+			if e.ID > lastID {
+				sub.Replay = append(sub.Replay, e)
+			}
+		}
+	}
+	s.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if st, ok := s.bus.users[s.user]; ok {
+		s.bus.remove(st, s)
+	}
+}
+
+// Overflowed reports whether the bus dropped the subscription because it fell
+// behind. Only meaningful once Events is closed.
+func (s *Subscription) Overflowed() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.overflowed
+}
+
+// remove detaches sub from st and closes its channel. Called with mu held.
+func (b *Bus) remove(st *stream, sub *Subscription) {
+	if _, ok := st.subs[sub]; !ok {
+		return
+	}
+	delete(st.subs, sub)
+	close(sub.ch)
+	st.seen = time.Now()
+}
+
+// Sweep forgets users with no open stream and no activity for Idle, so the
+// buffers of users who went away do not pile up. A user forgotten and then
+// resuming gets Gap.
+func (b *Bus) Sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for user, s := range b.users {
+		if len(s.subs) == 0 && now.Sub(s.seen) > b.opts.Idle {
+			delete(b.users, user)
+		}
+	}
+}
+
+// Close ends every open subscription and refuses new ones, so streams do not
+// hold up a server shutdown. Publish keeps working, with no one to deliver to.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, s := range b.users {
+		for sub := range s.subs {
+			b.remove(s, sub)
+		}
+	}
+}
+
+// Subscribers returns how many streams are open across all users.
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, s := range b.users {
+		n += len(s.subs)
+	}
+	return n
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func drain(sub *Subscription) []Event {
+	var out []Event
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return out
+			}
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+func TestPublishFansOutPerUser(t *testing.T) {
+	b := New(Options{})
+	alice1, _ := b.Subscribe("alice", 0)
+	alice2, _ := b.Subscribe("alice", 0)
+	bob, _ := b.Subscribe("bob", 0)
+
+	b.Publish("alice", TypeSync, map[string]int{"synced": 3})
+
+	for _, sub := range []*Subscription{alice1, alice2} {
+		got := drain(sub)
+		if len(got) != 1 || got[0].Type != TypeSync {
+			t.Fatalf("alice got %+v, want one sync event", got)
+		}
+	}
+	if got := drain(bob); len(got) != 0 {
+		t.Fatalf("bob got %+v, want nothing", got)
+	}
+}
+
+func TestIDsIncreaseAndOutliveRestart(t *testing.T) {
+	old := New(Options{})
+	e1 := old.Publish("alice", TypeAction, nil)
+	e2 := old.Publish("bob", TypeAction, nil)
+	if e2.ID <= e1.ID {
+		t.Fatalf("ids not increasing: %d then %d", e1.ID, e2.ID)
+	}
+
+	time.Sleep(time.Millisecond)
+	restarted := New(Options{})
+	sub, _ := restarted.Subscribe("alice", e2.ID)
+	if !sub.Gap {
+		t.Fatal("resuming from a previous process's id should report a gap")
+	}
+	if e3 := restarted.Publish("alice", TypeAction, nil); e3.ID <= e2.ID {
+		t.Fatalf("new process id %d not above old %d", e3.ID, e2.ID)
+	}
+}
+
+func TestResumeReplaysAfterLastID(t *testing.T) {
+	b := New(Options{Buffer: 3})
+	var ids []uint64
+	for i := 0; i < 3; i++ {
+		ids = append(ids, b.Publish("alice", TypeJob, i).ID)
+	}
+
+	sub, err := b.Subscribe("alice", ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Gap || len(sub.Replay) != 2 || sub.Replay[0].ID != ids[1] || sub.Replay[1].ID != ids[2] {
+		t.Fatalf("replay = %+v gap=%v, want events 2 and 3 without gap", sub.Replay, sub.Gap)
+	}
+
+	fresh, _ := b.Subscribe("alice", 0)
+	if len(fresh.Replay) != 0 || fresh.Gap {
+		t.Fatalf("a fresh subscription replays nothing, got %+v", fresh.Replay)
+	}
+}
+
+func TestResumePastBufferReportsGap(t *testing.T) {
+	b := New(Options{Buffer: 2})
+	first := b.Publish("alice", TypeJob, 1)
+	second := b.Publish("alice", TypeJob, 2)
+	b.Publish("alice", TypeJob, 3)
+	b.Publish("alice", TypeJob, 4) // evicts 1 and 2
+
+	if sub, _ := b.Subscribe("alice", second.ID); sub.Gap {
+		t.Fatal("the event right after lastID is still buffered: no gap")
+	}
+	sub, _ := b.Subscribe("alice", first.ID)
+	if !sub.Gap {
+		t.Fatal("event 2 was evicted: want a gap")
+	}
+	if len(sub.Replay) != 2 {
+		t.Fatalf("replay has %d events, want the 2 buffered", len(sub.Replay))
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := New(Options{Queue: 2})
+	slow, _ := b.Subscribe("alice", 0)
+	for i := 0; i < 3; i++ {
+		b.Publish("alice", TypeAction, i) // must not block
+	}
+	if got := drain(slow); len(got) != 2 {
+		t.Fatalf("queued %d events, want 2", len(got))
+	}
+	if _, ok := <-slow.Events; ok {
+		t.Fatal("overflowed subscription should be closed")
+	}
+	if !slow.Overflowed() {
+		t.Fatal("Overflowed() = false")
+	}
+	if b.Subscribers() != 0 {
+		t.Fatalf("dropped subscriber still counted")
+	}
+}
+
+func TestSubscriberLimitAndClose(t *testing.T) {
+	b := New(Options{MaxSubscribers: 1})
+	sub, err := b.Subscribe("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscribe("alice", 0); err != ErrTooManySubscribers {
+		t.Fatalf("err = %v, want ErrTooManySubscribers", err)
+	}
+	if _, err := b.Subscribe("bob", 0); err != nil {
+		t.Fatalf("limit is per user, bob got %v", err)
+	}
+
+	sub.Close()
+	sub.Close()
+	if _, err := b.Subscribe("alice", 0); err != nil {
+		t.Fatalf("slot not freed by Close: %v", err)
+	}
+	if sub.Overflowed() {
+		t.Fatal("a closed subscription did not overflow")
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	b := New(Options{})
+	sub, _ := b.Subscribe("alice", 0)
+	b.Close()
+	if _, ok := <-sub.Events; ok {
+		t.Fatal("subscription should be closed with the bus")
+	}
+	if _, err := b.Subscribe("alice", 0); err != ErrClosed {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+	b.Publish("alice", TypeAction, nil)
+}
+
+func TestSweepForgetsIdleUsers(t *testing.T) {
+	b := New(Options{Idle: time.Minute})
+	e := b.Publish("alice", TypeAction, nil)
+	open, _ := b.Subscribe("bob", 0)
+	defer open.Close()
+	b.Publish("bob", TypeAction, nil)
+
+	b.Sweep(time.Now().Add(2 * time.Minute))
+	if _, ok := b.users["bob"]; !ok {
+		t.Fatal("a user with an open stream must be kept")
+	}
+	sub, _ := b.Subscribe("alice", e.ID)
+	if !sub.Gap || len(sub.Replay) != 0 {
+		t.Fatalf("swept user resumed with gap=%v replay=%v, want a gap and nothing", sub.Gap, sub.Replay)
+	}
+}
@@ -0,0 +1,104 @@
+// Package followup decides which sent emails are still waiting for an answer
+// and when the user should be reminded of them.
+//
+// A thread awaits a reply when its newest message is one the user sent. The
+// reminder comes after a delay the user picks — by default for everyone, and
+// per recipient (an address or a whole domain) for the people who answer
+// faster or slower. Like snooze, the decisions are pure so they are tested
+// without Gmail or storage.
+package followup
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+	"github.com/nohe-sohbi/mailsorter/backend/internal/protect"
+)
+
+// DefaultDays is the reminder delay for users who have not picked one.
+const DefaultDays = 3
+
+// MaxDays bounds every delay, default or per recipient.
+const MaxDays = 60
+
+// Message is one message of a thread, projected down to what the tracker
+// needs.
+type Message struct {
+	ID    string
+	At    time.Time
+	Sent  bool // carries the SENT label
+	Draft bool
+}
+
+// Awaiting returns the newest message of a thread and whether it is one the
+// user sent, i.e. whether the thread is waiting for someone else's reply.
+// Drafts are not messages anyone has seen, so they are ignored.
+func Awaiting(thread []Message) (last Message, ok bool) {
+	found := false
+	for _, m := range thread {
+		if m.Draft {
+			continue
+		}
+		if !found || m.At.After(last.At) {
+			last, found = m, true
+		}
+	}
+	return last, found && last.Sent
+}
+
+// Recipients extracts the bare, lower-cased addresses from To/Cc header
+// values, leaving out the user's own and any duplicates.
+func Recipients(headers []string, self string) []string {
+	self = strings.ToLower(self)
+	seen := map[string]bool{self: true}
+	var out []string
+	add := func(addr string) {
+		addr = protect.NormalizeAddress(addr)
+		if addr == "" || !strings.Contains(addr, "@") || seen[addr] {
+			return
+		}
+		seen[addr] = true
+		out = append(out, addr)
+	}
+	for _, h := range headers {
+		if list, err := mail.ParseAddressList(h); err == nil {
+			for _, a := range list {
+				add(a.Address)
+			}
+			continue
+		}
+		for _, part := range strings.Split(h, ",") {
+			add(part)
+		}
+	}
+	return out
+}
+
+// DelayFor returns how long to wait for a reply to an email sent to
+// recipients: the shortest per-recipient delay that covers any of them, else
+// defaultDays (DefaultDays when unset). Delay recipients match like protected
+// senders: an address exactly, a domain with its subdomains.
+func DelayFor(recipients []string, delays []models.FollowUpDelay, defaultDays int) time.Duration {
+	days := 0
+	for _, d := range delays {
+		for _, r := range recipients {
+			if protect.Match(r, []string{d.Recipient}) && (days == 0 || d.Days < days) {
+				days = d.Days
+			}
+		}
+	}
+	if days == 0 {
+		days = defaultDays
+	}
+	if days <= 0 {
+		days = DefaultDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ValidDays reports whether days is an acceptable delay.
+func ValidDays(days int) bool {
+	return days >= 1 && days <= MaxDays
+}
@@ -0,0 +1,75 @@
+package followup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestAwaiting(t *testing.T) {
+	t0 := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		thread []Message
+		wantID string
+		wantOK bool
+	}{
+		{"user wrote last", []Message{{ID: "a", At: t0}, {ID: "b", At: t0.Add(time.Hour), Sent: true}}, "b", true},
+		{"someone answered", []Message{{ID: "a", At: t0, Sent: true}, {ID: "b", At: t0.Add(time.Hour)}}, "b", false},
+		{"order does not matter", []Message{{ID: "b", At: t0.Add(time.Hour), Sent: true}, {ID: "a", At: t0}}, "b", true},
+		{"a pending draft is not an answer", []Message{{ID: "a", At: t0, Sent: true}, {ID: "d", At: t0.Add(time.Hour), Draft: true}}, "a", true},
+		{"empty thread", nil, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			last, ok := Awaiting(tc.thread)
+			if ok != tc.wantOK || last.ID != tc.wantID {
+				t.Errorf("Awaiting = %q, %v; want %q, %v", last.ID, ok, tc.wantID, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	got := Recipients([]string{
+		`"Alice" <Alice@Example.com>, bob@example.org`,
+		`me@example.com, alice@example.com, <carol@acme.example>`,
+	}, "Me@example.com")
+	want := []string{"alice@example.com", "bob@example.org", "carol@acme.example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Recipients = %v, want %v", got, want)
+	}
+	if got := Recipients([]string{"me@example.com"}, "me@example.com"); len(got) != 0 {
+		t.Errorf("a note to self has no recipients, got %v", got)
+	}
+}
+
+func TestDelayFor(t *testing.T) {
+	day := 24 * time.Hour
+	delays := []models.FollowUpDelay{
+		{Recipient: "boss@acme.example", Days: 1},
+		{Recipient: "acme.example", Days: 2},
+		{Recipient: "slow.example", Days: 10},
+	}
+	cases := []struct {
+		name       string
+		recipients []string
+		def        int
+		want       time.Duration
+	}{
+		{"address entry", []string{"boss@acme.example"}, 5, day},
+		{"domain covers subdomains", []string{"x@mail.acme.example"}, 5, 2 * day},
+		{"shortest across recipients", []string{"a@slow.example", "b@acme.example"}, 5, 2 * day},
+		{"no match uses the default", []string{"a@other.example"}, 5, 5 * day},
+		{"unset default", []string{"a@other.example"}, 0, DefaultDays * day},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DelayFor(tc.recipients, delays, tc.def); got != tc.want {
+				t.Errorf("DelayFor = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
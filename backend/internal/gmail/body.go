@@ -0,0 +1,103 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"html"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Header returns the first value of the named header (case-insensitive), or "".
+func Header(message *gmail.Message, name string) string {
+	if message == nil || message.Payload == nil {
+		return ""
+	}
+	for _, h := range message.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// HeaderValues returns every value of the named header (case-insensitive) in
+// message order. Trace headers such as Authentication-Results repeat, the one
+// added by the user's own provider first.
+func HeaderValues(message *gmail.Message, name string) []string {
+	if message == nil || message.Payload == nil {
+		return nil
+	}
+	var out []string
+	for _, h := range message.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			out = append(out, h.Value)
+		}
+	}
+	return out
+}
+
+// MessageText returns the decoded, human-readable body of a message. It walks
+// the MIME tree depth-first, preferring the first text/plain part and falling
+// back to a tag-stripped text/html part, so callers (the summarizer) get prose
+// rather than the base64url payload GetEmailBody returns.
+func MessageText(message *gmail.Message) string {
+	if message == nil || message.Payload == nil {
+		return ""
+	}
+	if text := findPart(message.Payload, "text/plain"); text != "" {
+		return strings.TrimSpace(text)
+	}
+	if markup := findPart(message.Payload, "text/html"); markup != "" {
+		return htmlToText(markup)
+	}
+	return ""
+}
+
+// findPart returns the decoded data of the first part with the given MIME type.
+func findPart(part *gmail.MessagePart, mimeType string) string {
+	if part == nil {
+		return ""
+	}
+	if strings.HasPrefix(part.MimeType, mimeType) && part.Body != nil && part.Body.Data != "" {
+		if data, err := decodeBody(part.Body.Data); err == nil {
+			return data
+		}
+	}
+	for _, child := range part.Parts {
+		if text := findPart(child, mimeType); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// decodeBody decodes Gmail's base64url body data, padded or not.
+func decodeBody(data string) (string, error) {
+	b, err := base64.URLEncoding.DecodeString(data)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(data)
+	}
+	return string(b), err
+}
+
+var (
+	reDropBlocks = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	reBreaks     = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h[1-6])[^>]*>`)
+	reTags       = regexp.MustCompile(`<[^>]+>`)
+	reBlankLines = regexp.MustCompile(`\n\s*\n+`)
+	reSpaces     = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// htmlToText is a deliberately small HTML-to-text pass: enough for a model to
+// read a marketing or notification email, not a renderer.
+func htmlToText(markup string) string {
+	s := reDropBlocks.ReplaceAllString(markup, "")
+	s = reBreaks.ReplaceAllString(s, "\n")
+	s = reTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = reSpaces.ReplaceAllString(s, " ")
+	s = reBlankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
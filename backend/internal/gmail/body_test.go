@@ -0,0 +1,68 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"testing"
+
+	gmailapi "google.golang.org/api/gmail/v1"
+)
+
+func enc(s string) string { return base64.URLEncoding.EncodeToString([]byte(s)) }
+
+func TestMessageTextPrefersPlainInNestedParts(t *testing.T) {
+	m := &gmailapi.Message{Payload: &gmailapi.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmailapi.MessagePart{
+			{MimeType: "multipart/alternative", Parts: []*gmailapi.MessagePart{
+				{MimeType: "text/html", Body: &gmailapi.MessagePartBody{Data: enc("<p>html</p>")}},
+				{MimeType: "text/plain; charset=UTF-8", Body: &gmailapi.MessagePartBody{Data: enc("Bonjour,\nvoici la facture.\n")}},
+			}},
+		},
+	}}
+	if got := MessageText(m); got != "Bonjour,\nvoici la facture." {
+		t.Fatalf("MessageText = %q", got)
+	}
+}
+
+func TestMessageTextFallsBackToStrippedHTML(t *testing.T) {
+	markup := `<html><head><style>p{color:red}</style></head><body><p>Hello&nbsp;<b>world</b></p><p>Due &amp; payable</p><script>x()</script></body></html>`
+	m := &gmailapi.Message{Payload: &gmailapi.MessagePart{
+		MimeType: "text/html",
+		Body:     &gmailapi.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte(markup))},
+	}}
+	got := MessageText(m)
+	if got != "Hello world\nDue & payable" {
+		t.Fatalf("MessageText = %q", got)
+	}
+}
+
+func TestMessageTextEmpty(t *testing.T) {
+	if MessageText(nil) != "" || MessageText(&gmailapi.Message{}) != "" {
+		t.Fatal("nil/empty message should yield empty text")
+	}
+}
+
+func TestHeaderIsCaseInsensitive(t *testing.T) {
+	m := msg(map[string]string{"Message-ID": "<abc@x>"})
+	if got := Header(m, "message-id"); got != "<abc@x>" {
+		t.Fatalf("Header = %q", got)
+	}
+	if Header(m, "References") != "" {
+		t.Fatal("missing header should be empty")
+	}
+}
+
+func TestHeaderValuesKeepsEveryOccurrence(t *testing.T) {
+	m := &gmailapi.Message{Payload: &gmailapi.MessagePart{Headers: []*gmailapi.MessagePartHeader{
+		{Name: "Authentication-Results", Value: "mx.google.com; spf=pass"},
+		{Name: "Subject", Value: "hi"},
+		{Name: "authentication-results", Value: "relay; spf=fail"},
+	}}}
+	got := HeaderValues(m, "Authentication-Results")
+	if len(got) != 2 || got[0] != "mx.google.com; spf=pass" || got[1] != "relay; spf=fail" {
+		t.Fatalf("HeaderValues = %q", got)
+	}
+	if HeaderValues(nil, "Subject") != nil {
+		t.Fatal("nil message should yield nil")
+	}
+}
@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
@@ -21,6 +21,9 @@ type Service struct {
 	mu     sync.RWMutex
 	// retry resilience knobs, applied to every Gmail API call (see retry.go).
 	retry retryConfig
+	// unsubClient is the hardened client for one-click unsubscribes (see
+	// unsubscribe.go).
+	unsubClient *http.Client
 }
 
 func NewService(clientID, clientSecret, redirectURL string) *Service {
@@ -41,8 +44,9 @@ func NewService(clientID, clientSecret, redirectURL string) *Service {
 	}
 
 	return &Service{
-		config: config,
-		retry:  defaultRetryConfig(),
+		config:      config,
+		retry:       defaultRetryConfig(),
+		unsubClient: newUnsubscribeClient(nil, PublicIP),
 	}
 }
 
@@ -158,6 +162,75 @@ func (s *Service) GetMessage(gmailService *gmail.Service, messageID string) (*gm
 	})
 }
 
+// GetMessageLabels returns a message's current label ids, fetched in the
+// minimal format (no headers or body).
+func (s *Service) GetMessageLabels(gmailService *gmail.Service, messageID string) ([]string, error) {
+	msg, err := withRetry(s.retry, func() (*gmail.Message, error) {
+		return gmailService.Users.Messages.Get("me", messageID).Format("minimal").Do()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.LabelIds, nil
+}
+
+// SentMessage is the part of a sent message that says who the user writes to.
+type SentMessage struct {
+	ThreadID   string
+	Recipients []string // raw To and Cc header values
+}
+
+// ListSentMessages returns the user's most recent sent messages (up to max,
+// newer than days). Listing only yields thread ids; the recipient headers are
+// fetched (metadata format, one call each) for the newest withRecipients
+// messages only.
+func (s *Service) ListSentMessages(gmailService *gmail.Service, days int, max int64, withRecipients int) ([]SentMessage, error) {
+	resp, err := withRetry(s.retry, func() (*gmail.ListMessagesResponse, error) {
+		return gmailService.Users.Messages.List("me").Q(fmt.Sprintf("in:sent newer_than:%dd", days)).MaxResults(max).Do()
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SentMessage, 0, len(resp.Messages))
+	for i, m := range resp.Messages {
+		sm := SentMessage{ThreadID: m.ThreadId}
+		if i >= withRecipients {
+			out = append(out, sm)
+			continue
+		}
+		msg, err := withRetry(s.retry, func() (*gmail.Message, error) {
+			return gmailService.Users.Messages.Get("me", m.Id).Format("metadata").MetadataHeaders("To", "Cc").Do()
+		})
+		if err != nil || msg.Payload == nil {
+			out = append(out, sm)
+			continue
+		}
+		for _, h := range msg.Payload.Headers {
+			if h.Name == "To" || h.Name == "Cc" {
+				sm.Recipients = append(sm.Recipients, h.Value)
+			}
+		}
+		out = append(out, sm)
+	}
+	return out, nil
+}
+
+// GetThread fetches a whole conversation (every message, full format) in one
+// call, oldest message first as Gmail returns it.
+func (s *Service) GetThread(gmailService *gmail.Service, threadID string) (*gmail.Thread, error) {
+	return withRetry(s.retry, func() (*gmail.Thread, error) {
+		return gmailService.Users.Threads.Get("me", threadID).Format("full").Do()
+	})
+}
+
+// GetThreadHeaders fetches a conversation with only the headers the
+// follow-up tracker reads, which is far lighter than GetThread.
+func (s *Service) GetThreadHeaders(gmailService *gmail.Service, threadID string) (*gmail.Thread, error) {
+	return withRetry(s.retry, func() (*gmail.Thread, error) {
+		return gmailService.Users.Threads.Get("me", threadID).Format("metadata").MetadataHeaders("To", "Cc", "Subject").Do()
+	})
+}
+
 func (s *Service) ModifyMessage(gmailService *gmail.Service, messageID string, addLabels, removeLabels []string) error {
 	modifyRequest := &gmail.ModifyMessageRequest{
 		AddLabelIds:    addLabels,
@@ -178,6 +251,21 @@ func (s *Service) SendMessage(gmailService *gmail.Service, raw string) error {
 	})
 }
 
+// CreateDraft saves a pre-built, base64url-encoded message (see
+// internal/mailer.BuildReply) as a draft in the user's mailbox, attached to
+// threadID when set. It never sends anything. Returns the draft id.
+func (s *Service) CreateDraft(gmailService *gmail.Service, raw, threadID string) (string, error) {
+	draft, err := withRetry(s.retry, func() (*gmail.Draft, error) {
+		return gmailService.Users.Drafts.Create("me", &gmail.Draft{
+			Message: &gmail.Message{Raw: raw, ThreadId: threadID},
+		}).Do()
+	})
+	if err != nil {
+		return "", err
+	}
+	return draft.Id, nil
+}
+
 func (s *Service) ListLabels(gmailService *gmail.Service) ([]*gmail.Label, error) {
 	response, err := withRetry(s.retry, func() (*gmail.ListLabelsResponse, error) {
 		return gmailService.Users.Labels.List("me").Do()
@@ -188,28 +276,51 @@ func (s *Service) ListLabels(gmailService *gmail.Service) ([]*gmail.Label, error
 	return response.Labels, nil
 }
 
+// CreateLabel returns the id of the label named name, creating it with default
+// style when the mailbox does not have it yet.
 func (s *Service) CreateLabel(gmailService interface{}, name string) (string, error) {
+	return s.CreateLabelWithStyle(gmailService, name, LabelStyle{})
+}
+
+// CreateLabelWithStyle is CreateLabel for a possibly nested path
+// ("Finance/Factures"): missing parents are created first, with default style,
+// so Gmail nests the label; st applies to the label itself when it is created.
+// An existing label is returned as is — restyle it with UpdateLabelStyle.
+func (s *Service) CreateLabelWithStyle(gmailService interface{}, name string, st LabelStyle) (string, error) {
 	srv, ok := gmailService.(*gmail.Service)
 	if !ok {
 		return "", fmt.Errorf("invalid gmail service")
 	}
 
-	// First check if label already exists
-	existingLabels, err := s.ListLabels(srv)
-	if err == nil {
-		for _, label := range existingLabels {
-			if label.Name == name {
-				return label.Id, nil
-			}
+	// Gmail compares label names case-insensitively.
+	existing := map[string]string{}
+	if labels, err := s.ListLabels(srv); err == nil {
+		for _, label := range labels {
+			existing[strings.ToLower(label.Name)] = label.Id
 		}
 	}
+	if id, ok := existing[strings.ToLower(name)]; ok {
+		return id, nil
+	}
 
-	// Create new label
+	for _, parent := range labelpath.Ancestors(name) {
+		if _, ok := existing[strings.ToLower(parent)]; ok {
+			continue
+		}
+		if _, err := s.createLabel(srv, parent, LabelStyle{}); err != nil {
+			return "", err
+		}
+	}
+	return s.createLabel(srv, name, st)
+}
+
+func (s *Service) createLabel(srv *gmail.Service, name string, st LabelStyle) (string, error) {
 	label := &gmail.Label{
 		Name:                  name,
-		LabelListVisibility:   "labelShow",
-		MessageListVisibility: "show",
+		LabelListVisibility:   LabelShow,
+		MessageListVisibility: MessageShow,
 	}
+	st.apply(label)
 
 	created, err := withRetry(s.retry, func() (*gmail.Label, error) {
 		return srv.Users.Labels.Create("me", label).Do()
@@ -217,10 +328,85 @@ func (s *Service) CreateLabel(gmailService interface{}, name string) (string, er
 	if err != nil {
 		return "", err
 	}
-
 	return created.Id, nil
 }
 
+// GetLabel fetches one label with its message counters (ListLabels omits them).
+func (s *Service) GetLabel(gmailService *gmail.Service, labelID string) (*gmail.Label, error) {
+	return withRetry(s.retry, func() (*gmail.Label, error) {
+		return gmailService.Users.Labels.Get("me", labelID).Do()
+	})
+}
+
+// RenameLabel changes a label's name in place; messages keep it.
+func (s *Service) RenameLabel(gmailService *gmail.Service, labelID, name string) error {
+	return s.retryErr(func() error {
+		_, err := gmailService.Users.Labels.Patch("me", labelID, &gmail.Label{Name: name}).Do()
+		return err
+	})
+}
+
+// DeleteLabel removes a label from the mailbox and from every message.
+func (s *Service) DeleteLabel(gmailService *gmail.Service, labelID string) error {
+	return s.retryErr(func() error {
+		return gmailService.Users.Labels.Delete("me", labelID).Do()
+	})
+}
+
+// ListLabelMessageIDs returns the ids of the messages carrying labelID, up to
+// max (0 = no cap). Only ids are fetched, so it is cheap even for big labels.
+func (s *Service) ListLabelMessageIDs(gmailService *gmail.Service, labelID string, max int) ([]string, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		resp, err := withRetry(s.retry, func() (*gmail.ListMessagesResponse, error) {
+			call := gmailService.Users.Messages.List("me").LabelIds(labelID).MaxResults(500).IncludeSpamTrash(true)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			return call.Do()
+		})
+		if err != nil {
+			return ids, err
+		}
+		for _, m := range resp.Messages {
+			ids = append(ids, m.Id)
+			if max > 0 && len(ids) >= max {
+				return ids, nil
+			}
+		}
+		if resp.NextPageToken == "" {
+			return ids, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// batchModifyLimit is the most message ids Gmail accepts per batchModify call.
+const batchModifyLimit = 1000
+
+// BatchModify adds and removes labels on many messages, in chunks of
+// batchModifyLimit.
+func (s *Service) BatchModify(gmailService *gmail.Service, messageIDs, addLabels, removeLabels []string) error {
+	for start := 0; start < len(messageIDs); start += batchModifyLimit {
+		end := start + batchModifyLimit
+		if end > len(messageIDs) {
+			end = len(messageIDs)
+		}
+		req := &gmail.BatchModifyMessagesRequest{
+			Ids:            messageIDs[start:end],
+			AddLabelIds:    addLabels,
+			RemoveLabelIds: removeLabels,
+		}
+		if err := s.retryErr(func() error {
+			return gmailService.Users.Messages.BatchModify("me", req).Do()
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Service) GetUserProfile(gmailService *gmail.Service) (string, error) {
 	profile, err := withRetry(s.retry, func() (*gmail.Profile, error) {
 		return gmailService.Users.GetProfile("me").Do()
@@ -395,37 +581,6 @@ func splitAngleList(v string) []string {
 	return out
 }
 
-// OneClickUnsubscribe performs an RFC 8058 one-click unsubscribe: an HTTP POST to
-// the sender's https endpoint with the body `List-Unsubscribe=One-Click`. It must
-// only be used when ParseUnsubscribe reported oneClick == true.
-func (s *Service) OneClickUnsubscribe(rawURL string) error {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return err
-	}
-	if u.Scheme != "https" && u.Scheme != "http" {
-		return fmt.Errorf("unsupported unsubscribe scheme: %s", u.Scheme)
-	}
-
-	client := &http.Client{Timeout: 12 * time.Second}
-	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader("List-Unsubscribe=One-Click"))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mailsorter/1.0 (+unsubscribe)")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("unsubscribe endpoint returned %d", resp.StatusCode)
-	}
-	return nil
-}
-
 func TokenToJSON(token *oauth2.Token) (string, error) {
 	data, err := json.Marshal(token)
 	if err != nil {
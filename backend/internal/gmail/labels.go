@@ -0,0 +1,132 @@
+package gmail
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Label visibilities accepted by the Gmail API.
+const (
+	LabelShow         = "labelShow"
+	LabelShowIfUnread = "labelShowIfUnread"
+	LabelHide         = "labelHide"
+	MessageShow       = "show"
+	MessageHide       = "hide"
+)
+
+// LabelStyle is how a label looks in Gmail. Zero fields keep Gmail's (or the
+// label's current) setting.
+type LabelStyle struct {
+	BackgroundColor       string // a palette hex, e.g. "#16a766"
+	TextColor             string
+	LabelListVisibility   string // labelShow, labelShowIfUnread, labelHide
+	MessageListVisibility string // show, hide
+}
+
+// palette is Gmail's fixed label colour set: the API rejects any other hex,
+// for backgrounds and text alike.
+var palette = map[string]bool{}
+
+func init() {
+	for _, c := range strings.Fields(`
+		#000000 #434343 #666666 #999999 #cccccc #efefef #f3f3f3 #ffffff
+		#fb4c2f #ffad47 #fad165 #16a766 #43d692 #4a86e8 #a479e2 #f691b3
+		#f6c5be #ffe6c7 #fef1d1 #b9e4d0 #c6f3de #c9daf8 #e4d7f5 #fcdee8
+		#efa093 #ffd6a2 #fce8b3 #89d3b2 #a0eac9 #a4c2f4 #d0bcf1 #fbc8d9
+		#e66550 #ffbc6b #fcda83 #44b984 #68dfa9 #6d9eeb #b694e8 #f7a7c0
+		#cc3a21 #eaa041 #f2c960 #149e60 #3dc789 #3c78d8 #8e63ce #e07798
+		#ac2b16 #cf8933 #d5ae49 #0b804b #2a9c68 #285bac #653e9b #b65775
+		#822111 #a46a21 #aa8831 #076239 #1a764d #1c4587 #41236d #83334c
+		#464646 #e7e7e7 #0d3472 #b6cff5 #0d3b44 #98d7e4 #3d188e #e3d7ff
+		#711a36 #fbd3e0 #8a1c0a #f2b2a8 #7a2e0b #ffc8af #7a4706 #ffdeb5
+		#594c05 #fbe983 #684e07 #fdedc1 #0b4f30 #b3efd3 #04502e #a2dcc1
+		#c2c2c2 #4986e7 #2da2bb #b99aff #994a64 #f691b2 #ff7537 #ffad46
+		#662e37 #ebdbde #cca6ac #094228 #42d692 #16a765`) {
+		palette[c] = true
+	}
+}
+
+// namedColors maps friendly names to a readable background/text pair from
+// the palette.
+var namedColors = map[string][2]string{
+	"red":    {"#fb4c2f", "#ffffff"},
+	"orange": {"#ffad47", "#000000"},
+	"yellow": {"#fad165", "#000000"},
+	"green":  {"#16a766", "#ffffff"},
+	"teal":   {"#2da2bb", "#ffffff"},
+	"blue":   {"#4a86e8", "#ffffff"},
+	"purple": {"#a479e2", "#ffffff"},
+	"pink":   {"#f691b3", "#000000"},
+	"brown":  {"#a46a21", "#ffffff"},
+	"gray":   {"#999999", "#ffffff"},
+}
+
+// ResolveColor turns a colour name ("green") or palette hex into a
+// background/text pair. A hex background gets black or white text, whichever
+// reads better. Anything outside Gmail's palette is an error.
+func ResolveColor(color string) (background, text string, err error) {
+	c := strings.ToLower(strings.TrimSpace(color))
+	if pair, ok := namedColors[c]; ok {
+		return pair[0], pair[1], nil
+	}
+	if !palette[c] {
+		return "", "", fmt.Errorf("%q is not a Gmail label colour", color)
+	}
+	return c, contrastText(c), nil
+}
+
+// contrastText picks black or white text for a #rrggbb background by its
+// perceived luminance.
+func contrastText(hex string) string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return "#000000"
+	}
+	r, g, b := float64(v>>16&0xff), float64(v>>8&0xff), float64(v&0xff)
+	if 0.299*r+0.587*g+0.114*b > 150 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// ValidateVisibility checks the two visibility settings; empty values are
+// allowed and mean "leave as is".
+func ValidateVisibility(labelList, messageList string) error {
+	switch labelList {
+	case "", LabelShow, LabelShowIfUnread, LabelHide:
+	default:
+		return fmt.Errorf("invalid labelListVisibility %q", labelList)
+	}
+	switch messageList {
+	case "", MessageShow, MessageHide:
+	default:
+		return fmt.Errorf("invalid messageListVisibility %q", messageList)
+	}
+	return nil
+}
+
+// apply copies the non-empty parts of st onto l.
+func (st LabelStyle) apply(l *gmail.Label) {
+	if st.BackgroundColor != "" {
+		l.Color = &gmail.LabelColor{BackgroundColor: st.BackgroundColor, TextColor: st.TextColor}
+	}
+	if st.LabelListVisibility != "" {
+		l.LabelListVisibility = st.LabelListVisibility
+	}
+	if st.MessageListVisibility != "" {
+		l.MessageListVisibility = st.MessageListVisibility
+	}
+}
+
+// UpdateLabelStyle patches a label's colour and visibility in place.
+func (s *Service) UpdateLabelStyle(gmailService *gmail.Service, labelID string, st LabelStyle) error {
+	patch := &gmail.Label{}
+	st.apply(patch)
+	return s.retryErr(func() error {
+		_, err := gmailService.Users.Labels.Patch("me", labelID, patch).Do()
+		return err
+	})
+}
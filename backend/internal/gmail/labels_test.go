@@ -0,0 +1,35 @@
+package gmail
+
+import "testing"
+
+func TestResolveColor(t *testing.T) {
+	bg, text, err := ResolveColor(" Green ")
+	if err != nil || bg != "#16a766" || text != "#ffffff" {
+		t.Errorf("named colour = %q %q %v", bg, text, err)
+	}
+	bg, text, err = ResolveColor("#FAD165")
+	if err != nil || bg != "#fad165" || text != "#000000" {
+		t.Errorf("palette hex = %q %q %v", bg, text, err)
+	}
+	if _, _, err := ResolveColor("#123456"); err == nil {
+		t.Error("off-palette hex accepted")
+	}
+}
+
+func TestContrastText(t *testing.T) {
+	if contrastText("#000000") != "#ffffff" || contrastText("#ffffff") != "#000000" {
+		t.Error("contrastText picks the wrong extreme")
+	}
+}
+
+func TestValidateVisibility(t *testing.T) {
+	if err := ValidateVisibility("", ""); err != nil {
+		t.Errorf("empty visibilities rejected: %v", err)
+	}
+	if err := ValidateVisibility(LabelShowIfUnread, MessageHide); err != nil {
+		t.Errorf("valid visibilities rejected: %v", err)
+	}
+	if ValidateVisibility("hidden", "") == nil || ValidateVisibility("", "labelShow") == nil {
+		t.Error("invalid visibility accepted")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"math/rand"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -66,6 +67,21 @@ func shouldRetry(err error) (retryable bool, retryAfter time.Duration) {
 	return true, 0
 }
 
+// IsNotFound reports whether err is Gmail's 404: the message, label or draft
+// no longer exists (e.g. permanently deleted by the user).
+func IsNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// IsPermanent reports whether err is a Gmail API refusal that retrying will
+// not fix: a 4xx other than 429, such as the 403 a token without the needed
+// scope gets.
+func IsPermanent(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != http.StatusTooManyRequests
+}
+
 // parseRetryAfterHeader interprets the delta-seconds form of a Retry-After
 // header. Non-numeric or non-positive values yield 0.
 func parseRetryAfterHeader(v string) time.Duration {
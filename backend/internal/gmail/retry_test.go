@@ -114,3 +114,12 @@ func TestWithRetryDoesNotRetryPermanent(t *testing.T) {
 		t.Errorf("permanent error must not be retried, got %d attempts", calls)
 	}
 }
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(fmt.Errorf("get: %w", &googleapi.Error{Code: 404})) {
+		t.Error("wrapped 404 not recognised")
+	}
+	if IsNotFound(&googleapi.Error{Code: 403}) || IsNotFound(errors.New("boom")) {
+		t.Error("non-404 reported as not found")
+	}
+}
@@ -0,0 +1,200 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// One-click unsubscribe URLs come from mail headers, i.e. from anyone who can
+// email a user, so the client that follows them treats them as hostile: https
+// only (RFC 8058 §3.1), public addresses only — checked on the resolved IP at
+// connect time so DNS rebinding cannot slip past, and again on every redirect
+// — a short redirect chain and a capped response.
+const (
+	unsubscribeTimeout      = 12 * time.Second
+	unsubscribeMaxRedirects = 3
+	unsubscribeMaxBody      = 64 << 10
+)
+
+// ErrUnsafeUnsubscribeURL is returned for an unsubscribe URL (or redirect)
+// that is not https or points at a non-public address.
+var ErrUnsafeUnsubscribeURL = errors.New("unsafe unsubscribe URL")
+
+// UnsubscribeResult describes one one-click attempt, for auditing. StatusCode
+// is 0 when no response came back.
+type UnsubscribeResult struct {
+	StatusCode int
+	Latency    time.Duration
+}
+
+// carrierNAT is the RFC 6598 shared address space, not covered by
+// net.IP.IsPrivate but just as unreachable from the internet.
+var carrierNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip is a globally routable unicast address: not
+// loopback, private, link-local (169.254.169.254 and friends), multicast,
+// unspecified or carrier-grade NAT.
+func PublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		if ip[0] == 0 || carrierNAT.Contains(ip) {
+			return false
+		}
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// newUnsubscribeClient builds the hardened one-click client. allow decides
+// which resolved addresses may be dialed; proxy, when set, carries every
+// request, in which case the proxy is dialed instead and hostnames are checked
+// by resolving them before each request and redirect.
+func newUnsubscribeClient(proxy *url.URL, allow func(net.IP) bool) *http.Client {
+	checkHost := func(ctx context.Context, u *url.URL) error {
+		if u.Scheme != "https" {
+			return fmt.Errorf("%w: scheme %q", ErrUnsafeUnsubscribeURL, u.Scheme)
+		}
+		host := u.Hostname()
+		if ip := net.ParseIP(host); ip != nil {
+			if !allow(ip) {
+				return fmt.Errorf("%w: %s", ErrUnsafeUnsubscribeURL, host)
+			}
+			return nil
+		}
+		if proxy == nil {
+			return nil // checked on the dialed address
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		for _, a := range addrs {
+			if !allow(a.IP) {
+				return fmt.Errorf("%w: %s resolves to %s", ErrUnsafeUnsubscribeURL, host, a.IP)
+			}
+		}
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		DialContext:           dialer.DialContext,
+	}
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	} else {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !allow(ip) {
+				return fmt.Errorf("%w: %s", ErrUnsafeUnsubscribeURL, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout:   unsubscribeTimeout,
+		Transport: &checkedTransport{next: transport, check: checkHost},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > unsubscribeMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", unsubscribeMaxRedirects)
+			}
+			return nil // the transport checks the new URL
+		},
+	}
+}
+
+// checkedTransport vets every request URL, the first one and each redirect,
+// before handing it on.
+type checkedTransport struct {
+	next  http.RoundTripper
+	check func(context.Context, *url.URL) error
+}
+
+func (t *checkedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.check(req.Context(), req.URL); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// SetUnsubscribeProxy routes one-click unsubscribes through an egress proxy
+// (http or https URL); an empty string goes direct.
+func (s *Service) SetUnsubscribeProxy(rawURL string) error {
+	var proxy *url.URL
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid unsubscribe proxy URL %q", rawURL)
+		}
+		proxy = u
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unsubClient = newUnsubscribeClient(proxy, PublicIP)
+	return nil
+}
+
+// OneClickUnsubscribe performs an RFC 8058 one-click unsubscribe: an HTTPS POST
+// to the sender's endpoint with the body `List-Unsubscribe=One-Click`. It must
+// only be used when ParseUnsubscribe reported oneClick == true. The result is
+// filled in even on failure, as far as the attempt got.
+func (s *Service) OneClickUnsubscribe(rawURL string) (UnsubscribeResult, error) {
+	var res UnsubscribeResult
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return res, err
+	}
+	if u.Scheme != "https" {
+		return res, fmt.Errorf("%w: scheme %q", ErrUnsafeUnsubscribeURL, u.Scheme)
+	}
+
+	s.mu.RLock()
+	client := s.unsubClient
+	s.mu.RUnlock()
+	if client == nil {
+		client = newUnsubscribeClient(nil, PublicIP)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Mailsorter/1.0 (+unsubscribe)")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	res.Latency = time.Since(start)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, unsubscribeMaxBody))
+	res.StatusCode = resp.StatusCode
+	res.Latency = time.Since(start)
+	if resp.StatusCode >= 400 {
+		return res, fmt.Errorf("unsubscribe endpoint returned %d", resp.StatusCode)
+	}
+	return res, nil
+}
@@ -1,6 +1,12 @@
 package gmail
 
 import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	gmailapi "google.golang.org/api/gmail/v1"
@@ -66,3 +72,111 @@ func TestParseUnsubscribeNoHeaders(t *testing.T) {
 		t.Error("nil message should report oneClick=false")
 	}
 }
+
+func TestPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"0.1.2.3":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+	}
+	for addr, want := range cases {
+		if got := PublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("PublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// testUnsubscribeService returns a Service whose one-click client trusts srv's
+// certificate and dials only addresses allow accepts.
+func testUnsubscribeService(srv *httptest.Server, allow func(net.IP) bool) *Service {
+	c := newUnsubscribeClient(nil, allow)
+	c.Transport.(*checkedTransport).next.(*http.Transport).TLSClientConfig =
+		srv.Client().Transport.(*http.Transport).TLSClientConfig
+	return &Service{unsubClient: c}
+}
+
+func allowAll(net.IP) bool { return true }
+
+func TestOneClickUnsubscribePostsAndReportsResult(t *testing.T) {
+	var body string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = r.Method + " " + string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	res, err := testUnsubscribeService(srv, allowAll).OneClickUnsubscribe(srv.URL + "/u")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body != "POST List-Unsubscribe=One-Click" {
+		t.Errorf("server got %q", body)
+	}
+	if res.StatusCode != http.StatusAccepted || res.Latency <= 0 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestOneClickUnsubscribeReportsErrorStatus(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	res, err := testUnsubscribeService(srv, allowAll).OneClickUnsubscribe(srv.URL)
+	if err == nil || res.StatusCode != http.StatusGone {
+		t.Errorf("want an error and status 410, got %+v, %v", res, err)
+	}
+}
+
+func TestOneClickUnsubscribeRejectsUnsafeTargets(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/to-private":
+			http.Redirect(w, r, "https://10.0.0.1/u", http.StatusTemporaryRedirect)
+		case "/to-http":
+			http.Redirect(w, r, "http://example.com/u", http.StatusTemporaryRedirect)
+		default:
+			http.Redirect(w, r, r.URL.Path+"x", http.StatusTemporaryRedirect) // endless
+		}
+	}))
+	defer srv.Close()
+	noPrivate := func(ip net.IP) bool { return !ip.IsPrivate() }
+
+	cases := []struct {
+		name   string
+		svc    *Service
+		url    string
+		unsafe bool
+	}{
+		{"plain http", testUnsubscribeService(srv, allowAll), "http://example.com/u", true},
+		{"loopback by name", testUnsubscribeService(srv, PublicIP), strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), true},
+		{"redirect to a private address", testUnsubscribeService(srv, noPrivate), srv.URL + "/to-private", true},
+		{"redirect to http", testUnsubscribeService(srv, allowAll), srv.URL + "/to-http", true},
+		{"too many redirects", testUnsubscribeService(srv, allowAll), srv.URL + "/loop", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.svc.OneClickUnsubscribe(tc.url)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tc.unsafe && !errors.Is(err, ErrUnsafeUnsubscribeURL) {
+				t.Errorf("want ErrUnsafeUnsubscribeURL, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Package labelpath handles nested label names the way Gmail spells them: a
+// "/"-separated path such as "Finance/Factures", where every prefix is a label
+// of its own and the last segment is what the user reads in the sidebar.
+//
+// Gmail only nests a label when its parent exists, so callers create
+// Ancestors first. Matching helpers let a bare leaf ("Factures") resolve to
+// the full path of an existing nested label. Everything here is pure.
+package labelpath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Separator splits a label path into levels.
+const Separator = "/"
+
+// MaxLength is Gmail's limit on a label name, separators included.
+const MaxLength = 225
+
+// MaxDepth bounds how deep a path may nest; deeper trees are unusable in the
+// Gmail sidebar anyway.
+const MaxDepth = 5
+
+// reserved are Gmail system label names a user label may not take, compared
+// case-insensitively on the top level.
+var reserved = map[string]bool{
+	"inbox": true, "spam": true, "trash": true, "unread": true, "starred": true,
+	"important": true, "sent": true, "draft": true, "drafts": true, "chat": true,
+}
+
+// Clean normalizes a path: segments are trimmed, inner whitespace collapsed
+// and empty segments dropped (" Finance / /Factures " → "Finance/Factures").
+func Clean(path string) string {
+	parts := strings.Split(path, Separator)
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, Separator)
+}
+
+// Validate reports why path cannot be used as a Gmail label, or nil. It
+// expects a Clean path.
+func Validate(path string) error {
+	if path == "" {
+		return errors.New("label name required")
+	}
+	if len(path) > MaxLength {
+		return fmt.Errorf("label name longer than %d characters", MaxLength)
+	}
+	segs := Segments(path)
+	if len(segs) > MaxDepth {
+		return fmt.Errorf("label nested deeper than %d levels", MaxDepth)
+	}
+	if reserved[strings.ToLower(segs[0])] {
+		return fmt.Errorf("%q is a reserved Gmail label", segs[0])
+	}
+	return nil
+}
+
+// Segments splits a path into its levels.
+func Segments(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, Separator)
+}
+
+// Leaf returns the last level of path ("Finance/Factures" → "Factures").
+func Leaf(path string) string {
+	if i := strings.LastIndex(path, Separator); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// Parent returns path without its last level, "" for a top-level label.
+func Parent(path string) string {
+	if i := strings.LastIndex(path, Separator); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// Ancestors returns every proper prefix of path, outermost first
+// ("A/B/C" → ["A", "A/B"]): the labels to create before path itself.
+func Ancestors(path string) []string {
+	segs := Segments(path)
+	if len(segs) < 2 {
+		return nil
+	}
+	out := make([]string, 0, len(segs)-1)
+	for i := 1; i < len(segs); i++ {
+		out = append(out, strings.Join(segs[:i], Separator))
+	}
+	return out
+}
+
+// IsWithin reports whether path is root itself or nested under it, compared
+// case-insensitively as Gmail does.
+func IsWithin(path, root string) bool {
+	p, r := strings.ToLower(path), strings.ToLower(root)
+	return p == r || strings.HasPrefix(p, r+Separator)
+}
+
+// Reparent moves path from under oldRoot to under newRoot ("A/B/C", "A/B",
+// "X" → "X/C"). Paths outside oldRoot are returned unchanged.
+func Reparent(path, oldRoot, newRoot string) string {
+	if !IsWithin(path, oldRoot) {
+		return path
+	}
+	return newRoot + path[len(oldRoot):]
+}
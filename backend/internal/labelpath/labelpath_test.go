@@ -0,0 +1,56 @@
+package labelpath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		" Finance / /Factures ": "Finance/Factures",
+		"Suivi   Colis":         "Suivi Colis",
+		"/Voyages/":             "Voyages",
+		"":                      "",
+	}
+	for in, want := range tests {
+		if got := Clean(in); got != want {
+			t.Errorf("Clean(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("Finance/Factures"); err != nil {
+		t.Errorf("valid path rejected: %v", err)
+	}
+	for _, bad := range []string{"", "INBOX/Sub", "Spam", "a/b/c/d/e/f", strings.Repeat("x", MaxLength+1)} {
+		if Validate(bad) == nil {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	if got := Ancestors("A/B/C"); !reflect.DeepEqual(got, []string{"A", "A/B"}) {
+		t.Errorf("Ancestors = %v", got)
+	}
+	if Ancestors("A") != nil {
+		t.Error("top-level label has ancestors")
+	}
+	if Leaf("Finance/Factures") != "Factures" || Leaf("Travail") != "Travail" {
+		t.Error("Leaf")
+	}
+	if Parent("Finance/Factures") != "Finance" || Parent("Travail") != "" {
+		t.Error("Parent")
+	}
+	if !IsWithin("finance/Factures", "Finance") || IsWithin("Financement", "Finance") {
+		t.Error("IsWithin")
+	}
+	if got := Reparent("Finance/Factures/2024", "Finance", "Argent"); got != "Argent/Factures/2024" {
+		t.Errorf("Reparent = %q", got)
+	}
+	if got := Reparent("Travail", "Finance", "Argent"); got != "Travail" {
+		t.Errorf("Reparent outside root = %q", got)
+	}
+}
@@ -0,0 +1,189 @@
+// Package locale holds Mailsorter's language knowledge: which UI locales the AI
+// prompts come in, a lightweight detector for the language an email is written
+// in, the default label taxonomy per locale, and the cross-language synonyms
+// that let "Invoices", "Factures" and "Rechnungen" be recognized as one label.
+//
+// It is pure (no I/O, no model calls) so it can be tested exhaustively and run
+// on every email without cost.
+package locale
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Supported locales. French is the historical default.
+const (
+	French  = "fr"
+	English = "en"
+	German  = "de"
+
+	Default = French
+)
+
+// Supported reports whether tag names a locale the prompts exist in.
+func Supported(tag string) bool {
+	switch tag {
+	case French, English, German:
+		return true
+	}
+	return false
+}
+
+// Lookup maps a user-supplied tag ("en-US", "DE", " fr_FR ") onto the
+// supported locale of its language, reporting false when there is none.
+func Lookup(tag string) (string, bool) {
+	t := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(t, "-_"); i >= 0 {
+		t = t[:i]
+	}
+	if !Supported(t) {
+		return "", false
+	}
+	return t, true
+}
+
+// Normalize maps a user-supplied tag onto a supported locale (see Lookup),
+// falling back to Default.
+func Normalize(tag string) string {
+	if t, ok := Lookup(tag); ok {
+		return t
+	}
+	return Default
+}
+
+// languageNames spells each detectable language in each UI locale, so a prompt
+// can say "email language: English" in its own words.
+var languageNames = map[string]map[string]string{
+	French:  {French: "français", English: "anglais", German: "allemand"},
+	English: {French: "French", English: "English", German: "German"},
+	German:  {French: "Französisch", English: "Englisch", German: "Deutsch"},
+}
+
+// LanguageName returns the name of language lang written in locale in, or ""
+// when either is unknown.
+func LanguageName(lang, in string) string {
+	return languageNames[Normalize(in)][lang]
+}
+
+// stopwords are short, very frequent words that are distinctive for their
+// language. Words shared between the candidates ("in", "de") are left out.
+var stopwords = map[string][]string{
+	French:  {"le", "la", "les", "des", "est", "et", "pour", "vous", "votre", "vos", "une", "dans", "du", "au", "avec", "sur", "pas", "que", "qui", "nous", "ce", "cette", "commande", "bonjour"},
+	English: {"the", "and", "you", "your", "for", "with", "this", "that", "are", "is", "of", "to", "on", "our", "from", "has", "have", "order", "hello", "hi"},
+	German:  {"der", "die", "das", "und", "ist", "sie", "ihr", "ihre", "für", "mit", "nicht", "ein", "eine", "zu", "auf", "den", "dem", "von", "wir", "bestellung", "hallo"},
+}
+
+// stopwordIndex inverts stopwords for O(1) lookups.
+var stopwordIndex = func() map[string]string {
+	idx := map[string]string{}
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = lang
+		}
+	}
+	return idx
+}()
+
+// minDetectHits is how many stopwords a language needs before Detect commits.
+const minDetectHits = 2
+
+// Detect guesses the language of text by counting distinctive stopwords. It
+// returns "" when the text is too short or too mixed to call — subjects like
+// "Re: OK" carry no signal, and a wrong guess is worse than none.
+func Detect(text string) string {
+	counts := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if lang, ok := stopwordIndex[w]; ok {
+			counts[lang]++
+		}
+	}
+	best, tie := "", false
+	for _, lang := range []string{French, English, German} {
+		switch n := counts[lang]; {
+		case best == "" || n > counts[best]:
+			best, tie = lang, false
+		case n == counts[best]:
+			tie = true
+		}
+	}
+	if counts[best] < minDetectHits || tie {
+		return ""
+	}
+	return best
+}
+
+// concept is one entry of the label taxonomy: its display name per locale plus
+// extra synonyms that should resolve to it.
+type concept struct {
+	key      string
+	names    map[string]string
+	synonyms []string
+}
+
+// taxonomy is the default label catalogue, in presentation order.
+var taxonomy = []concept{
+	{"delivery", map[string]string{French: "Livraison", English: "Deliveries", German: "Lieferungen"},
+		[]string{"suivi colis", "colis", "livraisons", "delivery", "shipping", "package", "parcel", "parcels", "lieferung", "paket", "pakete", "sendungsverfolgung"}},
+	{"invoices", map[string]string{French: "Factures", English: "Invoices", German: "Rechnungen"},
+		[]string{"facture", "invoice", "bills", "bill", "billing", "receipts", "rechnung", "quittungen"}},
+	{"purchases", map[string]string{French: "Achats", English: "Purchases", German: "Einkäufe"},
+		[]string{"achat", "commandes", "purchase", "orders", "order", "shopping", "e-commerce", "einkauf", "bestellungen", "bestellung"}},
+	{"newsletters", map[string]string{French: "Newsletters", English: "Newsletters", German: "Newsletter"},
+		[]string{"newsletter", "infolettre", "infolettres", "lettre d'information"}},
+	{"social", map[string]string{French: "Social", English: "Social", German: "Soziale Netzwerke"},
+		[]string{"réseaux sociaux", "social media", "social network", "soziale medien", "sozial"}},
+	{"travel", map[string]string{French: "Voyages", English: "Travel", German: "Reisen"},
+		[]string{"voyage", "trips", "trip", "flights", "reise", "flüge"}},
+	{"bank", map[string]string{French: "Banque", English: "Banking", German: "Bank"},
+		[]string{"banques", "finance", "finances", "finanzen"}},
+	{"work", map[string]string{French: "Travail", English: "Work", German: "Arbeit"},
+		[]string{"boulot", "job", "jobs", "office", "beruf"}},
+	{"admin", map[string]string{French: "Administratif", English: "Administrative", German: "Verwaltung"},
+		[]string{"administration", "admin", "paperwork", "behörden", "amt"}},
+}
+
+// conceptIndex maps every normalized name and synonym to its concept key.
+var conceptIndex = func() map[string]string {
+	idx := map[string]string{}
+	for _, c := range taxonomy {
+		for _, n := range c.names {
+			idx[normalizeLabel(n)] = c.key
+		}
+		for _, s := range c.synonyms {
+			idx[normalizeLabel(s)] = c.key
+		}
+	}
+	return idx
+}()
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// DefaultLabels returns the default label catalogue in locale, in taxonomy
+// order. Unsupported locales get the Default one.
+func DefaultLabels(loc string) []string {
+	loc = Normalize(loc)
+	out := make([]string, 0, len(taxonomy))
+	for _, c := range taxonomy {
+		out = append(out, c.names[loc])
+	}
+	return out
+}
+
+// Concept returns the taxonomy key a label name belongs to in any supported
+// language ("Factures", "invoices" and "Rechnungen" all yield "invoices"), or
+// "" when the label is not part of the default taxonomy.
+func Concept(label string) string {
+	return conceptIndex[normalizeLabel(label)]
+}
+
+// SameConcept reports whether two label names denote the same taxonomy entry,
+// whatever language each is written in.
+func SameConcept(a, b string) bool {
+	ca := Concept(a)
+	return ca != "" && ca == Concept(b)
+}
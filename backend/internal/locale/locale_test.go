@@ -0,0 +1,101 @@
+package locale
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"en":    English,
+		"en-US": English,
+		" DE ":  German,
+		"fr_FR": French,
+		"es":    Default, // unsupported -> default
+		"":      Default,
+		"de-AT": German,
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if got, ok := Lookup("en-US"); !ok || got != English {
+		t.Errorf("Lookup(en-US) = %q, %v", got, ok)
+	}
+	for _, tag := range []string{"es", "", "-"} {
+		if got, ok := Lookup(tag); ok {
+			t.Errorf("Lookup(%q) = %q, want unsupported", tag, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"Votre commande est en route — suivez la livraison dans votre espace", French},
+		{"Your order has shipped and is on the way to you", English},
+		{"Ihre Bestellung ist unterwegs und wird bald zugestellt, vielen Dank für den Einkauf", German},
+		{"Re: OK", ""},        // no signal
+		{"Meeting 14:00", ""}, // no stopwords at all
+		{"the et", ""},        // below threshold for both
+		{"the and le la", ""}, // tie
+	}
+	for _, c := range cases {
+		if got := Detect(c.text); got != c.want {
+			t.Errorf("Detect(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestLanguageName(t *testing.T) {
+	if got := LanguageName(English, French); got != "anglais" {
+		t.Errorf("LanguageName(en, fr) = %q", got)
+	}
+	if got := LanguageName(German, "xx"); got != "allemand" {
+		t.Errorf("unknown UI locale should fall back to default, got %q", got)
+	}
+	if got := LanguageName("", English); got != "" {
+		t.Errorf("unknown language should be empty, got %q", got)
+	}
+}
+
+func TestDefaultLabelsPerLocale(t *testing.T) {
+	fr, en, de := DefaultLabels(French), DefaultLabels(English), DefaultLabels(German)
+	if len(fr) == 0 || len(fr) != len(en) || len(en) != len(de) {
+		t.Fatalf("taxonomies must align: fr=%d en=%d de=%d", len(fr), len(en), len(de))
+	}
+	if fr[1] != "Factures" || en[1] != "Invoices" || de[1] != "Rechnungen" {
+		t.Errorf("unexpected invoice labels: %q %q %q", fr[1], en[1], de[1])
+	}
+	for i := range fr {
+		if !SameConcept(fr[i], en[i]) || !SameConcept(en[i], de[i]) {
+			t.Errorf("entry %d does not resolve to one concept: %q/%q/%q", i, fr[i], en[i], de[i])
+		}
+	}
+	if got := DefaultLabels("xx"); got[1] != "Factures" {
+		t.Errorf("unsupported locale should get the default taxonomy, got %v", got)
+	}
+}
+
+func TestSameConcept(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"Factures", "invoices", true},
+		{"Rechnungen", "Bills", true},
+		{"Suivi Colis", "Deliveries", true},
+		{"E-commerce", "Achats", true},
+		{"Factures", "Voyages", false},
+		{"Projets", "Projects", false}, // outside the taxonomy
+		{"", "", false},
+	}
+	for _, c := range cases {
+		if got := SameConcept(c.a, c.b); got != c.want {
+			t.Errorf("SameConcept(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
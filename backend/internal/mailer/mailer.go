@@ -2,7 +2,8 @@
 // and decides, purely, when a recurring daily message is due.
 //
 // It deliberately holds no Gmail client, no clock and no I/O: BuildRaw turns a
-// subject + text/HTML bodies into an RFC 2822, base64url-encoded message, and
+// subject + text/HTML bodies into an RFC 2822, base64url-encoded message,
+// BuildReply does the same for a threaded answer (In-Reply-To/References), and
 // DueAt answers "should today's digest go out yet?" given the last send time
 // and a target hour. Keeping both pure makes the scheduler that drives them
 // trivial to test and the formatting deterministic.
@@ -58,20 +59,28 @@ func normalizeHour(hour, fallback int) int {
 	return hour
 }
 
-// DueAt reports whether a daily digest should be sent now. It is due when the
-// current UTC hour has reached sendHourUTC AND no digest has already gone out
-// today (UTC). A zero `last` means it has never been sent, so it is due as soon
-// as the hour arrives. This makes the scheduler idempotent: it can tick as
-// often as it likes and still send at most once per day.
+// DueAt reports whether a daily digest should be sent now, with the send hour
+// in UTC. See DueAtIn.
 func DueAt(last, now time.Time, sendHourUTC int) bool {
-	sendHourUTC = normalizeHour(sendHourUTC, 7)
-	now = now.UTC()
-	if now.Hour() < sendHourUTC {
+	return DueAtIn(last, now, sendHourUTC, time.UTC)
+}
+
+// DueAtIn reports whether a daily digest should be sent now. It is due when
+// the hour of day in loc has reached sendHour AND no digest has already gone
+// out today (in loc). A zero `last` means it has never been sent, so it is due
+// as soon as the hour arrives. This makes the scheduler idempotent: it can
+// tick as often as it likes and still send at most once per day — including
+// on the 23h and 25h days of a DST change, whose midnight is taken from the
+// calendar rather than by subtracting 24h.
+func DueAtIn(last, now time.Time, sendHour int, loc *time.Location) bool {
+	sendHour = normalizeHour(sendHour, 7)
+	now = now.In(loc)
+	if now.Hour() < sendHour {
 		return false
 	}
-	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	if last.IsZero() {
 		return true
 	}
-	return last.UTC().Before(startOfToday)
+	return last.Before(startOfToday)
 }
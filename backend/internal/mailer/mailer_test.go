@@ -69,3 +69,34 @@ func TestDueAt(t *testing.T) {
 		})
 	}
 }
+
+func TestDueAtInUserTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/Montreal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 07:30 in Montréal is 11:30 UTC in summer: a 7 AM digest goes out then,
+	// not at 3 AM local.
+	if DueAtIn(time.Time{}, time.Date(2026, 6, 21, 7, 30, 0, 0, time.UTC), 7, loc) {
+		t.Error("03:30 local must not be due for a 7 AM digest")
+	}
+	if !DueAtIn(time.Time{}, time.Date(2026, 6, 21, 11, 30, 0, 0, time.UTC), 7, loc) {
+		t.Error("07:30 local must be due for a 7 AM digest")
+	}
+
+	// Late evening local is already the next day in UTC; yesterday's local
+	// send must still count as today's.
+	sent := time.Date(2026, 6, 21, 7, 10, 0, 0, loc)
+	if DueAtIn(sent, time.Date(2026, 6, 21, 23, 0, 0, 0, loc), 7, loc) {
+		t.Error("a digest sent this morning (local) must not go out again tonight")
+	}
+
+	// Spring forward: Sunday 8 March 2026 is 23h long in Montréal.
+	sentSat := time.Date(2026, 3, 7, 7, 5, 0, 0, loc)
+	if !DueAtIn(sentSat, time.Date(2026, 3, 8, 7, 5, 0, 0, loc), 7, loc) {
+		t.Error("the digest is due on the morning after a DST change")
+	}
+	if DueAtIn(sentSat, time.Date(2026, 3, 8, 6, 5, 0, 0, loc), 7, loc) {
+		t.Error("the digest is not due before the hour on a DST day")
+	}
+}
@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// DefaultUnsubscribeSubject is used when a List-Unsubscribe mailto: URI leaves
+// the subject out; list servers key on the recipient, but an empty subject is
+// more likely to be filtered on the way.
+const DefaultUnsubscribeSubject = "unsubscribe"
+
+// Mailto is a parsed RFC 6068 mailto: URI.
+type Mailto struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// ParseMailto parses an RFC 6068 mailto: URI: comma-separated addresses in the
+// path, plus extra recipients in `to` fields and the `subject` and `body`
+// fields, all percent-decoded. Other header fields (cc, bcc, in-reply-to, …)
+// are ignored: an unsubscribe request goes to the list and nowhere else. It
+// fails when no valid address is left, when the URI names more than one
+// distinct recipient (a hostile header must not turn the user's mailbox into a
+// mass mailer), or when a field smuggles a line break.
+func ParseMailto(uri string) (Mailto, error) {
+	var m Mailto
+	if len(uri) < len("mailto:") || !strings.EqualFold(uri[:len("mailto:")], "mailto:") {
+		return m, errors.New("not a mailto: URI")
+	}
+	rest := uri[len("mailto:"):]
+	path, query, _ := strings.Cut(rest, "?")
+
+	// The query is decoded by ParseQuery below; only the path is left to unescape.
+	decoded, err := url.PathUnescape(path)
+	if err != nil {
+		return m, err
+	}
+	addrs := []string{decoded}
+	// RFC 6068 percent-encodes spaces; a literal '+' is part of the value
+	// (plus-addressing), not a form-encoded space.
+	fields, err := url.ParseQuery(strings.ReplaceAll(query, "+", "%2B"))
+	if err != nil {
+		return m, err
+	}
+	for key, values := range fields {
+		switch strings.ToLower(key) {
+		case "to":
+			addrs = append(addrs, values...)
+		case "subject":
+			m.Subject = values[0]
+		case "body":
+			m.Body = values[0]
+		}
+	}
+
+	for _, a := range addrs {
+		for _, part := range strings.Split(a, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			addr, err := mail.ParseAddress(part)
+			if err != nil {
+				return m, err
+			}
+			if len(m.To) > 0 && strings.EqualFold(m.To[0], addr.Address) {
+				continue
+			}
+			m.To = append(m.To, addr.Address)
+		}
+	}
+	if len(m.To) == 0 {
+		return m, errors.New("mailto: URI has no recipient")
+	}
+	if len(m.To) > 1 {
+		return m, errors.New("mailto: URI has more than one recipient")
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return m, errors.New("mailto: subject contains a line break")
+	}
+	if m.Subject == "" {
+		m.Subject = DefaultUnsubscribeSubject
+	}
+	return m, nil
+}
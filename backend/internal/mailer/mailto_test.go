@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMailto(t *testing.T) {
+	cases := []struct {
+		name string
+		uri  string
+		want Mailto
+	}{
+		{"bare address", "mailto:leave@list.example.com",
+			Mailto{To: []string{"leave@list.example.com"}, Subject: DefaultUnsubscribeSubject}},
+		{"subject and body", "MAILTO:leave@list.example.com?subject=Unsubscribe%20me&body=id%3D42",
+			Mailto{To: []string{"leave@list.example.com"}, Subject: "Unsubscribe me", Body: "id=42"}},
+		{"plus is literal", "mailto:unsub+abc123@bounce.example.com?subject=a+b",
+			Mailto{To: []string{"unsub+abc123@bounce.example.com"}, Subject: "a+b"}},
+		{"encoded path, repeated to, cc ignored", "mailto:a%40example.com?to=A@example.com&cc=boss@example.com",
+			Mailto{To: []string{"a@example.com"}, Subject: DefaultUnsubscribeSubject}},
+		{"to decoded once", "mailto:?to=unsub%2525list@bounce.example.com",
+			Mailto{To: []string{"unsub%25list@bounce.example.com"}, Subject: DefaultUnsubscribeSubject}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseMailto(tc.uri)
+			if err != nil {
+				t.Fatalf("ParseMailto(%q): %v", tc.uri, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseMailto(%q) = %+v, want %+v", tc.uri, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseMailtoRejects(t *testing.T) {
+	for _, uri := range []string{
+		"https://example.com/unsub",
+		"mailto:",
+		"mailto:?subject=unsubscribe",
+		"mailto:not-an-address",
+		"mailto:leave@list.example.com?subject=hi%0D%0ABcc:%20victim@example.com",
+		"mailto:a@example.com,b@example.com",
+		"mailto:leave@list.example.com?to=victim@example.org",
+	} {
+		if _, err := ParseMailto(uri); err == nil {
+			t.Errorf("ParseMailto(%q) should fail", uri)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+)
+
+// Reply describes an answer to an existing message. InReplyTo is the parent's
+// Message-ID header and References its References header (both verbatim, angle
+// brackets included); together they let every mail client thread the reply.
+type Reply struct {
+	From       string
+	To         string
+	Cc         string
+	Subject    string // the parent's subject; "Re: " is added when missing
+	InReplyTo  string
+	References string
+	Text       string
+}
+
+// ReplySubject prefixes subject with "Re: " unless it already carries a reply
+// prefix (any case, including the localized "Réf :"/"AW:" some clients use).
+func ReplySubject(subject string) string {
+	s := strings.TrimSpace(subject)
+	lower := strings.ToLower(s)
+	for _, p := range []string{"re:", "re :", "aw:", "réf :", "ref:"} {
+		if strings.HasPrefix(lower, p) {
+			return s
+		}
+	}
+	return "Re: " + s
+}
+
+// ReplyReferences builds the References header of a reply per RFC 5322 §3.6.4:
+// the parent's References followed by the parent's Message-ID, without
+// duplicating it when the parent already listed itself.
+func ReplyReferences(parentReferences, parentMessageID string) string {
+	refs := strings.Fields(parentReferences)
+	id := strings.TrimSpace(parentMessageID)
+	if id == "" {
+		return strings.Join(refs, " ")
+	}
+	for _, r := range refs {
+		if r == id {
+			return strings.Join(refs, " ")
+		}
+	}
+	return strings.Join(append(refs, id), " ")
+}
+
+// BuildReply assembles a plain-text reply and returns it base64url-encoded,
+// ready for a Gmail draft or send. References defaults to the chain derived from
+// InReplyTo when the caller leaves it empty.
+func BuildReply(r Reply) string {
+	refs := r.References
+	if refs == "" {
+		refs = strings.TrimSpace(r.InReplyTo)
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + r.From + "\r\n")
+	b.WriteString("To: " + r.To + "\r\n")
+	if r.Cc != "" {
+		b.WriteString("Cc: " + r.Cc + "\r\n")
+	}
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", ReplySubject(r.Subject)) + "\r\n")
+	if r.InReplyTo != "" {
+		b.WriteString("In-Reply-To: " + r.InReplyTo + "\r\n")
+	}
+	if refs != "" {
+		b.WriteString("References: " + refs + "\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(r.Text, "\n", "\r\n") + "\r\n")
+
+	return base64.URLEncoding.EncodeToString([]byte(b.String()))
+}
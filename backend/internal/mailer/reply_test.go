@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestReplySubject(t *testing.T) {
+	cases := map[string]string{
+		"Facture octobre":     "Re: Facture octobre",
+		"Re: Facture octobre": "Re: Facture octobre",
+		"RE: déjà":            "RE: déjà",
+		"AW: Rechnung":        "AW: Rechnung",
+		"  Rendez-vous ":      "Re: Rendez-vous",
+	}
+	for in, want := range cases {
+		if got := ReplySubject(in); got != want {
+			t.Errorf("ReplySubject(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReplyReferences(t *testing.T) {
+	if got := ReplyReferences("<a@x> <b@x>", "<c@x>"); got != "<a@x> <b@x> <c@x>" {
+		t.Errorf("chain = %q", got)
+	}
+	if got := ReplyReferences("", "<c@x>"); got != "<c@x>" {
+		t.Errorf("first reply = %q", got)
+	}
+	if got := ReplyReferences("<a@x> <c@x>", "<c@x>"); got != "<a@x> <c@x>" {
+		t.Errorf("parent must not be duplicated, got %q", got)
+	}
+	if got := ReplyReferences("<a@x>", ""); got != "<a@x>" {
+		t.Errorf("missing parent id = %q", got)
+	}
+}
+
+func TestBuildReplyThreadingHeaders(t *testing.T) {
+	raw := BuildReply(Reply{
+		From:       "me@example.com",
+		To:         "client@example.com",
+		Subject:    "Devis",
+		InReplyTo:  "<p@x>",
+		References: ReplyReferences("<root@x>", "<p@x>"),
+		Text:       "Bonjour,\nC'est noté.",
+	})
+	decoded, err := base64.URLEncoding.DecodeString(raw)
+	if err != nil {
+		t.Fatalf("output must be valid base64url: %v", err)
+	}
+	msg := string(decoded)
+	for _, want := range []string{
+		"To: client@example.com\r\n",
+		"Subject: Re: Devis\r\n",
+		"In-Reply-To: <p@x>\r\n",
+		"References: <root@x> <p@x>\r\n",
+		"Content-Type: text/plain; charset=\"UTF-8\"",
+		"Bonjour,\r\nC'est noté.",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("reply missing %q\n--- got ---\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "Cc:") {
+		t.Error("empty Cc must be omitted")
+	}
+}
+
+func TestBuildReplyDefaultsReferencesToParent(t *testing.T) {
+	decoded, _ := base64.URLEncoding.DecodeString(BuildReply(Reply{To: "a@x", Subject: "Hi", InReplyTo: "<p@x>"}))
+	if !strings.Contains(string(decoded), "References: <p@x>\r\n") {
+		t.Errorf("References should fall back to In-Reply-To\n%s", decoded)
+	}
+}
@@ -26,23 +26,94 @@ type User struct {
 	// Off by default so Mailsorter never touches Gmail unprompted.
 	AutoSyncEnabled bool      `json:"autoSyncEnabled" bson:"autoSyncEnabled,omitempty"`
 	LastAutoSyncAt  time.Time `json:"-" bson:"lastAutoSyncAt,omitempty"`
-	// Daily digest — when DigestEnabled is true, a background scheduler emails a
-	// recap of the last 7 days once a day at DigestHourUTC (0–23, UTC).
-	// DigestLastSentAt stamps the last attempt so we send at most once per day.
-	DigestEnabled    bool      `json:"digestEnabled" bson:"digestEnabled,omitempty"`
-	DigestHourUTC    int       `json:"digestHourUTC" bson:"digestHourUTC,omitempty"`
-	DigestLastSentAt time.Time `json:"-" bson:"digestLastSentAt,omitempty"`
-	CreatedAt        time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt        time.Time `json:"updatedAt" bson:"updatedAt"`
+	// Digest — when DigestEnabled is true, a background scheduler emails a
+	// recap at DigestHour (0–23, in Timezone); without it, at DigestHourUTC
+	// (1–23, UTC; 0 is unset) or else at the default hour in Timezone.
+	// Digest picks how often it goes out and what it carries. DigestLastSentAt
+	// stamps the last attempt so we send at most once per period.
+	DigestEnabled    bool        `json:"digestEnabled" bson:"digestEnabled,omitempty"`
+	DigestHourUTC    int         `json:"digestHourUTC" bson:"digestHourUTC,omitempty"`
+	DigestHour       *int        `json:"digestHour,omitempty" bson:"digestHour,omitempty"`
+	Digest           DigestPrefs `json:"digest" bson:"digest,omitempty"`
+	DigestLastSentAt time.Time   `json:"-" bson:"digestLastSentAt,omitempty"`
+	// Timezone is the user's IANA zone ("America/Montreal"); empty means UTC.
+	// With WorkHours it places snooze presets, recurring snoozes, the local
+	// digest hour and the activity recap's days on the user's own clock.
+	Timezone  string    `json:"timezone" bson:"timezone,omitempty"`
+	WorkHours WorkHours `json:"workHours" bson:"workHours,omitempty"`
+	// Locale is the user's UI language ("fr", "en", "de"). It selects the AI
+	// prompt templates, the language of the AI's reasoning and the default
+	// label taxonomy. Empty means the default (French).
+	Locale string `json:"locale" bson:"locale,omitempty"`
+	// ReplyTone and Signature personalize AI-drafted replies: a free-form tone
+	// ("tutoiement, chaleureux") and the text appended below every draft.
+	ReplyTone string `json:"replyTone" bson:"replyTone,omitempty"`
+	Signature string `json:"signature" bson:"signature,omitempty"`
+	// Autopilot holds the confidence thresholds above which an AI suggestion
+	// is applied straight away instead of waiting for a click.
+	Autopilot AutopilotThresholds `json:"autopilot" bson:"autopilot,omitempty"`
+	// UnsubscribeEnforcement is the rule action ("archive" or "trash")
+	// created automatically for a sender that keeps mailing after an
+	// unsubscribe; empty only flags the sender.
+	UnsubscribeEnforcement string `json:"unsubscribeEnforcement" bson:"unsubscribeEnforcement,omitempty"`
+	// FollowUps configures the tracker for sent emails awaiting a reply.
+	FollowUps FollowUpSettings `json:"followUps" bson:"followUps,omitempty"`
+	// NotifyChannels are where the digest is delivered (see internal/notify);
+	// none means the user's own Gmail account.
+	NotifyChannels []NotifyChannel `json:"-" bson:"notifyChannels,omitempty"`
+	// Webhooks are the user's event hooks for automation platforms (see
+	// notify.Hook). They hold credentials, so they live here on the account
+	// record with the other secrets.
+	Webhooks  []Webhook `json:"-" bson:"webhooks,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AutopilotThresholds are per-action confidence thresholds (0–1) for applying
+// AI suggestions without review. A zero threshold never auto-applies that
+// action, which is the default for all three.
+type AutopilotThresholds struct {
+	Label   float64 `json:"label" bson:"label,omitempty"`
+	Archive float64 `json:"archive" bson:"archive,omitempty"`
+	Delete  float64 `json:"delete" bson:"delete,omitempty"`
+}
+
+// WorkHours is the user's working week: the hours (0–23, in their timezone)
+// their working day starts and ends — the morning and evening of snooze
+// presets — and the lower-case English names of their weekend days. End == 0
+// means the default 08:00–18:00; no Weekend means Saturday and Sunday.
+type WorkHours struct {
+	Start   int      `json:"start" bson:"start"`
+	End     int      `json:"end" bson:"end"`
+	Weekend []string `json:"weekend,omitempty" bson:"weekend,omitempty"`
+}
+
+// DigestPrefs are the user's digest choices: Cadence is "daily", "weekly" or
+// "monthly" and Sections lists what the digest carries, in order (see
+// internal/digest). Empty values mean the daily digest with its default
+// sections.
+type DigestPrefs struct {
+	Cadence  string   `json:"cadence" bson:"cadence,omitempty"`
+	Sections []string `json:"sections" bson:"sections,omitempty"`
 }
 
 // UserSettings is the user-tunable subset of the account, exposed via
 // GET/PUT /api/account/settings.
 type UserSettings struct {
-	AutoApplyRules  bool `json:"autoApplyRules"`
-	AutoSyncEnabled bool `json:"autoSyncEnabled"`
-	DigestEnabled   bool `json:"digestEnabled"`
-	DigestHourUTC   int  `json:"digestHourUTC"`
+	AutoApplyRules  bool   `json:"autoApplyRules"`
+	AutoSyncEnabled bool   `json:"autoSyncEnabled"`
+	DigestEnabled   bool   `json:"digestEnabled"`
+	DigestHourUTC   int    `json:"digestHourUTC"`
+	DigestHour      *int   `json:"digestHour"`
+	Timezone        string `json:"timezone"`
+	Locale          string `json:"locale"`
+	ReplyTone       string `json:"replyTone"`
+	Signature       string `json:"signature"`
+
+	Digest                 DigestPrefs         `json:"digest"`
+	WorkHours              WorkHours           `json:"workHours"`
+	Autopilot              AutopilotThresholds `json:"autopilot"`
+	UnsubscribeEnforcement string              `json:"unsubscribeEnforcement"`
 }
 
 // SettingsUpdate is the request body for PUT /api/account/settings. Every field
@@ -51,10 +122,20 @@ type UserSettings struct {
 // (the Rules toggle, the digest card) update their own setting without
 // clobbering the others.
 type SettingsUpdate struct {
-	AutoApplyRules  *bool `json:"autoApplyRules"`
-	AutoSyncEnabled *bool `json:"autoSyncEnabled"`
-	DigestEnabled   *bool `json:"digestEnabled"`
-	DigestHourUTC   *int  `json:"digestHourUTC"`
+	AutoApplyRules  *bool   `json:"autoApplyRules"`
+	AutoSyncEnabled *bool   `json:"autoSyncEnabled"`
+	DigestEnabled   *bool   `json:"digestEnabled"`
+	DigestHourUTC   *int    `json:"digestHourUTC"`
+	DigestHour      *int    `json:"digestHour"` // local hour; negative clears it
+	Timezone        *string `json:"timezone"`
+	Locale          *string `json:"locale"`
+	ReplyTone       *string `json:"replyTone"`
+	Signature       *string `json:"signature"`
+
+	Digest                 *DigestPrefs         `json:"digest"`
+	WorkHours              *WorkHours           `json:"workHours"`
+	Autopilot              *AutopilotThresholds `json:"autopilot"`
+	UnsubscribeEnforcement *string              `json:"unsubscribeEnforcement"`
 }
 
 type Email struct {
@@ -71,10 +152,18 @@ type Email struct {
 	ReceivedDate time.Time `json:"receivedDate" bson:"receivedDate"`
 	IsRead       bool      `json:"isRead" bson:"isRead"`
 	// Unsubscribe affordances parsed from RFC 2369 / RFC 8058 headers.
-	UnsubURL      string    `json:"unsubUrl,omitempty" bson:"unsubUrl,omitempty"`
-	UnsubMailto   string    `json:"unsubMailto,omitempty" bson:"unsubMailto,omitempty"`
-	UnsubOneClick bool      `json:"unsubOneClick,omitempty" bson:"unsubOneClick,omitempty"`
-	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
+	UnsubURL      string `json:"unsubUrl,omitempty" bson:"unsubUrl,omitempty"`
+	UnsubMailto   string `json:"unsubMailto,omitempty" bson:"unsubMailto,omitempty"`
+	UnsubOneClick bool   `json:"unsubOneClick,omitempty" bson:"unsubOneClick,omitempty"`
+	// Priority is the importance score (0–100) computed at sync time by
+	// internal/priority, with the signals behind it.
+	Priority        int      `json:"priority" bson:"priority"`
+	PriorityReasons []string `json:"priorityReasons,omitempty" bson:"priorityReasons,omitempty"`
+	// Risk is the phishing/spoofing score (0–100) computed at sync time by
+	// internal/risk from authentication headers and impersonation heuristics.
+	Risk        int       `json:"risk" bson:"risk"`
+	RiskReasons []string  `json:"riskReasons,omitempty" bson:"riskReasons,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
 }
 
 type Label struct {
@@ -234,17 +323,168 @@ type Snooze struct {
 	From      string    `json:"from" bson:"from"`
 	Subject   string    `json:"subject" bson:"subject"`
 	WakeAt    time.Time `json:"wakeAt" bson:"wakeAt"`
-	Status    string    `json:"status" bson:"status"` // "scheduled", "done", "cancelled"
+	// Status is "scheduled", "done" (woken, or a recurrence stopped),
+	// "replied" (ended by a reply in the thread, see Condition) or
+	// "cancelled" (the email came back or disappeared some other way).
+	Status string `json:"status" bson:"status"`
+	// Repeat makes the snooze recurring (see snooze.Next) until Until, when
+	// set; Occurrences counts the wakes so far.
+	Repeat      string    `json:"repeat,omitempty" bson:"repeat,omitempty"`
+	Until       time.Time `json:"until,omitempty" bson:"until,omitempty"`
+	Occurrences int       `json:"occurrences,omitempty" bson:"occurrences,omitempty"`
+	// Condition ties the wake to the thread: "reply" or "noReply" (see
+	// snooze.WakeOnReply and snooze.WakeIfNoReply).
+	Condition string    `json:"condition,omitempty" bson:"condition,omitempty"`
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
-// SnoozeRequest is the request body for POST /api/emails/snooze. Either Preset
-// (resolved server-side) or an explicit WakeAt (RFC 3339) must be provided.
+// SnoozeRequest is the request body for POST /api/emails/snooze. One of
+// Preset (resolved server-side), an explicit WakeAt (RFC 3339) or Repeat (the
+// first occurrence) must be provided.
 type SnoozeRequest struct {
 	MessageID string    `json:"messageId"`
 	Preset    string    `json:"preset"`
 	WakeAt    time.Time `json:"wakeAt"`
+	Repeat    string    `json:"repeat"`
+	Until     time.Time `json:"until"`
+	Condition string    `json:"condition"`
+}
+
+// FollowUp is a sent email still waiting for a reply: the newest message of
+// its thread is one the user sent. Once DueAt passes without an answer it is
+// surfaced in the "waiting for reply" list and, if the user asked for it,
+// brought back to the inbox under the follow-up label.
+type FollowUp struct {
+	ID         string    `json:"id" bson:"_id,omitempty"`
+	UserID     string    `json:"userId" bson:"userId"`
+	ThreadID   string    `json:"threadId" bson:"threadId"`
+	MessageID  string    `json:"messageId" bson:"messageId"`   // the user's last message
+	CheckedID  string    `json:"-" bson:"checkedId,omitempty"` // newest sent message the scan fetched the thread for
+	Subject    string    `json:"subject" bson:"subject"`
+	Recipients []string  `json:"recipients" bson:"recipients"`
+	SentAt     time.Time `json:"sentAt" bson:"sentAt"`
+	DueAt      time.Time `json:"dueAt" bson:"dueAt"`
+	// Status is "waiting", "due" (the delay passed with no reply), "replied"
+	// or "dismissed" (by the user, or the thread is gone). "idle" marks a
+	// thread the scan checked that awaits no reply; it is never listed.
+	Status     string    `json:"status" bson:"status"`
+	Resurfaced bool      `json:"resurfaced,omitempty" bson:"resurfaced,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// FollowUpSettings configure the follow-up tracker, via
+// GET/PUT /api/followups/settings. Days is the default delay before a sent
+// email with no reply is surfaced, Delays override it per recipient, and
+// Resurface brings the email back to the inbox when it comes due. Off by
+// default: tracking scans the sent folder at every sync.
+type FollowUpSettings struct {
+	Enabled   bool            `json:"enabled" bson:"enabled,omitempty"`
+	Days      int             `json:"days" bson:"days,omitempty"`
+	Resurface bool            `json:"resurface" bson:"resurface,omitempty"`
+	Delays    []FollowUpDelay `json:"delays" bson:"delays,omitempty"`
+}
+
+// FollowUpDelay is the reply delay for one recipient: a full address or a
+// whole domain, normalized like a protected sender.
+type FollowUpDelay struct {
+	Recipient string `json:"recipient" bson:"recipient"`
+	Days      int    `json:"days" bson:"days"`
+}
+
+// ============================================
+// Notification channels and deliveries
+// ============================================
+
+// NotifyChannel is one place the user receives notifications: "gmail",
+// "smtp", "webhook" or "slack". URL (webhook and Slack) and Secret (the
+// webhook signing key) are credentials and stored encrypted.
+type NotifyChannel struct {
+	Type   string `json:"type" bson:"type"`
+	URL    string `json:"-" bson:"url,omitempty"`
+	Secret string `json:"-" bson:"secret,omitempty"`
+}
+
+// Delivery records one notification sent over one channel, with its retries.
+// Status is "pending" (first attempt under way), "retrying" (a transient
+// failure; tried again at NextAttemptAt), "sent" or "failed" (a permanent
+// failure, or out of attempts). The message is kept so a retry resends the
+// same content.
+type Delivery struct {
+	ID            string    `json:"id" bson:"_id,omitempty"`
+	UserID        string    `json:"userId" bson:"userId"`
+	Kind          string    `json:"kind" bson:"kind"`
+	Channel       string    `json:"channel" bson:"channel"`
+	Status        string    `json:"status" bson:"status"`
+	Attempts      int       `json:"attempts" bson:"attempts"`
+	LastError     string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	NextAttemptAt time.Time `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"`
+	Subject       string    `json:"subject" bson:"subject"`
+	Text          string    `json:"-" bson:"text"`
+	HTML          string    `json:"-" bson:"html"`
+	SentAt        time.Time `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Webhook is an endpoint a user registered to receive Mailsorter events, for
+// n8n, Zapier or their own tooling. URL and Secret (the signing key) are
+// credentials and stored encrypted. Failures counts the deliveries that failed
+// in a row; at the limit the hook is disabled, DisabledReason saying why.
+type Webhook struct {
+	ID             string    `json:"id" bson:"id"`
+	URL            string    `json:"-" bson:"url"`
+	Secret         string    `json:"-" bson:"secret"`
+	Events         []string  `json:"events" bson:"events"`
+	Description    string    `json:"description,omitempty" bson:"description,omitempty"`
+	Enabled        bool      `json:"enabled" bson:"enabled"`
+	Failures       int       `json:"failures" bson:"failures"`
+	DisabledReason string    `json:"disabledReason,omitempty" bson:"disabledReason,omitempty"`
+	LastDeliveryAt time.Time `json:"lastDeliveryAt,omitempty" bson:"lastDeliveryAt,omitempty"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WebhookDelivery records one event sent to one webhook, with its retries,
+// statuses as for Delivery. Payload is the exact JSON body, kept so retries
+// and redeliveries send the same event (re-signed with a fresh timestamp);
+// RedeliveryOf points at the delivery a manual redelivery repeats.
+type WebhookDelivery struct {
+	ID             string    `json:"id" bson:"_id,omitempty"`
+	UserID         string    `json:"userId" bson:"userId"`
+	WebhookID      string    `json:"webhookId" bson:"webhookId"`
+	EventID        string    `json:"eventId" bson:"eventId"`
+	Event          string    `json:"event" bson:"event"`
+	Payload        string    `json:"payload" bson:"payload"`
+	Status         string    `json:"status" bson:"status"`
+	Attempts       int       `json:"attempts" bson:"attempts"`
+	ResponseStatus int       `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	LastError      string    `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"`
+	DeliveredAt    time.Time `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	RedeliveryOf   string    `json:"redeliveryOf,omitempty" bson:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// APIToken is a personal access token: a credential the user creates for
+// scripts and the CLI, limited to Scopes. Only its keyed hash is stored
+// (auth.Manager.HashToken); Prefix is the clear start shown to recognize it.
+// A zero ExpiresAt never expires; RevokedAt ends it for good, the record
+// staying for the audit trail.
+type APIToken struct {
+	ID         string    `json:"id" bson:"_id,omitempty"`
+	UserID     string    `json:"userId" bson:"userId"`
+	Name       string    `json:"name" bson:"name"`
+	Prefix     string    `json:"prefix" bson:"prefix"`
+	Hash       string    `json:"-" bson:"hash"`
+	Scopes     []string  `json:"scopes" bson:"scopes"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	LastUsedIP string    `json:"lastUsedIp,omitempty" bson:"lastUsedIp,omitempty"`
+	RevokedAt  time.Time `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
 }
 
 // ============================================
@@ -259,7 +499,7 @@ type ActionLog struct {
 	UserID    string `json:"userId" bson:"userId"`
 	MessageID string `json:"messageId" bson:"messageId"`
 	Action    string `json:"action" bson:"action"`
-	Source    string `json:"source" bson:"source"` // direct, rule, ai, ai-auto, bulk, snooze, unsubscribe, undo
+	Source    string `json:"source" bson:"source"` // direct, rule, ai, ai-auto, ai-threshold, bulk, snooze, unsubscribe, undo
 	// Undone is set when the user reverses this entry from the action history
 	// (e.g. un-archiving a mail a rule archived). UndoneAt stamps when.
 	Undone    bool      `json:"undone,omitempty" bson:"undone,omitempty"`
@@ -281,7 +521,7 @@ type AISuggestion struct {
 	LabelID    string    `json:"labelId" bson:"labelId"`       // Gmail label ID (after creation/matching)
 	Confidence float64   `json:"confidence" bson:"confidence"` // 0.0 to 1.0
 	Reasoning  string    `json:"reasoning" bson:"reasoning"`   // AI explanation
-	Status     string    `json:"status" bson:"status"`         // "pending", "applied", "rejected"
+	Status     string    `json:"status" bson:"status"`         // "pending", "applied", "rejected", "obsolete", "expired"
 	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
 	AppliedAt  time.Time `json:"appliedAt,omitempty" bson:"appliedAt,omitempty"`
 }
@@ -290,56 +530,184 @@ type AISuggestion struct {
 type SenderPreference struct {
 	ID            string    `json:"id" bson:"_id,omitempty"`
 	UserID        string    `json:"userId" bson:"userId"`
-	SenderEmail   string    `json:"senderEmail" bson:"senderEmail"`     // Full email or domain
-	SenderDomain  string    `json:"senderDomain" bson:"senderDomain"`   // Extracted domain
-	SenderName    string    `json:"senderName" bson:"senderName"`       // Display name
-	AutoApply     bool      `json:"autoApply" bson:"autoApply"`         // Auto-apply suggestions?
-	DefaultAction string    `json:"defaultAction" bson:"defaultAction"` // Default action for this sender
-	DefaultLabel  string    `json:"defaultLabel" bson:"defaultLabel"`   // Default label name
-	EmailCount    int       `json:"emailCount" bson:"emailCount"`       // Number of emails from this sender
+	SenderID      string    `json:"senderId,omitempty" bson:"senderId,omitempty"` // Sender it applies to, when known
+	SenderEmail   string    `json:"senderEmail" bson:"senderEmail"`               // Full email or domain
+	SenderDomain  string    `json:"senderDomain" bson:"senderDomain"`             // Extracted domain
+	SenderName    string    `json:"senderName" bson:"senderName"`                 // Display name
+	AutoApply     bool      `json:"autoApply" bson:"autoApply"`                   // Auto-apply suggestions?
+	DefaultAction string    `json:"defaultAction" bson:"defaultAction"`           // Default action for this sender
+	DefaultLabel  string    `json:"defaultLabel" bson:"defaultLabel"`             // Default label name
+	EmailCount    int       `json:"emailCount" bson:"emailCount"`                 // Number of emails from this sender
 	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
 // SmartLabel represents an AI-managed label category
 type SmartLabel struct {
-	ID           string    `json:"id" bson:"_id,omitempty"`
-	UserID       string    `json:"userId" bson:"userId"`
-	Name         string    `json:"name" bson:"name"`                 // Label name (e.g., "E-commerce")
-	GmailLabelID string    `json:"gmailLabelId" bson:"gmailLabelId"` // Corresponding Gmail label ID
-	Description  string    `json:"description" bson:"description"`   // What this label represents
-	Keywords     []string  `json:"keywords" bson:"keywords"`         // Associated keywords for consistency
-	EmailCount   int       `json:"emailCount" bson:"emailCount"`     // Number of emails with this label
-	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+	ID           string   `json:"id" bson:"_id,omitempty"`
+	UserID       string   `json:"userId" bson:"userId"`
+	Name         string   `json:"name" bson:"name"`                 // Label name or nested path (e.g., "Finance/Factures")
+	GmailLabelID string   `json:"gmailLabelId" bson:"gmailLabelId"` // Corresponding Gmail label ID
+	Description  string   `json:"description" bson:"description"`   // What this label represents
+	Keywords     []string `json:"keywords" bson:"keywords"`         // Associated keywords for consistency
+	EmailCount   int      `json:"emailCount" bson:"emailCount"`     // Number of emails with this label
+	// Gmail appearance. Color accepts a palette hex or a name ("green") on input
+	// and is stored as the resolved background hex.
+	Color                 string    `json:"color,omitempty" bson:"color,omitempty"`
+	TextColor             string    `json:"textColor,omitempty" bson:"textColor,omitempty"`
+	LabelListVisibility   string    `json:"labelListVisibility,omitempty" bson:"labelListVisibility,omitempty"`     // labelShow, labelShowIfUnread, labelHide
+	MessageListVisibility string    `json:"messageListVisibility,omitempty" bson:"messageListVisibility,omitempty"` // show, hide
+	CreatedAt             time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// SmartLabelUpdate is the request body for PUT /api/smart-labels/{id}. Nil
+// fields are left unchanged; a new Name renames the label everywhere.
+type SmartLabelUpdate struct {
+	Name                  *string  `json:"name"`
+	Description           *string  `json:"description"`
+	Keywords              []string `json:"keywords"`
+	Color                 *string  `json:"color"`
+	LabelListVisibility   *string  `json:"labelListVisibility"`
+	MessageListVisibility *string  `json:"messageListVisibility"`
+}
+
+// MergeSmartLabelRequest is the request body for POST
+// /api/smart-labels/{id}/merge: the label {id} is folded into IntoID.
+type MergeSmartLabelRequest struct {
+	IntoID string `json:"intoId"`
 }
 
 // AnalysisJob tracks an asynchronous batch-analysis run.
 type AnalysisJob struct {
-	ID                 string    `json:"id" bson:"_id,omitempty"`
-	UserID             string    `json:"userId" bson:"userId"`
-	Status             string    `json:"status" bson:"status"` // queued, running, done, error
-	Total              int       `json:"total" bson:"total"`
-	Processed          int       `json:"processed" bson:"processed"`
-	AutoApplied        int       `json:"autoApplied" bson:"autoApplied"`
-	SuggestionsCreated int       `json:"suggestionsCreated" bson:"suggestionsCreated"`
-	CachedHits         int       `json:"cachedHits" bson:"cachedHits"`
-	Error              string    `json:"error,omitempty" bson:"error,omitempty"`
-	EmailIDs           []string  `json:"-" bson:"emailIds"`
-	CreatedAt          time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt          time.Time `json:"updatedAt" bson:"updatedAt"`
-}
-
-// AnalysisCacheEntry memoizes an AI verdict for a (from, subject) fingerprint
-// so identical emails are never analyzed twice. Content-based, not user-scoped.
+	ID                 string   `json:"id" bson:"_id,omitempty"`
+	UserID             string   `json:"userId" bson:"userId"`
+	Kind               string   `json:"kind,omitempty" bson:"kind,omitempty"` // "" = analysis, "cluster", "unsubscribe"
+	Status             string   `json:"status" bson:"status"`                 // queued, running, done, error
+	Total              int      `json:"total" bson:"total"`
+	Processed          int      `json:"processed" bson:"processed"`
+	AutoApplied        int      `json:"autoApplied" bson:"autoApplied"`
+	SuggestionsCreated int      `json:"suggestionsCreated" bson:"suggestionsCreated"`
+	CachedHits         int      `json:"cachedHits" bson:"cachedHits"`
+	Model              string   `json:"model,omitempty" bson:"model,omitempty"`
+	PromptTokens       int      `json:"promptTokens" bson:"promptTokens"`
+	CompletionTokens   int      `json:"completionTokens" bson:"completionTokens"`
+	TotalTokens        int      `json:"totalTokens" bson:"totalTokens"`
+	CostUSD            float64  `json:"costUsd" bson:"costUsd"`
+	BudgetExceeded     bool     `json:"budgetExceeded,omitempty" bson:"budgetExceeded,omitempty"`
+	Error              string   `json:"error,omitempty" bson:"error,omitempty"`
+	EmailIDs           []string `json:"-" bson:"emailIds"`
+	// Senders and AlsoArchive drive an "unsubscribe" job; Results reports
+	// its outcome per sender as it goes.
+	Senders     []string               `json:"-" bson:"senders,omitempty"`
+	AlsoArchive bool                   `json:"-" bson:"alsoArchive,omitempty"`
+	Results     []UnsubscribeJobResult `json:"results,omitempty" bson:"results,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AnalysisCacheEntry memoizes an AI verdict for a (version, from, subject)
+// fingerprint so identical emails are never analyzed twice. It lives in two
+// tiers: a per-user overlay (UserID set) holding the full verdict, consulted
+// first, and a shared global tier (UserID empty) holding action-only verdicts —
+// labels are user vocabulary and never leak across accounts. Both tiers expire
+// on ExpiresAt through a TTL index.
 type AnalysisCacheEntry struct {
 	ID         string    `bson:"_id,omitempty"`
-	Key        string    `bson:"key"`
-	Action     string    `bson:"action"`
-	LabelName  string    `bson:"labelName"`
-	Confidence float64   `bson:"confidence"`
-	Reasoning  string    `bson:"reasoning"`
-	CreatedAt  time.Time `bson:"createdAt"`
+	UserID     string    `json:"userId,omitempty" bson:"userId,omitempty"`
+	Key        string    `json:"key" bson:"key"`
+	Version    string    `json:"version" bson:"version"`
+	Action     string    `json:"action" bson:"action"`
+	LabelName  string    `json:"labelName,omitempty" bson:"labelName"`
+	Confidence float64   `json:"confidence" bson:"confidence"`
+	Reasoning  string    `json:"reasoning" bson:"reasoning"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// ActionItem is a task a summarized email asks of someone.
+type ActionItem struct {
+	Task  string `json:"task" bson:"task"`
+	Owner string `json:"owner,omitempty" bson:"owner,omitempty"`
+}
+
+// Deadline is a dated commitment found in an email. Date is YYYY-MM-DD.
+type Deadline struct {
+	Description string `json:"description" bson:"description"`
+	Date        string `json:"date" bson:"date"`
+}
+
+// Amount is a sum of money mentioned in an email.
+type Amount struct {
+	Description string  `json:"description" bson:"description"`
+	Value       float64 `json:"value" bson:"value"`
+	Currency    string  `json:"currency" bson:"currency"`
+}
+
+// EmailSummary caches the AI summary of a message (Scope "message") or of the
+// thread ending at that message (Scope "thread"), keyed by message id: a new
+// reply has a new id, so a thread summary is naturally recomputed when the
+// conversation moves on. Version pins the prompt revision it was made with.
+type EmailSummary struct {
+	ID          string       `json:"id" bson:"_id,omitempty"`
+	UserID      string       `json:"userId" bson:"userId"`
+	MessageID   string       `json:"messageId" bson:"messageId"`
+	ThreadID    string       `json:"threadId,omitempty" bson:"threadId,omitempty"`
+	Scope       string       `json:"scope" bson:"scope"` // "message" or "thread"
+	Messages    int          `json:"messages" bson:"messages"`
+	Version     string       `json:"version" bson:"version"`
+	Summary     string       `json:"summary" bson:"summary"`
+	ActionItems []ActionItem `json:"actionItems" bson:"actionItems"`
+	Deadlines   []Deadline   `json:"deadlines" bson:"deadlines"`
+	Amounts     []Amount     `json:"amounts" bson:"amounts"`
+	CreatedAt   time.Time    `json:"createdAt" bson:"createdAt"`
+}
+
+// EmailEmbedding is the vector an email was embedded into, under a given
+// model. Vectors from different models are never compared, so a model change
+// simply re-embeds on the next clustering run.
+type EmailEmbedding struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"userId" bson:"userId"`
+	MessageID string    `json:"messageId" bson:"messageId"`
+	Model     string    `json:"model" bson:"model"`
+	Vector    []float32 `json:"vector" bson:"vector"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// LabelSample is one representative email of a proposed label.
+type LabelSample struct {
+	MessageID string `json:"messageId" bson:"messageId"`
+	From      string `json:"from" bson:"from"`
+	Subject   string `json:"subject" bson:"subject"`
+}
+
+// LabelProposal is a new label discovered by clustering the inbox: a coherent
+// group of emails no existing label covers, with sample messages and, when
+// the group shares a sender domain or a subject term, a suggested rule that
+// would keep labelling its future emails. Status is pending until accepted
+// (which creates the SmartLabel and optionally the rule) or dismissed.
+type LabelProposal struct {
+	ID          string            `json:"id" bson:"_id,omitempty"`
+	UserID      string            `json:"userId" bson:"userId"`
+	Name        string            `json:"name" bson:"name"`
+	Description string            `json:"description" bson:"description"`
+	Keywords    []string          `json:"keywords" bson:"keywords"`
+	Size        int               `json:"size" bson:"size"`
+	Cohesion    float64           `json:"cohesion" bson:"cohesion"`
+	Samples     []LabelSample     `json:"samples" bson:"samples"`
+	MessageIDs  []string          `json:"-" bson:"messageIds"`
+	Rule        *SortingRuleInput `json:"rule,omitempty" bson:"rule,omitempty"`
+	Status      string            `json:"status" bson:"status"` // pending, accepted, dismissed
+	CreatedAt   time.Time         `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AcceptLabelProposalRequest is the request body for accepting a proposal.
+// Name overrides the proposed name; CreateRule also saves the suggested rule.
+type AcceptLabelProposalRequest struct {
+	Name       string `json:"name"`
+	CreateRule bool   `json:"createRule"`
 }
 
 // ============================================
@@ -362,6 +730,24 @@ type AnalyzeSenderRequest struct {
 	SenderEmail string `json:"senderEmail"`
 }
 
+// SummarizeRequest is the request body for POST /api/ai/summarize. Thread
+// summarizes the whole conversation the message belongs to; SnoozeUntilDeadline
+// snoozes the message until the morning before the earliest future deadline.
+type SummarizeRequest struct {
+	MessageID           string `json:"messageId"`
+	Thread              bool   `json:"thread"`
+	SnoozeUntilDeadline bool   `json:"snoozeUntilDeadline"`
+	Refresh             bool   `json:"refresh"` // bypass the cache
+}
+
+// DraftReplyRequest is the request body for POST /api/ai/draft-reply. Tone
+// overrides the user's configured tone for this draft only.
+type DraftReplyRequest struct {
+	MessageID    string `json:"messageId"`
+	Instructions string `json:"instructions"`
+	Tone         string `json:"tone"`
+}
+
 // ApplySuggestionRequest is the request body for POST /api/ai/apply
 type ApplySuggestionRequest struct {
 	SuggestionID string `json:"suggestionId"`
@@ -389,14 +775,48 @@ type UpdateSenderPreferenceRequest struct {
 // Unsubscribe records a completed or assisted unsubscribe from a mailing-list
 // sender, keyed by (userId, senderEmail) so it is idempotent.
 type Unsubscribe struct {
-	ID          string    `json:"id" bson:"_id,omitempty"`
-	UserID      string    `json:"userId" bson:"userId"`
-	SenderEmail string    `json:"senderEmail" bson:"senderEmail"`
-	SenderName  string    `json:"senderName" bson:"senderName"`
-	Method      string    `json:"method" bson:"method"` // "one-click", "browser", "mailto"
-	Status      string    `json:"status" bson:"status"` // "done", "opened"
-	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+	ID          string `json:"id" bson:"_id,omitempty"`
+	UserID      string `json:"userId" bson:"userId"`
+	SenderEmail string `json:"senderEmail" bson:"senderEmail"`
+	SenderName  string `json:"senderName" bson:"senderName"`
+	Method      string `json:"method" bson:"method"` // "one-click", "mailto-sent", "browser", "mailto"
+	Status      string `json:"status" bson:"status"` // "done", "opened"
+	// DoneAt is when the unsubscribe last completed; mail received more than
+	// a grace period later counts as ignored (IgnoredCount, LastIgnoredAt).
+	DoneAt        time.Time `json:"doneAt,omitempty" bson:"doneAt,omitempty"`
+	IgnoredCount  int       `json:"ignoredCount" bson:"ignoredCount,omitempty"`
+	LastIgnoredAt time.Time `json:"lastIgnoredAt,omitempty" bson:"lastIgnoredAt,omitempty"`
+	// Attempts audits the latest server-side one-click POSTs, newest last.
+	Attempts  []UnsubscribeAttempt `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	CreatedAt time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// UnsubscribeAttempt is one one-click unsubscribe POST. Host is the endpoint's
+// host only: the full URL usually carries a per-recipient token. StatusCode is
+// 0 when the request was refused or no response came back (see Error).
+type UnsubscribeAttempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	Host       string    `json:"host" bson:"host"`
+	StatusCode int       `json:"statusCode" bson:"statusCode"`
+	LatencyMs  int64     `json:"latencyMs" bson:"latencyMs"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// UnsubscribeViolation is one email received from a sender after the grace
+// period that followed a completed unsubscribe, kept as evidence the user can
+// export for a complaint (e.g. to the CNIL).
+type UnsubscribeViolation struct {
+	ID             string    `json:"id" bson:"_id,omitempty"`
+	UserID         string    `json:"userId" bson:"userId"`
+	SenderEmail    string    `json:"senderEmail" bson:"senderEmail"`
+	SenderName     string    `json:"senderName" bson:"senderName"`
+	MessageID      string    `json:"messageId" bson:"messageId"`
+	Subject        string    `json:"subject" bson:"subject"`
+	ReceivedAt     time.Time `json:"receivedAt" bson:"receivedAt"`
+	UnsubscribedAt time.Time `json:"unsubscribedAt" bson:"unsubscribedAt"`
+	Method         string    `json:"method" bson:"method"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
 }
 
 // UnsubscribeRequest is the request body for POST /api/unsubscribe
@@ -405,6 +825,25 @@ type UnsubscribeRequest struct {
 	AlsoArchive bool   `json:"alsoArchive"`
 }
 
+// BulkUnsubscribeRequest is the request body for POST /api/unsubscribe/bulk
+type BulkUnsubscribeRequest struct {
+	Senders     []string `json:"senders"`
+	AlsoArchive bool     `json:"alsoArchive"`
+}
+
+// UnsubscribeJobResult is the outcome of a bulk unsubscribe for one sender.
+// When not Done, URL or Mailto is what the user still has to open.
+type UnsubscribeJobResult struct {
+	Sender    string `json:"sender" bson:"sender"`
+	MessageID string `json:"messageId,omitempty" bson:"messageId,omitempty"`
+	Method    string `json:"method,omitempty" bson:"method,omitempty"`
+	Done      bool   `json:"done" bson:"done"`
+	URL       string `json:"url,omitempty" bson:"url,omitempty"`
+	Mailto    string `json:"mailto,omitempty" bson:"mailto,omitempty"`
+	Archived  int    `json:"archived" bson:"archived"`
+	Error     string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
 // Subscription is an aggregated mailing-list sender that advertises an
 // unsubscribe link, returned by GET /api/subscriptions.
 type Subscription struct {
@@ -415,13 +854,35 @@ type Subscription struct {
 	SampleMessageID string    `json:"sampleMessageId"`
 	OneClick        bool      `json:"oneClick"`
 	Unsubscribed    bool      `json:"unsubscribed"`
-}
-
-// SenderStats represents aggregated info about a sender
-type SenderStats struct {
-	SenderEmail  string            `json:"senderEmail"`
-	SenderDomain string            `json:"senderDomain"`
-	SenderName   string            `json:"senderName"`
-	EmailCount   int               `json:"emailCount"`
-	Preference   *SenderPreference `json:"preference,omitempty"`
+	// IgnoringUnsubscribe flags a sender still mailing after the grace period
+	// that followed the user's unsubscribe; IgnoredCount is how many emails.
+	IgnoringUnsubscribe bool `json:"ignoringUnsubscribe"`
+	IgnoredCount        int  `json:"ignoredCount"`
+}
+
+// Sender aggregates everything synced from one address, keyed by
+// (userId, senderEmail) and maintained incrementally at sync time, so sender
+// views never group the mailbox mirror on the fly. GET /api/senders and
+// GET /api/subscriptions page through it.
+type Sender struct {
+	ID           string `json:"id" bson:"_id,omitempty"`
+	UserID       string `json:"userId" bson:"userId"`
+	SenderEmail  string `json:"senderEmail" bson:"senderEmail"` // bare, lower-cased address
+	SenderDomain string `json:"senderDomain" bson:"senderDomain"`
+	SenderName   string `json:"senderName" bson:"senderName"` // latest display name
+	EmailCount   int    `json:"emailCount" bson:"emailCount"`
+	UnreadCount  int    `json:"unreadCount" bson:"unreadCount"`
+	// UnreadRate is UnreadCount/EmailCount, stored so it can be sorted on.
+	UnreadRate float64   `json:"unreadRate" bson:"unreadRate"`
+	FirstSeen  time.Time `json:"firstSeen" bson:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen" bson:"lastSeen"`
+	// Unsubscribe capability, from the newest email advertising it.
+	CanUnsubscribe  bool   `json:"canUnsubscribe" bson:"canUnsubscribe"`
+	OneClick        bool   `json:"oneClick" bson:"oneClick"`
+	SampleMessageID string `json:"sampleMessageId,omitempty" bson:"sampleMessageId,omitempty"`
+	// LastAction is the last Gmail action Mailsorter took on this sender's mail.
+	LastAction   string            `json:"lastAction,omitempty" bson:"lastAction,omitempty"`
+	LastActionAt time.Time         `json:"lastActionAt,omitempty" bson:"lastActionAt,omitempty"`
+	UpdatedAt    time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Preference   *SenderPreference `json:"preference,omitempty" bson:"-"`
 }
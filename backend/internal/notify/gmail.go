@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
+)
+
+// Gmail sends the message from the user's own mailbox to the message's To,
+// through SendRaw — in production the Gmail API's send call, which needs the
+// gmail.send scope. SendRaw should mark refusals that retrying will not fix
+// (a token without that scope) with Permanent.
+type Gmail struct {
+	From    string
+	SendRaw func(ctx context.Context, raw string) error
+}
+
+// Send builds m with mailer.BuildRaw and hands it to SendRaw.
+func (g *Gmail) Send(ctx context.Context, m Message) error {
+	return g.SendRaw(ctx, mailer.BuildRaw(g.From, m.To, m.Subject, m.Text, m.HTML))
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Events a hook can subscribe to.
+const (
+	HookActionLogged    = "action.logged"    // an entry was added to the action ledger
+	HookRuleFired       = "rule.fired"       // sorting rules acted on the mailbox
+	HookUnsubscribeDone = "unsubscribe.done" // an unsubscribe went through
+	HookJobFinished     = "job.finished"     // an async job ended, done or in error
+	HookDigestSent      = "digest.sent"      // the digest went out
+	HookPing            = "ping"             // sent on request, whatever the hook's events
+)
+
+// HookEvents lists the events a hook can subscribe to, in documentation order.
+var HookEvents = []string{HookActionLogged, HookRuleFired, HookUnsubscribeDone, HookJobFinished, HookDigestSent}
+
+// ValidHookEvent reports whether a hook can subscribe to event.
+func ValidHookEvent(event string) bool {
+	for _, e := range HookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Headers on a hook delivery. HeaderHookSignature follows Stripe's scheme
+// (see billing.ConstructEvent): "t=<unix seconds>,v1=<hex HMAC-SHA256 of
+// "<t>.<body>">", so receivers can reuse the verification they already have.
+const (
+	HeaderHookSignature = "Mailsorter-Signature"
+	HeaderHookDelivery  = "X-Mailsorter-Delivery"
+)
+
+// HookMaxAttempts is how many times a hook delivery is tried before giving up.
+const HookMaxAttempts = 8
+
+// Hook retry backoff: hookBaseDelay after the first failure, doubling each
+// time up to hookMaxDelay — about four hours from first attempt to last.
+const (
+	hookBaseDelay = time.Minute
+	hookMaxDelay  = 2 * time.Hour
+)
+
+// HookRetryAt returns when a hook delivery that has failed `attempts` times,
+// the last time with err, should be tried again — and false when it should be
+// given up: the failure is permanent or HookMaxAttempts is spent.
+func HookRetryAt(attempts int, err error, now time.Time) (time.Time, bool) {
+	if IsPermanent(err) || attempts < 1 || attempts >= HookMaxAttempts {
+		return time.Time{}, false
+	}
+	delay := hookBaseDelay
+	for i := 1; i < attempts && delay < hookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > hookMaxDelay {
+		delay = hookMaxDelay
+	}
+	return now.Add(delay), true
+}
+
+// SignHook returns the HeaderHookSignature value for body sent at timestamp
+// (Unix seconds).
+func SignHook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Hook posts event payloads to a user's endpoint.
+type Hook struct {
+	URL    string
+	Secret string
+	Client *http.Client // nil: http.DefaultClient
+}
+
+// Send posts body, the JSON payload of one event, signed as of now. It returns
+// the endpoint's response status (0 when it did not answer); failures are
+// classified like the digest webhook's (see post).
+func (h *Hook) Send(ctx context.Context, event, deliveryID string, body []byte, now time.Time) (int, error) {
+	if h.Secret == "" {
+		return 0, Permanent(fmt.Errorf("hook has no signing secret"))
+	}
+	return post(ctx, h.Client, h.URL, body, map[string]string{
+		HeaderEvent:         event,
+		HeaderHookDelivery:  deliveryID,
+		HeaderHookSignature: SignHook(h.Secret, now.Unix(), body),
+	})
+}
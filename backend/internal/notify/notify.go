@@ -0,0 +1,91 @@
+// Package notify delivers Mailsorter's notifications — today, the digest —
+// over the channels a user picks: their own Gmail account, an SMTP relay run
+// by the operator, a generic webhook (signed JSON) or a Slack-compatible
+// incoming webhook.
+//
+// Each channel is a Driver. Drivers hold no database and no scheduler: they
+// make one attempt and classify its failure as transient (worth retrying
+// later) or permanent (see Permanent). Recording attempts and retrying them
+// belongs to the caller, with RetryAt deciding when. Drivers talk to plain
+// addresses and URLs, so tests run them against local stand-in servers.
+//
+// It also carries event hooks (see Hook): endpoints a user registers to
+// receive Mailsorter events as signed JSON, for automation platforms.
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Channels a user can receive notifications on.
+const (
+	ChannelGmail   = "gmail"   // sent from and to the user's Gmail account (gmail.send scope)
+	ChannelSMTP    = "smtp"    // sent to the user's address through the operator's relay
+	ChannelWebhook = "webhook" // signed JSON POST to a URL of the user's
+	ChannelSlack   = "slack"   // Slack-compatible incoming webhook
+)
+
+// ValidChannel reports whether name is a known channel.
+func ValidChannel(name string) bool {
+	switch name {
+	case ChannelGmail, ChannelSMTP, ChannelWebhook, ChannelSlack:
+		return true
+	}
+	return false
+}
+
+// Message is one notification. To is the recipient address, used by the email
+// channels; the webhook channels post Subject and Text (and HTML for the
+// generic webhook).
+type Message struct {
+	Kind    string    `json:"event"` // what it is about, e.g. "digest"
+	To      string    `json:"-"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	HTML    string    `json:"html,omitempty"`
+	At      time.Time `json:"sentAt"`
+}
+
+// Driver delivers a Message over one channel, in a single attempt.
+type Driver interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// permanentError marks a failure that retrying will not fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: a rejected recipient, a webhook
+// answering 404, a Gmail token without the send scope.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// MaxAttempts is how many times a delivery is tried before giving up.
+const MaxAttempts = 4
+
+// retryDelays are the waits after the first, second and third failed attempt.
+var retryDelays = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// RetryAt returns when a delivery that has failed `attempts` times, the last
+// time with err, should be tried again — and false when it should be given
+// up: the failure is permanent or MaxAttempts is spent.
+func RetryAt(attempts int, err error, now time.Time) (time.Time, bool) {
+	if IsPermanent(err) || attempts < 1 || attempts >= MaxAttempts {
+		return time.Time{}, false
+	}
+	return now.Add(retryDelays[attempts-1]), true
+}
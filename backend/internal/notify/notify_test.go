@@ -0,0 +1,373 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/billing"
+)
+
+var sample = Message{
+	Kind:    "digest",
+	To:      "me@example.com",
+	Subject: "Mailsorter — 3 emails triés aujourd'hui",
+	Text:    "Aujourd'hui : 3 emails triés.",
+	HTML:    "<p>3 emails triés</p>",
+	At:      time.Date(2026, 6, 21, 7, 0, 0, 0, time.UTC),
+}
+
+func TestWebhookSignsBody(t *testing.T) {
+	var got Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if want := SignHook("s3cret", sample.At.Unix(), body); r.Header.Get(HeaderHookSignature) != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get(HeaderHookSignature), want)
+		}
+		if r.Header.Get(HeaderEvent) != "digest" {
+			t.Errorf("event = %q", r.Header.Get(HeaderEvent))
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	clock := func() time.Time { return sample.At }
+	if err := (&Webhook{URL: srv.URL, Secret: "s3cret", Now: clock}).Send(context.Background(), sample); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Subject != sample.Subject || got.HTML != sample.HTML || !got.At.Equal(sample.At) {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+// A retried digest keeps its original sentAt but is signed at the retry, so it
+// still passes the receiver's timestamp tolerance.
+func TestWebhookRetryVerifies(t *testing.T) {
+	var body []byte
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		sig = r.Header.Get(HeaderHookSignature)
+	}))
+	defer srv.Close()
+
+	retry := sample
+	retry.At = time.Now().Add(-30 * time.Minute) // the delivery's creation time
+	if err := (&Webhook{URL: srv.URL, Secret: "whsec_test"}).Send(context.Background(), retry); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := billing.ConstructEvent(body, sig, "whsec_test"); err != nil {
+		t.Errorf("retried delivery fails verification: %v", err)
+	}
+	var got Message
+	json.Unmarshal(body, &got)
+	if !got.At.Equal(retry.At) {
+		t.Errorf("sentAt = %v, want the original %v", got.At, retry.At)
+	}
+}
+
+func TestWebhookClassifiesStatus(t *testing.T) {
+	cases := map[int]bool{ // status → permanent
+		http.StatusNotFound:            true,
+		http.StatusGone:                true,
+		http.StatusFound:               true, // redirects are not followed
+		http.StatusTooManyRequests:     false,
+		http.StatusBadGateway:          false,
+		http.StatusInternalServerError: false,
+	}
+	for code, permanent := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if code == http.StatusFound {
+				w.Header().Set("Location", "http://169.254.169.254/")
+			}
+			w.WriteHeader(code)
+		}))
+		client := NewHTTPClient(func(net.IP) bool { return true })
+		err := (&Slack{URL: srv.URL, Client: client}).Send(context.Background(), sample)
+		srv.Close()
+		if err == nil || IsPermanent(err) != permanent {
+			t.Errorf("%d: err = %v, permanent = %v, want %v", code, err, IsPermanent(err), permanent)
+		}
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	if err := (&Slack{URL: srv.URL}).Send(context.Background(), sample); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if want := "*" + sample.Subject + "*\n\n" + sample.Text; got["text"] != want {
+		t.Errorf("text = %q, want %q", got["text"], want)
+	}
+}
+
+func TestHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+	client := NewHTTPClient(func(ip net.IP) bool { return !ip.IsLoopback() })
+	err := (&Webhook{URL: srv.URL, Client: client}).Send(context.Background(), sample)
+	if !errors.Is(err, ErrUnsafeURL) || !IsPermanent(err) {
+		t.Errorf("err = %v, want a permanent ErrUnsafeURL", err)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	public := func(ip net.IP) bool { return !ip.IsLoopback() && !ip.IsPrivate() }
+	for raw, ok := range map[string]bool{
+		"https://hooks.slack.com/services/T/B/X": true,
+		"http://hooks.example.com/x":             false,
+		"https://127.0.0.1/x":                    false,
+		"https://10.0.0.8/x":                     false,
+		"hooks.example.com/x":                    false,
+	} {
+		if err := CheckURL(raw, public); (err == nil) != ok {
+			t.Errorf("CheckURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	now := time.Date(2026, 6, 21, 7, 0, 0, 0, time.UTC)
+	transient := errors.New("connection reset")
+	if at, ok := RetryAt(1, transient, now); !ok || !at.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("first retry = %v %v", at, ok)
+	}
+	if at, ok := RetryAt(3, transient, now); !ok || !at.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("third retry = %v %v", at, ok)
+	}
+	if _, ok := RetryAt(MaxAttempts, transient, now); ok {
+		t.Error("retried past MaxAttempts")
+	}
+	if _, ok := RetryAt(1, Permanent(transient), now); ok {
+		t.Error("retried a permanent failure")
+	}
+}
+
+func TestHookRetryAtBacksOffExponentially(t *testing.T) {
+	now := time.Date(2026, 6, 21, 7, 0, 0, 0, time.UTC)
+	transient := errors.New("connection reset")
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, 64 * time.Minute}
+	for i, d := range want {
+		if at, ok := HookRetryAt(i+1, transient, now); !ok || !at.Equal(now.Add(d)) {
+			t.Errorf("retry after attempt %d = %v %v, want +%v", i+1, at, ok, d)
+		}
+	}
+	if _, ok := HookRetryAt(HookMaxAttempts, transient, now); ok {
+		t.Error("retried past HookMaxAttempts")
+	}
+	if _, ok := HookRetryAt(1, Permanent(transient), now); ok {
+		t.Error("retried a permanent failure")
+	}
+}
+
+// A hook delivery verifies with the same code that checks Stripe's webhooks.
+func TestHookSignatureMatchesStripeScheme(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"rule.fired","data":{"object":{}}}`)
+	var gotSig, gotEvent, gotDelivery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(HeaderHookSignature)
+		gotEvent, gotDelivery = r.Header.Get(HeaderEvent), r.Header.Get(HeaderHookDelivery)
+		if b, _ := io.ReadAll(r.Body); string(b) != string(body) {
+			t.Errorf("body = %s", b)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	hook := &Hook{URL: srv.URL, Secret: "whsec_test"}
+	if code, err := hook.Send(context.Background(), HookRuleFired, "d1", body, now); err != nil || code != http.StatusOK {
+		t.Fatalf("Send = %d, %v", code, err)
+	}
+	if gotEvent != HookRuleFired || gotDelivery != "d1" {
+		t.Errorf("headers event=%q delivery=%q", gotEvent, gotDelivery)
+	}
+	if _, err := billing.ConstructEvent(body, gotSig, "whsec_test"); err != nil {
+		t.Errorf("billing.ConstructEvent rejects %q: %v", gotSig, err)
+	}
+	if _, err := billing.ConstructEvent(body, gotSig, "other"); err == nil {
+		t.Error("verified with the wrong secret")
+	}
+	if _, err := billing.ConstructEvent(body, SignHook("whsec_test", now.Add(-time.Hour).Unix(), body), "whsec_test"); err == nil {
+		t.Error("verified a stale signature")
+	}
+}
+
+func TestHookReportsStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+	code, err := (&Hook{URL: srv.URL, Secret: "s"}).Send(context.Background(), HookPing, "d", []byte("{}"), time.Now())
+	if code != http.StatusGone || !IsPermanent(err) {
+		t.Errorf("410 = %d, %v; want a permanent failure", code, err)
+	}
+}
+
+func TestSMTPSendsOverSTARTTLS(t *testing.T) {
+	srv := newSMTPStandIn(t, true)
+	relay := &SMTP{Addr: srv.addr, Username: "relay", Password: "pw", From: "digest@mailsorter.example", TLSConfig: srv.clientTLS}
+	if err := relay.Send(context.Background(), sample); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got := <-srv.done
+	if !got.tls || got.auth == "" {
+		t.Errorf("tls = %v, auth = %q: credentials must only travel over TLS", got.tls, got.auth)
+	}
+	if got.from != "<digest@mailsorter.example>" || got.rcpt != "<me@example.com>" {
+		t.Errorf("envelope = %s → %s", got.from, got.rcpt)
+	}
+	for _, want := range []string{"Date: Sun, 21 Jun 2026 07:00:00 +0000", "From: digest@mailsorter.example", "To: me@example.com", "Aujourd'hui : 3 emails triés.", "<p>3 emails triés</p>"} {
+		if !strings.Contains(got.data, want) {
+			t.Errorf("message missing %q\n%s", want, got.data)
+		}
+	}
+}
+
+func TestSMTPRequiresSTARTTLS(t *testing.T) {
+	srv := newSMTPStandIn(t, false)
+	err := (&SMTP{Addr: srv.addr, From: "digest@mailsorter.example"}).Send(context.Background(), sample)
+	if !errors.Is(err, ErrNoStartTLS) || !IsPermanent(err) {
+		t.Errorf("err = %v, want a permanent ErrNoStartTLS", err)
+	}
+}
+
+func TestSMTPRejectedRecipientIsPermanent(t *testing.T) {
+	srv := newSMTPStandIn(t, true)
+	m := sample
+	m.To = "unknown@example.com"
+	err := (&SMTP{Addr: srv.addr, From: "digest@mailsorter.example", TLSConfig: srv.clientTLS}).Send(context.Background(), m)
+	if err == nil || !IsPermanent(err) {
+		t.Errorf("err = %v, want permanent", err)
+	}
+}
+
+// smtpStandIn is a one-connection SMTP server speaking just enough of the
+// protocol (EHLO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA) for the driver.
+type smtpStandIn struct {
+	addr      string
+	clientTLS *tls.Config
+	done      chan smtpSession
+}
+
+type smtpSession struct {
+	tls                    bool
+	auth, from, rcpt, data string
+}
+
+func newSMTPStandIn(t *testing.T, offerTLS bool) *smtpStandIn {
+	t.Helper()
+	cert, pool := selfSignedCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpStandIn{
+		addr:      ln.Addr().String(),
+		clientTLS: &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"},
+		done:      make(chan smtpSession, 1),
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sess smtpSession
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 stand-in ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO":
+				if offerTLS && !sess.tls {
+					tp.PrintfLine("250-stand-in\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+				} else {
+					tp.PrintfLine("250-stand-in\r\n250 AUTH PLAIN")
+				}
+			case cmd == "STARTTLS":
+				tp.PrintfLine("220 go ahead")
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+				if tlsConn.Handshake() != nil {
+					return
+				}
+				conn, tp, sess.tls = tlsConn, textproto.NewConn(tlsConn), true
+			case cmd == "AUTH":
+				sess.auth = line
+				tp.PrintfLine("235 ok")
+			case cmd == "MAIL":
+				sess.from = strings.SplitN(line, ":", 2)[1]
+				tp.PrintfLine("250 ok")
+			case cmd == "RCPT":
+				sess.rcpt = strings.SplitN(line, ":", 2)[1]
+				if strings.Contains(line, "unknown") {
+					tp.PrintfLine("550 no such user")
+					continue
+				}
+				tp.PrintfLine("250 ok")
+			case cmd == "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, _ := io.ReadAll(tp.DotReader())
+				sess.data = string(data)
+				tp.PrintfLine("250 queued")
+			case cmd == "QUIT":
+				tp.PrintfLine("221 bye")
+				s.done <- sess
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return s
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/mailer"
+)
+
+// ErrNoStartTLS is returned by a relay that does not offer STARTTLS: the
+// credentials and the message would otherwise cross the network in clear.
+var ErrNoStartTLS = errors.New("smtp: server does not offer STARTTLS")
+
+// SMTP sends through a relay over STARTTLS, as From to the message's To. The
+// message is the same multipart/alternative email the Gmail channel sends
+// (see mailer.BuildRaw).
+type SMTP struct {
+	Addr     string // host:port, typically port 587
+	Username string // empty: no AUTH
+	Password string
+	From     string
+	// TLSConfig overrides the STARTTLS configuration; nil verifies the relay's
+	// certificate against the host part of Addr.
+	TLSConfig *tls.Config
+	Timeout   time.Duration // whole conversation; 0 means 30s
+}
+
+// Send delivers m in one SMTP conversation. A 5xx reply (unknown recipient,
+// rejected sender, failed AUTH) and a relay without STARTTLS are permanent.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return Permanent(fmt.Errorf("smtp: bad address %q: %w", s.Addr, err))
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		return Permanent(ErrNoStartTLS)
+	}
+	cfg := s.TLSConfig
+	if cfg == nil {
+		cfg = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	if err := c.StartTLS(cfg); err != nil {
+		return classifySMTP(err)
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return classifySMTP(err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return classifySMTP(err)
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return classifySMTP(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return classifySMTP(err)
+	}
+	if _, err := w.Write(s.message(m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return classifySMTP(err)
+	}
+	return c.Quit()
+}
+
+// message is m in wire format: BuildRaw's email, decoded, with the Date
+// header a relay expects from its submitter.
+func (s *SMTP) message(m Message) []byte {
+	raw, _ := base64.URLEncoding.DecodeString(mailer.BuildRaw(s.From, m.To, m.Subject, m.Text, m.HTML))
+	at := m.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return append([]byte("Date: "+at.Format(time.RFC1123Z)+"\r\n"), raw...)
+}
+
+// classifySMTP marks 5xx replies as permanent; 4xx (greylisting, a full
+// mailbox, a busy relay) and network errors stay transient.
+func classifySMTP(err error) error {
+	var tp *textproto.Error
+	if errors.As(err, &tp) && tp.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// HeaderEvent names the event of a webhook delivery, digest or hook. Both are
+// signed the same way, in HeaderHookSignature (see SignHook).
+const HeaderEvent = "X-Mailsorter-Event"
+
+// ErrUnsafeURL is returned for a webhook URL that is not https or whose host
+// is not a public address.
+var ErrUnsafeURL = errors.New("unsafe webhook URL")
+
+// Webhook POSTs the message as signed JSON to URL.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client     // nil: http.DefaultClient
+	Now    func() time.Time // nil: time.Now
+}
+
+// Send posts m, signed as of this attempt: a retry keeps the message's
+// original sentAt in the body but must carry a fresh signature timestamp, or
+// receivers reject it as stale. See post for how the response is classified.
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	if m.At.IsZero() {
+		m.At = time.Now()
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return Permanent(err)
+	}
+	now := time.Now
+	if w.Now != nil {
+		now = w.Now
+	}
+	_, err = post(ctx, w.Client, w.URL, body, map[string]string{
+		HeaderEvent:         m.Kind,
+		HeaderHookSignature: SignHook(w.Secret, now().Unix(), body),
+	})
+	return err
+}
+
+// Slack posts the message to a Slack-compatible incoming webhook (Slack,
+// Mattermost, Rocket.Chat…): the subject in bold over the plain-text body.
+type Slack struct {
+	URL    string
+	Client *http.Client // nil: http.DefaultClient
+}
+
+// Send posts m. See post for how the response is classified.
+func (s *Slack) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]string{"text": "*" + m.Subject + "*\n\n" + m.Text})
+	if err != nil {
+		return Permanent(err)
+	}
+	_, err = post(ctx, s.Client, s.URL, body, nil)
+	return err
+}
+
+// post sends a JSON body and returns the response status, 0 when there was
+// no response. A 2xx is success; 408, 429 and 5xx are transient, like network
+// errors; any other status is permanent (the URL is wrong or revoked, which
+// retrying will not fix).
+func post(ctx context.Context, client *http.Client, target string, body []byte, headers map[string]string) (int, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mailsorter-Notify/1.0")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Drop the URL from the error: for a Slack webhook it is the credential,
+		// and errors end up in delivery records.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = fmt.Errorf("webhook request failed: %w", uerr.Err)
+		}
+		if errors.Is(err, ErrUnsafeURL) {
+			return 0, Permanent(err)
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return code, nil
+	case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500:
+		return code, fmt.Errorf("webhook answered %d", code)
+	default:
+		return code, Permanent(fmt.Errorf("webhook answered %d", code))
+	}
+}
+
+// CheckURL validates a webhook URL when a user saves it: https, with a host
+// that is not a literal non-public address. Names are checked again on the
+// resolved address at connect time (see NewHTTPClient).
+func CheckURL(raw string, allow func(net.IP) bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: not an absolute URL", ErrUnsafeURL)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrUnsafeURL, u.Scheme)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allow(ip) {
+		return fmt.Errorf("%w: %s", ErrUnsafeURL, ip)
+	}
+	return nil
+}
+
+// NewHTTPClient returns the client webhook deliveries use: users choose the
+// URLs, so it only dials addresses allow accepts (checked on the resolved IP,
+// so DNS rebinding cannot slip past) and follows no redirects.
+func NewHTTPClient(allow func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !allow(ip) {
+				return fmt.Errorf("%w: %s", ErrUnsafeURL, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,209 @@
+// Package priority ranks what stays in the inbox. Everywhere else Mailsorter
+// decides what to take out; here each email gets an importance score from 0 to
+// 100 built from signals the user already gave away by how they use their
+// mailbox: who they write to, which threads they took part in, who they
+// protect, whether they were addressed directly, and whether the subject
+// carries a deadline. An AI verdict, when there is one, nudges the score.
+//
+// Scoring is pure and explainable: Score returns the reasons next to the
+// number, so the UI can say why a message ranks where it does.
+package priority
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Points awarded per signal. The base keeps an unremarkable email above zero
+// so a negative AI verdict still ranks it below its neighbours.
+const (
+	pointsBase       = 10
+	pointsVIP        = 30
+	pointsReplied    = 15 // the user wrote to this sender before
+	pointsRepliedMax = 25 // ... at least repliedOften times
+	pointsThread     = 15
+	pointsDirect     = 10
+	pointsDeadline   = 15
+	pointsAIKeep     = 10
+	pointsAIDiscard  = 20
+
+	repliedOften = 3
+)
+
+// Reasons reported by Score.
+const (
+	ReasonVIP      = "vip"
+	ReasonReplied  = "replied"
+	ReasonThread   = "thread"
+	ReasonDirect   = "direct"
+	ReasonDeadline = "deadline"
+	ReasonAIKeep   = "ai-keep"
+	ReasonAILow    = "ai-low"
+)
+
+// Signals are the inputs for one email.
+type Signals struct {
+	VIP             bool // sender is on the protected list
+	RepliesToSender int  // messages the user sent to this sender
+	InThread        bool // the user has written in this thread
+	Direct          bool // the user is in To, not only Cc/Bcc or a list
+	Deadline        bool // the subject announces a deadline
+	// AIAction and AIConfidence carry the AI verdict when one exists; an empty
+	// action leaves the score untouched.
+	AIAction     string
+	AIConfidence float64
+}
+
+// Result is a score with the signals that produced it.
+type Result struct {
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Score computes the importance of an email from its signals.
+func Score(s Signals) Result {
+	score := float64(pointsBase)
+	reasons := []string{}
+	add := func(points float64, reason string) {
+		score += points
+		reasons = append(reasons, reason)
+	}
+
+	if s.VIP {
+		add(pointsVIP, ReasonVIP)
+	}
+	switch {
+	case s.RepliesToSender >= repliedOften:
+		add(pointsRepliedMax, ReasonReplied)
+	case s.RepliesToSender > 0:
+		add(pointsReplied, ReasonReplied)
+	}
+	if s.InThread {
+		add(pointsThread, ReasonThread)
+	}
+	if s.Direct {
+		add(pointsDirect, ReasonDirect)
+	}
+	if s.Deadline {
+		add(pointsDeadline, ReasonDeadline)
+	}
+	switch s.AIAction {
+	case "keep":
+		add(pointsAIKeep*s.AIConfidence, ReasonAIKeep)
+	case "archive", "delete":
+		add(-pointsAIDiscard*s.AIConfidence, ReasonAILow)
+	}
+
+	n := int(score + 0.5)
+	if n < 0 {
+		n = 0
+	}
+	if n > 100 {
+		n = 100
+	}
+	return Result{Score: n, Reasons: reasons}
+}
+
+// History is what the user's sent mail says about their correspondents.
+type History struct {
+	sent    map[string]int
+	threads map[string]bool
+}
+
+// NewHistory returns an empty History.
+func NewHistory() *History {
+	return &History{sent: map[string]int{}, threads: map[string]bool{}}
+}
+
+// AddSent records one message the user sent in threadID. headers are its raw
+// To/Cc values; each may hold several comma-separated addresses.
+func (h *History) AddSent(threadID string, headers ...string) {
+	if threadID != "" {
+		h.threads[threadID] = true
+	}
+	seen := map[string]bool{}
+	for _, v := range headers {
+		for _, addr := range Addresses(v) {
+			if !seen[addr] {
+				seen[addr] = true
+				h.sent[addr]++
+			}
+		}
+	}
+}
+
+// SentTo returns how many messages the user sent to the address in from.
+func (h *History) SentTo(from string) int {
+	addrs := Addresses(from)
+	if len(addrs) == 0 {
+		return 0
+	}
+	return h.sent[addrs[0]]
+}
+
+// Correspondents returns every address the user wrote to, in no particular
+// order.
+func (h *History) Correspondents() []string {
+	out := make([]string, 0, len(h.sent))
+	for addr := range h.sent {
+		out = append(out, addr)
+	}
+	return out
+}
+
+// InThread reports whether the user wrote in threadID.
+func (h *History) InThread(threadID string) bool {
+	return threadID != "" && h.threads[threadID]
+}
+
+// Addresses extracts the lower-cased addresses from a header value such as
+// `"Doe, Jane" <jane@x.com>, bob@y.com`. Unparseable values fall back to a
+// plain comma split.
+func Addresses(header string) []string {
+	var out []string
+	if list, err := mail.ParseAddressList(header); err == nil {
+		for _, a := range list {
+			out = append(out, strings.ToLower(a.Address))
+		}
+		return out
+	}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if i := strings.LastIndex(part, "<"); i >= 0 {
+			part = strings.TrimSuffix(part[i+1:], ">")
+		}
+		if strings.Contains(part, "@") {
+			out = append(out, strings.ToLower(strings.TrimSpace(part)))
+		}
+	}
+	return out
+}
+
+// Addressed reports whether me appears in the To headers of an email.
+func Addressed(to []string, me string) bool {
+	me = strings.ToLower(strings.TrimSpace(me))
+	for _, v := range to {
+		for _, addr := range Addresses(v) {
+			if addr == me {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deadlineWords are subject markers of something due, in the supported
+// locales (fr, en, de). Word boundaries are spelled out because \b only knows
+// ASCII letters ("échéance").
+var deadlineWords = regexp.MustCompile(`(?i)(^|[^\p{L}])(urgent|asap|deadline|due|overdue|expires?|avant le|échéance|dernier rappel|date limite|relance|dringend|frist|fällig|bis zum|letzte erinnerung)($|[^\p{L}])`)
+
+// deadlineDate matches a numeric day/month date introduced by "before/by"
+// ("à payer avant le 12/03", "reply by 5.6"). Dates in words are left to the
+// AI summary.
+var deadlineDate = regexp.MustCompile(`(?i)(^|[^\p{L}])(avant|before|by|bis)[^0-9]{0,12}\d{1,2}[/.]\d{1,2}`)
+
+// SubjectDeadline reports whether a subject announces a deadline.
+func SubjectDeadline(subject string) bool {
+	return deadlineWords.MatchString(subject) || deadlineDate.MatchString(subject)
+}
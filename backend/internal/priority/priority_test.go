@@ -0,0 +1,86 @@
+package priority
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreSignals(t *testing.T) {
+	plain := Score(Signals{})
+	if plain.Score != pointsBase || len(plain.Reasons) != 0 {
+		t.Fatalf("plain email = %+v", plain)
+	}
+
+	boss := Score(Signals{VIP: true, RepliesToSender: 5, InThread: true, Direct: true, Deadline: true})
+	if boss.Score != 100 {
+		t.Errorf("everything on = %d, want capped 100", boss.Score)
+	}
+	want := []string{ReasonVIP, ReasonReplied, ReasonThread, ReasonDirect, ReasonDeadline}
+	if !reflect.DeepEqual(boss.Reasons, want) {
+		t.Errorf("reasons = %v, want %v", boss.Reasons, want)
+	}
+
+	once := Score(Signals{RepliesToSender: 1, Direct: true})
+	often := Score(Signals{RepliesToSender: 4, Direct: true})
+	if once.Score >= often.Score {
+		t.Errorf("frequent correspondent (%d) should outrank a one-off (%d)", often.Score, once.Score)
+	}
+}
+
+func TestScoreAIVerdict(t *testing.T) {
+	keep := Score(Signals{AIAction: "keep", AIConfidence: 1})
+	if keep.Score != pointsBase+pointsAIKeep {
+		t.Errorf("keep = %d", keep.Score)
+	}
+	promo := Score(Signals{AIAction: "archive", AIConfidence: 0.9})
+	if promo.Score != 0 || promo.Reasons[0] != ReasonAILow {
+		t.Errorf("archive verdict = %+v, want clamped to 0", promo)
+	}
+	if Score(Signals{AIAction: "label", AIConfidence: 1}).Score != pointsBase {
+		t.Error("a label verdict should not move the score")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := NewHistory()
+	h.AddSent("t1", `"Doe, Jane" <Jane@Example.com>, bob@y.com`, "jane@example.com")
+	h.AddSent("t2", "Jane <jane@example.com>")
+	if got := h.SentTo("Jane Doe <JANE@example.com>"); got != 2 {
+		t.Errorf("SentTo(jane) = %d, want 2 (one per message)", got)
+	}
+	if h.SentTo("bob@y.com") != 1 || h.SentTo("nobody@z.com") != 0 {
+		t.Error("SentTo miscounts")
+	}
+	if !h.InThread("t2") || h.InThread("t3") || h.InThread("") {
+		t.Error("InThread")
+	}
+}
+
+func TestAddressed(t *testing.T) {
+	to := []string{"Team <team@corp.com>, Me <me@corp.com>"}
+	if !Addressed(to, "ME@corp.com") {
+		t.Error("direct recipient not detected")
+	}
+	if Addressed([]string{"team@corp.com"}, "me@corp.com") {
+		t.Error("list mail reported as direct")
+	}
+}
+
+func TestSubjectDeadline(t *testing.T) {
+	for _, s := range []string{
+		"URGENT : contrat à signer",
+		"Facture — échéance dépassée",
+		"Please reply by 12/03",
+		"Rechnung fällig",
+		"À payer avant le 5.6",
+	} {
+		if !SubjectDeadline(s) {
+			t.Errorf("SubjectDeadline(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"Newsletter de mars", "Produit en duel", "Votre reçu"} {
+		if SubjectDeadline(s) {
+			t.Errorf("SubjectDeadline(%q) = true", s)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+// Package risk scores how likely an email is to be phishing or spoofed, from
+// what its headers prove and what its sender pretends to be.
+//
+// Two families of evidence are combined. Authentication verdicts written by
+// the receiving server (Authentication-Results, Received-SPF) tell whether the
+// From domain really sent the message. Impersonation heuristics compare the
+// sender with who the user actually corresponds with: a display name that
+// borrows a brand or a known correspondent ("PayPal" <x@randomdomain>), a
+// lookalike domain (paypa1.com, rn for m, Cyrillic letters, one typo away
+// from a known domain) and a Reply-To that diverts answers elsewhere.
+//
+// Assess returns a 0–100 score with the reasons behind it. Everything here is
+// pure; callers gather the headers and the user's known domains.
+package risk
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// High is the score from which an email is treated as risky: the AI is warned
+// and analysis caches are bypassed.
+const High = 50
+
+// Reasons reported by Assess.
+const (
+	ReasonAuthFail    = "auth-fail"          // DMARC failed, or SPF failed without a passing DKIM signature
+	ReasonAuthWeak    = "auth-weak"          // SPF soft-failed or DKIM failed, DMARC did not decide
+	ReasonDisplayName = "display-name-spoof" // the display name claims a brand or another domain
+	ReasonLookalike   = "lookalike-domain"   // the domain imitates a known one
+	ReasonReplyTo     = "reply-to-mismatch"  // answers go to another domain
+)
+
+var points = map[string]int{
+	ReasonAuthFail:    45,
+	ReasonAuthWeak:    15,
+	ReasonDisplayName: 40,
+	ReasonLookalike:   40,
+	ReasonReplyTo:     20,
+}
+
+// Auth holds the verdicts of the receiving server, lower-cased ("pass",
+// "fail", "softfail", "neutral", "none", ...). Empty means no verdict.
+type Auth struct {
+	SPF   string
+	DKIM  string
+	DMARC string
+}
+
+var authResult = regexp.MustCompile(`(?i)\b(spf|dkim|dmarc)\s*=\s*([a-z]+)`)
+
+// ParseAuth reads the Authentication-Results and Received-SPF header values.
+// Several DKIM signatures may be reported: one pass is enough. Otherwise the
+// first verdict per method wins, as the topmost header is the one added by
+// the user's own provider.
+func ParseAuth(authResults, receivedSPF []string) Auth {
+	var a Auth
+	for _, v := range authResults {
+		for _, m := range authResult.FindAllStringSubmatch(v, -1) {
+			verdict := strings.ToLower(m[2])
+			switch strings.ToLower(m[1]) {
+			case "spf":
+				if a.SPF == "" {
+					a.SPF = verdict
+				}
+			case "dkim":
+				if a.DKIM == "" || verdict == "pass" {
+					a.DKIM = verdict
+				}
+			case "dmarc":
+				if a.DMARC == "" {
+					a.DMARC = verdict
+				}
+			}
+		}
+	}
+	if a.SPF == "" {
+		for _, v := range receivedSPF {
+			if f := strings.Fields(v); len(f) > 0 {
+				a.SPF = strings.ToLower(f[0])
+				break
+			}
+		}
+	}
+	return a
+}
+
+// Failed reports whether the From domain is disproved: DMARC failed, or SPF
+// failed and no DKIM signature passed.
+func (a Auth) Failed() bool {
+	return a.DMARC == "fail" || (a.SPF == "fail" && a.DKIM != "pass")
+}
+
+// weak reports a doubtful but not failed authentication.
+func (a Auth) weak() bool {
+	return a.DMARC != "pass" && (a.SPF == "softfail" || (a.DKIM == "fail" && a.SPF != "pass"))
+}
+
+// Input is what Assess looks at for one email.
+type Input struct {
+	From    string // raw From header
+	ReplyTo string // raw Reply-To header, if any
+	Auth    Auth
+	// Known are domains the user corresponds with (stored senders, protected
+	// senders, ...). Exact matches are trusted; near matches are suspicious.
+	Known []string
+}
+
+// Result is a risk score with the reasons behind it.
+type Result struct {
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Assess scores one email.
+func Assess(in Input) Result {
+	name, addr := splitFrom(in.From)
+	domain := Domain(addr)
+	reasons := []string{}
+
+	switch {
+	case in.Auth.Failed():
+		reasons = append(reasons, ReasonAuthFail)
+	case in.Auth.weak():
+		reasons = append(reasons, ReasonAuthWeak)
+	}
+	if domain != "" {
+		if DisplayNameSpoof(name, domain, in.Known) {
+			reasons = append(reasons, ReasonDisplayName)
+		}
+		if _, ok := lookalike(domain, in.Known, brandDomains); ok {
+			reasons = append(reasons, ReasonLookalike)
+		}
+		if in.ReplyTo != "" {
+			if _, rt := splitFrom(in.ReplyTo); rt != "" && !sameOrganization(Domain(rt), domain) {
+				reasons = append(reasons, ReasonReplyTo)
+			}
+		}
+	}
+
+	score := 0
+	for _, r := range reasons {
+		score += points[r]
+	}
+	if score > 100 {
+		score = 100
+	}
+	return Result{Score: score, Reasons: reasons}
+}
+
+// Untrusted reports whether reasons (as stored by Assess) disprove the sender
+// address itself. Such an email must not inherit the trust granted to that
+// address, like a protected sender's VIP priority.
+func Untrusted(reasons []string) bool {
+	for _, r := range reasons {
+		if r == ReasonAuthFail {
+			return true
+		}
+	}
+	return false
+}
+
+// brands are names phishing most often borrows, matched in display names.
+var brands = []string{
+	"paypal", "amazon", "apple", "icloud", "microsoft", "outlook", "office 365", "google", "gmail",
+	"netflix", "facebook", "instagram", "linkedin", "whatsapp", "docusign", "dropbox", "wetransfer",
+	"dhl", "fedex", "ups", "chronopost", "colissimo", "la poste", "ameli", "impots", "caf",
+	"crédit agricole", "société générale", "bnp", "lcl", "banque populaire", "boursorama",
+	"orange", "sfr", "bouygues", "sparkasse", "deutsche bank", "commerzbank", "postbank",
+}
+
+// brandDomains are the real domains of often imitated brands, compared
+// against for lookalikes on top of the user's own correspondents. Their names
+// are short and common words, so a typo only counts from brandFuzzyMin
+// letters (see lookalike).
+var brandDomains = []string{
+	"paypal.com", "amazon.com", "amazon.fr", "apple.com", "icloud.com", "microsoft.com", "outlook.com",
+	"google.com", "gmail.com", "netflix.com", "facebook.com", "instagram.com", "linkedin.com",
+	"docusign.com", "dropbox.com", "laposte.fr", "colissimo.fr", "chronopost.fr", "ameli.fr",
+	"boursorama.com", "orange.fr", "sparkasse.de", "postbank.de",
+}
+
+// mailboxProviders host personal addresses: writing to someone@free.fr makes
+// "free" a known domain, not a name whose every mention is suspicious.
+var mailboxProviders = map[string]bool{
+	"gmail": true, "googlemail": true, "yahoo": true, "hotmail": true, "outlook": true, "live": true,
+	"msn": true, "icloud": true, "aol": true, "proton": true, "protonmail": true, "free": true,
+	"orange": true, "wanadoo": true, "laposte": true, "sfr": true, "neuf": true, "gmx": true,
+	"web": true, "mail": true, "t-online": true,
+}
+
+// DisplayNameSpoof reports whether a display name claims an identity its
+// address domain does not back: another email address, a brand, or the name
+// of a known domain ("Acme Support" sent from a domain that is not acme's).
+func DisplayNameSpoof(name, domain string, known []string) bool {
+	n := strings.ToLower(name)
+	if n == "" {
+		return false
+	}
+	if _, inName := splitFrom(strings.Trim(n, `"' `)); strings.Contains(n, "@") && inName != "" {
+		return !sameOrganization(Domain(inName), domain)
+	}
+	org := orgLabel(domain)
+	for _, b := range brands {
+		if containsWord(n, b) && !strings.Contains(org, strings.ReplaceAll(b, " ", "")) {
+			return true
+		}
+	}
+	for _, k := range known {
+		label := orgLabel(k)
+		if mailboxProviders[label] {
+			continue
+		}
+		if len(label) >= 4 && containsWord(n, label) && !sameOrganization(k, domain) && !strings.Contains(org, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// brandFuzzyMin is the shortest brand name a typo is matched against: below
+// it, one edit turns a brand into a real, unrelated domain (cloud.com is one
+// letter from icloud.com), so only homoglyph spellings count.
+const brandFuzzyMin = 8
+
+// Lookalike returns the known domain that domain imitates: same skeleton once
+// homoglyphs are folded, or an organization name within a small edit
+// distance. An exact match (or a subdomain of one) is not a lookalike.
+func Lookalike(domain string, known []string) (string, bool) {
+	return lookalike(domain, known, nil)
+}
+
+// lookalike is Lookalike over the user's known domains and brand domains.
+// Mailbox providers are real domains in their own right: one is never a
+// lookalike (mail.com is not an imitation of gmail.com), and imitating one
+// takes a homoglyph spelling, not a mere typo.
+func lookalike(domain string, known, brands []string) (string, bool) {
+	domain = strings.ToLower(domain)
+	for _, list := range [][]string{known, brands} {
+		for _, k := range list {
+			if sameOrganization(domain, k) {
+				return "", false
+			}
+		}
+	}
+	org := orgLabel(domain)
+	if mailboxProviders[org] {
+		return "", false
+	}
+	skel := skeleton(org)
+	match := func(k string, fuzzyMin int) bool {
+		ko := orgLabel(k)
+		if len(ko) < 4 || ko == org {
+			return false
+		}
+		if skeleton(ko) == skel {
+			return true
+		}
+		if mailboxProviders[ko] || len(ko) < fuzzyMin {
+			return false
+		}
+		limit := 1
+		if len(ko) >= 8 {
+			limit = 2
+		}
+		return Levenshtein(org, ko) <= limit
+	}
+	for _, k := range known {
+		if match(k, 4) {
+			return k, true
+		}
+	}
+	for _, k := range brands {
+		if match(k, brandFuzzyMin) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// homoglyphs fold characters that read alike onto one spelling.
+var homoglyphs = strings.NewReplacer(
+	"rn", "m", "vv", "w", "cl", "d",
+	"0", "o", "1", "l", "i", "l", "3", "e", "5", "s", "@", "a",
+	// Cyrillic and Greek letters drawn like Latin ones.
+	"а", "a", "е", "e", "о", "o", "р", "p", "с", "c", "у", "y", "х", "x", "і", "l", "ј", "j",
+	"ο", "o", "α", "a", "ε", "e", "ρ", "p", "ν", "v",
+)
+
+func skeleton(s string) string {
+	return strings.ReplaceAll(homoglyphs.Replace(strings.ToLower(s)), "-", "")
+}
+
+// Levenshtein is the edit distance between two strings, by rune.
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// splitFrom parses an address header into display name and lower-cased
+// address, falling back to the text inside <...> when it is not RFC 5322.
+func splitFrom(v string) (name, addr string) {
+	if a, err := mail.ParseAddress(v); err == nil {
+		return a.Name, strings.ToLower(a.Address)
+	}
+	if i := strings.LastIndex(v, "<"); i >= 0 {
+		return strings.Trim(strings.TrimSpace(v[:i]), `"`), strings.ToLower(strings.Trim(v[i+1:], "> "))
+	}
+	if strings.Contains(v, "@") {
+		return "", strings.ToLower(strings.TrimSpace(v))
+	}
+	return v, ""
+}
+
+// Domain returns the domain part of an address.
+func Domain(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return strings.ToLower(strings.TrimSpace(addr[i+1:]))
+	}
+	return strings.ToLower(strings.TrimSpace(addr))
+}
+
+// BaseDomain approximates the registrable domain: the last two labels, or
+// three under a short second level such as co.uk or com.au.
+func BaseDomain(domain string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	n := len(labels)
+	if n <= 2 {
+		return strings.Join(labels, ".")
+	}
+	if len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		return strings.Join(labels[n-3:], ".")
+	}
+	return strings.Join(labels[n-2:], ".")
+}
+
+// orgLabel is the organization part of a domain ("mail.paypal.co.uk" →
+// "paypal").
+func orgLabel(domain string) string {
+	base := BaseDomain(domain)
+	if i := strings.Index(base, "."); i >= 0 {
+		return base[:i]
+	}
+	return base
+}
+
+// sameOrganization reports whether two domains share a registrable domain.
+func sameOrganization(a, b string) bool {
+	return a != "" && b != "" && BaseDomain(a) == BaseDomain(b)
+}
+
+// containsWord reports whether needle appears in s delimited by non-letters.
+func containsWord(s, needle string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], needle)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(needle)
+		if (start == 0 || !isLetter(s[start-1])) && (end == len(s) || !isLetter(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 0x80
+}
@@ -0,0 +1,186 @@
+package risk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAuth(t *testing.T) {
+	got := ParseAuth([]string{
+		"mx.google.com; dkim=fail header.i=@x.com; dkim=pass header.i=@esp.net; spf=softfail smtp.mailfrom=x.com; dmarc=FAIL (p=NONE) header.from=x.com",
+		"relay.example; spf=pass",
+	}, nil)
+	want := Auth{SPF: "softfail", DKIM: "pass", DMARC: "fail"}
+	if got != want {
+		t.Errorf("ParseAuth = %+v, want %+v", got, want)
+	}
+
+	// Received-SPF is only a fallback when Authentication-Results has no spf.
+	got = ParseAuth(nil, []string{"Fail (google.com: domain of x@y.com does not designate 1.2.3.4)"})
+	if got.SPF != "fail" {
+		t.Errorf("Received-SPF fallback = %q, want fail", got.SPF)
+	}
+}
+
+func TestAuthVerdicts(t *testing.T) {
+	cases := []struct {
+		a            Auth
+		failed, weak bool
+	}{
+		{Auth{SPF: "pass", DKIM: "pass", DMARC: "pass"}, false, false},
+		{Auth{DMARC: "fail"}, true, false},
+		{Auth{SPF: "fail"}, true, false},
+		{Auth{SPF: "fail", DKIM: "pass"}, false, false}, // forwarded mail keeps its signature
+		{Auth{SPF: "softfail"}, false, true},
+		{Auth{SPF: "softfail", DMARC: "pass"}, false, false},
+		{Auth{}, false, false}, // no verdict is not evidence
+	}
+	for _, tc := range cases {
+		if got := tc.a.Failed(); got != tc.failed {
+			t.Errorf("%+v Failed() = %v, want %v", tc.a, got, tc.failed)
+		}
+		if got := tc.a.weak(); got != tc.weak {
+			t.Errorf("%+v weak() = %v, want %v", tc.a, got, tc.weak)
+		}
+	}
+}
+
+func TestDisplayNameSpoof(t *testing.T) {
+	known := []string{"acme-corp.com", "free.fr"}
+	cases := []struct {
+		name, domain string
+		want         bool
+	}{
+		{"PayPal Service", "secure-notify.xyz", true},
+		{"PayPal", "paypal.com", false},
+		{"PayPal", "mail.paypal.co.uk", false},
+		{"La Poste — suivi", "colis-suivi.info", true},
+		{"billing@acme-corp.com", "evil.net", true},
+		{"billing@acme-corp.com", "acme-corp.com", false},
+		{"ACME-CORP Support", "helpdesk.ru", true},
+		{"Jean Dupont", "gmail.com", false},
+		{"Upsilon Club", "upsilon.org", false},        // "ups" only as a whole word
+		{"Free shipping club", "shop.example", false}, // free.fr is a mailbox provider
+		{"", "whatever.com", false},
+	}
+	for _, tc := range cases {
+		if got := DisplayNameSpoof(tc.name, tc.domain, known); got != tc.want {
+			t.Errorf("DisplayNameSpoof(%q, %q) = %v, want %v", tc.name, tc.domain, got, tc.want)
+		}
+	}
+}
+
+func TestLookalike(t *testing.T) {
+	known := []string{"paypal.com", "mybank.fr", "example-industries.com"}
+	cases := []struct {
+		domain, want string
+		ok           bool
+	}{
+		{"paypa1.com", "paypal.com", true},
+		{"paypal.net", "", false}, // same name under another TLD is left alone
+		{"rnybank.fr", "mybank.fr", true},
+		{"mybanq.fr", "mybank.fr", true},   // one typo away
+		{"pаypal.com", "paypal.com", true}, // Cyrillic а
+		{"exarnple-industries.com", "example-industries.com", true},
+		{"paypal.com", "", false},
+		{"mail.paypal.com", "", false},
+		{"unrelated.org", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := Lookalike(tc.domain, known)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("Lookalike(%q) = %q, %v; want %q, %v", tc.domain, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// Real providers and ordinary domains one letter away from a short brand are
+// not lookalikes; homoglyph spellings of those brands still are.
+func TestLookalikeBrands(t *testing.T) {
+	cases := []struct {
+		domain string
+		ok     bool
+	}{
+		{"mail.com", false},
+		{"email.fr", false},
+		{"cloud.com", false},
+		{"gmx.de", false},
+		{"gmai1.com", true},
+		{"paypa1.com", true},
+		{"icl0ud.com", true},
+		{"rnicrosoft.com", true},
+		{"microsotf.com", true}, // long brand: a typo still counts
+	}
+	for _, tc := range cases {
+		if k, ok := lookalike(tc.domain, []string{"gmail.com"}, brandDomains); ok != tc.ok {
+			t.Errorf("lookalike(%q) = %q, %v; want %v", tc.domain, k, ok, tc.ok)
+		}
+	}
+
+	r := Assess(Input{From: "Jean Orange <jean@mail.com>", Auth: Auth{SPF: "pass", DKIM: "pass", DMARC: "pass"}})
+	for _, reason := range r.Reasons {
+		if reason == ReasonLookalike {
+			t.Errorf("mail.com flagged as a lookalike: %v", r.Reasons)
+		}
+	}
+	if r.Score >= High {
+		t.Errorf("Jean Orange <jean@mail.com> scored %d, want below High", r.Score)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	pass := Auth{SPF: "pass", DKIM: "pass", DMARC: "pass"}
+	cases := []struct {
+		name string
+		in   Input
+		want []string
+	}{
+		{"clean", Input{From: "Alice <alice@acme.com>", Auth: pass, Known: []string{"acme.com"}}, []string{}},
+		{"auth fail", Input{From: "ceo@acme.com", Auth: Auth{DMARC: "fail"}}, []string{ReasonAuthFail}},
+		{"reply-to elsewhere", Input{From: "a@acme.com", ReplyTo: "<collect@other.biz>", Auth: pass}, []string{ReasonReplyTo}},
+		{"reply-to same org", Input{From: "a@acme.com", ReplyTo: "support@help.acme.com", Auth: pass}, []string{}},
+		{"classic phish", Input{
+			From:    `"PayPal" <service@paypa1.com>`,
+			ReplyTo: "x@collect.ru",
+			Auth:    Auth{SPF: "softfail"},
+		}, []string{ReasonAuthWeak, ReasonDisplayName, ReasonLookalike, ReasonReplyTo}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Assess(tc.in)
+			if !reflect.DeepEqual(got.Reasons, tc.want) {
+				t.Errorf("reasons = %v, want %v", got.Reasons, tc.want)
+			}
+		})
+	}
+
+	if r := Assess(Input{From: `"PayPal" <service@paypa1.com>`, ReplyTo: "x@collect.ru", Auth: Auth{DMARC: "fail"}}); r.Score != 100 {
+		t.Errorf("score = %d, want clamped 100", r.Score)
+	}
+	if r := Assess(Input{From: "ceo@acme.com", Auth: Auth{DMARC: "fail"}}); r.Score >= High {
+		t.Errorf("auth failure alone scored %d, want below High", r.Score)
+	}
+}
+
+func TestUntrusted(t *testing.T) {
+	if !Untrusted([]string{ReasonReplyTo, ReasonAuthFail}) {
+		t.Error("auth-fail should be untrusted")
+	}
+	if Untrusted([]string{ReasonLookalike, ReasonDisplayName}) {
+		t.Error("impersonation alone does not disprove the address")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0}, {"abc", "", 3}, {"kitten", "sitting", 3}, {"paypal", "paypa1", 1}, {"café", "cafe", 1},
+	}
+	for _, tc := range cases {
+		if got := Levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
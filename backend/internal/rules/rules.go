@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nohe-sohbi/mailsorter/backend/internal/labelpath"
 	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
 )
 
@@ -25,6 +26,7 @@ const (
 	FieldSnippet = "snippet"
 	FieldTo      = "to"
 	FieldBody    = "body"
+	FieldRisk    = "risk" // the 0–100 phishing/spoofing score computed at sync
 )
 
 // Supported operators.
@@ -38,6 +40,8 @@ const (
 	OpNotEquals   = "notEquals"
 	OpOlderThan   = "olderThan" // value = age in days; matches mail received before now-N days
 	OpNewerThan   = "newerThan" // value = age in days; matches mail received within the last N days
+	OpAtLeast     = "atLeast"   // value = score; matches a risk score of at least N
+	OpBelow       = "below"     // value = score; matches a risk score strictly below N
 )
 
 // textOperators compare a string field; temporalOperators compare ReceivedDate
@@ -47,6 +51,12 @@ var temporalOperators = map[string]bool{
 	OpOlderThan: true, OpNewerThan: true,
 }
 
+// scoreOperators compare a numeric field (risk) against a 0–100 value; they
+// are the only operators that field accepts.
+var scoreOperators = map[string]bool{
+	OpAtLeast: true, OpBelow: true,
+}
+
 // Supported actions.
 const (
 	ActionArchive  = "archive"
@@ -58,11 +68,13 @@ const (
 
 var validFields = map[string]bool{
 	FieldFrom: true, FieldSubject: true, FieldSnippet: true, FieldTo: true, FieldBody: true,
+	FieldRisk: true,
 }
 
 var validOperators = map[string]bool{
 	OpContains: true, OpEquals: true, OpStartsWith: true, OpEndsWith: true, OpRegex: true,
 	OpNotContains: true, OpNotEquals: true, OpOlderThan: true, OpNewerThan: true,
+	OpAtLeast: true, OpBelow: true,
 }
 
 var validActions = map[string]bool{
@@ -134,6 +146,9 @@ func matchConditionAt(email models.Email, c models.RuleCondition, now time.Time)
 	if temporalOperators[c.Operator] {
 		return matchTemporal(email, c, now)
 	}
+	if strings.ToLower(c.Field) == FieldRisk {
+		return matchScore(email.Risk, c)
+	}
 	actual := fieldValue(email, c.Field)
 	switch c.Operator {
 	case OpContains:
@@ -182,6 +197,23 @@ func matchTemporal(email models.Email, c models.RuleCondition, now time.Time) bo
 	}
 }
 
+// matchScore evaluates a numeric condition against score. A malformed value,
+// or a text operator on a numeric field, never matches.
+func matchScore(score int, c models.RuleCondition) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(c.Value))
+	if err != nil {
+		return false
+	}
+	switch c.Operator {
+	case OpAtLeast:
+		return score >= n
+	case OpBelow:
+		return score < n
+	default:
+		return false
+	}
+}
+
 // Matches reports whether the rule applies to the email, resolving temporal
 // conditions against the current time.
 func Matches(email models.Email, rule models.SortingRule) bool {
@@ -318,8 +350,13 @@ func Validate(rule models.SortingRule) error {
 		if !validActions[a.Type] {
 			return fmt.Errorf("action invalide : %q", a.Type)
 		}
-		if a.Type == ActionLabel && strings.TrimSpace(a.LabelName) == "" {
-			return fmt.Errorf("un libellé est requis pour l'action \"label\"")
+		if a.Type == ActionLabel {
+			if strings.TrimSpace(a.LabelName) == "" {
+				return fmt.Errorf("un libellé est requis pour l'action \"label\"")
+			}
+			if err := labelpath.Validate(labelpath.Clean(a.LabelName)); err != nil {
+				return fmt.Errorf("libellé invalide %q : %v", a.LabelName, err)
+			}
 		}
 	}
 	if len(rule.Conditions) == 0 {
@@ -340,6 +377,17 @@ func Validate(rule models.SortingRule) error {
 				return fmt.Errorf("condition %d : expression régulière invalide : %v", i+1, err)
 			}
 		}
+		if isRisk := strings.ToLower(c.Field) == FieldRisk; isRisk != scoreOperators[c.Operator] {
+			if isRisk {
+				return fmt.Errorf("condition %d : le champ \"risk\" n'accepte que atLeast ou below", i+1)
+			}
+			return fmt.Errorf("condition %d : l'opérateur %q ne s'applique qu'au champ \"risk\"", i+1, c.Operator)
+		}
+		if scoreOperators[c.Operator] {
+			if n, err := strconv.Atoi(strings.TrimSpace(c.Value)); err != nil || n < 0 || n > 100 {
+				return fmt.Errorf("condition %d : un score entre 0 et 100 est requis pour cet opérateur", i+1)
+			}
+		}
 		if temporalOperators[c.Operator] {
 			if n, err := strconv.Atoi(strings.TrimSpace(c.Value)); err != nil || n < 0 {
 				return fmt.Errorf("condition %d : un nombre de jours (≥ 0) est requis pour cet opérateur", i+1)
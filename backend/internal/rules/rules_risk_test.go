@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/models"
+)
+
+func TestRiskConditions(t *testing.T) {
+	risky := models.Email{From: "PayPal <service@paypa1-secure.com>", Risk: 85}
+	clean := models.Email{From: "news@acme.com", Risk: 0}
+
+	cases := []struct {
+		name  string
+		email models.Email
+		c     models.RuleCondition
+		want  bool
+	}{
+		{"atLeast hit", risky, cond(FieldRisk, OpAtLeast, "60"), true},
+		{"atLeast is inclusive", risky, cond(FieldRisk, OpAtLeast, "85"), true},
+		{"atLeast miss", clean, cond(FieldRisk, OpAtLeast, "60"), false},
+		{"below hit", clean, cond(FieldRisk, OpBelow, "20"), true},
+		{"below is strict", risky, cond(FieldRisk, OpBelow, "85"), false},
+		{"malformed score never matches", risky, cond(FieldRisk, OpAtLeast, "high"), false},
+		{"text operator on risk never matches", risky, cond(FieldRisk, OpContains, "8"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchCondition(tc.email, tc.c); got != tc.want {
+				t.Errorf("matchCondition(%+v) = %v, want %v", tc.c, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRisk(t *testing.T) {
+	good := []models.SortingRule{
+		{Name: "phishing", Action: ActionLabel, LabelName: "Suspect", Conditions: []models.RuleCondition{cond(FieldRisk, OpAtLeast, "60")}},
+		{Name: "safe", Action: ActionStar, Conditions: []models.RuleCondition{cond(FieldRisk, OpBelow, "100")}},
+	}
+	for i, r := range good {
+		if err := Validate(r); err != nil {
+			t.Errorf("good case %d should validate, got %v", i, err)
+		}
+	}
+
+	bad := []models.SortingRule{
+		{Name: "range", Action: ActionArchive, Conditions: []models.RuleCondition{cond(FieldRisk, OpAtLeast, "150")}},
+		{Name: "nan", Action: ActionArchive, Conditions: []models.RuleCondition{cond(FieldRisk, OpBelow, "élevé")}},
+		{Name: "text-op", Action: ActionArchive, Conditions: []models.RuleCondition{cond(FieldRisk, OpContains, "60")}},
+		{Name: "score-op", Action: ActionArchive, Conditions: []models.RuleCondition{cond(FieldSubject, OpAtLeast, "60")}},
+	}
+	for i, r := range bad {
+		if err := Validate(r); err == nil {
+			t.Errorf("bad risk case %d should fail validation", i)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default working day, used when a user has not defined theirs.
+const (
+	DefaultDayStart = 8
+	DefaultDayEnd   = 18
+)
+
+// weekdayNames maps lower-case English weekday names onto their day.
+var weekdayNames = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sunday": time.Sunday,
+}
+
+// ParseWeekday resolves a lower-case English weekday name ("monday").
+func ParseWeekday(name string) (time.Weekday, bool) {
+	d, ok := weekdayNames[name]
+	return d, ok
+}
+
+// Calendar is a user's wall clock: the timezone their mornings and evenings
+// live in, their working hours and which days are their weekend. The zero
+// Calendar keeps each time in its own location with an 08:00–18:00 day and a
+// Saturday–Sunday weekend, which is what the server did before users could
+// choose.
+type Calendar struct {
+	Location *time.Location // nil: leave times in their own location
+	DayStart int            // first working hour; DayEnd == 0 means the default day
+	DayEnd   int            // hour the working day ends
+	Weekend  []time.Weekday // nil: Saturday and Sunday
+}
+
+// NewCalendar builds a Calendar from stored user settings: an IANA timezone
+// ("" = UTC), working hours (end == 0 = default) and weekend day names (none =
+// Saturday and Sunday).
+func NewCalendar(tz string, dayStart, dayEnd int, weekend []string) (Calendar, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return Calendar{}, fmt.Errorf("unknown timezone %q", tz)
+	}
+	c := Calendar{Location: loc, DayStart: dayStart, DayEnd: dayEnd}
+	if dayEnd != 0 && (dayStart < 0 || dayStart >= dayEnd || dayEnd > 23) {
+		return Calendar{}, fmt.Errorf("invalid working hours %d–%d", dayStart, dayEnd)
+	}
+	for _, name := range weekend {
+		d, ok := ParseWeekday(strings.ToLower(strings.TrimSpace(name)))
+		if !ok {
+			return Calendar{}, fmt.Errorf("unknown weekday %q", name)
+		}
+		c.Weekend = append(c.Weekend, d)
+	}
+	if len(c.Weekend) == 7 {
+		return Calendar{}, fmt.Errorf("the weekend cannot be the whole week")
+	}
+	return c, nil
+}
+
+// In returns t on the calendar's wall clock.
+func (c Calendar) In(t time.Time) time.Time {
+	if c.Location == nil {
+		return t
+	}
+	return t.In(c.Location)
+}
+
+// Hours returns the working day's first and end hours.
+func (c Calendar) Hours() (start, end int) {
+	if c.DayEnd == 0 {
+		return DefaultDayStart, DefaultDayEnd
+	}
+	return c.DayStart, c.DayEnd
+}
+
+// IsWeekend reports whether d is a day off.
+func (c Calendar) IsWeekend(d time.Weekday) bool {
+	if c.Weekend == nil {
+		return d == time.Saturday || d == time.Sunday
+	}
+	for _, w := range c.Weekend {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
+
+// StartOfDay returns midnight of t's calendar day on the calendar's wall
+// clock — not t minus 24h, which is off by an hour across a DST change.
+func (c Calendar) StartOfDay(t time.Time) time.Time {
+	t = c.In(t)
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -38,3 +38,59 @@ func TestDueNonPositiveInterval(t *testing.T) {
 		t.Fatal("a negative interval should always be due")
 	}
 }
+
+func TestNewCalendar(t *testing.T) {
+	cal, err := NewCalendar("Europe/Paris", 9, 17, []string{"Friday", "saturday"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := cal.Hours(); start != 9 || end != 17 {
+		t.Errorf("Hours = %d–%d, want 9–17", start, end)
+	}
+	if !cal.IsWeekend(time.Friday) || cal.IsWeekend(time.Sunday) {
+		t.Error("weekend should be Friday and Saturday")
+	}
+
+	def, err := NewCalendar("", 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := def.Hours(); start != DefaultDayStart || end != DefaultDayEnd {
+		t.Errorf("default Hours = %d–%d", start, end)
+	}
+	if !def.IsWeekend(time.Saturday) || !def.IsWeekend(time.Sunday) || def.IsWeekend(time.Monday) {
+		t.Error("default weekend should be Saturday and Sunday")
+	}
+
+	for _, bad := range []struct {
+		tz         string
+		start, end int
+		weekend    []string
+	}{
+		{"Mars/Olympus", 0, 0, nil},
+		{"UTC", 18, 8, nil},
+		{"UTC", 8, 24, nil},
+		{"UTC", 0, 0, []string{"samedi"}},
+		{"UTC", 0, 0, []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}},
+	} {
+		if _, err := NewCalendar(bad.tz, bad.start, bad.end, bad.weekend); err == nil {
+			t.Errorf("NewCalendar(%q, %d, %d, %v) should fail", bad.tz, bad.start, bad.end, bad.weekend)
+		}
+	}
+}
+
+func TestStartOfDayAcrossDST(t *testing.T) {
+	cal, err := NewCalendar("Europe/Paris", 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 29 March 2026 is 23h long in Paris; noon minus 12h is 01:00, not midnight.
+	noon := time.Date(2026, 3, 29, 12, 0, 0, 0, cal.Location)
+	got := cal.StartOfDay(noon)
+	if want := time.Date(2026, 3, 29, 0, 0, 0, 0, cal.Location); !got.Equal(want) {
+		t.Errorf("StartOfDay = %v, want %v", got, want)
+	}
+	if noon.Sub(got) != 11*time.Hour {
+		t.Errorf("a spring-forward morning lasts 11h, got %v", noon.Sub(got))
+	}
+}
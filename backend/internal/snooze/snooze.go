@@ -11,72 +11,186 @@ import (
 	"fmt"
 	"strings"
 	"time"
-)
 
-// Presets understood by Resolve.
-const (
-	PresetLaterToday  = "laterToday"  // +3h, but at least 18:00 if that is later
-	PresetThisEvening = "thisEvening" // today at 18:00 (or tomorrow if already past)
-	PresetTomorrow    = "tomorrow"    // tomorrow at 08:00
-	PresetThisWeekend = "weekend"     // the coming Saturday at 08:00
-	PresetNextWeek    = "nextWeek"    // the coming Monday at 08:00
+	"github.com/nohe-sohbi/mailsorter/backend/internal/schedule"
 )
 
-// morningHour and eveningHour anchor the day-grained presets.
+// Presets understood by Resolve. Morning and evening are the start and end of
+// the user's working day (08:00 and 18:00 by default), and the weekend is
+// theirs (Saturday and Sunday by default).
 const (
-	morningHour = 8
-	eveningHour = 18
+	PresetLaterToday  = "laterToday"  // +3h, but at least the evening if that is later
+	PresetThisEvening = "thisEvening" // today in the evening (or tomorrow if already past)
+	PresetTomorrow    = "tomorrow"    // tomorrow morning
+	PresetThisWeekend = "weekend"     // the morning of the coming weekend's first day
+	PresetNextWeek    = "nextWeek"    // the morning of the first working day after it
 )
 
-// Resolve turns a preset into an absolute wake time, computed in now's location
-// so it lines up with the user's wall clock. It always returns a time strictly
-// in the future relative to now. Unknown presets yield an error so callers can
-// fall back to an explicit timestamp.
+// morningHour anchors deadline reminders, which are not tied to a user.
+const morningHour = schedule.DefaultDayStart
+
+// Resolve turns a preset into an absolute wake time with the default calendar,
+// in now's location. See ResolveIn.
 func Resolve(preset string, now time.Time) (time.Time, error) {
+	return ResolveIn(preset, now, schedule.Calendar{})
+}
+
+// ResolveIn turns a preset into an absolute wake time on the user's calendar,
+// so "tomorrow" means tomorrow morning where they live. It always returns a
+// time strictly in the future relative to now. Unknown presets yield an error
+// so callers can fall back to an explicit timestamp.
+func ResolveIn(preset string, now time.Time, cal schedule.Calendar) (time.Time, error) {
+	now = cal.In(now)
+	morning, evening := cal.Hours()
 	switch strings.TrimSpace(preset) {
 	case PresetLaterToday:
 		t := now.Add(3 * time.Hour)
-		evening := atHour(now, eveningHour)
-		if evening.After(t) {
-			t = evening
+		if e := atHour(now, evening); e.After(t) {
+			t = e
 		}
 		return t, nil
 
 	case PresetThisEvening:
-		t := atHour(now, eveningHour)
+		t := atHour(now, evening)
 		if !t.After(now) {
-			t = atHour(now.AddDate(0, 0, 1), eveningHour)
+			t = atHour(now.AddDate(0, 0, 1), evening)
 		}
 		return t, nil
 
 	case PresetTomorrow:
-		return atHour(now.AddDate(0, 0, 1), morningHour), nil
+		return atHour(now.AddDate(0, 0, 1), morning), nil
 
 	case PresetThisWeekend:
-		return nextWeekday(now, time.Saturday, morningHour), nil
+		return nextDay(now, morning, func(d time.Weekday) bool {
+			return cal.IsWeekend(d) && !cal.IsWeekend(previous(d))
+		}), nil
 
 	case PresetNextWeek:
-		return nextWeekday(now, time.Monday, morningHour), nil
+		return nextDay(now, morning, func(d time.Weekday) bool {
+			return !cal.IsWeekend(d) && cal.IsWeekend(previous(d))
+		}), nil
 
 	default:
 		return time.Time{}, fmt.Errorf("preset de report inconnu : %q", preset)
 	}
 }
 
+// previous returns the weekday before d, wrapping Sunday round to Saturday.
+func previous(d time.Weekday) time.Weekday {
+	return (d + 6) % 7
+}
+
 // atHour returns the given calendar day at hour:00:00 in the day's location.
+// An hour skipped by a DST change lands on the next valid instant.
 func atHour(day time.Time, hour int) time.Time {
 	y, m, d := day.Date()
 	return time.Date(y, m, d, hour, 0, 0, 0, day.Location())
 }
 
-// nextWeekday returns the next occurrence of weekday at hour:00, strictly after
-// now. If today is that weekday but the hour has passed (or it is the same
-// instant), it rolls forward a full week so the result is always in the future.
-func nextWeekday(now time.Time, weekday time.Weekday, hour int) time.Time {
-	delta := (int(weekday) - int(now.Weekday()) + 7) % 7
-	candidate := atHour(now.AddDate(0, 0, delta), hour)
-	if !candidate.After(now) {
-		candidate = candidate.AddDate(0, 0, 7)
+// nextDay returns hour:00 on the first day, starting today, that matches and
+// is strictly after now. If today matches but the hour has passed (or it is
+// the same instant), it keeps looking so the result is always in the future.
+// The week always holds a working day and a weekend day, so a search over two
+// weeks never comes back empty for the predicates Resolve uses.
+func nextDay(now time.Time, hour int, match func(time.Weekday) bool) time.Time {
+	for i := 0; i < 14; i++ {
+		t := atHour(now.AddDate(0, 0, i), hour)
+		if match(t.Weekday()) && t.After(now) {
+			return t
+		}
+	}
+	return atHour(now.AddDate(0, 0, 7), hour)
+}
+
+// DayBefore returns when an email tied to deadline should resurface: the
+// morning of the day before it is due, in deadline's location. ok is false when
+// that moment is not in the future relative to now — the reminder would be
+// pointless (or the deadline already passed) and no snooze should be created.
+func DayBefore(deadline, now time.Time) (time.Time, bool) {
+	t := atHour(deadline.AddDate(0, 0, -1), morningHour)
+	if !t.After(now) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Repeat values for a recurring snooze: every morning, every working-day
+// morning, or — as a lower-case English weekday name ("monday") — once a week
+// on that day. A recurring snooze brings its email back at each occurrence
+// until it is stopped or its end date passes.
+const (
+	RepeatDaily    = "daily"
+	RepeatWeekdays = "weekdays"
+)
+
+// ValidRepeat reports whether repeat is a supported recurrence ("" = none).
+func ValidRepeat(repeat string) bool {
+	_, weekly := schedule.ParseWeekday(repeat)
+	return repeat == "" || repeat == RepeatDaily || repeat == RepeatWeekdays || weekly
+}
+
+// Next returns the first occurrence of repeat strictly after now, at the
+// morning of the user's calendar; "weekdays" skips their weekend.
+func Next(repeat string, now time.Time, cal schedule.Calendar) (time.Time, error) {
+	now = cal.In(now)
+	morning, _ := cal.Hours()
+	switch repeat {
+	case RepeatDaily:
+		return nextDay(now, morning, func(time.Weekday) bool { return true }), nil
+	case RepeatWeekdays:
+		return nextDay(now, morning, func(d time.Weekday) bool { return !cal.IsWeekend(d) }), nil
+	}
+	if day, ok := schedule.ParseWeekday(repeat); ok {
+		return nextDay(now, morning, func(d time.Weekday) bool { return d == day }), nil
+	}
+	return time.Time{}, fmt.Errorf("récurrence inconnue : %q", repeat)
+}
+
+// AfterWake returns when a snooze that just woke at now should wake again:
+// the next occurrence of repeat, as long as it falls before until (zero = no
+// end). again is false for a one-off snooze or once the recurrence is over.
+func AfterWake(repeat string, until, now time.Time, cal schedule.Calendar) (next time.Time, again bool) {
+	if repeat == "" {
+		return time.Time{}, false
+	}
+	next, err := Next(repeat, now, cal)
+	if err != nil || (!until.IsZero() && next.After(until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Wake conditions tie a snooze to its thread. With WakeOnReply the email
+// comes back early as soon as someone replies in the thread (and at WakeAt
+// otherwise); with WakeIfNoReply — a follow-up reminder — it only comes back
+// at WakeAt if nobody has replied by then.
+const (
+	WakeOnReply   = "reply"
+	WakeIfNoReply = "noReply"
+)
+
+// ValidCondition reports whether condition is a supported wake condition
+// ("" = time only).
+func ValidCondition(condition string) bool {
+	return condition == "" || condition == WakeOnReply || condition == WakeIfNoReply
+}
+
+// ReplyOutcome is what a reply in the thread does to a conditional snooze.
+type ReplyOutcome int
+
+const (
+	ReplyIgnored  ReplyOutcome = iota // no condition: the snooze runs to its wake time
+	ReplyWakes                        // bring the email back now
+	ReplyResolves                     // the follow-up got its answer: end the snooze without waking
+)
+
+// OnReply returns what a reply in the thread does to a snooze with condition.
+func OnReply(condition string) ReplyOutcome {
+	switch condition {
+	case WakeOnReply:
+		return ReplyWakes
+	case WakeIfNoReply:
+		return ReplyResolves
 	}
-	return candidate
+	return ReplyIgnored
 }
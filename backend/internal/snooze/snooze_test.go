@@ -3,6 +3,8 @@ package snooze
 import (
 	"testing"
 	"time"
+
+	"github.com/nohe-sohbi/mailsorter/backend/internal/schedule"
 )
 
 // ref is a fixed reference instant: Wednesday 2026-06-17, 10:00 local UTC.
@@ -82,3 +84,179 @@ func TestResolveUnknownPreset(t *testing.T) {
 		t.Error("expected error for unknown preset")
 	}
 }
+
+func TestDayBefore(t *testing.T) {
+	now := ref() // Wednesday 2026-06-17 10:00
+
+	// Due Friday -> back Thursday morning.
+	got, ok := DayBefore(time.Date(2026, 6, 19, 0, 0, 0, 0, time.UTC), now)
+	if !ok || !got.Equal(time.Date(2026, 6, 18, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("DayBefore(Fri) = %v, %v", got, ok)
+	}
+
+	// Due tomorrow -> the day before is today at 08:00, already past.
+	if _, ok := DayBefore(time.Date(2026, 6, 18, 17, 0, 0, 0, time.UTC), now); ok {
+		t.Fatal("a reminder in the past must be refused")
+	}
+
+	// Past deadline.
+	if _, ok := DayBefore(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), now); ok {
+		t.Fatal("a past deadline must be refused")
+	}
+}
+
+func TestNext(t *testing.T) {
+	now := ref() // Wednesday 10:00
+	friday := time.Date(2026, 6, 19, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		repeat string
+		now    time.Time
+		want   time.Time
+	}{
+		{RepeatDaily, now, time.Date(2026, 6, 18, 8, 0, 0, 0, time.UTC)},
+		{RepeatDaily, time.Date(2026, 6, 17, 7, 0, 0, 0, time.UTC), time.Date(2026, 6, 17, 8, 0, 0, 0, time.UTC)},
+		{RepeatWeekdays, now, time.Date(2026, 6, 18, 8, 0, 0, 0, time.UTC)},
+		{RepeatWeekdays, friday, time.Date(2026, 6, 22, 8, 0, 0, 0, time.UTC)}, // skips the weekend
+		{"monday", now, time.Date(2026, 6, 22, 8, 0, 0, 0, time.UTC)},
+		{"wednesday", now, time.Date(2026, 6, 24, 8, 0, 0, 0, time.UTC)}, // today's 08:00 has passed
+	}
+	for _, tc := range cases {
+		got, err := Next(tc.repeat, tc.now, schedule.Calendar{})
+		if err != nil {
+			t.Fatalf("Next(%q) unexpected error: %v", tc.repeat, err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("Next(%q, %v) = %v, want %v", tc.repeat, tc.now, got, tc.want)
+		}
+	}
+	if _, err := Next("fortnightly", now, schedule.Calendar{}); err == nil {
+		t.Error("unknown recurrence must be rejected")
+	}
+}
+
+func TestValidRepeatAndCondition(t *testing.T) {
+	for _, r := range []string{"", RepeatDaily, RepeatWeekdays, "monday", "sunday"} {
+		if !ValidRepeat(r) {
+			t.Errorf("ValidRepeat(%q) = false", r)
+		}
+	}
+	for _, r := range []string{"Monday", "weekly", "lundi"} {
+		if ValidRepeat(r) {
+			t.Errorf("ValidRepeat(%q) = true", r)
+		}
+	}
+	if !ValidCondition("") || !ValidCondition(WakeOnReply) || !ValidCondition(WakeIfNoReply) || ValidCondition("read") {
+		t.Error("ValidCondition accepts exactly \"\", reply and noReply")
+	}
+}
+
+func TestAfterWake(t *testing.T) {
+	now := time.Date(2026, 6, 22, 8, 0, 0, 0, time.UTC) // Monday 08:00, just woke
+
+	if _, again := AfterWake("", time.Time{}, now, schedule.Calendar{}); again {
+		t.Error("a one-off snooze must not wake again")
+	}
+	next, again := AfterWake("monday", time.Time{}, now, schedule.Calendar{})
+	if !again || !next.Equal(time.Date(2026, 6, 29, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly snooze should come back next Monday, got %v (again=%v)", next, again)
+	}
+	if _, again := AfterWake("monday", time.Date(2026, 6, 28, 0, 0, 0, 0, time.UTC), now, schedule.Calendar{}); again {
+		t.Error("recurrence must stop once the next occurrence is past its end date")
+	}
+}
+
+func TestOnReply(t *testing.T) {
+	if OnReply(WakeOnReply) != ReplyWakes || OnReply(WakeIfNoReply) != ReplyResolves || OnReply("") != ReplyIgnored {
+		t.Error("OnReply: reply wakes, noReply resolves, no condition ignores")
+	}
+}
+
+func montreal(t *testing.T) schedule.Calendar {
+	t.Helper()
+	cal, err := schedule.NewCalendar("America/Montreal", 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+func TestResolveInUserTimezone(t *testing.T) {
+	cal := montreal(t)
+	loc := cal.Location
+	// 01:00 UTC on Thursday is still Wednesday 21:00 in Montréal.
+	now := time.Date(2026, 6, 18, 1, 0, 0, 0, time.UTC)
+
+	got, err := ResolveIn(PresetTomorrow, now, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 6, 18, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("tomorrow = %v, want %v (Thursday 08:00 local)", got, want)
+	}
+}
+
+func TestResolveInAcrossDST(t *testing.T) {
+	cal := montreal(t)
+	loc := cal.Location
+	// Saturday 7 March 2026, 20:00 EST; clocks spring forward on Sunday 8 March.
+	now := time.Date(2026, 3, 7, 20, 0, 0, 0, loc)
+
+	got, err := ResolveIn(PresetTomorrow, now, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 8, 8, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("tomorrow across DST = %v, want %v", got, want)
+	}
+	if got.Sub(now) != 11*time.Hour {
+		t.Errorf("the night is an hour short across DST: got %v, want 11h", got.Sub(now))
+	}
+
+	// Autumn: 1 November 2026 falls back; a daily recurrence stays at 08:00 local.
+	woke := time.Date(2026, 10, 31, 8, 0, 0, 0, loc)
+	next, again := AfterWake(RepeatDaily, time.Time{}, woke, cal)
+	if !again || next.Hour() != 8 || next.Day() != 1 {
+		t.Errorf("daily snooze after fall back = %v, want 1 Nov 08:00 local", next)
+	}
+	if next.Sub(woke) != 25*time.Hour {
+		t.Errorf("fall-back day lasts 25h, got %v", next.Sub(woke))
+	}
+}
+
+func TestResolveInCustomWorkWeek(t *testing.T) {
+	// Working 07:00–16:00 Sunday to Thursday, weekend on Friday and Saturday.
+	cal, err := schedule.NewCalendar("Asia/Jerusalem", 7, 16, []string{"friday", "saturday"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := cal.Location
+	now := time.Date(2026, 6, 17, 10, 0, 0, 0, loc) // Wednesday
+
+	cases := []struct {
+		preset string
+		want   time.Time
+	}{
+		{PresetThisEvening, time.Date(2026, 6, 17, 16, 0, 0, 0, loc)},
+		{PresetTomorrow, time.Date(2026, 6, 18, 7, 0, 0, 0, loc)},
+		{PresetThisWeekend, time.Date(2026, 6, 19, 7, 0, 0, 0, loc)}, // Friday
+		{PresetNextWeek, time.Date(2026, 6, 21, 7, 0, 0, 0, loc)},    // Sunday
+	}
+	for _, tc := range cases {
+		got, err := ResolveIn(tc.preset, now, cal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ResolveIn(%q) = %v, want %v", tc.preset, got, tc.want)
+		}
+	}
+
+	// Thursday after work: "weekdays" skips Friday and Saturday.
+	next, err := Next(RepeatWeekdays, time.Date(2026, 6, 18, 17, 0, 0, 0, loc), cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 6, 21, 7, 0, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("weekdays after Thursday = %v, want %v", next, want)
+	}
+}